@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"github.com/nathaliaguayos/msg-receiver/config"
 	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
 	"github.com/nathaliaguayos/msg-receiver/internal/rest"
 	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/spool"
 	"github.com/nathaliaguayos/msg-receiver/pkg/logger"
 	"net/http"
 	"os"
@@ -24,10 +28,251 @@ func main() {
 	log := logger.New(cfg)
 	log.Info().Msg("msg-receiver loading")
 
-	jwtService := services.NewJWTService(cfg.SecretKey, cfg.Issuer)
-	jwtHandler := handlers.NewJWTHandler(jwtService)
+	signingKey, err := newSigningKey(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading signing key")
+	}
+	keyring, err := services.NewKeyring(signingKey, services.KeyringConfig{
+		Overlap: cfg.KeyOverlap,
+		Dir:     cfg.KeyringDir,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading keyring")
+	}
+	log.Info().Str("kid", keyring.SigningKey().ID).Str("alg", keyring.SigningKey().Method.Alg()).Msg("configured signing key")
+
+	// appCtx stops background workers on shutdown
+	appCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if cfg.KeyRotationInterval > 0 {
+		if cfg.KeyringDir == "" {
+			// replicas would sign with keys the others cannot verify
+			log.Fatal().Msg("key rotation needs a keyring dir shared by every replica")
+		}
+		go services.RunKeyRotation(appCtx, keyring, cfg.KeyRotationInterval, func(err error) {
+			log.Error().Err(err).Msg("error rotating signing key")
+		})
+	}
+	revocations, err := newRevocationStore(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading revocation store")
+	}
+	if cfg.RevocationFile != "" && cfg.RevocationReloadInterval > 0 {
+		go services.RunRevocationReload(appCtx, revocations, cfg.RevocationReloadInterval, func(err error) {
+			log.Error().Err(err).Msg("error reloading revocation file")
+		})
+	}
+	jwtService := services.NewJWTService(keyring, services.JWTConfig{
+		Issuer:      cfg.Issuer,
+		Audience:    cfg.Audience,
+		TokenTTL:    cfg.AccessTokenTTL,
+		Revocations: revocations,
+	})
+	refreshService := services.NewRefreshTokenService(services.NewInMemoryRefreshTokenStore(), cfg.RefreshTokenTTL)
+	var clients []services.Client
+	if cfg.ClientsFile == "" {
+		log.Warn().Msg("clients file not configured, no client can request tokens")
+	} else if clients, err = services.LoadClients(cfg.ClientsFile); err != nil {
+		log.Fatal().Err(err).Msg("error loading clients")
+	}
+	log.Info().Int("clients", len(clients)).Msg("loaded clients")
+	clientAuthenticator := services.NewClientAuthenticator(clients, services.LockoutConfig{
+		MaxAttempts:       cfg.ClientMaxFailedAttempts,
+		MaxClientAttempts: cfg.ClientMaxTotalFailures,
+		Duration:          cfg.ClientLockoutDuration,
+	})
+	jwtHandler := handlers.NewJWTHandler(jwtService, refreshService, clientAuthenticator)
+
+	producer, err := newProducer(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating producer")
+	}
+	defer producer.Close()
+	// the spool forwarder retries on its own, messages sent synchronously are
+	// retried a few times; both fail fast while the breaker is open. A
+	// synchronous produce gives up before its idempotency key is released,
+	// or a retry of the request could produce the message again.
+	if cfg.ProduceTimeout <= 0 || cfg.ProduceTimeout >= middleware.DefaultIdempotencyLockTTL {
+		log.Fatal().Msg(fmt.Sprintf("produce timeout should be below %s, how long idempotency keys are held", middleware.DefaultIdempotencyLockTTL))
+	}
+	if cfg.BatchTimeout <= 0 || cfg.BatchTimeout >= middleware.DefaultIdempotencyLockTTL {
+		log.Fatal().Msg(fmt.Sprintf("batch timeout should be below %s, how long idempotency keys are held", middleware.DefaultIdempotencyLockTTL))
+	}
+	forwardProducer, sendProducer := producer, services.NewRetryingProducer(producer, services.RetryPolicy{
+		MaxAttempts:    cfg.ProduceMaxAttempts,
+		InitialBackoff: cfg.ProduceRetryBackoff,
+		MaxBackoff:     cfg.ProduceMaxRetryBackoff,
+		MaxElapsed:     cfg.ProduceTimeout,
+	})
+	var circuitBreakerHandler handlers.CircuitBreakerHandler
+	if cfg.CircuitBreakerMinRequests > 0 {
+		breaker := services.NewCircuitBreaker(services.CircuitBreakerConfig{
+			Window:       cfg.CircuitBreakerWindow,
+			MinRequests:  cfg.CircuitBreakerMinRequests,
+			FailureRatio: cfg.CircuitBreakerFailureRatio,
+			OpenTimeout:  cfg.CircuitBreakerOpenTimeout,
+		})
+		forwardProducer = services.NewCircuitBreakerProducer(forwardProducer, breaker)
+		sendProducer = services.NewCircuitBreakerProducer(sendProducer, breaker)
+		circuitBreakerHandler = handlers.NewCircuitBreakerHandler(breaker)
+	}
+	quotaService, err := newQuotaService(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating quota service")
+	}
+	deadLetters, err := newDeadLetterQueue(cfg, producer)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating dead-letter queue")
+	}
+	// a missing dead-letter topic would refuse every dead letter; brokers
+	// that cannot be reached now may be by the time a message is given up
+	if checker, ok := producer.(services.TopicChecker); ok && cfg.DeadLetterTopic != "" {
+		ctx, cancel := context.WithTimeout(appCtx, cfg.KafkaTimeout)
+		err := checker.CheckTopic(ctx, cfg.DeadLetterTopic)
+		cancel()
+		if err != nil && !services.Retriable(err) {
+			log.Fatal().Err(err).Str("dead_letter_topic", cfg.DeadLetterTopic).Msg("error checking dead-letter topic")
+		} else if err != nil {
+			log.Warn().Err(err).Str("dead_letter_topic", cfg.DeadLetterTopic).Msg("error checking dead-letter topic")
+		}
+	}
+	var messageSpool services.Spool
+	var messageStatuses services.MessageStatusStore
+	if cfg.SpoolDir != "" {
+		if messageStatuses, err = newMessageStatusStore(cfg); err != nil {
+			log.Fatal().Err(err).Msg("error creating message status store")
+		}
+		spoolLog, err := spool.Open(cfg.SpoolDir, spool.Config{
+			SegmentBytes: cfg.SpoolSegmentBytes,
+			MaxBytes:     cfg.SpoolMaxBytes,
+			MaxAge:       cfg.SpoolMaxAge,
+			OnExpire: func(lost uint64) {
+				log.Error().Uint64("messages", lost).Msg("spooled messages expired before being produced")
+			},
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("error opening spool")
+		}
+		log.Info().Uint64("pending", spoolLog.Pending()).Str("dir", cfg.SpoolDir).Msg("opened spool")
+		messageSpool = services.NewWALSpool(spoolLog, services.WALSpoolConfig{
+			Statuses:  messageStatuses,
+			StatusTTL: cfg.MessageStatusTTL,
+		})
+		// the forwarder stops with appCtx, before the spool is closed
+		forwarderDone := make(chan struct{})
+		go func() {
+			defer close(forwarderDone)
+			services.RunSpoolForwarder(appCtx, spoolLog, forwardProducer, services.ForwarderConfig{
+				Retry: services.RetryPolicy{
+					MaxAttempts:    cfg.SpoolMaxAttempts,
+					InitialBackoff: cfg.SpoolRetryBackoff,
+				},
+				Statuses:    messageStatuses,
+				StatusTTL:   cfg.MessageStatusTTL,
+				DeadLetters: deadLetters,
+				OnError: func(message services.SpooledMessage, err error, dropped bool) {
+					event := log.Warn()
+					if dropped {
+						event = log.Error()
+					}
+					event.Err(err).Str("id", message.ID).Str("topic", message.Topic).Bool("dropped", dropped).Msg("error forwarding spooled message")
+				},
+			})
+		}()
+		defer func() {
+			stop()
+			<-forwarderDone
+			_ = spoolLog.Close()
+		}()
+	}
+	// tokens may only publish to the topics their scopes allow
+	messageHandler := handlers.NewMessageHandler(services.NewAuthorizedProducer(sendProducer), quotaService, handlers.MessageHandlerConfig{
+		Spool:            messageSpool,
+		Statuses:         messageStatuses,
+		MaxBatchMessages: cfg.BatchMaxMessages,
+		MaxBatchBytes:    cfg.BatchMaxBytes,
+		BatchConcurrency: cfg.BatchConcurrency,
+		BatchTimeout:     cfg.BatchTimeout,
+	})
+
+	var rateLimitPolicies middleware.RateLimitPolicies
+	if cfg.RateLimitFile != "" {
+		if rateLimitPolicies, err = middleware.LoadRateLimitPolicies(cfg.RateLimitFile); err != nil {
+			log.Fatal().Err(err).Msg("error loading rate limit policies")
+		}
+	}
+	rateLimitKey, err := middleware.ParseKeyFuncs(cfg.RateLimitKeys, rateLimitPolicies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error parsing rate limit keys")
+	}
+
+	rateLimitBackend, err := newRateLimitBackend(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rate limit backend")
+	}
+
+	concurrencyAlgorithm, err := middleware.ParseLimitAlgorithm(cfg.ConcurrencyAlgorithm, cfg.ConcurrencyLatencyThreshold)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error parsing concurrency algorithm")
+	}
+
+	idempotencyStore, err := newIdempotencyStore(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating idempotency store")
+	}
+
+	var ipFilter middleware.IPFilter
+	if cfg.IPFilterFile != "" {
+		if ipFilter, err = middleware.NewIPFilter(cfg.IPFilterFile); err != nil {
+			log.Fatal().Err(err).Msg("error loading ip filter")
+		}
+		if cfg.IPFilterReloadInterval > 0 {
+			go middleware.RunIPFilterReload(appCtx, ipFilter, cfg.IPFilterReloadInterval, func(err error) {
+				log.Error().Err(err).Msg("error reloading ip filter, keeping the current rules")
+			})
+		}
+	}
+
+	var quotaHandler handlers.QuotaHandler
+	if quotaService != nil {
+		quotaHandler = handlers.NewQuotaHandler(quotaService)
+	}
+	// a dead-letter topic cannot be browsed without a consumer, so the admin
+	// routes are only served for a dead-letter file
+	var deadLetterHandler handlers.DeadLetterHandler
+	if queue, ok := deadLetters.(services.DeadLetterQueue); ok {
+		deadLetterHandler = handlers.NewDeadLetterHandler(queue, sendProducer, handlers.DeadLetterHandlerConfig{
+			Statuses:  messageStatuses,
+			StatusTTL: cfg.MessageStatusTTL,
+		})
+	} else if cfg.DeadLetterTopic != "" {
+		log.Info().Str("dead_letter_topic", cfg.DeadLetterTopic).Msg("dead letters are read with the broker tools, the dead-letter admin routes are disabled")
+	}
 
-	restClient, err := rest.NewRestClient(log, jwtHandler, cfg.RateLimit)
+	restClient, err := rest.NewRestClient(log, jwtService, jwtHandler, messageHandler, rest.Options{
+		RateLimit:               cfg.RateLimit,
+		RateLimitBurst:          cfg.RateLimitBurst,
+		RateLimitMaxEntries:     cfg.RateLimitMaxEntries,
+		RateLimitIdleTTL:        cfg.RateLimitIdleTTL,
+		RateLimitKey:            rateLimitKey,
+		RateLimitPolicies:       rateLimitPolicies,
+		RateLimitBackend:        rateLimitBackend,
+		IPRateLimit:             cfg.RateLimitPerIP,
+		IPRateLimitBurst:        cfg.RateLimitPerIPBurst,
+		IdempotencyStore:        idempotencyStore,
+		IdempotencyTTL:          cfg.IdempotencyTTL,
+		IdempotencyMaxBodyBytes: cfg.BatchMaxBytes,
+		TrustedProxies:          cfg.TrustedProxies,
+		IPFilter:                ipFilter,
+		ConcurrencyLimit:        cfg.ConcurrencyLimit,
+		ConcurrencyMinLimit:     cfg.ConcurrencyMinLimit,
+		ConcurrencyMaxLimit:     cfg.ConcurrencyMaxLimit,
+		ConcurrencyAlgorithm:    concurrencyAlgorithm,
+		AdminToken:              cfg.AdminToken,
+		QuotaHandler:            quotaHandler,
+		DeadLetterHandler:       deadLetterHandler,
+		CircuitBreakerHandler:   circuitBreakerHandler,
+	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("error creating rest service")
 	}
@@ -51,7 +296,7 @@ func main() {
 	}()
 
 	//Init shutting down gracefully
-	shutdown := make(chan os.Signal)
+	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 	<-shutdown
 	log.Info().Msg("shutting down server")
@@ -68,3 +313,162 @@ func main() {
 	}
 	log.Info().Msg("the server has been turned off gracefully")
 }
+
+// newSigningKey loads the PEM signing key when configured and falls back
+// to an HS256 key built from the shared secret
+func newSigningKey(cfg *config.Config) (*services.SigningKey, error) {
+	if cfg.SigningKeyFile != "" {
+		return services.LoadSigningKey(cfg.SigningKeyID, cfg.SigningKeyFile)
+	}
+	if cfg.SecretKey == "" {
+		return nil, errors.New("either a secret key or a signing key file is required")
+	}
+	kid := cfg.SigningKeyID
+	if kid == "" {
+		kid = "default"
+	}
+	return services.NewHMACKey(kid, []byte(cfg.SecretKey)), nil
+}
+
+// newRevocationStore creates a file-backed revocation store when a file is
+// configured and an in-memory store otherwise
+func newRevocationStore(cfg *config.Config) (services.RevocationStore, error) {
+	if cfg.RevocationFile == "" {
+		return services.NewInMemoryRevocationStore(), nil
+	}
+	return services.NewFileRevocationStore(cfg.RevocationFile)
+}
+
+// newProducer creates a batching Kafka producer when brokers are configured
+// and an in-memory producer otherwise
+func newProducer(cfg *config.Config) (services.Producer, error) {
+	if len(cfg.KafkaBrokers) == 0 {
+		return services.NewInMemoryProducer(), nil
+	}
+	acks, err := kafka.ParseAcks(cfg.KafkaAcks)
+	if err != nil {
+		return nil, err
+	}
+	compression, err := kafka.ParseCompression(cfg.KafkaCompression)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewBatchingKafkaProducer(kafka.ProducerConfig{
+		Brokers:      cfg.KafkaBrokers,
+		ClientID:     cfg.KafkaClientID,
+		Acks:         acks,
+		Idempotent:   cfg.KafkaIdempotent,
+		Timeout:      cfg.KafkaTimeout,
+		MaxRetries:   cfg.KafkaMaxRetries,
+		RetryBackoff: cfg.KafkaRetryBackoff,
+		Compression:  compression,
+	}, services.BatchConfig{
+		Linger:        cfg.KafkaLinger,
+		MaxBatchBytes: cfg.KafkaBatchMaxBytes,
+		MaxInFlight:   cfg.KafkaMaxInFlight,
+	})
+}
+
+// newRateLimitBackend creates a backend sharing the rate limits through a
+// Redis compatible server when one is configured, and nil otherwise
+func newRateLimitBackend(cfg *config.Config) (middleware.LimiterBackend, error) {
+	if cfg.RateLimitRedisAddr == "" {
+		return nil, nil
+	}
+	client, err := resp.NewClient(resp.ClientConfig{
+		Addr:     cfg.RateLimitRedisAddr,
+		Password: cfg.RateLimitRedisPassword,
+		DB:       cfg.RateLimitRedisDB,
+		Timeout:  cfg.RateLimitRedisTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return middleware.NewRESPLimiterBackend(client, ""), nil
+}
+
+// newQuotaService creates the tenant quotas when a quotas file is
+// configured, and nil otherwise. Usage is shared through a Redis compatible
+// server, kept in a file, or kept in memory, in that order of preference.
+func newQuotaService(cfg *config.Config) (services.QuotaService, error) {
+	if cfg.QuotasFile == "" {
+		return nil, nil
+	}
+	quotaConfig, err := services.LoadQuotaConfig(cfg.QuotasFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var store services.QuotaStore
+	switch {
+	case cfg.QuotaRedisAddr != "":
+		client, err := resp.NewClient(resp.ClientConfig{
+			Addr:     cfg.QuotaRedisAddr,
+			Password: cfg.QuotaRedisPassword,
+			DB:       cfg.QuotaRedisDB,
+		})
+		if err != nil {
+			return nil, err
+		}
+		store = services.NewRESPQuotaStore(client, "")
+	case cfg.QuotaUsageFile != "":
+		if store, err = services.NewFileQuotaStore(cfg.QuotaUsageFile); err != nil {
+			return nil, err
+		}
+	default:
+		store = services.NewInMemoryQuotaStore()
+	}
+	return services.NewQuotaService(quotaConfig, store), nil
+}
+
+// newIdempotencyStore shares idempotency keys through a Redis compatible
+// server when one is configured, and keeps them in memory otherwise
+func newIdempotencyStore(cfg *config.Config) (services.IdempotencyStore, error) {
+	if cfg.IdempotencyRedisAddr == "" {
+		return services.NewInMemoryIdempotencyStore(), nil
+	}
+	client, err := resp.NewClient(resp.ClientConfig{
+		Addr:     cfg.IdempotencyRedisAddr,
+		Password: cfg.IdempotencyRedisPassword,
+		DB:       cfg.IdempotencyRedisDB,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services.NewRESPIdempotencyStore(client, ""), nil
+}
+
+// newMessageStatusStore shares message statuses through a Redis compatible
+// server when one is configured, and keeps them in memory otherwise
+func newMessageStatusStore(cfg *config.Config) (services.MessageStatusStore, error) {
+	if cfg.MessageStatusRedisAddr == "" {
+		return services.NewInMemoryMessageStatusStore(), nil
+	}
+	client, err := resp.NewClient(resp.ClientConfig{
+		Addr:     cfg.MessageStatusRedisAddr,
+		Password: cfg.MessageStatusRedisPassword,
+		DB:       cfg.MessageStatusRedisDB,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services.NewRESPMessageStatusStore(client, ""), nil
+}
+
+// newDeadLetterQueue keeps the messages given up by the spool in a file or
+// produces them to a topic, and returns nil when neither is configured
+func newDeadLetterQueue(cfg *config.Config, producer services.Producer) (services.DeadLetterWriter, error) {
+	switch {
+	case cfg.DeadLetterFile != "" && cfg.DeadLetterTopic != "":
+		return nil, errors.New("either a dead-letter file or a dead-letter topic can be configured, not both")
+	case cfg.DeadLetterFile != "":
+		return services.NewFileDeadLetterQueue(cfg.DeadLetterFile)
+	case cfg.DeadLetterTopic != "":
+		if err := services.ValidateTopic(cfg.DeadLetterTopic); err != nil {
+			return nil, err
+		}
+		return services.NewTopicDeadLetterQueue(producer, cfg.DeadLetterTopic), nil
+	default:
+		return nil, nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"time"
 )
 
 const EnvPrefix = "MSG_RECEIVER"
@@ -11,11 +12,162 @@ const EnvPrefix = "MSG_RECEIVER"
 type Config struct {
 	ServiceName string  `split_words:"true" default:"msg-receiver"`
 	LogLevel    string  `split_words:"true" default:"info"`
-	SecretKey   string  `split_words:"true" required:"true"`
+	SecretKey   string  `split_words:"true"`
 	Issuer      string  `split_words:"true" required:"true"`
+	Audience    string  `split_words:"true"`
 	Port        uint    `required:"true" default:"8080"`
 	Host        string  `default:"0.0.0.0"`
 	RateLimit   float64 `default:"5"`
+
+	// RateLimitBurst is the number of requests allowed at once, RateLimit when zero
+	RateLimitBurst int `split_words:"true"`
+	// RateLimitPerIP is the number of requests per second allowed per client IP
+	// before authentication, on every route, with bursts of RateLimitPerIPBurst
+	// (RateLimitPerIP when zero); disabled when zero
+	RateLimitPerIP      float64 `split_words:"true" default:"50"`
+	RateLimitPerIPBurst int     `split_words:"true"`
+	// RateLimitMaxEntries and RateLimitIdleTTL bound the memory used to track clients
+	RateLimitMaxEntries int           `split_words:"true" default:"100000"`
+	RateLimitIdleTTL    time.Duration `split_words:"true" default:"10m"`
+	// RateLimitKeys identify clients, tried in order: sub, tenant, claim:<name>, header:<name> and ip
+	RateLimitKeys []string `split_words:"true" default:"sub,ip"`
+	// RateLimitFile holds per-key and per-route policies overriding RateLimit
+	RateLimitFile string `split_words:"true"`
+	// RateLimitRedisAddr is a Redis compatible server sharing the limits between
+	// replicas; each replica enforces its own limits when empty
+	RateLimitRedisAddr     string        `split_words:"true"`
+	RateLimitRedisPassword string        `split_words:"true"`
+	RateLimitRedisDB       int           `split_words:"true"`
+	RateLimitRedisTimeout  time.Duration `split_words:"true" default:"100ms"`
+
+	// QuotasFile holds the daily and monthly tenant quotas, which are disabled when empty
+	QuotasFile string `split_words:"true"`
+	// QuotaUsageFile persists the quota usage, kept in memory when empty
+	QuotaUsageFile string `split_words:"true"`
+	// QuotaRedisAddr is a Redis compatible server sharing the quota usage between replicas
+	QuotaRedisAddr     string `split_words:"true"`
+	QuotaRedisPassword string `split_words:"true"`
+	QuotaRedisDB       int    `split_words:"true"`
+
+	// BatchMaxMessages and BatchMaxBytes cap the batches of POST /v1/messages:batch,
+	// whose messages are produced BatchConcurrency at a time for up to BatchTimeout
+	BatchMaxMessages int           `split_words:"true" default:"1000"`
+	BatchMaxBytes    int64         `split_words:"true" default:"5242880"`
+	BatchConcurrency int           `split_words:"true" default:"16"`
+	BatchTimeout     time.Duration `split_words:"true" default:"45s"`
+
+	// IdempotencyTTL is how long responses to requests with an Idempotency-Key are replayed
+	IdempotencyTTL time.Duration `split_words:"true" default:"24h"`
+	// IdempotencyRedisAddr is a Redis compatible server sharing idempotency keys
+	// between replicas; keys are kept in memory when empty
+	IdempotencyRedisAddr     string `split_words:"true"`
+	IdempotencyRedisPassword string `split_words:"true"`
+	IdempotencyRedisDB       int    `split_words:"true"`
+
+	// SpoolDir holds the write-ahead log messages are accepted into with 202
+	// before being produced; messages are produced synchronously when empty.
+	// Segments of SpoolSegmentBytes are kept up to SpoolMaxBytes and
+	// SpoolMaxAge, and failed produce calls are retried after SpoolRetryBackoff
+	SpoolDir          string        `split_words:"true"`
+	SpoolSegmentBytes int64         `split_words:"true" default:"67108864"`
+	SpoolMaxBytes     int64         `split_words:"true" default:"1073741824"`
+	SpoolMaxAge       time.Duration `split_words:"true" default:"168h"`
+	SpoolRetryBackoff time.Duration `split_words:"true" default:"1s"`
+	// MessageStatusTTL is how long the status of a spooled message can be looked up
+	MessageStatusTTL time.Duration `split_words:"true" default:"24h"`
+	// MessageStatusRedisAddr is a Redis compatible server sharing message
+	// statuses between replicas; statuses are kept in memory when empty
+	MessageStatusRedisAddr     string `split_words:"true"`
+	MessageStatusRedisPassword string `split_words:"true"`
+	MessageStatusRedisDB       int    `split_words:"true"`
+
+	// SpoolMaxAttempts gives a spooled message up after that many failed
+	// produce calls, never when 0; messages the broker can never take are
+	// given up at once. Given up messages go to DeadLetterFile or
+	// DeadLetterTopic, and are only logged when both are empty
+	SpoolMaxAttempts int    `split_words:"true"`
+	DeadLetterFile   string `split_words:"true"`
+	DeadLetterTopic  string `split_words:"true"`
+
+	// ProduceMaxAttempts bounds the produce calls made for a message sent
+	// synchronously; delays start at ProduceRetryBackoff and double up to
+	// ProduceMaxRetryBackoff, with jitter. ProduceTimeout bounds the calls
+	// and delays together, and must stay below the minute an idempotency
+	// key is held.
+	ProduceMaxAttempts     int           `split_words:"true" default:"3"`
+	ProduceRetryBackoff    time.Duration `split_words:"true" default:"100ms"`
+	ProduceMaxRetryBackoff time.Duration `split_words:"true" default:"2s"`
+	ProduceTimeout         time.Duration `split_words:"true" default:"30s"`
+
+	// CircuitBreakerMinRequests is the produce calls a CircuitBreakerWindow
+	// needs before CircuitBreakerFailureRatio failed ones open the breaker,
+	// which fails calls at once for CircuitBreakerOpenTimeout; 0 disables it
+	CircuitBreakerMinRequests  int           `split_words:"true" default:"20"`
+	CircuitBreakerFailureRatio float64       `split_words:"true" default:"0.5"`
+	CircuitBreakerWindow       time.Duration `split_words:"true" default:"10s"`
+	CircuitBreakerOpenTimeout  time.Duration `split_words:"true" default:"30s"`
+
+	// TrustedProxies are the proxy CIDRs whose X-Forwarded-For and X-Real-IP
+	// headers are believed; the client IP is the peer address when empty
+	TrustedProxies []string `split_words:"true"`
+	// IPFilterFile holds CIDR allow and deny lists per route group, re-read
+	// every IPFilterReloadInterval (never when 0); every address is allowed when empty
+	IPFilterFile           string        `split_words:"true"`
+	IPFilterReloadInterval time.Duration `split_words:"true" default:"30s"`
+
+	// ConcurrencyLimit is the initial number of requests in flight, adapted
+	// to the latency between the min and max limits; 0 disables load shedding
+	ConcurrencyLimit    int `split_words:"true" default:"100"`
+	ConcurrencyMinLimit int `split_words:"true" default:"10"`
+	ConcurrencyMaxLimit int `split_words:"true" default:"1000"`
+	// ConcurrencyAlgorithm adapts the limit: vegas or aimd
+	ConcurrencyAlgorithm string `split_words:"true" default:"vegas"`
+	// ConcurrencyLatencyThreshold is the latency aimd treats as congestion
+	ConcurrencyLatencyThreshold time.Duration `split_words:"true" default:"1s"`
+
+	// SigningKeyFile is a PEM private key (RSA, ECDSA or Ed25519); SecretKey is used for HS256 when empty
+	SigningKeyFile string `split_words:"true"`
+	SigningKeyID   string `split_words:"true"`
+
+	// Keyring settings; keys are rotated on a schedule when KeyRotationInterval is set
+	KeyringDir          string        `split_words:"true"`
+	KeyOverlap          time.Duration `split_words:"true" default:"48h"`
+	KeyRotationInterval time.Duration `split_words:"true"`
+
+	// ClientsFile is a JSON file of the OAuth2 clients allowed to request tokens
+	// ClientMaxFailedAttempts failures from one address lock a client ID out
+	// of it, and ClientMaxTotalFailures from any address out of all
+	ClientsFile             string        `split_words:"true"`
+	ClientMaxFailedAttempts int           `split_words:"true" default:"5"`
+	ClientMaxTotalFailures  int           `split_words:"true" default:"50"`
+	ClientLockoutDuration   time.Duration `split_words:"true" default:"15m"`
+
+	// AccessTokenTTL is the lifetime of access tokens; clients renew them with a refresh token
+	AccessTokenTTL  time.Duration `split_words:"true" default:"15m"`
+	RefreshTokenTTL time.Duration `split_words:"true" default:"720h"`
+	// RevocationFile keeps revoked token IDs across restarts; they are kept in memory when empty.
+	// It is re-read every RevocationReloadInterval (never when 0) for the IDs other replicas revoke
+	RevocationFile           string        `split_words:"true"`
+	RevocationReloadInterval time.Duration `split_words:"true" default:"5s"`
+
+	// AdminToken protects the /admin routes, which are disabled when empty
+	AdminToken string `split_words:"true"`
+
+	// Kafka producer settings; the in-memory producer is used when no brokers are set
+	KafkaBrokers      []string      `split_words:"true"`
+	KafkaClientID     string        `split_words:"true" default:"msg-receiver"`
+	KafkaAcks         string        `split_words:"true" default:"all"`
+	KafkaIdempotent   bool          `split_words:"true" default:"true"`
+	KafkaTimeout      time.Duration `split_words:"true" default:"10s"`
+	KafkaMaxRetries   int           `split_words:"true" default:"3"`
+	KafkaRetryBackoff time.Duration `split_words:"true" default:"100ms"`
+	// messages sent to a partition at once are written in batches of up to
+	// KafkaBatchMaxBytes, each waiting KafkaLinger for more messages, with up
+	// to KafkaMaxInFlight batches sent at once
+	KafkaCompression   string        `split_words:"true" default:"none"`
+	KafkaLinger        time.Duration `split_words:"true" default:"5ms"`
+	KafkaBatchMaxBytes int           `split_words:"true" default:"524288"`
+	KafkaMaxInFlight   int           `split_words:"true" default:"5"`
 }
 
 func Get() (*Config, error) {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGet(t *testing.T) {
@@ -36,6 +37,54 @@ func TestGet(t *testing.T) {
 					Port:        8080,
 					Host:        "0.0.0.0",
 					RateLimit:   5,
+
+					RateLimitMaxEntries:         100000,
+					RateLimitIdleTTL:            10 * time.Minute,
+					RateLimitKeys:               []string{"sub", "ip"},
+					RateLimitRedisTimeout:       100 * time.Millisecond,
+					BatchMaxMessages:            1000,
+					BatchMaxBytes:               5 << 20,
+					BatchConcurrency:            16,
+					BatchTimeout:                45 * time.Second,
+					IdempotencyTTL:              24 * time.Hour,
+					SpoolSegmentBytes:           64 << 20,
+					SpoolMaxBytes:               1 << 30,
+					SpoolMaxAge:                 168 * time.Hour,
+					SpoolRetryBackoff:           time.Second,
+					MessageStatusTTL:            24 * time.Hour,
+					ProduceMaxAttempts:          3,
+					ProduceRetryBackoff:         100 * time.Millisecond,
+					ProduceMaxRetryBackoff:      2 * time.Second,
+					ProduceTimeout:              30 * time.Second,
+					CircuitBreakerMinRequests:   20,
+					CircuitBreakerFailureRatio:  0.5,
+					CircuitBreakerWindow:        10 * time.Second,
+					CircuitBreakerOpenTimeout:   30 * time.Second,
+					IPFilterReloadInterval:      30 * time.Second,
+					RateLimitPerIP:              50,
+					ConcurrencyLimit:            100,
+					ConcurrencyMinLimit:         10,
+					ConcurrencyMaxLimit:         1000,
+					ConcurrencyAlgorithm:        "vegas",
+					ConcurrencyLatencyThreshold: time.Second,
+					KeyOverlap:                  48 * time.Hour,
+					ClientMaxFailedAttempts:     5,
+					ClientMaxTotalFailures:      50,
+					ClientLockoutDuration:       15 * time.Minute,
+					AccessTokenTTL:              15 * time.Minute,
+					RefreshTokenTTL:             720 * time.Hour,
+					RevocationReloadInterval:    5 * time.Second,
+
+					KafkaClientID:      "msg-receiver",
+					KafkaAcks:          "all",
+					KafkaIdempotent:    true,
+					KafkaTimeout:       10 * time.Second,
+					KafkaMaxRetries:    3,
+					KafkaRetryBackoff:  100 * time.Millisecond,
+					KafkaCompression:   "none",
+					KafkaLinger:        5 * time.Millisecond,
+					KafkaBatchMaxBytes: 524288,
+					KafkaMaxInFlight:   5,
 				}, c, "invalid config returned")
 			},
 		}, {
@@ -89,6 +138,54 @@ func TestNew(t *testing.T) {
 					Port:        8080,
 					Host:        "0.0.0.0",
 					RateLimit:   5,
+
+					RateLimitMaxEntries:         100000,
+					RateLimitIdleTTL:            10 * time.Minute,
+					RateLimitKeys:               []string{"sub", "ip"},
+					RateLimitRedisTimeout:       100 * time.Millisecond,
+					BatchMaxMessages:            1000,
+					BatchMaxBytes:               5 << 20,
+					BatchConcurrency:            16,
+					BatchTimeout:                45 * time.Second,
+					IdempotencyTTL:              24 * time.Hour,
+					SpoolSegmentBytes:           64 << 20,
+					SpoolMaxBytes:               1 << 30,
+					SpoolMaxAge:                 168 * time.Hour,
+					SpoolRetryBackoff:           time.Second,
+					MessageStatusTTL:            24 * time.Hour,
+					ProduceMaxAttempts:          3,
+					ProduceRetryBackoff:         100 * time.Millisecond,
+					ProduceMaxRetryBackoff:      2 * time.Second,
+					ProduceTimeout:              30 * time.Second,
+					CircuitBreakerMinRequests:   20,
+					CircuitBreakerFailureRatio:  0.5,
+					CircuitBreakerWindow:        10 * time.Second,
+					CircuitBreakerOpenTimeout:   30 * time.Second,
+					IPFilterReloadInterval:      30 * time.Second,
+					RateLimitPerIP:              50,
+					ConcurrencyLimit:            100,
+					ConcurrencyMinLimit:         10,
+					ConcurrencyMaxLimit:         1000,
+					ConcurrencyAlgorithm:        "vegas",
+					ConcurrencyLatencyThreshold: time.Second,
+					KeyOverlap:                  48 * time.Hour,
+					ClientMaxFailedAttempts:     5,
+					ClientMaxTotalFailures:      50,
+					ClientLockoutDuration:       15 * time.Minute,
+					AccessTokenTTL:              15 * time.Minute,
+					RefreshTokenTTL:             720 * time.Hour,
+					RevocationReloadInterval:    5 * time.Second,
+
+					KafkaClientID:      "msg-receiver",
+					KafkaAcks:          "all",
+					KafkaIdempotent:    true,
+					KafkaTimeout:       10 * time.Second,
+					KafkaMaxRetries:    3,
+					KafkaRetryBackoff:  100 * time.Millisecond,
+					KafkaCompression:   "none",
+					KafkaLinger:        5 * time.Millisecond,
+					KafkaBatchMaxBytes: 524288,
+					KafkaMaxInFlight:   5,
 				}, c, "invalid config returned")
 			},
 		},
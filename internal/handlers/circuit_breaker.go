@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"net/http"
+)
+
+// CircuitBreakerHandler is the interface that provides the circuit breaker admin methods.
+//
+//counterfeiter:generate . CircuitBreakerHandler
+type CircuitBreakerHandler interface {
+	State(c *gin.Context)
+}
+
+type circuitBreakerHandler struct {
+	breaker services.CircuitBreaker
+}
+
+// NewCircuitBreakerHandler creates a new CircuitBreakerHandler.
+func NewCircuitBreakerHandler(breaker services.CircuitBreaker) CircuitBreakerHandler {
+	return &circuitBreakerHandler{
+		breaker: breaker,
+	}
+}
+
+// State returns whether the breaker around producing is closed, open or
+// half open, with the failures counted so far.
+// Params: c *gin.Context - the request context
+func (h *circuitBreakerHandler) State(c *gin.Context) {
+	c.JSON(http.StatusOK, h.breaker.State())
+}
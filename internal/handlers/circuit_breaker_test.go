@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerHandler_State(t *testing.T) {
+	openedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	probeAt := openedAt.Add(30 * time.Second)
+	breaker := &servicesfakes.FakeCircuitBreaker{}
+	breaker.StateReturns(services.CircuitBreakerState{State: services.CircuitOpen, Trips: 1, OpenedAt: &openedAt, ProbeAt: &probeAt, LastError: "broker is unavailable"})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/breaker", nil)
+	NewCircuitBreakerHandler(breaker).State(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"state":"open","requests":0,"failures":0,"trips":1,"opened_at":"2024-05-01T12:00:00Z","probe_at":"2024-05-01T12:00:30Z","last_error":"broker is unavailable"}`, w.Body.String())
+}
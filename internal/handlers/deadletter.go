@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDeadLetterListLimit caps the messages listed at once
+const maxDeadLetterListLimit = 1000
+
+// DeadLetterHandler is the interface that provides the dead-letter admin methods.
+//
+//counterfeiter:generate . DeadLetterHandler
+type DeadLetterHandler interface {
+	List(c *gin.Context)
+	Get(c *gin.Context)
+	Replay(c *gin.Context)
+	Remove(c *gin.Context)
+	Purge(c *gin.Context)
+}
+
+// DeadLetterHandlerConfig configures the replays of DeadLetterHandler
+type DeadLetterHandlerConfig struct {
+	// Statuses, when set, records the replayed messages delivered for
+	// StatusTTL, 24 hours when zero
+	Statuses  services.MessageStatusStore
+	StatusTTL time.Duration
+}
+
+type deadLetterHandler struct {
+	deadLetters services.DeadLetterQueue
+	producer    services.Producer
+	cfg         DeadLetterHandlerConfig
+}
+
+// NewDeadLetterHandler creates a new DeadLetterHandler. Replayed messages
+// are handed to producer without checking token scopes.
+func NewDeadLetterHandler(deadLetters services.DeadLetterQueue, producer services.Producer, cfg DeadLetterHandlerConfig) DeadLetterHandler {
+	if cfg.StatusTTL <= 0 {
+		cfg.StatusTTL = 24 * time.Hour
+	}
+	return &deadLetterHandler{
+		deadLetters: deadLetters,
+		producer:    producer,
+		cfg:         cfg,
+	}
+}
+
+// List returns the dead-lettered messages, oldest first, without their
+// key, headers and value.
+// Params: c *gin.Context - the request context, with the optional topic and limit (100 by default) query parameters
+func (h *deadLetterHandler) List(c *gin.Context) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxDeadLetterListLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "limit should be between 1 and 1000"})
+			return
+		}
+	}
+	letters, err := h.deadLetters.List(c.Query("topic"), limit)
+	if err != nil {
+		deadLetterError(c, err, "failed to list dead letters")
+		return
+	}
+	for i := range letters {
+		letters[i].Key, letters[i].Headers, letters[i].Value = nil, nil, nil
+	}
+	c.JSON(http.StatusOK, gin.H{"deadletters": letters})
+}
+
+// Get returns a dead-lettered message.
+// Params: c *gin.Context - the request context, with the id path parameter
+func (h *deadLetterHandler) Get(c *gin.Context) {
+	letter, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, letter)
+}
+
+// Replay produces a dead-lettered message to its topic again, records it
+// delivered and removes it once produced; it is kept when producing fails.
+// Params: c *gin.Context - the request context, with the id path parameter
+func (h *deadLetterHandler) Replay(c *gin.Context) {
+	letter, ok := h.find(c)
+	if !ok {
+		return
+	}
+	result, err := h.producer.Produce(c.Request.Context(), letter.Topic, letter.Key, letter.Headers, letter.Value)
+	if err != nil {
+		writeProduceError(c, err)
+		return
+	}
+	h.recordDelivered(letter, result)
+	if _, err := h.deadLetters.Remove(letter.ID); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "message replayed but not removed from the dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Remove drops a dead-lettered message.
+// Params: c *gin.Context - the request context, with the id path parameter
+func (h *deadLetterHandler) Remove(c *gin.Context) {
+	removed, err := h.deadLetters.Remove(c.Param("id"))
+	if err != nil {
+		deadLetterError(c, err, "failed to remove dead letter")
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "error_description": "unknown dead letter"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+	c.Writer.WriteHeaderNow()
+}
+
+// Purge drops the dead-lettered messages of a topic, or all of them.
+// Params: c *gin.Context - the request context, with the optional topic query parameter
+func (h *deadLetterHandler) Purge(c *gin.Context) {
+	purged, err := h.deadLetters.Purge(c.Query("topic"))
+	if err != nil {
+		deadLetterError(c, err, "failed to purge dead letters")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// find reads the message of the id path parameter, or writes the error response
+func (h *deadLetterHandler) find(c *gin.Context) (*services.DeadLetter, bool) {
+	letter, err := h.deadLetters.Get(c.Param("id"))
+	if err != nil {
+		deadLetterError(c, err, "failed to read dead letter")
+		return nil, false
+	}
+	if letter == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "error_description": "unknown dead letter"})
+		return nil, false
+	}
+	return letter, true
+}
+
+// recordDelivered replaces the failed status of a replayed message, best effort
+func (h *deadLetterHandler) recordDelivered(letter *services.DeadLetter, result *services.DeliveryResult) {
+	if h.cfg.Statuses == nil {
+		return
+	}
+	status := services.MessageStatus{
+		ID:         letter.ID,
+		Tenant:     letter.Tenant,
+		State:      services.MessageDelivered,
+		Topic:      letter.Topic,
+		AcceptedAt: letter.AcceptedAt,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if result != nil {
+		status.Partition, status.Offset = &result.Partition, &result.Offset
+	}
+	_ = h.cfg.Statuses.Put([]services.MessageStatus{status}, h.cfg.StatusTTL)
+}
+
+// deadLetterError writes the response of a failed dead-letter queue call
+func deadLetterError(c *gin.Context, err error, description string) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": description})
+}
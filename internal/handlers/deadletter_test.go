@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterHandler(t *testing.T) {
+	letter := services.DeadLetter{ID: "m1", Topic: "orders", Key: []byte("k"), Value: []byte("1"), Error: "unknown topic", Attempts: 1}
+	found := func(string) (*services.DeadLetter, error) {
+		copied := letter
+		return &copied, nil
+	}
+
+	testCases := []struct {
+		name               string
+		call               func(h DeadLetterHandler, c *gin.Context)
+		query              string
+		deadLetters        *servicesfakes.FakeDeadLetterQueue
+		producer           *servicesfakes.FakeProducer
+		expectedStatusCode int
+		assert             func(t *testing.T, w *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, producer *servicesfakes.FakeProducer)
+	}{
+		{
+			name:  "should list the dead letters without their payload",
+			call:  DeadLetterHandler.List,
+			query: "?topic=orders&limit=5",
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{
+				ListStub: func(string, int) ([]services.DeadLetter, error) {
+					return []services.DeadLetter{letter}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, _ *servicesfakes.FakeProducer) {
+				topic, limit := deadLetters.ListArgsForCall(0)
+				assert.Equal(t, "orders", topic)
+				assert.Equal(t, 5, limit)
+				assert.JSONEq(t, `{"deadletters":[{"id":"m1","topic":"orders","error":"unknown topic","attempts":1,"accepted_at":"0001-01-01T00:00:00Z","failed_at":"0001-01-01T00:00:00Z"}]}`, w.Body.String())
+			},
+		}, {
+			name:               "should reject an invalid limit",
+			call:               DeadLetterHandler.List,
+			query:              "?limit=5000",
+			deadLetters:        &servicesfakes.FakeDeadLetterQueue{},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, _ *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, _ *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, deadLetters.ListCallCount())
+			},
+		}, {
+			name:               "should return a dead letter with its payload",
+			call:               DeadLetterHandler.Get,
+			deadLetters:        &servicesfakes.FakeDeadLetterQueue{GetStub: found},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, _ *servicesfakes.FakeProducer) {
+				assert.Equal(t, "m1", deadLetters.GetArgsForCall(0))
+				assert.Contains(t, w.Body.String(), `"value":"MQ=="`)
+			},
+		}, {
+			name:               "should not find an unknown dead letter",
+			call:               DeadLetterHandler.Get,
+			deadLetters:        &servicesfakes.FakeDeadLetterQueue{},
+			expectedStatusCode: http.StatusNotFound,
+		}, {
+			name:        "should replay a dead letter and remove it",
+			call:        DeadLetterHandler.Replay,
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{GetStub: found},
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(_ context.Context, topic string, _ []byte, _ []services.Header, _ []byte) (*services.DeliveryResult, error) {
+					return &services.DeliveryResult{Topic: topic, Offset: 3}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, producer *servicesfakes.FakeProducer) {
+				_, topic, key, _, value := producer.ProduceArgsForCall(0)
+				assert.Equal(t, "orders", topic)
+				assert.Equal(t, []byte("k"), key)
+				assert.Equal(t, []byte("1"), value)
+				assert.Equal(t, "m1", deadLetters.RemoveArgsForCall(0))
+				assert.Contains(t, w.Body.String(), `"offset":3`)
+			},
+		}, {
+			name:        "should keep a dead letter that fails again",
+			call:        DeadLetterHandler.Replay,
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{GetStub: found},
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, services.ErrUnknownTopic
+				},
+			},
+			expectedStatusCode: http.StatusNotFound,
+			assert: func(t *testing.T, _ *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, _ *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, deadLetters.RemoveCallCount())
+			},
+		}, {
+			name: "should remove a dead letter",
+			call: DeadLetterHandler.Remove,
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{
+				RemoveStub: func(string) (bool, error) { return true, nil },
+			},
+			expectedStatusCode: http.StatusNoContent,
+		}, {
+			name:               "should not remove an unknown dead letter",
+			call:               DeadLetterHandler.Remove,
+			deadLetters:        &servicesfakes.FakeDeadLetterQueue{},
+			expectedStatusCode: http.StatusNotFound,
+		}, {
+			name:  "should purge the dead letters of a topic",
+			call:  DeadLetterHandler.Purge,
+			query: "?topic=orders",
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{
+				PurgeStub: func(string) (int, error) { return 2, nil },
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, deadLetters *servicesfakes.FakeDeadLetterQueue, _ *servicesfakes.FakeProducer) {
+				assert.Equal(t, "orders", deadLetters.PurgeArgsForCall(0))
+				assert.JSONEq(t, `{"purged":2}`, w.Body.String())
+			},
+		}, {
+			name: "should return status code 503 when the queue fails",
+			call: DeadLetterHandler.Purge,
+			deadLetters: &servicesfakes.FakeDeadLetterQueue{
+				PurgeStub: func(string) (int, error) { return 0, assert.AnError },
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.producer == nil {
+				tc.producer = &servicesfakes.FakeProducer{}
+			}
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/admin/deadletters/m1"+tc.query, nil)
+			c.Params = gin.Params{{Key: "id", Value: "m1"}}
+
+			tc.call(NewDeadLetterHandler(tc.deadLetters, tc.producer, DeadLetterHandlerConfig{}), c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.assert != nil {
+				tc.assert(t, w, tc.deadLetters, tc.producer)
+			}
+		})
+	}
+}
+
+func TestDeadLetterHandler_ReplayStatus(t *testing.T) {
+	accepted := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	letter := services.DeadLetter{ID: "m1", Topic: "orders", Value: []byte("1"), Tenant: "acme", AcceptedAt: accepted}
+	deadLetters := &servicesfakes.FakeDeadLetterQueue{}
+	deadLetters.GetReturns(&letter, nil)
+
+	testCases := []struct {
+		name        string
+		produceErr  error
+		expectedPut int
+	}{
+		{name: "should record a replayed message delivered", expectedPut: 1},
+		{name: "should keep the failed status of a message failing again", produceErr: services.ErrUnknownTopic},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := &servicesfakes.FakeProducer{}
+			if tc.produceErr != nil {
+				producer.ProduceReturns(nil, tc.produceErr)
+			} else {
+				producer.ProduceReturns(&services.DeliveryResult{Topic: "orders", Partition: 2, Offset: 7}, nil)
+			}
+			statuses := &servicesfakes.FakeMessageStatusStore{}
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/admin/deadletters/m1/replay", nil)
+			c.Params = gin.Params{{Key: "id", Value: "m1"}}
+
+			NewDeadLetterHandler(deadLetters, producer, DeadLetterHandlerConfig{Statuses: statuses, StatusTTL: time.Hour}).Replay(c)
+			require.Equal(t, tc.expectedPut, statuses.PutCallCount())
+			if tc.expectedPut == 0 {
+				return
+			}
+			put, ttl := statuses.PutArgsForCall(0)
+			assert.Equal(t, time.Hour, ttl)
+			require.Len(t, put, 1)
+			assert.Equal(t, "m1", put[0].ID)
+			assert.Equal(t, "acme", put[0].Tenant)
+			assert.Equal(t, services.MessageDelivered, put[0].State)
+			assert.Equal(t, "orders", put[0].Topic)
+			assert.Equal(t, accepted, put[0].AcceptedAt)
+			require.NotNil(t, put[0].Partition)
+			assert.Equal(t, int32(2), *put[0].Partition)
+			require.NotNil(t, put[0].Offset)
+			assert.Equal(t, int64(7), *put[0].Offset)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
+)
+
+type FakeCircuitBreakerHandler struct {
+	StateStub        func(*gin.Context)
+	stateMutex       sync.RWMutex
+	stateArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCircuitBreakerHandler) State(arg1 *gin.Context) {
+	fake.stateMutex.Lock()
+	fake.stateArgsForCall = append(fake.stateArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.StateStub
+	fake.recordInvocation("State", []interface{}{arg1})
+	fake.stateMutex.Unlock()
+	if stub != nil {
+		fake.StateStub(arg1)
+	}
+}
+
+func (fake *FakeCircuitBreakerHandler) StateCallCount() int {
+	fake.stateMutex.RLock()
+	defer fake.stateMutex.RUnlock()
+	return len(fake.stateArgsForCall)
+}
+
+func (fake *FakeCircuitBreakerHandler) StateCalls(stub func(*gin.Context)) {
+	fake.stateMutex.Lock()
+	defer fake.stateMutex.Unlock()
+	fake.StateStub = stub
+}
+
+func (fake *FakeCircuitBreakerHandler) StateArgsForCall(i int) *gin.Context {
+	fake.stateMutex.RLock()
+	defer fake.stateMutex.RUnlock()
+	argsForCall := fake.stateArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeCircuitBreakerHandler) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.stateMutex.RLock()
+	defer fake.stateMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCircuitBreakerHandler) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.CircuitBreakerHandler = new(FakeCircuitBreakerHandler)
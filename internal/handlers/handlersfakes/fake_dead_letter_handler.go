@@ -0,0 +1,233 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
+)
+
+type FakeDeadLetterHandler struct {
+	GetStub        func(*gin.Context)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	ListStub        func(*gin.Context)
+	listMutex       sync.RWMutex
+	listArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	PurgeStub        func(*gin.Context)
+	purgeMutex       sync.RWMutex
+	purgeArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	RemoveStub        func(*gin.Context)
+	removeMutex       sync.RWMutex
+	removeArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	ReplayStub        func(*gin.Context)
+	replayMutex       sync.RWMutex
+	replayArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeDeadLetterHandler) Get(arg1 *gin.Context) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.GetStub
+	fake.recordInvocation("Get", []interface{}{arg1})
+	fake.getMutex.Unlock()
+	if stub != nil {
+		fake.GetStub(arg1)
+	}
+}
+
+func (fake *FakeDeadLetterHandler) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeDeadLetterHandler) GetCalls(stub func(*gin.Context)) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = stub
+}
+
+func (fake *FakeDeadLetterHandler) GetArgsForCall(i int) *gin.Context {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	argsForCall := fake.getArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeadLetterHandler) List(arg1 *gin.Context) {
+	fake.listMutex.Lock()
+	fake.listArgsForCall = append(fake.listArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.ListStub
+	fake.recordInvocation("List", []interface{}{arg1})
+	fake.listMutex.Unlock()
+	if stub != nil {
+		fake.ListStub(arg1)
+	}
+}
+
+func (fake *FakeDeadLetterHandler) ListCallCount() int {
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	return len(fake.listArgsForCall)
+}
+
+func (fake *FakeDeadLetterHandler) ListCalls(stub func(*gin.Context)) {
+	fake.listMutex.Lock()
+	defer fake.listMutex.Unlock()
+	fake.ListStub = stub
+}
+
+func (fake *FakeDeadLetterHandler) ListArgsForCall(i int) *gin.Context {
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	argsForCall := fake.listArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeadLetterHandler) Purge(arg1 *gin.Context) {
+	fake.purgeMutex.Lock()
+	fake.purgeArgsForCall = append(fake.purgeArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.PurgeStub
+	fake.recordInvocation("Purge", []interface{}{arg1})
+	fake.purgeMutex.Unlock()
+	if stub != nil {
+		fake.PurgeStub(arg1)
+	}
+}
+
+func (fake *FakeDeadLetterHandler) PurgeCallCount() int {
+	fake.purgeMutex.RLock()
+	defer fake.purgeMutex.RUnlock()
+	return len(fake.purgeArgsForCall)
+}
+
+func (fake *FakeDeadLetterHandler) PurgeCalls(stub func(*gin.Context)) {
+	fake.purgeMutex.Lock()
+	defer fake.purgeMutex.Unlock()
+	fake.PurgeStub = stub
+}
+
+func (fake *FakeDeadLetterHandler) PurgeArgsForCall(i int) *gin.Context {
+	fake.purgeMutex.RLock()
+	defer fake.purgeMutex.RUnlock()
+	argsForCall := fake.purgeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeadLetterHandler) Remove(arg1 *gin.Context) {
+	fake.removeMutex.Lock()
+	fake.removeArgsForCall = append(fake.removeArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.RemoveStub
+	fake.recordInvocation("Remove", []interface{}{arg1})
+	fake.removeMutex.Unlock()
+	if stub != nil {
+		fake.RemoveStub(arg1)
+	}
+}
+
+func (fake *FakeDeadLetterHandler) RemoveCallCount() int {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return len(fake.removeArgsForCall)
+}
+
+func (fake *FakeDeadLetterHandler) RemoveCalls(stub func(*gin.Context)) {
+	fake.removeMutex.Lock()
+	defer fake.removeMutex.Unlock()
+	fake.RemoveStub = stub
+}
+
+func (fake *FakeDeadLetterHandler) RemoveArgsForCall(i int) *gin.Context {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	argsForCall := fake.removeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeadLetterHandler) Replay(arg1 *gin.Context) {
+	fake.replayMutex.Lock()
+	fake.replayArgsForCall = append(fake.replayArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.ReplayStub
+	fake.recordInvocation("Replay", []interface{}{arg1})
+	fake.replayMutex.Unlock()
+	if stub != nil {
+		fake.ReplayStub(arg1)
+	}
+}
+
+func (fake *FakeDeadLetterHandler) ReplayCallCount() int {
+	fake.replayMutex.RLock()
+	defer fake.replayMutex.RUnlock()
+	return len(fake.replayArgsForCall)
+}
+
+func (fake *FakeDeadLetterHandler) ReplayCalls(stub func(*gin.Context)) {
+	fake.replayMutex.Lock()
+	defer fake.replayMutex.Unlock()
+	fake.ReplayStub = stub
+}
+
+func (fake *FakeDeadLetterHandler) ReplayArgsForCall(i int) *gin.Context {
+	fake.replayMutex.RLock()
+	defer fake.replayMutex.RUnlock()
+	argsForCall := fake.replayArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeadLetterHandler) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	fake.purgeMutex.RLock()
+	defer fake.purgeMutex.RUnlock()
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	fake.replayMutex.RLock()
+	defer fake.replayMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeDeadLetterHandler) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.DeadLetterHandler = new(FakeDeadLetterHandler)
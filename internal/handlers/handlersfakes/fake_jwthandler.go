@@ -14,6 +14,31 @@ type FakeJWTHandler struct {
 	generateTokenArgsForCall []struct {
 		arg1 *gin.Context
 	}
+	IntrospectTokenStub        func(*gin.Context)
+	introspectTokenMutex       sync.RWMutex
+	introspectTokenArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	JWKSStub        func(*gin.Context)
+	jWKSMutex       sync.RWMutex
+	jWKSArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	RefreshTokenStub        func(*gin.Context)
+	refreshTokenMutex       sync.RWMutex
+	refreshTokenArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	RevokeTokenStub        func(*gin.Context)
+	revokeTokenMutex       sync.RWMutex
+	revokeTokenArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	RotateSigningKeyStub        func(*gin.Context)
+	rotateSigningKeyMutex       sync.RWMutex
+	rotateSigningKeyArgsForCall []struct {
+		arg1 *gin.Context
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -50,11 +75,181 @@ func (fake *FakeJWTHandler) GenerateTokenArgsForCall(i int) *gin.Context {
 	return argsForCall.arg1
 }
 
+func (fake *FakeJWTHandler) IntrospectToken(arg1 *gin.Context) {
+	fake.introspectTokenMutex.Lock()
+	fake.introspectTokenArgsForCall = append(fake.introspectTokenArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.IntrospectTokenStub
+	fake.recordInvocation("IntrospectToken", []interface{}{arg1})
+	fake.introspectTokenMutex.Unlock()
+	if stub != nil {
+		fake.IntrospectTokenStub(arg1)
+	}
+}
+
+func (fake *FakeJWTHandler) IntrospectTokenCallCount() int {
+	fake.introspectTokenMutex.RLock()
+	defer fake.introspectTokenMutex.RUnlock()
+	return len(fake.introspectTokenArgsForCall)
+}
+
+func (fake *FakeJWTHandler) IntrospectTokenCalls(stub func(*gin.Context)) {
+	fake.introspectTokenMutex.Lock()
+	defer fake.introspectTokenMutex.Unlock()
+	fake.IntrospectTokenStub = stub
+}
+
+func (fake *FakeJWTHandler) IntrospectTokenArgsForCall(i int) *gin.Context {
+	fake.introspectTokenMutex.RLock()
+	defer fake.introspectTokenMutex.RUnlock()
+	argsForCall := fake.introspectTokenArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeJWTHandler) JWKS(arg1 *gin.Context) {
+	fake.jWKSMutex.Lock()
+	fake.jWKSArgsForCall = append(fake.jWKSArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.JWKSStub
+	fake.recordInvocation("JWKS", []interface{}{arg1})
+	fake.jWKSMutex.Unlock()
+	if stub != nil {
+		fake.JWKSStub(arg1)
+	}
+}
+
+func (fake *FakeJWTHandler) JWKSCallCount() int {
+	fake.jWKSMutex.RLock()
+	defer fake.jWKSMutex.RUnlock()
+	return len(fake.jWKSArgsForCall)
+}
+
+func (fake *FakeJWTHandler) JWKSCalls(stub func(*gin.Context)) {
+	fake.jWKSMutex.Lock()
+	defer fake.jWKSMutex.Unlock()
+	fake.JWKSStub = stub
+}
+
+func (fake *FakeJWTHandler) JWKSArgsForCall(i int) *gin.Context {
+	fake.jWKSMutex.RLock()
+	defer fake.jWKSMutex.RUnlock()
+	argsForCall := fake.jWKSArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeJWTHandler) RefreshToken(arg1 *gin.Context) {
+	fake.refreshTokenMutex.Lock()
+	fake.refreshTokenArgsForCall = append(fake.refreshTokenArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.RefreshTokenStub
+	fake.recordInvocation("RefreshToken", []interface{}{arg1})
+	fake.refreshTokenMutex.Unlock()
+	if stub != nil {
+		fake.RefreshTokenStub(arg1)
+	}
+}
+
+func (fake *FakeJWTHandler) RefreshTokenCallCount() int {
+	fake.refreshTokenMutex.RLock()
+	defer fake.refreshTokenMutex.RUnlock()
+	return len(fake.refreshTokenArgsForCall)
+}
+
+func (fake *FakeJWTHandler) RefreshTokenCalls(stub func(*gin.Context)) {
+	fake.refreshTokenMutex.Lock()
+	defer fake.refreshTokenMutex.Unlock()
+	fake.RefreshTokenStub = stub
+}
+
+func (fake *FakeJWTHandler) RefreshTokenArgsForCall(i int) *gin.Context {
+	fake.refreshTokenMutex.RLock()
+	defer fake.refreshTokenMutex.RUnlock()
+	argsForCall := fake.refreshTokenArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeJWTHandler) RevokeToken(arg1 *gin.Context) {
+	fake.revokeTokenMutex.Lock()
+	fake.revokeTokenArgsForCall = append(fake.revokeTokenArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.RevokeTokenStub
+	fake.recordInvocation("RevokeToken", []interface{}{arg1})
+	fake.revokeTokenMutex.Unlock()
+	if stub != nil {
+		fake.RevokeTokenStub(arg1)
+	}
+}
+
+func (fake *FakeJWTHandler) RevokeTokenCallCount() int {
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	return len(fake.revokeTokenArgsForCall)
+}
+
+func (fake *FakeJWTHandler) RevokeTokenCalls(stub func(*gin.Context)) {
+	fake.revokeTokenMutex.Lock()
+	defer fake.revokeTokenMutex.Unlock()
+	fake.RevokeTokenStub = stub
+}
+
+func (fake *FakeJWTHandler) RevokeTokenArgsForCall(i int) *gin.Context {
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	argsForCall := fake.revokeTokenArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeJWTHandler) RotateSigningKey(arg1 *gin.Context) {
+	fake.rotateSigningKeyMutex.Lock()
+	fake.rotateSigningKeyArgsForCall = append(fake.rotateSigningKeyArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.RotateSigningKeyStub
+	fake.recordInvocation("RotateSigningKey", []interface{}{arg1})
+	fake.rotateSigningKeyMutex.Unlock()
+	if stub != nil {
+		fake.RotateSigningKeyStub(arg1)
+	}
+}
+
+func (fake *FakeJWTHandler) RotateSigningKeyCallCount() int {
+	fake.rotateSigningKeyMutex.RLock()
+	defer fake.rotateSigningKeyMutex.RUnlock()
+	return len(fake.rotateSigningKeyArgsForCall)
+}
+
+func (fake *FakeJWTHandler) RotateSigningKeyCalls(stub func(*gin.Context)) {
+	fake.rotateSigningKeyMutex.Lock()
+	defer fake.rotateSigningKeyMutex.Unlock()
+	fake.RotateSigningKeyStub = stub
+}
+
+func (fake *FakeJWTHandler) RotateSigningKeyArgsForCall(i int) *gin.Context {
+	fake.rotateSigningKeyMutex.RLock()
+	defer fake.rotateSigningKeyMutex.RUnlock()
+	argsForCall := fake.rotateSigningKeyArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeJWTHandler) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.generateTokenMutex.RLock()
 	defer fake.generateTokenMutex.RUnlock()
+	fake.introspectTokenMutex.RLock()
+	defer fake.introspectTokenMutex.RUnlock()
+	fake.jWKSMutex.RLock()
+	defer fake.jWKSMutex.RUnlock()
+	fake.refreshTokenMutex.RLock()
+	defer fake.refreshTokenMutex.RUnlock()
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	fake.rotateSigningKeyMutex.RLock()
+	defer fake.rotateSigningKeyMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,155 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
+)
+
+type FakeMessageHandler struct {
+	ProduceStub        func(*gin.Context)
+	produceMutex       sync.RWMutex
+	produceArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	ProduceBatchStub        func(*gin.Context)
+	produceBatchMutex       sync.RWMutex
+	produceBatchArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	StatusStub        func(*gin.Context)
+	statusMutex       sync.RWMutex
+	statusArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeMessageHandler) Produce(arg1 *gin.Context) {
+	fake.produceMutex.Lock()
+	fake.produceArgsForCall = append(fake.produceArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.ProduceStub
+	fake.recordInvocation("Produce", []interface{}{arg1})
+	fake.produceMutex.Unlock()
+	if stub != nil {
+		fake.ProduceStub(arg1)
+	}
+}
+
+func (fake *FakeMessageHandler) ProduceCallCount() int {
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	return len(fake.produceArgsForCall)
+}
+
+func (fake *FakeMessageHandler) ProduceCalls(stub func(*gin.Context)) {
+	fake.produceMutex.Lock()
+	defer fake.produceMutex.Unlock()
+	fake.ProduceStub = stub
+}
+
+func (fake *FakeMessageHandler) ProduceArgsForCall(i int) *gin.Context {
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	argsForCall := fake.produceArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeMessageHandler) ProduceBatch(arg1 *gin.Context) {
+	fake.produceBatchMutex.Lock()
+	fake.produceBatchArgsForCall = append(fake.produceBatchArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.ProduceBatchStub
+	fake.recordInvocation("ProduceBatch", []interface{}{arg1})
+	fake.produceBatchMutex.Unlock()
+	if stub != nil {
+		fake.ProduceBatchStub(arg1)
+	}
+}
+
+func (fake *FakeMessageHandler) ProduceBatchCallCount() int {
+	fake.produceBatchMutex.RLock()
+	defer fake.produceBatchMutex.RUnlock()
+	return len(fake.produceBatchArgsForCall)
+}
+
+func (fake *FakeMessageHandler) ProduceBatchCalls(stub func(*gin.Context)) {
+	fake.produceBatchMutex.Lock()
+	defer fake.produceBatchMutex.Unlock()
+	fake.ProduceBatchStub = stub
+}
+
+func (fake *FakeMessageHandler) ProduceBatchArgsForCall(i int) *gin.Context {
+	fake.produceBatchMutex.RLock()
+	defer fake.produceBatchMutex.RUnlock()
+	argsForCall := fake.produceBatchArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeMessageHandler) Status(arg1 *gin.Context) {
+	fake.statusMutex.Lock()
+	fake.statusArgsForCall = append(fake.statusArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.StatusStub
+	fake.recordInvocation("Status", []interface{}{arg1})
+	fake.statusMutex.Unlock()
+	if stub != nil {
+		fake.StatusStub(arg1)
+	}
+}
+
+func (fake *FakeMessageHandler) StatusCallCount() int {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	return len(fake.statusArgsForCall)
+}
+
+func (fake *FakeMessageHandler) StatusCalls(stub func(*gin.Context)) {
+	fake.statusMutex.Lock()
+	defer fake.statusMutex.Unlock()
+	fake.StatusStub = stub
+}
+
+func (fake *FakeMessageHandler) StatusArgsForCall(i int) *gin.Context {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	argsForCall := fake.statusArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeMessageHandler) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	fake.produceBatchMutex.RLock()
+	defer fake.produceBatchMutex.RUnlock()
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeMessageHandler) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.MessageHandler = new(FakeMessageHandler)
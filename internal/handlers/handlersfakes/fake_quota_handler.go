@@ -0,0 +1,116 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
+)
+
+type FakeQuotaHandler struct {
+	ResetStub        func(*gin.Context)
+	resetMutex       sync.RWMutex
+	resetArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	UsageStub        func(*gin.Context)
+	usageMutex       sync.RWMutex
+	usageArgsForCall []struct {
+		arg1 *gin.Context
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeQuotaHandler) Reset(arg1 *gin.Context) {
+	fake.resetMutex.Lock()
+	fake.resetArgsForCall = append(fake.resetArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.ResetStub
+	fake.recordInvocation("Reset", []interface{}{arg1})
+	fake.resetMutex.Unlock()
+	if stub != nil {
+		fake.ResetStub(arg1)
+	}
+}
+
+func (fake *FakeQuotaHandler) ResetCallCount() int {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	return len(fake.resetArgsForCall)
+}
+
+func (fake *FakeQuotaHandler) ResetCalls(stub func(*gin.Context)) {
+	fake.resetMutex.Lock()
+	defer fake.resetMutex.Unlock()
+	fake.ResetStub = stub
+}
+
+func (fake *FakeQuotaHandler) ResetArgsForCall(i int) *gin.Context {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	argsForCall := fake.resetArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaHandler) Usage(arg1 *gin.Context) {
+	fake.usageMutex.Lock()
+	fake.usageArgsForCall = append(fake.usageArgsForCall, struct {
+		arg1 *gin.Context
+	}{arg1})
+	stub := fake.UsageStub
+	fake.recordInvocation("Usage", []interface{}{arg1})
+	fake.usageMutex.Unlock()
+	if stub != nil {
+		fake.UsageStub(arg1)
+	}
+}
+
+func (fake *FakeQuotaHandler) UsageCallCount() int {
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	return len(fake.usageArgsForCall)
+}
+
+func (fake *FakeQuotaHandler) UsageCalls(stub func(*gin.Context)) {
+	fake.usageMutex.Lock()
+	defer fake.usageMutex.Unlock()
+	fake.UsageStub = stub
+}
+
+func (fake *FakeQuotaHandler) UsageArgsForCall(i int) *gin.Context {
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	argsForCall := fake.usageArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaHandler) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeQuotaHandler) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.QuotaHandler = new(FakeQuotaHandler)
@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // JWTHandler is the interface that provides JWT handling methods.
@@ -11,35 +18,310 @@ import (
 //counterfeiter:generate . JWTHandler
 type JWTHandler interface {
 	GenerateToken(c *gin.Context)
+	RefreshToken(c *gin.Context)
+	RevokeToken(c *gin.Context)
+	IntrospectToken(c *gin.Context)
+	JWKS(c *gin.Context)
+	RotateSigningKey(c *gin.Context)
 }
 
 type jwtHandler struct {
-	jwtService services.JWTService
+	jwtService     services.JWTService
+	refreshService services.RefreshTokenService
+	clients        services.ClientAuthenticator
+}
+
+// tokenResponse is the body returned when tokens are issued (RFC 6749 section 5.1).
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty"`
 }
 
 // NewJWTHandler creates a new JWTHandler.
-func NewJWTHandler(jwtService services.JWTService) JWTHandler {
+func NewJWTHandler(jwtService services.JWTService, refreshService services.RefreshTokenService, clients services.ClientAuthenticator) JWTHandler {
 	return &jwtHandler{
-		jwtService: jwtService,
+		jwtService:     jwtService,
+		refreshService: refreshService,
+		clients:        clients,
 	}
 }
 
-// GenerateToken generates a JWT token.
+// GenerateToken issues a JWT token to a registered client using the OAuth2
+// client_credentials grant. Clients authenticate with HTTP Basic or with
+// client_id and client_secret in the body; the token subject is the client ID.
+// The token carries the requested scope, or every client scope when omitted.
 // Params: c *gin.Context - the request context
 func (h *jwtHandler) GenerateToken(c *gin.Context) {
 	var request struct {
-		UserID string `json:"user_id" binding:"required"`
+		GrantType    string `form:"grant_type" json:"grant_type" binding:"required"`
+		ClientID     string `form:"client_id" json:"client_id"`
+		ClientSecret string `form:"client_secret" json:"client_secret"`
+		Scope        string `form:"scope" json:"scope"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	if request.GrantType != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type", "error_description": "only client_credentials is supported"})
+		return
+	}
+
+	client, ok := h.authenticateClient(c, request.ClientID, request.ClientSecret)
+	if !ok {
+		return
+	}
+
+	scopes, err := client.GrantScopes(services.ParseScopes(request.Scope))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+		return
+	}
+
+	claims := services.TokenClaims{Subject: client.ID, Scopes: scopes, Tenant: client.Tenant}
+	refreshToken, err := h.refreshService.Issue(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to generate token"})
+		return
+	}
+
+	h.respondWithTokens(c, claims, refreshToken)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// Callers authenticate as the client the token was issued to, like on
+// GenerateToken, so removed clients cannot refresh (RFC 6749 section 6).
+// Each refresh token can be used once; reusing one revokes its whole family.
+// The new tokens keep the scopes the client is still granted.
+// Params: c *gin.Context - the request context
+func (h *jwtHandler) RefreshToken(c *gin.Context) {
+	var request struct {
+		RefreshToken string `form:"refresh_token" json:"refresh_token" binding:"required"`
+		ClientID     string `form:"client_id" json:"client_id"`
+		ClientSecret string `form:"client_secret" json:"client_secret"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	client, ok := h.authenticateClient(c, request.ClientID, request.ClientSecret)
+	if !ok {
+		return
+	}
+
+	record, refreshToken, err := h.refreshService.Rotate(request.RefreshToken, client.ID)
+	switch {
+	case errors.Is(err, services.ErrInvalidRefreshToken),
+		errors.Is(err, services.ErrRefreshTokenExpired),
+		errors.Is(err, services.ErrRefreshTokenReused):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to refresh token"})
+		return
+	}
+
+	claims := record.TokenClaims
+	claims.Scopes = client.RetainScopes(record.Scopes)
+	if len(claims.Scopes) == 0 && len(record.Scopes) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": "the client is no longer granted any scope of the refresh token"})
+		return
+	}
+	h.respondWithTokens(c, claims, refreshToken)
+}
+
+// RevokeToken revokes an access or refresh token (RFC 7009). Callers
+// authenticate as the client the token was issued to, like on GenerateToken.
+// Unknown, invalid and expired tokens and tokens of other clients are
+// ignored, so the response does not tell whether the token was valid.
+// Params: c *gin.Context - the request context
+func (h *jwtHandler) RevokeToken(c *gin.Context) {
+	var request struct {
+		Token        string `form:"token" json:"token" binding:"required"`
+		ClientID     string `form:"client_id" json:"client_id"`
+		ClientSecret string `form:"client_secret" json:"client_secret"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	client, ok := h.authenticateClient(c, request.ClientID, request.ClientSecret)
+	if !ok {
+		return
+	}
+
+	// access tokens are JWTs while refresh tokens are opaque, so token_type_hint is not needed
+	var err error
+	if strings.Count(request.Token, ".") == 2 {
+		if err = h.jwtService.RevokeToken(request.Token, client.ID); invalidToken(err) {
+			err = nil
+		}
+	} else {
+		err = h.refreshService.Revoke(request.Token, client.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to revoke token"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// introspectionResponse is the body returned by IntrospectToken (RFC 7662 section 2.2).
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Subject   string `json:"sub,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ID        string `json:"jti,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// IntrospectToken reports whether an access token is active and returns
+// its claims (RFC 7662), for services that cannot verify tokens themselves.
+// Callers authenticate as a registered client like on GenerateToken.
+// Params: c *gin.Context - the request context
+func (h *jwtHandler) IntrospectToken(c *gin.Context) {
+	var request struct {
+		Token        string `form:"token" json:"token" binding:"required"`
+		ClientID     string `form:"client_id" json:"client_id"`
+		ClientSecret string `form:"client_secret" json:"client_secret"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	if _, ok := h.authenticateClient(c, request.ClientID, request.ClientSecret); !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	token, err := h.jwtService.ValidateToken(request.Token)
+	if invalidToken(err) {
+		c.JSON(http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to introspect token"})
+		return
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		c.JSON(http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	response := introspectionResponse{Active: true, TokenType: "Bearer"}
+	response.Subject, _ = claims["sub"].(string)
+	response.Scope, _ = claims["scope"].(string)
+	response.Issuer, _ = claims["iss"].(string)
+	response.ID, _ = claims["jti"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		response.ExpiresAt = int64(exp)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// authenticateClient checks the HTTP Basic client credentials, falling back
+// to the ones sent in the body, and writes the error response on failure
+func (h *jwtHandler) authenticateClient(c *gin.Context, bodyClientID, bodySecret string) (*services.Client, bool) {
+	clientID, secret, ok := clientCredentials(c)
+	if !ok {
+		clientID, secret = bodyClientID, bodySecret
+	}
+	if clientID == "" {
+		abortInvalidClient(c, "missing client credentials")
+		return nil, false
+	}
+
+	client, err := h.clients.Authenticate(clientID, secret, c.ClientIP())
+	var lockedErr *services.ClientLockedError
+	switch {
+	case errors.As(err, &lockedErr):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(lockedErr.Until).Seconds()))))
+		abortInvalidClient(c, err.Error())
+		return nil, false
+	case errors.Is(err, services.ErrInvalidClient):
+		abortInvalidClient(c, err.Error())
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to authenticate client"})
+		return nil, false
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return client, true
+}
+
+// invalidToken reports whether a token error comes from the token itself
+// rather than from a failing dependency
+func invalidToken(err error) bool {
+	var serviceErr services.ServiceError
+	var validationErr *jwt.ValidationError
+	return errors.As(err, &serviceErr) || errors.As(err, &validationErr)
+}
+
+// respondWithTokens issues an access token with the claims and writes it
+// along with the refresh token
+func (h *jwtHandler) respondWithTokens(c *gin.Context, claims services.TokenClaims, refreshToken string) {
+	token, err := h.jwtService.GenerateToken(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to generate token"})
 		return
 	}
 
-	token, err := h.jwtService.GenerateToken(request.UserID)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.jwtService.TokenTTL().Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(claims.Scopes, " "),
+	})
+}
+
+// JWKS publishes the public keys that verify issued tokens.
+// Params: c *gin.Context - the request context
+func (h *jwtHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
+
+// RotateSigningKey replaces the signing key; tokens signed by the previous
+// key stay valid until the keyring overlap elapses.
+// Params: c *gin.Context - the request context
+func (h *jwtHandler) RotateSigningKey(c *gin.Context) {
+	kid, err := h.jwtService.RotateSigningKey()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to rotate signing key"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, gin.H{"kid": kid})
+}
+
+// clientCredentials reads HTTP Basic client credentials, which are form
+// encoded before being base64 encoded (RFC 6749 section 2.3.1)
+func clientCredentials(c *gin.Context) (string, string, bool) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err := url.QueryUnescape(password)
+	if err != nil {
+		return "", "", false
+	}
+	return clientID, secret, true
+}
+
+// abortInvalidClient writes an RFC 6749 invalid_client error
+func abortInvalidClient(c *gin.Context, description string) {
+	c.Header("WWW-Authenticate", `Basic realm="msg-receiver"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": description})
 }
@@ -4,23 +4,32 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
 	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewJWTHandler(t *testing.T) {
 	jwtFakeService := &servicesfakes.FakeJWTService{}
-	handler := NewJWTHandler(jwtFakeService)
+	handler := NewJWTHandler(jwtFakeService, &servicesfakes.FakeRefreshTokenService{}, &servicesfakes.FakeClientAuthenticator{})
 	assert.NotNil(t, handler)
 }
 
 func TestGenerateToken(t *testing.T) {
+	authenticated := func(clientID, secret, sourceIP string) (*services.Client, error) {
+		return &services.Client{ID: clientID, Scopes: []string{"produce:orders", "produce:payments.*"}}, nil
+	}
 	testCases := []struct {
 		name               string
 		requestBody        map[string]string
+		basicAuth          []string
+		authenticate       func(clientID, secret, sourceIP string) (*services.Client, error)
 		jwtService         *servicesfakes.FakeJWTService
 		expectedStatusCode int
 		assert             func(t *testing.T, w *httptest.ResponseRecorder)
@@ -28,19 +37,77 @@ func TestGenerateToken(t *testing.T) {
 		{
 			name: "should success and retrieve token",
 			requestBody: map[string]string{
-				"user_id": "1",
+				"grant_type":    "client_credentials",
+				"client_id":     "orders",
+				"client_secret": "secret",
 			},
+			authenticate: authenticated,
 			jwtService: &servicesfakes.FakeJWTService{
-				GenerateTokenStub: func(userID string) (string, error) {
-					return "token", nil
+				GenerateTokenStub: func(claims services.TokenClaims) (string, error) {
+					return "token-" + claims.Subject, nil
+				},
+				TokenTTLStub: func() time.Duration {
+					return 15 * time.Minute
 				},
 			},
 			expectedStatusCode: http.StatusOK,
 			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]string
+				var response map[string]interface{}
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.Nil(t, err)
-				assert.Equal(t, "token", response["token"])
+				assert.Equal(t, "token-orders", response["access_token"])
+				assert.Equal(t, "Bearer", response["token_type"])
+				assert.Equal(t, float64(900), response["expires_in"])
+				assert.Equal(t, "refresh-token", response["refresh_token"])
+				assert.Equal(t, "produce:orders produce:payments.*", response["scope"])
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			},
+		}, {
+			name: "should grant the requested scopes",
+			requestBody: map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "orders",
+				"client_secret": "secret",
+				"scope":         "produce:payments.eu",
+			},
+			authenticate: authenticated,
+			jwtService: &servicesfakes.FakeJWTService{
+				GenerateTokenStub: func(claims services.TokenClaims) (string, error) {
+					return strings.Join(claims.Scopes, ","), nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"access_token":"produce:payments.eu"`)
+				assert.Contains(t, w.Body.String(), `"scope":"produce:payments.eu"`)
+			},
+		}, {
+			name: "Should return status code 400 when a scope is not granted to the client",
+			requestBody: map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "orders",
+				"client_secret": "secret",
+				"scope":         "produce:billing",
+			},
+			authenticate:       authenticated,
+			jwtService:         &servicesfakes.FakeJWTService{},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_scope"`)
+			},
+		}, {
+			name:         "should authenticate clients with HTTP Basic",
+			requestBody:  map[string]string{"grant_type": "client_credentials"},
+			basicAuth:    []string{"orders%3Aeu", "s%26cret"},
+			authenticate: authenticated,
+			jwtService: &servicesfakes.FakeJWTService{
+				GenerateTokenStub: func(claims services.TokenClaims) (string, error) {
+					return "token-" + claims.Subject, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"access_token":"token-orders:eu"`)
 			},
 		}, {
 			name:               "Should return status code 400 when request body is empty",
@@ -51,16 +118,58 @@ func TestGenerateToken(t *testing.T) {
 				var response map[string]string
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.Nil(t, err)
-				assert.Containsf(t, response, "error", "response should contain error message")
-
+				assert.Equal(t, "invalid_request", response["error"])
+			},
+		}, {
+			name:               "Should return status code 400 when grant type is not supported",
+			requestBody:        map[string]string{"grant_type": "password", "client_id": "orders"},
+			jwtService:         &servicesfakes.FakeJWTService{},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"unsupported_grant_type"`)
+			},
+		}, {
+			name:               "Should return status code 401 when client credentials are missing",
+			requestBody:        map[string]string{"grant_type": "client_credentials"},
+			jwtService:         &servicesfakes.FakeJWTService{},
+			expectedStatusCode: http.StatusUnauthorized,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+			},
+		}, {
+			name:        "Should return status code 401 when client secret is wrong",
+			requestBody: map[string]string{"grant_type": "client_credentials", "client_id": "orders", "client_secret": "wrong"},
+			authenticate: func(clientID, secret, sourceIP string) (*services.Client, error) {
+				return nil, services.ErrInvalidClient
+			},
+			jwtService:         &servicesfakes.FakeJWTService{},
+			expectedStatusCode: http.StatusUnauthorized,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"error":"invalid_client","error_description":"client authentication failed"}`, w.Body.String())
+			},
+		}, {
+			name:        "Should return status code 401 and Retry-After when client is locked",
+			requestBody: map[string]string{"grant_type": "client_credentials", "client_id": "orders", "client_secret": "secret"},
+			authenticate: func(clientID, secret, sourceIP string) (*services.Client, error) {
+				return nil, &services.ClientLockedError{Until: time.Now().Add(time.Minute)}
+			},
+			jwtService:         &servicesfakes.FakeJWTService{},
+			expectedStatusCode: http.StatusUnauthorized,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+				assert.Equal(t, "60", w.Header().Get("Retry-After"))
 			},
 		}, {
 			name: "Should return status code 500 when jwt service fails",
 			requestBody: map[string]string{
-				"user_id": "1",
+				"grant_type":    "client_credentials",
+				"client_id":     "orders",
+				"client_secret": "secret",
 			},
+			authenticate: authenticated,
 			jwtService: &servicesfakes.FakeJWTService{
-				GenerateTokenStub: func(userID string) (string, error) {
+				GenerateTokenStub: func(services.TokenClaims) (string, error) {
 					return "", assert.AnError
 				},
 			},
@@ -82,8 +191,14 @@ func TestGenerateToken(t *testing.T) {
 			jsonValue, _ := json.Marshal(tc.requestBody)
 			c.Request = httptest.NewRequest(http.MethodPost, "/v1/token", bytes.NewBuffer(jsonValue))
 			c.Request.Header.Set("Content-Type", "application/json")
+			if tc.basicAuth != nil {
+				c.Request.SetBasicAuth(tc.basicAuth[0], tc.basicAuth[1])
+			}
 
-			handler := NewJWTHandler(tc.jwtService)
+			refreshService := &servicesfakes.FakeRefreshTokenService{}
+			refreshService.IssueReturns("refresh-token", nil)
+			clients := &servicesfakes.FakeClientAuthenticator{AuthenticateStub: tc.authenticate}
+			handler := NewJWTHandler(tc.jwtService, refreshService, clients)
 
 			handler.GenerateToken(c)
 			assert.Equal(t, tc.expectedStatusCode, w.Code)
@@ -91,3 +206,373 @@ func TestGenerateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshToken(t *testing.T) {
+	testCases := []struct {
+		name               string
+		requestBody        string
+		contentType        string
+		rotate             func(string, string) (*services.RefreshToken, string, error)
+		noCredentials      bool
+		expectedStatusCode int
+		assert             func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "should return a new token pair",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return &services.RefreshToken{TokenClaims: services.TokenClaims{Subject: "user-1", Scopes: []string{"produce:orders"}}}, "new", nil
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"access_token":"token","token_type":"Bearer","expires_in":900,"refresh_token":"new","scope":"produce:orders"}`, w.Body.String())
+			},
+		}, {
+			name:        "should accept a form encoded request",
+			requestBody: "refresh_token=old",
+			contentType: "application/x-www-form-urlencoded",
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				assert.Equal(t, "old", refreshToken)
+				return &services.RefreshToken{TokenClaims: services.TokenClaims{Subject: "user-1", Scopes: []string{"produce:orders"}}}, "new", nil
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"refresh_token":"new"`)
+			},
+		}, {
+			name:        "should drop the scopes no longer granted to the client",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return &services.RefreshToken{TokenClaims: services.TokenClaims{Subject: "user-1", Scopes: []string{"produce:orders", "produce:legacy"}}}, "new", nil
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"scope":"produce:orders"`)
+			},
+		}, {
+			name:        "should return invalid_scope when no scope is granted anymore",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return &services.RefreshToken{TokenClaims: services.TokenClaims{Subject: "user-1", Scopes: []string{"produce:legacy"}}}, "new", nil
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_scope"`)
+			},
+		}, {
+			name:               "should return status code 401 without client credentials",
+			requestBody:        `{"refresh_token":"old"}`,
+			noCredentials:      true,
+			expectedStatusCode: http.StatusUnauthorized,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+			},
+		}, {
+			name:               "should return status code 400 when refresh token is missing",
+			requestBody:        `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+			},
+		}, {
+			name:        "should return invalid_grant when refresh token was reused",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return nil, "", services.ErrRefreshTokenReused
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"error":"invalid_grant","error_description":"refresh token was already used, the session has been revoked"}`, w.Body.String())
+			},
+		}, {
+			name:        "should return invalid_grant when refresh token is expired",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return nil, "", services.ErrRefreshTokenExpired
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+			},
+		}, {
+			name:        "should return status code 500 when the store fails",
+			requestBody: `{"refresh_token":"old"}`,
+			rotate: func(refreshToken, clientID string) (*services.RefreshToken, string, error) {
+				return nil, "", assert.AnError
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.JSONEq(t, `{"error":"server_error","error_description":"failed to refresh token"}`, w.Body.String())
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBufferString(tc.requestBody))
+			c.Request.Header.Set("Content-Type", "application/json")
+			if tc.contentType != "" {
+				c.Request.Header.Set("Content-Type", tc.contentType)
+			}
+			if !tc.noCredentials {
+				c.Request.SetBasicAuth("user-1", "secret")
+			}
+
+			jwtService := &servicesfakes.FakeJWTService{}
+			jwtService.GenerateTokenReturns("token", nil)
+			jwtService.TokenTTLReturns(15 * time.Minute)
+			refreshService := &servicesfakes.FakeRefreshTokenService{RotateStub: tc.rotate}
+			clients := &servicesfakes.FakeClientAuthenticator{}
+			clients.AuthenticateReturns(&services.Client{ID: "user-1", Scopes: []string{"produce:orders", "produce:billing"}}, nil)
+			handler := NewJWTHandler(jwtService, refreshService, clients)
+
+			handler.RefreshToken(c)
+			if refreshService.RotateCallCount() > 0 {
+				_, clientID := refreshService.RotateArgsForCall(0)
+				assert.Equal(t, "user-1", clientID)
+			}
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			tc.assert(t, w)
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	testCases := []struct {
+		name               string
+		requestBody        string
+		revokeAccess       error
+		revokeRefresh      error
+		noCredentials      bool
+		expectedStatusCode int
+		assertion          func(t *testing.T, jwtService *servicesfakes.FakeJWTService, refreshService *servicesfakes.FakeRefreshTokenService)
+	}{
+		{
+			name:               "should revoke an access token",
+			requestBody:        "token=a.b.c",
+			expectedStatusCode: http.StatusOK,
+			assertion: func(t *testing.T, jwtService *servicesfakes.FakeJWTService, refreshService *servicesfakes.FakeRefreshTokenService) {
+				assert.Equal(t, 1, jwtService.RevokeTokenCallCount())
+				token, clientID := jwtService.RevokeTokenArgsForCall(0)
+				assert.Equal(t, "a.b.c", token)
+				assert.Equal(t, "orders", clientID)
+				assert.Equal(t, 0, refreshService.RevokeCallCount())
+			},
+		}, {
+			name:               "should revoke a refresh token",
+			requestBody:        "token=opaque&token_type_hint=refresh_token",
+			expectedStatusCode: http.StatusOK,
+			assertion: func(t *testing.T, jwtService *servicesfakes.FakeJWTService, refreshService *servicesfakes.FakeRefreshTokenService) {
+				assert.Equal(t, 0, jwtService.RevokeTokenCallCount())
+				token, clientID := refreshService.RevokeArgsForCall(0)
+				assert.Equal(t, "opaque", token)
+				assert.Equal(t, "orders", clientID)
+			},
+		}, {
+			name:               "should ignore invalid access tokens",
+			requestBody:        "token=a.b.c",
+			revokeAccess:       services.ErrInvalidIssuer,
+			expectedStatusCode: http.StatusOK,
+		}, {
+			name:               "should ignore access tokens with an invalid signature",
+			requestBody:        "token=a.b.c",
+			revokeAccess:       &jwt.ValidationError{Errors: jwt.ValidationErrorSignatureInvalid},
+			expectedStatusCode: http.StatusOK,
+		}, {
+			name:               "should return status code 400 when token is missing",
+			requestBody:        "token_type_hint=access_token",
+			expectedStatusCode: http.StatusBadRequest,
+		}, {
+			name:               "should return status code 401 without client credentials",
+			requestBody:        "token=a.b.c",
+			noCredentials:      true,
+			expectedStatusCode: http.StatusUnauthorized,
+			assertion: func(t *testing.T, jwtService *servicesfakes.FakeJWTService, refreshService *servicesfakes.FakeRefreshTokenService) {
+				assert.Equal(t, 0, jwtService.RevokeTokenCallCount())
+			},
+		}, {
+			name:               "should return status code 503 when the revocation store fails",
+			requestBody:        "token=a.b.c",
+			revokeAccess:       assert.AnError,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		}, {
+			name:               "should return status code 503 when the refresh token store fails",
+			requestBody:        "token=opaque",
+			revokeRefresh:      assert.AnError,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/token/revoke", bytes.NewBufferString(tc.requestBody))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if !tc.noCredentials {
+				c.Request.SetBasicAuth("orders", "secret")
+			}
+
+			jwtService := &servicesfakes.FakeJWTService{}
+			jwtService.RevokeTokenReturns(tc.revokeAccess)
+			refreshService := &servicesfakes.FakeRefreshTokenService{}
+			refreshService.RevokeReturns(tc.revokeRefresh)
+			clients := &servicesfakes.FakeClientAuthenticator{}
+			clients.AuthenticateReturns(&services.Client{ID: "orders"}, nil)
+			handler := NewJWTHandler(jwtService, refreshService, clients)
+
+			handler.RevokeToken(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.assertion != nil {
+				tc.assertion(t, jwtService, refreshService)
+			}
+		})
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	activeToken := &jwt.Token{Valid: true, Claims: jwt.MapClaims{
+		"sub": "orders", "scope": "produce:orders", "exp": float64(1700000000), "iss": "issuer", "jti": "id-1",
+	}}
+	authenticated := func(clientID, secret, sourceIP string) (*services.Client, error) {
+		return &services.Client{ID: clientID}, nil
+	}
+	testCases := []struct {
+		name               string
+		requestBody        string
+		authenticate       func(clientID, secret, sourceIP string) (*services.Client, error)
+		validateToken      func(string) (*jwt.Token, error)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "should return the claims of an active token",
+			requestBody:        "token=a.b.c&client_id=legacy&client_secret=secret",
+			authenticate:       authenticated,
+			validateToken:      func(string) (*jwt.Token, error) { return activeToken, nil },
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"active":true,"sub":"orders","scope":"produce:orders","exp":1700000000,"iss":"issuer","jti":"id-1","token_type":"Bearer"}`,
+		}, {
+			name:               "should report revoked tokens as inactive",
+			requestBody:        "token=a.b.c&client_id=legacy&client_secret=secret",
+			authenticate:       authenticated,
+			validateToken:      func(string) (*jwt.Token, error) { return nil, services.ErrTokenRevoked },
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"active":false}`,
+		}, {
+			name:         "should report malformed tokens as inactive",
+			requestBody:  "token=garbage&client_id=legacy&client_secret=secret",
+			authenticate: authenticated,
+			validateToken: func(string) (*jwt.Token, error) {
+				return nil, &jwt.ValidationError{Errors: jwt.ValidationErrorMalformed}
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"active":false}`,
+		}, {
+			name:               "should return status code 503 when validation cannot complete",
+			requestBody:        "token=a.b.c&client_id=legacy&client_secret=secret",
+			authenticate:       authenticated,
+			validateToken:      func(string) (*jwt.Token, error) { return nil, assert.AnError },
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `{"error":"temporarily_unavailable","error_description":"failed to introspect token"}`,
+		}, {
+			name:               "should return status code 401 without client credentials",
+			requestBody:        "token=a.b.c",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       `{"error":"invalid_client","error_description":"missing client credentials"}`,
+		}, {
+			name:        "should return status code 401 when client authentication fails",
+			requestBody: "token=a.b.c&client_id=legacy&client_secret=wrong",
+			authenticate: func(clientID, secret, sourceIP string) (*services.Client, error) {
+				return nil, services.ErrInvalidClient
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       `{"error":"invalid_client","error_description":"client authentication failed"}`,
+		}, {
+			name:               "should return status code 400 when token is missing",
+			requestBody:        "client_id=legacy&client_secret=secret",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid_request","error_description":"Key: 'Token' Error:Field validation for 'Token' failed on the 'required' tag"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/token/introspect", bytes.NewBufferString(tc.requestBody))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			jwtService := &servicesfakes.FakeJWTService{ValidateTokenStub: tc.validateToken}
+			clients := &servicesfakes.FakeClientAuthenticator{AuthenticateStub: tc.authenticate}
+			handler := NewJWTHandler(jwtService, &servicesfakes.FakeRefreshTokenService{}, clients)
+
+			handler.IntrospectToken(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			if tc.validateToken == nil {
+				assert.Equal(t, 0, jwtService.ValidateTokenCallCount())
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+
+	jwtService := &servicesfakes.FakeJWTService{
+		JWKSStub: func() services.JWKS {
+			return services.JWKS{Keys: []services.JWK{{KeyType: "OKP", KeyID: "kid-1", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519", X: "abc"}}}
+		},
+	}
+	handler := NewJWTHandler(jwtService, &servicesfakes.FakeRefreshTokenService{}, &servicesfakes.FakeClientAuthenticator{})
+
+	handler.JWKS(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age")
+
+	var response services.JWKS
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "kid-1", response.Keys[0].KeyID)
+	assert.Equal(t, "Ed25519", response.Keys[0].Curve)
+}
+
+func TestRotateSigningKey(t *testing.T) {
+	testCases := []struct {
+		name               string
+		rotate             func() (string, error)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "should return the new key id",
+			rotate:             func() (string, error) { return "kid-2", nil },
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"kid":"kid-2"}`,
+		}, {
+			name:               "should return status code 500 when rotation fails",
+			rotate:             func() (string, error) { return "", assert.AnError },
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error":"server_error","error_description":"failed to rotate signing key"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/admin/keys/rotate", nil)
+
+			handler := NewJWTHandler(&servicesfakes.FakeJWTService{RotateSigningKeyStub: tc.rotate}, &servicesfakes.FakeRefreshTokenService{}, &servicesfakes.FakeClientAuthenticator{})
+
+			handler.RotateSigningKey(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
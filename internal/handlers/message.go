@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MessageHandler is the interface that provides message handling methods.
+//
+//counterfeiter:generate . MessageHandler
+type MessageHandler interface {
+	Produce(c *gin.Context)
+	ProduceBatch(c *gin.Context)
+	Status(c *gin.Context)
+}
+
+// MessageHandlerConfig bounds the batches of ProduceBatch
+type MessageHandlerConfig struct {
+	// Spool, when set, stores messages on disk and answers 202 instead of
+	// waiting for the broker
+	Spool services.Spool
+	// Statuses, when set, serves the status of the spooled messages
+	Statuses services.MessageStatusStore
+	// MaxBatchMessages caps the messages of a batch, 1000 when zero
+	MaxBatchMessages int
+	// MaxBatchBytes caps the size of a batch request body, 5 MiB when zero
+	MaxBatchBytes int64
+	// BatchConcurrency caps the messages of a batch produced at once, 16 when zero
+	BatchConcurrency int
+	// BatchTimeout bounds the produce calls of a batch, 45 seconds when
+	// zero; messages not produced by then fail with 504
+	BatchTimeout time.Duration
+}
+
+type messageHandler struct {
+	producer services.Producer
+	quotas   services.QuotaService
+	cfg      MessageHandlerConfig
+}
+
+type produceRequest struct {
+	Topic   string            `json:"topic" binding:"required"`
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers"`
+	Value   json.RawMessage   `json:"value" binding:"required"`
+}
+
+// NewMessageHandler creates a new MessageHandler. Messages are counted
+// against the tenant quotas unless quotas is nil.
+func NewMessageHandler(producer services.Producer, quotas services.QuotaService, cfg MessageHandlerConfig) MessageHandler {
+	if cfg.MaxBatchMessages <= 0 {
+		cfg.MaxBatchMessages = 1000
+	}
+	if cfg.MaxBatchBytes <= 0 {
+		cfg.MaxBatchBytes = 5 << 20
+	}
+	if cfg.BatchConcurrency <= 0 {
+		cfg.BatchConcurrency = 16
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = 45 * time.Second
+	}
+	return &messageHandler{
+		producer: producer,
+		quotas:   quotas,
+		cfg:      cfg,
+	}
+}
+
+// Produce publishes a client message to the requested topic, once it fits
+// in the caller tenant quotas. With a spool the message is accepted with
+// 202 once it is on disk, and produced in the background.
+// Params: c *gin.Context - the request context
+func (h *messageHandler) Produce(c *gin.Context) {
+	var request produceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	var key []byte
+	if request.Key != "" {
+		key = []byte(request.Key)
+	}
+	value := decodeValue(request.Value)
+	if h.cfg.Spool != nil {
+		if err := checkSpooledTopic(c, request.Topic); err != nil {
+			writeProduceError(c, err)
+			return
+		}
+	}
+	reservation, ok := reserveQuota(c, h.quotas, 1, int64(len(value)))
+	if !ok {
+		return
+	}
+	if h.cfg.Spool != nil {
+		ids, err := h.cfg.Spool.Accept([]services.SpooledMessage{{
+			Topic:   request.Topic,
+			Key:     key,
+			Headers: toHeaders(request.Headers),
+			Value:   value,
+			Tenant:  services.TenantFromContext(c.Request.Context()),
+		}})
+		if err != nil {
+			releaseQuota(h.quotas, reservation)
+			writeProduceError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"id": ids[0], "status": "queued"})
+		return
+	}
+	result, err := h.producer.Produce(c.Request.Context(), request.Topic, key, toHeaders(request.Headers), value)
+	if err != nil {
+		releaseQuota(h.quotas, reservation)
+		writeProduceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// checkSpooledTopic runs the checks the producer would, before a message
+// is spooled rather than produced
+func checkSpooledTopic(c *gin.Context, topic string) error {
+	if err := services.ValidateTopic(topic); err != nil {
+		return err
+	}
+	return services.AuthorizeTopic(c.Request.Context(), topic)
+}
+
+// decodeValue returns the payload bytes: JSON strings are unquoted, anything else is kept as raw JSON
+func decodeValue(raw json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// toHeaders converts the request headers into a slice ordered by key
+func toHeaders(headers map[string]string) []services.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]services.Header, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, services.Header{Key: k, Value: []byte(headers[k])})
+	}
+	return result
+}
+
+// writeProduceError answers a failed produce call; an open circuit breaker
+// also tells when to try again
+func writeProduceError(c *gin.Context, err error) {
+	if services.Unwritten(err) {
+		middleware.MarkNothingWritten(c)
+	}
+	var openErr *services.CircuitOpenError
+	if errors.As(err, &openErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+	}
+	c.JSON(produceErrorStatus(err), gin.H{"error": produceErrorCode(err), "error_description": err.Error()})
+}
+
+// produceErrorStatus maps a producer error to an HTTP status code
+func produceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTopic):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTopicForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUnknownTopic):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrProducerClosed), errors.Is(err, services.ErrSpoolUnavailable), errors.Is(err, services.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// produceErrorCode maps a producer error to the error code of the response
+func produceErrorCode(err error) string {
+	switch {
+	case errors.Is(err, services.ErrInvalidTopic):
+		return "invalid_topic"
+	case errors.Is(err, services.ErrTopicForbidden):
+		return "insufficient_scope"
+	case errors.Is(err, services.ErrUnknownTopic):
+		return "unknown_topic"
+	case errors.Is(err, services.ErrMessageTooLarge):
+		return "message_too_large"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, services.ErrProducerClosed), errors.Is(err, services.ErrSpoolUnavailable), errors.Is(err, services.ErrCircuitOpen):
+		return "temporarily_unavailable"
+	default:
+		return "broker_error"
+	}
+}
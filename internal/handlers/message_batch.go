@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// batchItemResult is the outcome of one message of a batch
+type batchItemResult struct {
+	Index  int                      `json:"index"`
+	Status int                      `json:"status"`
+	ID     string                   `json:"id,omitempty"`
+	Result *services.DeliveryResult `json:"result,omitempty"`
+	// Error and ErrorDescription tell why a message failed, in the shape
+	// of the error responses
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// batchResponse lists the outcome of every message of a batch, in request order
+type batchResponse struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []batchItemResult `json:"results"`
+}
+
+// batchItem is a message of a batch once parsed; err is set when the
+// message is invalid and will not be produced, with status when it is
+// not a bad request
+type batchItem struct {
+	request produceRequest
+	value   []byte
+	err     error
+	status  int
+}
+
+// ProduceBatch publishes a JSON array, or NDJSON stream, of messages
+// concurrently and reports the status of each one. Invalid messages are
+// reported without failing the others; the answer is 200 when every
+// message is produced and 207 otherwise. With a spool the valid messages
+// are accepted together and the answer is 202 when every one is.
+// Params: c *gin.Context - the request context
+func (h *messageHandler) ProduceBatch(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.cfg.MaxBatchBytes)
+	var items []batchItem
+	var err error
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/ndjson":
+		items, err = h.readNDJSONBatch(c.Request.Body)
+	default:
+		items, err = h.readJSONBatch(c.Request.Body)
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "batch_too_large", "error_description": fmt.Sprintf("batch is larger than %d bytes", h.cfg.MaxBatchBytes)})
+		return
+	case errors.Is(err, errBatchTooLong):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "batch_too_large", "error_description": fmt.Sprintf("batch has more than %d messages", h.cfg.MaxBatchMessages)})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	case len(items) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "batch has no messages"})
+		return
+	}
+
+	if h.cfg.Spool != nil {
+		for i := range items {
+			if items[i].err != nil {
+				continue
+			}
+			if err := checkSpooledTopic(c, items[i].request.Topic); err != nil {
+				items[i].err, items[i].status = err, produceErrorStatus(err)
+			}
+		}
+	}
+
+	// the valid messages are counted against the quotas together, so a
+	// batch fits whole or is rejected whole
+	var messages, size int64
+	for _, item := range items {
+		if item.err == nil {
+			messages++
+			size += int64(len(item.value))
+		}
+	}
+	var reservation *services.QuotaReservation
+	if messages > 0 {
+		var ok bool
+		if reservation, ok = reserveQuota(c, h.quotas, messages, size); !ok {
+			return
+		}
+	}
+
+	var results []batchItemResult
+	if h.cfg.Spool != nil {
+		results = h.spoolBatch(c, items)
+	} else {
+		results = h.produceBatch(c, items)
+	}
+	response := batchResponse{Results: results}
+	var failedMessages, failedBytes int64
+	for i, result := range results {
+		if result.Status < http.StatusMultipleChoices {
+			response.Succeeded++
+			continue
+		}
+		response.Failed++
+		if items[i].err == nil {
+			failedMessages++
+			failedBytes += int64(len(items[i].value))
+		}
+	}
+	if failedMessages > 0 && reservation != nil {
+		releaseQuota(h.quotas, reservation.Part(failedMessages, failedBytes))
+	}
+
+	status := http.StatusOK
+	if h.cfg.Spool != nil {
+		status = http.StatusAccepted
+	}
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, response)
+}
+
+// produceBatch produces the valid messages with at most BatchConcurrency in
+// flight, until BatchTimeout; the messages not started by then are not
+// produced
+func (h *messageHandler) produceBatch(c *gin.Context, items []batchItem) []batchItemResult {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.cfg.BatchTimeout)
+	defer cancel()
+	results := make([]batchItemResult, len(items))
+	sem := make(chan struct{}, h.cfg.BatchConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		if item.err != nil {
+			results[i] = invalidItemResult(i, item)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item batchItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := ctx.Err(); err != nil {
+				results[i] = failedItemResult(i, err)
+				return
+			}
+			var key []byte
+			if item.request.Key != "" {
+				key = []byte(item.request.Key)
+			}
+			result, err := h.producer.Produce(ctx, item.request.Topic, key, toHeaders(item.request.Headers), item.value)
+			if err != nil {
+				results[i] = failedItemResult(i, err)
+				return
+			}
+			results[i] = batchItemResult{Index: i, Status: http.StatusOK, Result: result}
+		}(i, item)
+	}
+	wg.Wait()
+	return results
+}
+
+// spoolBatch accepts the valid messages into the spool in one write
+func (h *messageHandler) spoolBatch(c *gin.Context, items []batchItem) []batchItemResult {
+	tenant := services.TenantFromContext(c.Request.Context())
+	results := make([]batchItemResult, len(items))
+	var messages []services.SpooledMessage
+	var indexes []int
+	for i, item := range items {
+		if item.err != nil {
+			results[i] = invalidItemResult(i, item)
+			continue
+		}
+		var key []byte
+		if item.request.Key != "" {
+			key = []byte(item.request.Key)
+		}
+		messages = append(messages, services.SpooledMessage{Topic: item.request.Topic, Key: key, Headers: toHeaders(item.request.Headers), Value: item.value, Tenant: tenant})
+		indexes = append(indexes, i)
+	}
+	if len(messages) == 0 {
+		return results
+	}
+
+	ids, err := h.cfg.Spool.Accept(messages)
+	for n, i := range indexes {
+		if err != nil {
+			results[i] = failedItemResult(i, err)
+			continue
+		}
+		results[i] = batchItemResult{Index: i, Status: http.StatusAccepted, ID: ids[n]}
+	}
+	return results
+}
+
+// invalidItemResult reports a message rejected before being produced
+func invalidItemResult(i int, item batchItem) batchItemResult {
+	if item.status != 0 {
+		return failedItemResult(i, item.err)
+	}
+	return batchItemResult{Index: i, Status: http.StatusBadRequest, Error: "invalid_request", ErrorDescription: item.err.Error()}
+}
+
+// failedItemResult reports a message the producer or the spool failed
+func failedItemResult(i int, err error) batchItemResult {
+	return batchItemResult{Index: i, Status: produceErrorStatus(err), Error: produceErrorCode(err), ErrorDescription: err.Error()}
+}
+
+var errBatchTooLong = errors.New("batch has too many messages")
+
+// readJSONBatch reads a JSON array of messages
+func (h *messageHandler) readJSONBatch(body io.Reader) ([]batchItem, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("batch should be a JSON array of messages: %w", err)
+	}
+	if len(raw) > h.cfg.MaxBatchMessages {
+		return nil, errBatchTooLong
+	}
+	items := make([]batchItem, len(raw))
+	for i, message := range raw {
+		items[i] = parseBatchItem(message)
+	}
+	return items, nil
+}
+
+// readNDJSONBatch reads one message per line; blank lines are skipped and
+// a malformed line only fails its own message
+func (h *messageHandler) readNDJSONBatch(body io.Reader) ([]batchItem, error) {
+	var items []batchItem
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(h.cfg.MaxBatchBytes)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == h.cfg.MaxBatchMessages {
+			return nil, errBatchTooLong
+		}
+		items = append(items, parseBatchItem(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// parseBatchItem checks a message the way Produce binds one
+func parseBatchItem(raw []byte) batchItem {
+	var item batchItem
+	if err := json.Unmarshal(raw, &item.request); err != nil {
+		item.err = fmt.Errorf("invalid message: %w", err)
+		return item
+	}
+	if err := binding.Validator.ValidateStruct(&item.request); err != nil {
+		item.err = err
+		return item
+	}
+	item.value = decodeValue(item.request.Value)
+	return item
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// produceByTopic fails the messages of the "broken" topic
+func produceByTopic(_ context.Context, topic string, _ []byte, _ []services.Header, value []byte) (*services.DeliveryResult, error) {
+	if topic == "broken" {
+		return nil, assert.AnError
+	}
+	return &services.DeliveryResult{Topic: topic, Offset: int64(len(value))}, nil
+}
+
+func TestProduceBatch(t *testing.T) {
+	testCases := []struct {
+		name               string
+		contentType        string
+		requestBody        string
+		cfg                MessageHandlerConfig
+		expectedStatusCode int
+		assert             func(t *testing.T, response batchResponse, producer *servicesfakes.FakeProducer)
+	}{
+		{
+			name:               "should produce every message of a JSON array",
+			contentType:        "application/json",
+			requestBody:        `[{"topic":"orders","value":"a"},{"topic":"orders","key":"k","value":{"id":1}}]`,
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, response batchResponse, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 2, producer.ProduceCallCount())
+				assert.Equal(t, 2, response.Succeeded)
+				assert.Equal(t, 0, response.Failed)
+				require.Len(t, response.Results, 2)
+				assert.Equal(t, int64(1), response.Results[0].Result.Offset)
+				assert.Equal(t, 1, response.Results[1].Index)
+				assert.Equal(t, int64(8), response.Results[1].Result.Offset)
+			},
+		}, {
+			name:               "should produce every line of an NDJSON stream",
+			contentType:        "application/x-ndjson",
+			requestBody:        "{\"topic\":\"orders\",\"value\":\"a\"}\n\n{\"topic\":\"orders\",\"value\":\"bb\"}\n",
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, response batchResponse, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 2, producer.ProduceCallCount())
+				require.Len(t, response.Results, 2)
+				assert.Equal(t, int64(2), response.Results[1].Result.Offset)
+			},
+		}, {
+			name:               "should report the status of each message on partial success",
+			contentType:        "application/x-ndjson",
+			requestBody:        "{\"topic\":\"orders\",\"value\":\"a\"}\nnot json\n{\"topic\":\"broken\",\"value\":\"c\"}\n{\"topic\":\"orders\"}\n",
+			expectedStatusCode: http.StatusMultiStatus,
+			assert: func(t *testing.T, response batchResponse, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 2, producer.ProduceCallCount())
+				assert.Equal(t, 1, response.Succeeded)
+				assert.Equal(t, 3, response.Failed)
+				require.Len(t, response.Results, 4)
+				assert.Equal(t, http.StatusOK, response.Results[0].Status)
+				assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+				assert.Equal(t, "invalid_request", response.Results[1].Error)
+				assert.Contains(t, response.Results[1].ErrorDescription, "invalid message")
+				assert.Equal(t, http.StatusBadGateway, response.Results[2].Status)
+				assert.Equal(t, "broker_error", response.Results[2].Error)
+				assert.Equal(t, http.StatusBadRequest, response.Results[3].Status)
+			},
+		}, {
+			name:               "should return status code 400 when the body is not an array",
+			contentType:        "application/json",
+			requestBody:        `{"topic":"orders","value":"a"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		}, {
+			name:               "should return status code 400 for an empty batch",
+			contentType:        "application/json",
+			requestBody:        `[]`,
+			expectedStatusCode: http.StatusBadRequest,
+		}, {
+			name:               "should return status code 413 when the batch has too many messages",
+			contentType:        "application/x-ndjson",
+			requestBody:        "{\"topic\":\"orders\",\"value\":\"a\"}\n{\"topic\":\"orders\",\"value\":\"b\"}\n",
+			cfg:                MessageHandlerConfig{MaxBatchMessages: 1},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		}, {
+			name:               "should return status code 413 when the JSON array is too large",
+			contentType:        "application/json",
+			requestBody:        `[{"topic":"orders","value":"` + strings.Repeat("a", 100) + `"}]`,
+			cfg:                MessageHandlerConfig{MaxBatchBytes: 64},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		}, {
+			name:               "should return status code 413 when the NDJSON stream is too large",
+			contentType:        "application/x-ndjson",
+			requestBody:        strings.Repeat("{\"topic\":\"orders\",\"value\":\"a\"}\n", 4),
+			cfg:                MessageHandlerConfig{MaxBatchBytes: 64},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(tc.requestBody))
+			c.Request.Header.Set("Content-Type", tc.contentType)
+			producer := &servicesfakes.FakeProducer{ProduceStub: produceByTopic}
+
+			NewMessageHandler(producer, nil, tc.cfg).ProduceBatch(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.assert == nil {
+				assert.Equal(t, 0, producer.ProduceCallCount())
+				assert.Contains(t, w.Body.String(), "error")
+				return
+			}
+			var response batchResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			tc.assert(t, response, producer)
+		})
+	}
+}
+
+func TestProduceBatch_Concurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	producer := &servicesfakes.FakeProducer{
+		ProduceStub: func(_ context.Context, topic string, _ []byte, _ []services.Header, _ []byte) (*services.DeliveryResult, error) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				previous := peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return &services.DeliveryResult{Topic: topic}, nil
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	body := "[" + strings.TrimSuffix(strings.Repeat(`{"topic":"orders","value":"a"},`, 20), ",") + "]"
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	NewMessageHandler(producer, nil, MessageHandlerConfig{BatchConcurrency: 4}).ProduceBatch(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 20, producer.ProduceCallCount())
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+	assert.Greater(t, peak.Load(), int32(1))
+}
+
+func TestProduceBatch_Timeout(t *testing.T) {
+	producer := &servicesfakes.FakeProducer{
+		ProduceStub: func(ctx context.Context, topic string, _ []byte, _ []services.Header, _ []byte) (*services.DeliveryResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	body := "[" + strings.TrimSuffix(strings.Repeat(`{"topic":"orders","value":"a"},`, 5), ",") + "]"
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// the messages waiting for a slot when the batch times out are not produced
+	NewMessageHandler(producer, nil, MessageHandlerConfig{BatchConcurrency: 2, BatchTimeout: 20 * time.Millisecond}).ProduceBatch(c)
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 2, producer.ProduceCallCount())
+	var response batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 5, response.Failed)
+	for _, result := range response.Results {
+		assert.Equal(t, http.StatusGatewayTimeout, result.Status)
+	}
+	assert.False(t, c.GetBool(middleware.NothingWrittenKey))
+}
+
+func TestProduceBatch_Quotas(t *testing.T) {
+	t.Run("should reserve the valid messages and release the failed ones", func(t *testing.T) {
+		quotas := &servicesfakes.FakeQuotaService{
+			ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+				return &services.QuotaReservation{}, nil
+			},
+		}
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(
+			`[{"topic":"orders","value":"aa"},{"topic":"broken","value":"bbb"},{"topic":"orders"}]`))
+		c.Request = c.Request.WithContext(services.ContextWithTenant(c.Request.Context(), "acme"))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		NewMessageHandler(&servicesfakes.FakeProducer{ProduceStub: produceByTopic}, quotas, MessageHandlerConfig{}).ProduceBatch(c)
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		tenant, messages, size := quotas.ReserveArgsForCall(0)
+		assert.Equal(t, "acme", tenant)
+		assert.Equal(t, int64(2), messages)
+		assert.Equal(t, int64(5), size)
+		assert.Equal(t, 1, quotas.ReleaseCallCount())
+	})
+
+	t.Run("should reject the whole batch when it does not fit the quota", func(t *testing.T) {
+		quotas := &servicesfakes.FakeQuotaService{
+			ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+				return nil, &services.QuotaExceededError{Period: services.QuotaMonthly, Metric: "messages", Limit: 1}
+			},
+		}
+		producer := &servicesfakes.FakeProducer{ProduceStub: produceByTopic}
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(
+			`[{"topic":"orders","value":"a"},{"topic":"orders","value":"b"}]`))
+		c.Request = c.Request.WithContext(services.ContextWithTenant(c.Request.Context(), "acme"))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		NewMessageHandler(producer, quotas, MessageHandlerConfig{}).ProduceBatch(c)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, 0, producer.ProduceCallCount())
+		assert.True(t, c.GetBool(middleware.NothingWrittenKey))
+	})
+}
+
+func TestProduceBatch_Spool(t *testing.T) {
+	testCases := []struct {
+		name               string
+		acceptErr          error
+		expectedStatusCode int
+		expectedResults    []batchItemResult
+	}{
+		{
+			name:               "should accept the valid messages together",
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedResults: []batchItemResult{
+				{Index: 0, Status: http.StatusAccepted, ID: "id-1"},
+				{Index: 1, Status: http.StatusForbidden, Error: "insufficient_scope", ErrorDescription: services.ErrTopicForbidden.Error()},
+				{Index: 2, Status: http.StatusAccepted, ID: "id-2"},
+			},
+		}, {
+			name:               "should fail the valid messages when the spool is full",
+			acceptErr:          services.ErrSpoolUnavailable,
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedResults: []batchItemResult{
+				{Index: 0, Status: http.StatusServiceUnavailable, Error: "temporarily_unavailable", ErrorDescription: services.ErrSpoolUnavailable.Error()},
+				{Index: 1, Status: http.StatusForbidden, Error: "insufficient_scope", ErrorDescription: services.ErrTopicForbidden.Error()},
+				{Index: 2, Status: http.StatusServiceUnavailable, Error: "temporarily_unavailable", ErrorDescription: services.ErrSpoolUnavailable.Error()},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spool := &servicesfakes.FakeSpool{}
+			spool.AcceptReturns([]string{"id-1", "id-2"}, tc.acceptErr)
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(
+				`[{"topic":"orders","value":"a"},{"topic":"billing","value":"b"},{"topic":"orders","value":"c"}]`))
+			c.Request = c.Request.WithContext(services.ContextWithScopes(c.Request.Context(), []string{"produce:orders"}))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			NewMessageHandler(&servicesfakes.FakeProducer{}, nil, MessageHandlerConfig{Spool: spool}).ProduceBatch(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			var response batchResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expectedResults, response.Results)
+			assert.Len(t, spool.AcceptArgsForCall(0), 2)
+		})
+	}
+
+	t.Run("should answer 202 when every message is accepted", func(t *testing.T) {
+		spool := &servicesfakes.FakeSpool{}
+		spool.AcceptReturns([]string{"id-1"}, nil)
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages:batch", bytes.NewBufferString(`[{"topic":"orders","value":"a"}]`))
+		c.Request = c.Request.WithContext(services.ContextWithScopes(c.Request.Context(), []string{"produce:orders"}))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		NewMessageHandler(&servicesfakes.FakeProducer{}, nil, MessageHandlerConfig{Spool: spool}).ProduceBatch(c)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.JSONEq(t, `{"succeeded":1,"failed":0,"results":[{"index":0,"status":202,"id":"id-1"}]}`, w.Body.String())
+	})
+}
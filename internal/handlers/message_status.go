@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"net/http"
+)
+
+// Status reports whether a message accepted with 202 is queued, delivered
+// or failed. Messages are only visible to the tenant that sent them; those
+// of other tenants, and expired ones, are not found.
+// Params: c *gin.Context - the request context
+func (h *messageHandler) Status(c *gin.Context) {
+	if h.cfg.Statuses == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "error_description": "messages are produced synchronously and have no status"})
+		return
+	}
+	status, err := h.cfg.Statuses.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to read message status"})
+		return
+	}
+	if status == nil || status.Tenant != services.TenantFromContext(c.Request.Context()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "error_description": "unknown message"})
+		return
+	}
+	status.Tenant = ""
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	partition, offset := int32(2), int64(41)
+	delivered := &services.MessageStatus{ID: "m1", Tenant: "acme", State: services.MessageDelivered, Topic: "orders", Partition: &partition, Offset: &offset, AcceptedAt: at, UpdatedAt: at}
+	failed := &services.MessageStatus{ID: "m2", Tenant: "acme", State: services.MessageFailed, Topic: "billing", Reason: "unknown topic", AcceptedAt: at, UpdatedAt: at}
+
+	testCases := []struct {
+		name               string
+		tenant             string
+		status             *services.MessageStatus
+		err                error
+		disabled           bool
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "should report where a delivered message is stored",
+			tenant:             "acme",
+			status:             delivered,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":"m1","status":"delivered","topic":"orders","partition":2,"offset":41,"accepted_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`,
+		}, {
+			name:               "should report why a message failed",
+			tenant:             "acme",
+			status:             failed,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":"m2","status":"failed","topic":"billing","reason":"unknown topic","accepted_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`,
+		},
+		{name: "should hide the messages of other tenants", tenant: "globex", status: delivered, expectedStatusCode: http.StatusNotFound},
+		{name: "should not find an unknown message", tenant: "acme", expectedStatusCode: http.StatusNotFound},
+		{name: "should fail when the store fails", tenant: "acme", err: assert.AnError, expectedStatusCode: http.StatusServiceUnavailable},
+		{name: "should not find messages without a status store", tenant: "acme", disabled: true, expectedStatusCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statuses := &servicesfakes.FakeMessageStatusStore{}
+			statuses.GetStub = func(string) (*services.MessageStatus, error) {
+				if tc.status == nil {
+					return nil, tc.err
+				}
+				status := *tc.status
+				return &status, tc.err
+			}
+			cfg := MessageHandlerConfig{Statuses: statuses}
+			if tc.disabled {
+				cfg.Statuses = nil
+			}
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/messages/m1", nil)
+			c.Request = c.Request.WithContext(services.ContextWithTenant(context.Background(), tc.tenant))
+			c.Params = gin.Params{{Key: "id", Value: "m1"}}
+
+			NewMessageHandler(&servicesfakes.FakeProducer{}, nil, cfg).Status(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			}
+			if !tc.disabled {
+				assert.Equal(t, "m1", statuses.GetArgsForCall(0))
+			}
+		})
+	}
+}
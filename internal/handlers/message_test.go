@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/nathaliaguayos/msg-receiver/internal/spool"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewMessageHandler(t *testing.T) {
+	producer := &servicesfakes.FakeProducer{}
+	handler := NewMessageHandler(producer, nil, MessageHandlerConfig{})
+	assert.NotNil(t, handler)
+}
+
+func TestProduce(t *testing.T) {
+	testCases := []struct {
+		name               string
+		requestBody        string
+		producer           *servicesfakes.FakeProducer
+		expectedStatusCode int
+		assert             func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer)
+	}{
+		{
+			name:        "should produce a string value",
+			requestBody: `{"topic":"orders","key":"k1","headers":{"b":"2","a":"1"},"value":"hello"}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(_ context.Context, topic string, _ []byte, _ []services.Header, _ []byte) (*services.DeliveryResult, error) {
+					return &services.DeliveryResult{Topic: topic, Partition: 0, Offset: 7}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 1, producer.ProduceCallCount())
+				_, topic, key, headers, value := producer.ProduceArgsForCall(0)
+				assert.Equal(t, "orders", topic)
+				assert.Equal(t, []byte("k1"), key)
+				assert.Equal(t, []services.Header{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}, headers)
+				assert.Equal(t, []byte("hello"), value)
+
+				var response services.DeliveryResult
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, int64(7), response.Offset)
+			},
+		}, {
+			name:        "should produce a JSON object value as raw JSON",
+			requestBody: `{"topic":"orders","value":{"id":1}}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return &services.DeliveryResult{Topic: "orders"}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				_, _, key, headers, value := producer.ProduceArgsForCall(0)
+				assert.Nil(t, key)
+				assert.Nil(t, headers)
+				assert.Equal(t, []byte(`{"id":1}`), value)
+			},
+		}, {
+			name:               "should return status code 400 when value is missing",
+			requestBody:        `{"topic":"orders"}`,
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, producer.ProduceCallCount())
+				assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+			},
+		}, {
+			name:        "should return status code 400 when topic is invalid",
+			requestBody: `{"topic":"orders/eu","value":"hello"}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, services.ErrInvalidTopic
+				},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.Contains(t, w.Body.String(), services.ErrInvalidTopic.Error())
+			},
+		}, {
+			name:        "should return status code 403 when the token scopes do not allow the topic",
+			requestBody: `{"topic":"billing","value":"hello"}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, services.ErrTopicForbidden
+				},
+			},
+			expectedStatusCode: http.StatusForbidden,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.Contains(t, w.Body.String(), services.ErrTopicForbidden.Error())
+			},
+		}, {
+			name:        "should return status code 502 when producing fails",
+			requestBody: `{"topic":"orders","value":"hello"}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, assert.AnError
+				},
+			},
+			expectedStatusCode: http.StatusBadGateway,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.JSONEq(t, `{"error":"broker_error","error_description":"`+assert.AnError.Error()+`"}`, w.Body.String())
+			},
+		}, {
+			name:        "should return status code 503 while the circuit breaker is open",
+			requestBody: `{"topic":"orders","value":"hello"}`,
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, &services.CircuitOpenError{RetryAfter: 1500 * time.Millisecond}
+				},
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, "2", w.Header().Get("Retry-After"))
+				assert.Contains(t, w.Body.String(), services.ErrCircuitOpen.Error())
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(tc.requestBody))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler := NewMessageHandler(tc.producer, nil, MessageHandlerConfig{})
+
+			handler.Produce(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			tc.assert(t, w, tc.producer)
+		})
+	}
+}
+
+func TestProduce_NothingWritten(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "should mark an open circuit breaker", err: &services.CircuitOpenError{RetryAfter: time.Second}, expected: true},
+		{name: "should mark a closed producer", err: services.ErrProducerClosed, expected: true},
+		{name: "should mark a full spool", err: fmt.Errorf("%w: %w", services.ErrSpoolUnavailable, spool.ErrFull), expected: true},
+		{name: "should not mark a spool failing once written", err: fmt.Errorf("%w: %w", services.ErrSpoolUnavailable, assert.AnError)},
+		{name: "should not mark a broker failure", err: services.ErrBrokerUnavailable},
+		{name: "should not mark a timeout", err: context.DeadlineExceeded},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := &servicesfakes.FakeProducer{}
+			producer.ProduceReturns(nil, tc.err)
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(`{"topic":"orders","value":"a"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			NewMessageHandler(producer, nil, MessageHandlerConfig{}).Produce(c)
+			assert.Equal(t, tc.expected, c.GetBool(middleware.NothingWrittenKey))
+		})
+	}
+}
+
+func TestProduce_Quotas(t *testing.T) {
+	reservation := &services.QuotaReservation{Usage: services.QuotaUsage{
+		Tenant:   "acme",
+		Daily:    services.QuotaPeriodUsage{Messages: 9, MessagesLimit: 10},
+		Monthly:  services.QuotaPeriodUsage{Messages: 90, MessagesLimit: 100, Bytes: 400, BytesLimit: 1000},
+		Warnings: []string{"daily messages at 90%", "monthly messages at 90%"},
+	}}
+	testCases := []struct {
+		name               string
+		tenant             string
+		quotas             *servicesfakes.FakeQuotaService
+		producer           *servicesfakes.FakeProducer
+		expectedStatusCode int
+		assert             func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer)
+	}{
+		{
+			name:   "should count the message and report the remaining quota",
+			tenant: "acme",
+			quotas: &servicesfakes.FakeQuotaService{
+				ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+					return reservation, nil
+				},
+			},
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				tenant, messages, bytes := quotas.ReserveArgsForCall(0)
+				assert.Equal(t, "acme", tenant)
+				assert.Equal(t, int64(1), messages)
+				assert.Equal(t, int64(5), bytes)
+				assert.Equal(t, "1", w.Header().Get("X-Quota-Remaining-Messages"))
+				assert.Equal(t, "600", w.Header().Get("X-Quota-Remaining-Bytes"))
+				assert.Equal(t, "daily messages at 90%, monthly messages at 90%", w.Header().Get("X-Quota-Warning"))
+				assert.Equal(t, 0, quotas.ReleaseCallCount())
+			},
+		}, {
+			name:   "should return status code 429 when the daily quota is exceeded",
+			tenant: "acme",
+			quotas: &servicesfakes.FakeQuotaService{
+				ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+					return nil, &services.QuotaExceededError{Period: services.QuotaDaily, Metric: "messages", Limit: 10, ResetsAt: time.Now().Add(time.Hour)}
+				},
+			},
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusTooManyRequests,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, producer.ProduceCallCount())
+				assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+				assert.Contains(t, w.Body.String(), "daily_quota_exceeded")
+			},
+		}, {
+			name:   "should return status code 403 when the monthly quota is exceeded",
+			tenant: "acme",
+			quotas: &servicesfakes.FakeQuotaService{
+				ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+					return nil, &services.QuotaExceededError{Period: services.QuotaMonthly, Metric: "bytes", Limit: 1000}
+				},
+			},
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusForbidden,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, producer.ProduceCallCount())
+				assert.Empty(t, w.Header().Get("Retry-After"))
+				assert.Contains(t, w.Body.String(), "monthly_quota_exceeded")
+			},
+		}, {
+			name:   "should return status code 503 when the quota cannot be checked",
+			tenant: "acme",
+			quotas: &servicesfakes.FakeQuotaService{
+				ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+					return nil, assert.AnError
+				},
+			},
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, producer.ProduceCallCount())
+			},
+		}, {
+			name:   "should release the quota when producing fails",
+			tenant: "acme",
+			quotas: &servicesfakes.FakeQuotaService{
+				ReserveStub: func(string, int64, int64) (*services.QuotaReservation, error) {
+					return reservation, nil
+				},
+			},
+			producer: &servicesfakes.FakeProducer{
+				ProduceStub: func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error) {
+					return nil, assert.AnError
+				},
+			},
+			expectedStatusCode: http.StatusBadGateway,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 1, quotas.ReleaseCallCount())
+				assert.Equal(t, reservation, quotas.ReleaseArgsForCall(0))
+			},
+		}, {
+			name:               "should not count requests without a tenant",
+			quotas:             &servicesfakes.FakeQuotaService{},
+			producer:           &servicesfakes.FakeProducer{},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService, producer *servicesfakes.FakeProducer) {
+				assert.Equal(t, 0, quotas.ReserveCallCount())
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(`{"topic":"orders","value":"hello"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			if tc.tenant != "" {
+				c.Request = c.Request.WithContext(services.ContextWithTenant(c.Request.Context(), tc.tenant))
+			}
+			if tc.producer.ProduceStub == nil {
+				tc.producer.ProduceReturns(&services.DeliveryResult{Topic: "orders"}, nil)
+			}
+
+			NewMessageHandler(tc.producer, tc.quotas, MessageHandlerConfig{}).Produce(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			tc.assert(t, w, tc.quotas, tc.producer)
+		})
+	}
+}
+
+func TestProduce_Spool(t *testing.T) {
+	testCases := []struct {
+		name               string
+		requestBody        string
+		scopes             []string
+		acceptErr          error
+		expectedStatusCode int
+		expectedAccepted   int
+		expectedReleased   int
+	}{
+		{name: "should accept the message once it is spooled", requestBody: `{"topic":"orders","key":"k1","value":"hello"}`, scopes: []string{"produce:orders"}, expectedStatusCode: http.StatusAccepted, expectedAccepted: 1},
+		{name: "should reject a topic the scopes do not allow", requestBody: `{"topic":"billing","value":"hello"}`, scopes: []string{"produce:orders"}, expectedStatusCode: http.StatusForbidden},
+		{name: "should reject an invalid topic", requestBody: `{"topic":"a/b","value":"hello"}`, scopes: []string{"produce:*"}, expectedStatusCode: http.StatusBadRequest},
+		{name: "should release the quota when the spool is full", requestBody: `{"topic":"orders","value":"hello"}`, scopes: []string{"produce:orders"}, acceptErr: services.ErrSpoolUnavailable, expectedStatusCode: http.StatusServiceUnavailable, expectedAccepted: 1, expectedReleased: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spool := &servicesfakes.FakeSpool{}
+			spool.AcceptReturns([]string{"id-1"}, tc.acceptErr)
+			quotas := &servicesfakes.FakeQuotaService{}
+			quotas.ReserveReturns(&services.QuotaReservation{}, nil)
+			producer := &servicesfakes.FakeProducer{}
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(tc.requestBody))
+			ctx := services.ContextWithScopes(services.ContextWithTenant(c.Request.Context(), "acme"), tc.scopes)
+			c.Request = c.Request.WithContext(ctx)
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			NewMessageHandler(producer, quotas, MessageHandlerConfig{Spool: spool}).Produce(c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, 0, producer.ProduceCallCount())
+			assert.Equal(t, tc.expectedAccepted, spool.AcceptCallCount())
+			assert.Equal(t, tc.expectedReleased, quotas.ReleaseCallCount())
+			if tc.expectedStatusCode == http.StatusAccepted {
+				assert.JSONEq(t, `{"id":"id-1","status":"queued"}`, w.Body.String())
+				messages := spool.AcceptArgsForCall(0)
+				assert.Equal(t, []services.SpooledMessage{{Topic: "orders", Key: []byte("k1"), Value: []byte("hello"), Tenant: "acme"}}, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotaHandler is the interface that provides the quota admin methods.
+//
+//counterfeiter:generate . QuotaHandler
+type QuotaHandler interface {
+	Usage(c *gin.Context)
+	Reset(c *gin.Context)
+}
+
+type quotaHandler struct {
+	quotas services.QuotaService
+}
+
+// NewQuotaHandler creates a new QuotaHandler.
+func NewQuotaHandler(quotas services.QuotaService) QuotaHandler {
+	return &quotaHandler{
+		quotas: quotas,
+	}
+}
+
+// Usage returns the usage and limits of a tenant for the current day and month.
+// Params: c *gin.Context - the request context, with the tenant path parameter
+func (h *quotaHandler) Usage(c *gin.Context) {
+	usage, err := h.quotas.Usage(c.Param("tenant"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to read quota usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// Reset clears the usage of a tenant for the current day and month.
+// Params: c *gin.Context - the request context, with the tenant path parameter
+func (h *quotaHandler) Reset(c *gin.Context) {
+	tenant := c.Param("tenant")
+	if err := h.quotas.Reset(tenant); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to reset quota usage"})
+		return
+	}
+	h.Usage(c)
+}
+
+// reserveQuota counts messages against the quotas of the caller tenant and
+// writes the quota headers, or the error response when a limit is reached.
+// Nothing is counted when quotas are disabled or the caller has no tenant.
+func reserveQuota(c *gin.Context, quotas services.QuotaService, messages, bytes int64) (*services.QuotaReservation, bool) {
+	tenant := services.TenantFromContext(c.Request.Context())
+	if quotas == nil || tenant == "" {
+		return nil, true
+	}
+
+	reservation, err := quotas.Reserve(tenant, messages, bytes)
+	if err != nil {
+		middleware.MarkNothingWritten(c)
+	}
+	var exceededErr *services.QuotaExceededError
+	switch {
+	case errors.As(err, &exceededErr):
+		// daily quotas reset soon enough to retry, monthly ones need a plan change
+		if exceededErr.Period == services.QuotaDaily {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(exceededErr.ResetsAt).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "daily_quota_exceeded", "error_description": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "monthly_quota_exceeded", "error_description": err.Error()})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to check quota"})
+		return nil, false
+	}
+
+	setQuotaHeaders(c, &reservation.Usage)
+	return reservation, true
+}
+
+// releaseQuota gives back a reservation whose messages were not produced
+func releaseQuota(quotas services.QuotaService, reservation *services.QuotaReservation) {
+	if quotas == nil || reservation == nil {
+		return
+	}
+	// a failed release leaves the tenant charged, which the admin endpoint can correct
+	_ = quotas.Release(reservation)
+}
+
+// setQuotaHeaders writes the lowest remaining messages and bytes across the
+// limited periods, and the soft limit warnings
+func setQuotaHeaders(c *gin.Context, usage *services.QuotaUsage) {
+	remainingMessages, remainingBytes := int64(-1), int64(-1)
+	for _, period := range []services.QuotaPeriodUsage{usage.Daily, usage.Monthly} {
+		remainingMessages = lowestRemaining(remainingMessages, period.MessagesLimit, period.Messages)
+		remainingBytes = lowestRemaining(remainingBytes, period.BytesLimit, period.Bytes)
+	}
+	if remainingMessages >= 0 {
+		c.Header("X-Quota-Remaining-Messages", strconv.FormatInt(remainingMessages, 10))
+	}
+	if remainingBytes >= 0 {
+		c.Header("X-Quota-Remaining-Bytes", strconv.FormatInt(remainingBytes, 10))
+	}
+	if len(usage.Warnings) > 0 {
+		c.Header("X-Quota-Warning", strings.Join(usage.Warnings, ", "))
+	}
+}
+
+// lowestRemaining returns the lowest of current and what is left of a
+// limit; -1 stands for unlimited
+func lowestRemaining(current, limit, used int64) int64 {
+	if limit <= 0 {
+		return current
+	}
+	remaining := max(limit-used, 0)
+	if current < 0 || remaining < current {
+		return remaining
+	}
+	return current
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQuotaHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		call               func(h QuotaHandler, c *gin.Context)
+		quotas             *servicesfakes.FakeQuotaService
+		expectedStatusCode int
+		assert             func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService)
+	}{
+		{
+			name: "should return the tenant usage",
+			call: QuotaHandler.Usage,
+			quotas: &servicesfakes.FakeQuotaService{
+				UsageStub: func(tenant string) (*services.QuotaUsage, error) {
+					return &services.QuotaUsage{Tenant: tenant, Daily: services.QuotaPeriodUsage{Period: "2024-05-31", Messages: 3}}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService) {
+				assert.Equal(t, "acme", quotas.UsageArgsForCall(0))
+				var usage services.QuotaUsage
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+				assert.Equal(t, "acme", usage.Tenant)
+				assert.Equal(t, int64(3), usage.Daily.Messages)
+			},
+		}, {
+			name: "should reset the tenant usage",
+			call: QuotaHandler.Reset,
+			quotas: &servicesfakes.FakeQuotaService{
+				UsageStub: func(tenant string) (*services.QuotaUsage, error) {
+					return &services.QuotaUsage{Tenant: tenant}, nil
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService) {
+				assert.Equal(t, 1, quotas.ResetCallCount())
+				assert.Equal(t, "acme", quotas.ResetArgsForCall(0))
+			},
+		}, {
+			name: "should return status code 503 when the usage cannot be read",
+			call: QuotaHandler.Usage,
+			quotas: &servicesfakes.FakeQuotaService{
+				UsageStub: func(string) (*services.QuotaUsage, error) {
+					return nil, assert.AnError
+				},
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService) {
+				assert.Contains(t, w.Body.String(), "temporarily_unavailable")
+			},
+		}, {
+			name: "should return status code 503 when the usage cannot be reset",
+			call: QuotaHandler.Reset,
+			quotas: &servicesfakes.FakeQuotaService{
+				ResetStub: func(string) error {
+					return assert.AnError
+				},
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			assert: func(t *testing.T, w *httptest.ResponseRecorder, quotas *servicesfakes.FakeQuotaService) {
+				assert.Equal(t, 0, quotas.UsageCallCount())
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/admin/quotas/acme", nil)
+			c.Params = gin.Params{{Key: "tenant", Value: "acme"}}
+
+			tc.call(NewQuotaHandler(tc.quotas), c)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			tc.assert(t, w, tc.quotas)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"strings"
+	"sync"
+)
+
+// maxDecompressedSize bounds the records of a single decompressed batch
+const maxDecompressedSize = maxFrameSize
+
+// xerialHeader starts snappy data framed by the Java client's
+// SnappyOutputStream, followed by two version numbers and the chunks
+var xerialHeader = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// String returns the name of the codec, as accepted by ParseCompression
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionLZ4:
+		return "lz4"
+	case CompressionZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("compression(%d)", int8(c))
+}
+
+// ParseCompression parses "none", "gzip", "snappy", "lz4" or "zstd"
+func ParseCompression(s string) (Compression, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionSnappy, CompressionLZ4, CompressionZstd} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("kafka: invalid compression %q, expected none, gzip, snappy, lz4 or zstd", s)
+}
+
+// compress encodes the records of a batch with a codec
+func compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		// brokers read raw blocks as well as the Java client's framing
+		return snappy.Encode(nil, data), nil
+	case CompressionLZ4:
+		return lz4Compress(data)
+	case CompressionZstd:
+		encoder, _, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return nil, ErrUnsupportedCompression
+}
+
+// decompress decodes the records of a batch, refusing to inflate them past
+// maxDecompressedSize
+func decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		out, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		if len(out) > maxDecompressedSize {
+			return nil, fmt.Errorf("%w: decompressed batch too large", ErrMalformed)
+		}
+		return out, nil
+	case CompressionSnappy:
+		return snappyDecode(data)
+	case CompressionLZ4:
+		return lz4Decompress(data, maxDecompressedSize)
+	case CompressionZstd:
+		_, decoder, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		out, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		return out, nil
+	}
+	return nil, ErrUnsupportedCompression
+}
+
+// snappyDecode decodes a raw snappy block or the chunks of the xerial framing
+func snappyDecode(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, xerialHeader) {
+		return snappyBlock(data, 0)
+	}
+	// the header is followed by the version and the compatible version
+	data = data[min(len(xerialHeader)+8, len(data)):]
+	var out []byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: truncated snappy chunk", ErrMalformed)
+		}
+		size := binary.BigEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, fmt.Errorf("%w: snappy chunk length %d out of range", ErrMalformed, size)
+		}
+		chunk, err := snappyBlock(data[4:4+size], len(out))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+		data = data[4+size:]
+	}
+	return out, nil
+}
+
+// snappyBlock decodes one snappy block, following size bytes already decoded
+func snappyBlock(block []byte, size int) ([]byte, error) {
+	n, err := snappy.DecodedLen(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if size+n > maxDecompressedSize {
+		return nil, fmt.Errorf("%w: decompressed batch too large", ErrMalformed)
+	}
+	out, err := snappy.Decode(nil, block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return out, nil
+}
+
+// zstdCodec returns the shared zstd encoder and decoder, which are safe for
+// concurrent use through EncodeAll and DecodeAll
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize), zstd.WithDecoderConcurrency(0))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
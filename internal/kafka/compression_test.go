@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseCompression(t *testing.T) {
+	for input, expected := range map[string]Compression{"none": CompressionNone, "gzip": CompressionGzip, "Snappy": CompressionSnappy, "lz4": CompressionLZ4, " zstd ": CompressionZstd} {
+		compression, err := ParseCompression(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, compression)
+	}
+	_, err := ParseCompression("brotli")
+	assert.Error(t, err)
+	assert.Equal(t, "compression(7)", Compression(7).String())
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"order":1,"customer":"acme","total":42}`), 1000)
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionSnappy, CompressionLZ4, CompressionZstd} {
+		t.Run(c.String(), func(t *testing.T) {
+			compressed, err := compress(c, data)
+			require.NoError(t, err)
+			if c != CompressionNone {
+				assert.Less(t, len(compressed), len(data)/4)
+			}
+			decompressed, err := decompress(c, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+
+			if c != CompressionNone {
+				_, err = decompress(c, compressed[:len(compressed)/2])
+				assert.ErrorIs(t, err, ErrMalformed)
+			}
+		})
+	}
+
+	_, err := compress(7, data)
+	assert.ErrorIs(t, err, ErrUnsupportedCompression)
+	_, err = decompress(7, data)
+	assert.ErrorIs(t, err, ErrUnsupportedCompression)
+}
+
+func TestDecompress_XerialSnappy(t *testing.T) {
+	// the Java client frames snappy chunks after a header and two versions
+	framed := append([]byte(nil), xerialHeader...)
+	framed = binary.BigEndian.AppendUint32(framed, 1)
+	framed = binary.BigEndian.AppendUint32(framed, 1)
+	for _, chunk := range []string{"hello ", "kafka"} {
+		block := snappy.Encode(nil, []byte(chunk))
+		framed = binary.BigEndian.AppendUint32(framed, uint32(len(block)))
+		framed = append(framed, block...)
+	}
+	data, err := decompress(CompressionSnappy, framed)
+	require.NoError(t, err)
+	assert.Equal(t, "hello kafka", string(data))
+
+	_, err = decompress(CompressionSnappy, framed[:len(framed)-1])
+	assert.ErrorIs(t, err, ErrMalformed)
+}
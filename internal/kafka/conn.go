@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// errConnBroken is returned for requests on a connection that failed
+var errConnBroken = errors.New("kafka: connection broken")
+
+// conn is a connection to a single broker. Requests are pipelined: several
+// may be in flight, and brokers answer them in the order they were sent.
+// A request that fails or times out breaks the connection, failing every
+// request in flight with it; a request whose context is done only stops
+// waiting, and its response is discarded.
+type conn struct {
+	addr     string
+	nc       net.Conn
+	clientID string
+	timeout  time.Duration
+
+	// writeMu orders the requests on the wire and in waiting
+	writeMu       sync.Mutex
+	correlationID int32
+
+	mu      sync.Mutex
+	waiting []*call
+	err     error
+}
+
+// call is a request waiting for its response
+type call struct {
+	correlationID int32
+	payload       []byte
+	err           error
+	done          chan struct{}
+}
+
+func dial(ctx context.Context, addr, clientID string, timeout time.Duration) (*conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		addr:     addr,
+		nc:       nc,
+		clientID: clientID,
+		timeout:  timeout,
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// roundTrip sends req and decodes the reply into resp. When resp is nil
+// no reply is expected, as for Produce requests with acks=0. The deadline
+// of ctx is not applied to the connection, which other requests share.
+func (c *conn) roundTrip(ctx context.Context, req Request, resp Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(c.timeout)
+	cl, err := c.send(req, resp != nil, deadline)
+	if err != nil || cl == nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-cl.done:
+	case <-ctx.Done():
+		// the call keeps its place in waiting, so readLoop reads and
+		// discards its response while the other requests go on
+		return ctx.Err()
+	case <-timer.C:
+		_ = c.fail(fmt.Errorf("kafka: request to %s timed out: %w", c.addr, errConnBroken))
+		<-cl.done
+	}
+	if cl.err != nil {
+		return cl.err
+	}
+
+	d := NewDecoder(cl.payload)
+	if err := resp.Decode(d); err != nil {
+		return err
+	}
+	if d.Remaining() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes in response to api %d", ErrMalformed, d.Remaining(), req.APIKey())
+	}
+	return nil
+}
+
+// send writes a request, returning the call its response is delivered to
+// when one is expected
+func (c *conn) send(req Request, expectResponse bool, deadline time.Time) (*call, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.correlationID++
+	header := RequestHeader{
+		APIKey:        req.APIKey(),
+		APIVersion:    req.APIVersion(),
+		CorrelationID: c.correlationID,
+		ClientID:      &c.clientID,
+	}
+	e := NewEncoder()
+	header.Encode(e)
+	req.Encode(e)
+
+	var cl *call
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	if expectResponse {
+		cl = &call{correlationID: c.correlationID, done: make(chan struct{})}
+		c.waiting = append(c.waiting, cl)
+	}
+	c.mu.Unlock()
+
+	if err := c.nc.SetWriteDeadline(deadline); err != nil {
+		_ = c.fail(err)
+		return nil, err
+	}
+	if err := WriteFrame(c.nc, e.Bytes()); err != nil {
+		_ = c.fail(err)
+		return nil, err
+	}
+	return cl, nil
+}
+
+// readLoop hands every response to the oldest waiting call until the
+// connection fails
+func (c *conn) readLoop() {
+	for {
+		payload, err := ReadFrame(c.nc)
+		if err != nil {
+			_ = c.fail(err)
+			return
+		}
+		c.mu.Lock()
+		if len(c.waiting) == 0 {
+			c.mu.Unlock()
+			_ = c.fail(fmt.Errorf("%w: unexpected response", ErrMalformed))
+			return
+		}
+		cl := c.waiting[0]
+		c.waiting = c.waiting[1:]
+		c.mu.Unlock()
+
+		d := NewDecoder(payload)
+		if id := d.Int32(); d.Err() != nil || id != cl.correlationID {
+			cl.err = fmt.Errorf("%w: correlation id %d does not match request %d", ErrMalformed, id, cl.correlationID)
+			close(cl.done)
+			_ = c.fail(cl.err)
+			return
+		}
+		cl.payload = payload[4:]
+		close(cl.done)
+	}
+}
+
+// fail breaks the connection, failing every waiting call with err, and
+// returns the error closing it the first time
+func (c *conn) fail(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var closeErr error
+	if c.err == nil {
+		c.err = err
+		closeErr = c.nc.Close()
+	}
+	for _, cl := range c.waiting {
+		cl.err = err
+		close(cl.done)
+	}
+	c.waiting = nil
+	return closeErr
+}
+
+func (c *conn) close() error {
+	return c.fail(net.ErrClosed)
+}
@@ -0,0 +1,6 @@
+// Package kafka provides a minimal client for the Kafka wire protocol.
+//
+// Only the APIs needed to produce messages are implemented: Metadata v1,
+// Produce v3, or v7 for zstd batches, (record batches, magic v2, compressed
+// with gzip, snappy, lz4 or zstd) and InitProducerId v0.
+package kafka
@@ -0,0 +1,127 @@
+package kafka
+
+import "fmt"
+
+// KError is an error code returned by a Kafka broker
+type KError int16
+
+// Error codes handled by this client
+const (
+	ErrNone                         KError = 0
+	ErrUnknownServerError           KError = -1
+	ErrOffsetOutOfRange             KError = 1
+	ErrCorruptMessage               KError = 2
+	ErrUnknownTopicOrPartition      KError = 3
+	ErrInvalidFetchSize             KError = 4
+	ErrLeaderNotAvailable           KError = 5
+	ErrNotLeaderOrFollower          KError = 6
+	ErrRequestTimedOut              KError = 7
+	ErrBrokerNotAvailable           KError = 8
+	ErrReplicaNotAvailable          KError = 9
+	ErrMessageTooLarge              KError = 10
+	ErrNetworkException             KError = 13
+	ErrCoordinatorLoadInProgress    KError = 14
+	ErrCoordinatorNotAvailable      KError = 15
+	ErrNotCoordinator               KError = 16
+	ErrInvalidTopic                 KError = 17
+	ErrRecordListTooLarge           KError = 18
+	ErrNotEnoughReplicas            KError = 19
+	ErrNotEnoughReplicasAfterAppend KError = 20
+	ErrInvalidRequiredAcks          KError = 21
+	ErrTopicAuthorizationFailed     KError = 29
+	ErrClusterAuthorizationFailed   KError = 31
+	ErrInvalidTimestamp             KError = 32
+	ErrUnsupportedVersion           KError = 35
+	ErrUnsupportedForMessageFormat  KError = 43
+	ErrOutOfOrderSequenceNumber     KError = 45
+	ErrDuplicateSequenceNumber      KError = 46
+	ErrInvalidProducerEpoch         KError = 47
+	ErrKafkaStorageError            KError = 56
+	ErrUnknownProducerID            KError = 59
+	ErrUnsupportedCompressionType   KError = 76
+	ErrInvalidRecord                KError = 87
+	ErrThrottlingQuotaExceeded      KError = 89
+)
+
+var kerrorNames = map[KError]string{
+	ErrUnknownServerError:           "UNKNOWN_SERVER_ERROR",
+	ErrOffsetOutOfRange:             "OFFSET_OUT_OF_RANGE",
+	ErrCorruptMessage:               "CORRUPT_MESSAGE",
+	ErrUnknownTopicOrPartition:      "UNKNOWN_TOPIC_OR_PARTITION",
+	ErrInvalidFetchSize:             "INVALID_FETCH_SIZE",
+	ErrLeaderNotAvailable:           "LEADER_NOT_AVAILABLE",
+	ErrNotLeaderOrFollower:          "NOT_LEADER_OR_FOLLOWER",
+	ErrRequestTimedOut:              "REQUEST_TIMED_OUT",
+	ErrBrokerNotAvailable:           "BROKER_NOT_AVAILABLE",
+	ErrReplicaNotAvailable:          "REPLICA_NOT_AVAILABLE",
+	ErrMessageTooLarge:              "MESSAGE_TOO_LARGE",
+	ErrNetworkException:             "NETWORK_EXCEPTION",
+	ErrCoordinatorLoadInProgress:    "COORDINATOR_LOAD_IN_PROGRESS",
+	ErrCoordinatorNotAvailable:      "COORDINATOR_NOT_AVAILABLE",
+	ErrNotCoordinator:               "NOT_COORDINATOR",
+	ErrInvalidTopic:                 "INVALID_TOPIC_EXCEPTION",
+	ErrRecordListTooLarge:           "RECORD_LIST_TOO_LARGE",
+	ErrNotEnoughReplicas:            "NOT_ENOUGH_REPLICAS",
+	ErrNotEnoughReplicasAfterAppend: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	ErrInvalidRequiredAcks:          "INVALID_REQUIRED_ACKS",
+	ErrTopicAuthorizationFailed:     "TOPIC_AUTHORIZATION_FAILED",
+	ErrClusterAuthorizationFailed:   "CLUSTER_AUTHORIZATION_FAILED",
+	ErrInvalidTimestamp:             "INVALID_TIMESTAMP",
+	ErrUnsupportedVersion:           "UNSUPPORTED_VERSION",
+	ErrUnsupportedForMessageFormat:  "UNSUPPORTED_FOR_MESSAGE_FORMAT",
+	ErrOutOfOrderSequenceNumber:     "OUT_OF_ORDER_SEQUENCE_NUMBER",
+	ErrDuplicateSequenceNumber:      "DUPLICATE_SEQUENCE_NUMBER",
+	ErrInvalidProducerEpoch:         "INVALID_PRODUCER_EPOCH",
+	ErrKafkaStorageError:            "KAFKA_STORAGE_ERROR",
+	ErrUnknownProducerID:            "UNKNOWN_PRODUCER_ID",
+	ErrUnsupportedCompressionType:   "UNSUPPORTED_COMPRESSION_TYPE",
+	ErrInvalidRecord:                "INVALID_RECORD",
+	ErrThrottlingQuotaExceeded:      "THROTTLING_QUOTA_EXCEEDED",
+}
+
+func (e KError) Error() string {
+	if name, ok := kerrorNames[e]; ok {
+		return "kafka: " + name
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// Retriable reports whether the request may succeed if it is sent again
+func (e KError) Retriable() bool {
+	switch e {
+	case ErrCorruptMessage,
+		ErrUnknownTopicOrPartition,
+		ErrLeaderNotAvailable,
+		ErrNotLeaderOrFollower,
+		ErrRequestTimedOut,
+		ErrReplicaNotAvailable,
+		ErrNetworkException,
+		ErrCoordinatorLoadInProgress,
+		ErrCoordinatorNotAvailable,
+		ErrNotCoordinator,
+		ErrNotEnoughReplicas,
+		ErrNotEnoughReplicasAfterAppend,
+		ErrKafkaStorageError,
+		ErrThrottlingQuotaExceeded:
+		return true
+	}
+	return false
+}
+
+// refreshesMetadata reports whether the error means our view of the
+// cluster is stale
+func (e KError) refreshesMetadata() bool {
+	switch e {
+	case ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderOrFollower, ErrKafkaStorageError:
+		return true
+	}
+	return false
+}
+
+// asError returns nil for ErrNone and the error otherwise
+func (e KError) asError() error {
+	if e == ErrNone {
+		return nil
+	}
+	return e
+}
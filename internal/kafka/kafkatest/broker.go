@@ -0,0 +1,380 @@
+// Package kafkatest provides an in-process Kafka broker for tests.
+//
+// The broker listens on a loopback port and speaks enough of the wire
+// protocol to serve the kafka package: Metadata v1, Produce v3 and v7 and
+// InitProducerId v0. Every record batch is decoded and CRC-checked, so
+// tests exercise the real request framing.
+package kafkatest
+
+import (
+	"errors"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request is a request header received by the broker
+type Request = kafka.RequestHeader
+
+// Broker is a single-node fake Kafka cluster
+type Broker struct {
+	listener net.Listener
+	nodeID   int32
+
+	mu             sync.Mutex
+	topics         map[string][][]kafka.RecordBatch
+	injected       map[partitionKey][]kafka.KError
+	sequences      map[sequenceKey]int32
+	requests       []Request
+	nextProducerID int64
+	dropResponses  int
+	latency        time.Duration
+	conns          map[net.Conn]struct{}
+	wg             sync.WaitGroup
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+type sequenceKey struct {
+	producerID int64
+	partition  partitionKey
+}
+
+// NewBroker starts a broker on a random loopback port
+func NewBroker() *Broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("kafkatest: failed to listen on a port: " + err.Error())
+	}
+	b := &Broker{
+		listener:       listener,
+		nodeID:         1,
+		topics:         make(map[string][][]kafka.RecordBatch),
+		injected:       make(map[partitionKey][]kafka.KError),
+		sequences:      make(map[sequenceKey]int32),
+		nextProducerID: 1000,
+		conns:          make(map[net.Conn]struct{}),
+	}
+	b.wg.Add(1)
+	go b.serve()
+	return b
+}
+
+// Addr returns the host:port the broker listens on
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Close stops the broker and closes every client connection
+func (b *Broker) Close() {
+	_ = b.listener.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		_ = c.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// CreateTopic creates a topic with the given number of partitions
+func (b *Broker) CreateTopic(name string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topics[name] = make([][]kafka.RecordBatch, partitions)
+}
+
+// InjectError makes the next Produce requests for a partition fail with
+// the given error codes, one per request
+func (b *Broker) InjectError(topic string, partition int32, errs ...kafka.KError) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := partitionKey{topic: topic, partition: partition}
+	b.injected[key] = append(b.injected[key], errs...)
+}
+
+// DropResponses makes the broker store the next n Produce requests but
+// close the connection instead of answering, as if the response was lost
+func (b *Broker) DropResponses(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropResponses = n
+}
+
+// SetLatency delays every response by d, like a network round trip;
+// requests keep being read meanwhile, so pipelined requests overlap
+func (b *Broker) SetLatency(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency = d
+}
+
+// Batches returns the record batches stored for a partition
+func (b *Broker) Batches(topic string, partition int32) []kafka.RecordBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	partitions := b.topics[topic]
+	if int(partition) >= len(partitions) {
+		return nil
+	}
+	return append([]kafka.RecordBatch(nil), partitions[partition]...)
+}
+
+// Records returns the records stored for a partition
+func (b *Broker) Records(topic string, partition int32) []kafka.Record {
+	var records []kafka.Record
+	for _, batch := range b.Batches(topic, partition) {
+		records = append(records, batch.Records...)
+	}
+	return records
+}
+
+// Requests returns the header of every request received so far
+func (b *Broker) Requests() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Request(nil), b.requests...)
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		c, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns[c] = struct{}{}
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go b.handle(c)
+	}
+}
+
+// delayedResponse is a response to write once due
+type delayedResponse struct {
+	payload []byte
+	due     time.Time
+}
+
+func (b *Broker) handle(c net.Conn) {
+	defer b.wg.Done()
+	// responses are written in order by another goroutine, so the latency
+	// of one does not hold the next request back
+	responses := make(chan delayedResponse, 128)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		failed := false
+		for r := range responses {
+			time.Sleep(time.Until(r.due))
+			if !failed && kafka.WriteFrame(c, r.payload) != nil {
+				failed = true
+				_ = c.Close()
+			}
+		}
+	}()
+	defer func() {
+		close(responses)
+		<-written
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	for {
+		payload, err := kafka.ReadFrame(c)
+		if err != nil {
+			return
+		}
+		response, ok := b.dispatch(payload)
+		if !ok {
+			return
+		}
+		if response == nil {
+			continue
+		}
+		b.mu.Lock()
+		due := time.Now().Add(b.latency)
+		b.mu.Unlock()
+		responses <- delayedResponse{payload: response, due: due}
+	}
+}
+
+// dispatch decodes a request and returns the encoded response, nil when no
+// response is expected, and false when the connection must be closed
+func (b *Broker) dispatch(payload []byte) ([]byte, bool) {
+	d := kafka.NewDecoder(payload)
+	var header kafka.RequestHeader
+	if err := header.Decode(d); err != nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	b.requests = append(b.requests, header)
+	b.mu.Unlock()
+
+	e := kafka.NewEncoder()
+	e.PutInt32(header.CorrelationID)
+
+	switch {
+	case header.APIKey == kafka.APIKeyMetadata && header.APIVersion == 1:
+		req := &kafka.MetadataRequest{}
+		if err := req.Decode(d); err != nil || d.Remaining() != 0 {
+			return nil, false
+		}
+		b.metadata(req).Encode(e)
+	case header.APIKey == kafka.APIKeyInitProducerID && header.APIVersion == 0:
+		req := &kafka.InitProducerIDRequest{}
+		if err := req.Decode(d); err != nil || d.Remaining() != 0 {
+			return nil, false
+		}
+		b.initProducerID().Encode(e)
+	case header.APIKey == kafka.APIKeyProduce && (header.APIVersion == 3 || header.APIVersion == 7):
+		req := &kafka.ProduceRequest{Version: header.APIVersion}
+		if err := req.Decode(d); err != nil || d.Remaining() != 0 {
+			return nil, false
+		}
+		resp, drop := b.produce(req)
+		if drop {
+			return nil, false
+		}
+		if req.Acks == 0 {
+			return nil, true
+		}
+		resp.Encode(e)
+	default:
+		// real brokers close the connection on unsupported versions
+		return nil, false
+	}
+	return e.Bytes(), true
+}
+
+func (b *Broker) metadata(req *kafka.MetadataRequest) *kafka.MetadataResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	host, portStr, _ := net.SplitHostPort(b.Addr())
+	port, _ := strconv.Atoi(portStr)
+	resp := &kafka.MetadataResponse{
+		Brokers:      []kafka.BrokerMetadata{{NodeID: b.nodeID, Host: host, Port: int32(port)}},
+		ControllerID: b.nodeID,
+	}
+
+	names := req.Topics
+	if names == nil {
+		for name := range b.topics {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		partitions, ok := b.topics[name]
+		if !ok {
+			resp.Topics = append(resp.Topics, kafka.TopicMetadata{ErrorCode: kafka.ErrUnknownTopicOrPartition, Name: name})
+			continue
+		}
+		topic := kafka.TopicMetadata{Name: name}
+		for i := range partitions {
+			topic.Partitions = append(topic.Partitions, kafka.PartitionMetadata{
+				Partition: int32(i),
+				Leader:    b.nodeID,
+				Replicas:  []int32{b.nodeID},
+				ISR:       []int32{b.nodeID},
+			})
+		}
+		resp.Topics = append(resp.Topics, topic)
+	}
+	return resp
+}
+
+func (b *Broker) initProducerID() *kafka.InitProducerIDResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextProducerID
+	b.nextProducerID++
+	return &kafka.InitProducerIDResponse{ProducerID: id, ProducerEpoch: 0}
+}
+
+func (b *Broker) produce(req *kafka.ProduceRequest) (*kafka.ProduceResponse, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := &kafka.ProduceResponse{Version: req.Version}
+	for _, t := range req.Topics {
+		topic := kafka.ProduceTopicResponse{Name: t.Name}
+		for _, p := range t.Partitions {
+			topic.Partitions = append(topic.Partitions, b.appendRecords(req, t.Name, p))
+		}
+		resp.Topics = append(resp.Topics, topic)
+	}
+	if b.dropResponses > 0 {
+		b.dropResponses--
+		return nil, true
+	}
+	return resp, false
+}
+
+func (b *Broker) appendRecords(req *kafka.ProduceRequest, topic string, p kafka.ProducePartition) kafka.ProducePartitionResponse {
+	acks := req.Acks
+	resp := kafka.ProducePartitionResponse{Partition: p.Partition, BaseOffset: -1, LogAppendTimeMs: -1}
+	key := partitionKey{topic: topic, partition: p.Partition}
+
+	if acks != -1 && acks != 0 && acks != 1 {
+		resp.ErrorCode = kafka.ErrInvalidRequiredAcks
+		return resp
+	}
+	partitions, ok := b.topics[topic]
+	if !ok || p.Partition < 0 || int(p.Partition) >= len(partitions) {
+		resp.ErrorCode = kafka.ErrUnknownTopicOrPartition
+		return resp
+	}
+	if errs := b.injected[key]; len(errs) > 0 {
+		b.injected[key] = errs[1:]
+		resp.ErrorCode = errs[0]
+		return resp
+	}
+	batches, err := kafka.DecodeRecordBatches(p.Records)
+	if err != nil {
+		resp.ErrorCode = kafka.ErrCorruptMessage
+		if errors.Is(err, kafka.ErrUnsupportedCompression) {
+			resp.ErrorCode = kafka.ErrUnsupportedCompressionType
+		}
+		return resp
+	}
+	for _, batch := range batches {
+		// zstd batches need a client recent enough to read them back
+		if batch.Compression == kafka.CompressionZstd && req.Version < 7 {
+			resp.ErrorCode = kafka.ErrUnsupportedCompressionType
+			return resp
+		}
+	}
+
+	offset := int64(0)
+	for _, batch := range partitions[p.Partition] {
+		offset += int64(len(batch.Records))
+	}
+	resp.BaseOffset = offset
+	for _, batch := range batches {
+		if batch.ProducerID >= 0 {
+			seqKey := sequenceKey{producerID: batch.ProducerID, partition: key}
+			expected := b.sequences[seqKey]
+			switch {
+			case batch.BaseSequence < expected:
+				resp.ErrorCode, resp.BaseOffset = kafka.ErrDuplicateSequenceNumber, -1
+				return resp
+			case batch.BaseSequence > expected:
+				resp.ErrorCode, resp.BaseOffset = kafka.ErrOutOfOrderSequenceNumber, -1
+				return resp
+			}
+			b.sequences[seqKey] = expected + int32(len(batch.Records))
+		}
+		batch.BaseOffset = offset
+		offset += int64(len(batch.Records))
+		partitions[p.Partition] = append(partitions[p.Partition], batch)
+	}
+	return resp
+}
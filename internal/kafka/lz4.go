@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pierrec/lz4/v4"
+	"io"
+	"sync"
+)
+
+// lz4Writers are reused as each one holds its block buffers. Frames are
+// written with independent 64 KiB blocks, the block size of the Java client.
+var lz4Writers = sync.Pool{New: func() any {
+	w := lz4.NewWriter(nil)
+	_ = w.Apply(lz4.BlockSizeOption(lz4.Block64Kb))
+	return w
+}}
+
+// lz4Compress encodes data as an LZ4 frame, the format Kafka uses for lz4 batches
+func lz4Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := lz4Writers.Get().(*lz4.Writer)
+	defer lz4Writers.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lz4Decompress decodes an LZ4 frame, refusing to inflate it past limit
+func lz4Decompress(data []byte, limit int) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(lz4.NewReader(bytes.NewReader(data)), int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if len(out) > limit {
+		return nil, fmt.Errorf("%w: decompressed batch too large", ErrMalformed)
+	}
+	return out, nil
+}
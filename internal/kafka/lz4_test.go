@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestLZ4_RoundTrip(t *testing.T) {
+	random := make([]byte, 100<<10)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(rng.Uint32())
+	}
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "shorter than a match", data: []byte("kafka")},
+		{name: "repeated", data: bytes.Repeat([]byte("abc"), 1000)},
+		{name: "long runs across blocks", data: bytes.Repeat([]byte{'x'}, 200<<10)},
+		{name: "incompressible", data: random},
+		{name: "json lines", data: bytes.Repeat([]byte(`{"order":1,"customer":"acme","total":42}`+"\n"), 5000)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			compressed, err := lz4Compress(tc.data)
+			require.NoError(t, err)
+			decompressed, err := lz4Decompress(compressed, len(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.data), len(decompressed))
+			assert.True(t, bytes.Equal(tc.data, decompressed))
+			if len(tc.data) > 1000 && tc.name != "incompressible" {
+				assert.Less(t, len(compressed), len(tc.data)/4)
+			}
+		})
+	}
+}
+
+func TestLZ4Decompress(t *testing.T) {
+	// written by the lz4 command line tool with linked blocks, block and
+	// content checksums
+	frame, err := hex.DecodeString("04224d187440bd14000000af6b61666b61206c7a34200a001a50206c7a3421fe2badc900000000654e515e")
+	require.NoError(t, err)
+	data, err := lz4Decompress(frame, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "kafka lz4 kafka lz4 kafka lz4 kafka lz4 kafka lz4 kafka lz4!", string(data))
+
+	_, err = lz4Decompress(frame, 10)
+	assert.ErrorIs(t, err, ErrMalformed, "output past the limit")
+
+	for i := range frame {
+		corrupt := append([]byte(nil), frame...)
+		corrupt[i] ^= 0x01
+		_, err := lz4Decompress(corrupt, 1000)
+		assert.ErrorIs(t, err, ErrMalformed, "byte %d flipped", i)
+	}
+}
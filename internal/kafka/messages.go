@@ -0,0 +1,294 @@
+package kafka
+
+// MetadataRequest is a Metadata v1 request. A nil Topics slice asks for
+// every topic in the cluster.
+type MetadataRequest struct {
+	Topics []string
+}
+
+func (r *MetadataRequest) APIKey() int16     { return APIKeyMetadata }
+func (r *MetadataRequest) APIVersion() int16 { return 1 }
+
+// Encode writes the request body
+func (r *MetadataRequest) Encode(e *Encoder) {
+	if r.Topics == nil {
+		e.PutArrayLen(-1)
+		return
+	}
+	e.PutArrayLen(len(r.Topics))
+	for _, topic := range r.Topics {
+		e.PutString(topic)
+	}
+}
+
+// Decode reads the request body
+func (r *MetadataRequest) Decode(d *Decoder) error {
+	n := d.ArrayLen()
+	if n < 0 {
+		r.Topics = nil
+		return d.Err()
+	}
+	r.Topics = make([]string, n)
+	for i := range r.Topics {
+		r.Topics[i] = d.String()
+	}
+	return d.Err()
+}
+
+// BrokerMetadata describes a broker in a Metadata response
+type BrokerMetadata struct {
+	NodeID int32
+	Host   string
+	Port   int32
+	Rack   *string
+}
+
+// PartitionMetadata describes a partition in a Metadata response
+type PartitionMetadata struct {
+	ErrorCode KError
+	Partition int32
+	Leader    int32
+	Replicas  []int32
+	ISR       []int32
+}
+
+// TopicMetadata describes a topic in a Metadata response
+type TopicMetadata struct {
+	ErrorCode  KError
+	Name       string
+	IsInternal bool
+	Partitions []PartitionMetadata
+}
+
+// MetadataResponse is a Metadata v1 response
+type MetadataResponse struct {
+	Brokers      []BrokerMetadata
+	ControllerID int32
+	Topics       []TopicMetadata
+}
+
+// Encode writes the response body
+func (r *MetadataResponse) Encode(e *Encoder) {
+	e.PutArrayLen(len(r.Brokers))
+	for _, b := range r.Brokers {
+		e.PutInt32(b.NodeID)
+		e.PutString(b.Host)
+		e.PutInt32(b.Port)
+		e.PutNullableString(b.Rack)
+	}
+	e.PutInt32(r.ControllerID)
+	e.PutArrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.PutInt16(int16(t.ErrorCode))
+		e.PutString(t.Name)
+		e.PutBool(t.IsInternal)
+		e.PutArrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.PutInt16(int16(p.ErrorCode))
+			e.PutInt32(p.Partition)
+			e.PutInt32(p.Leader)
+			e.PutInt32Array(p.Replicas)
+			e.PutInt32Array(p.ISR)
+		}
+	}
+}
+
+// Decode reads the response body
+func (r *MetadataResponse) Decode(d *Decoder) error {
+	r.Brokers = make([]BrokerMetadata, max(d.ArrayLen(), 0))
+	for i := range r.Brokers {
+		b := &r.Brokers[i]
+		b.NodeID = d.Int32()
+		b.Host = d.String()
+		b.Port = d.Int32()
+		b.Rack = d.NullableString()
+	}
+	r.ControllerID = d.Int32()
+	r.Topics = make([]TopicMetadata, max(d.ArrayLen(), 0))
+	for i := range r.Topics {
+		t := &r.Topics[i]
+		t.ErrorCode = KError(d.Int16())
+		t.Name = d.String()
+		t.IsInternal = d.Bool()
+		t.Partitions = make([]PartitionMetadata, max(d.ArrayLen(), 0))
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			p.ErrorCode = KError(d.Int16())
+			p.Partition = d.Int32()
+			p.Leader = d.Int32()
+			p.Replicas = d.Int32Array()
+			p.ISR = d.Int32Array()
+		}
+	}
+	return d.Err()
+}
+
+// ProducePartition holds the record batches for one partition
+type ProducePartition struct {
+	Partition int32
+	Records   []byte
+}
+
+// ProduceTopic holds the partitions written for one topic
+type ProduceTopic struct {
+	Name       string
+	Partitions []ProducePartition
+}
+
+// ProduceRequest is a Produce v3 request, or v7 when Version is 7, which
+// brokers require for zstd batches; both versions share their layout
+type ProduceRequest struct {
+	Version         int16
+	TransactionalID *string
+	Acks            int16
+	TimeoutMs       int32
+	Topics          []ProduceTopic
+}
+
+func (r *ProduceRequest) APIKey() int16     { return APIKeyProduce }
+func (r *ProduceRequest) APIVersion() int16 { return max(r.Version, 3) }
+
+// Encode writes the request body
+func (r *ProduceRequest) Encode(e *Encoder) {
+	e.PutNullableString(r.TransactionalID)
+	e.PutInt16(r.Acks)
+	e.PutInt32(r.TimeoutMs)
+	e.PutArrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.PutString(t.Name)
+		e.PutArrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.PutInt32(p.Partition)
+			e.PutBytes(p.Records)
+		}
+	}
+}
+
+// Decode reads the request body
+func (r *ProduceRequest) Decode(d *Decoder) error {
+	r.TransactionalID = d.NullableString()
+	r.Acks = d.Int16()
+	r.TimeoutMs = d.Int32()
+	r.Topics = make([]ProduceTopic, max(d.ArrayLen(), 0))
+	for i := range r.Topics {
+		t := &r.Topics[i]
+		t.Name = d.String()
+		t.Partitions = make([]ProducePartition, max(d.ArrayLen(), 0))
+		for j := range t.Partitions {
+			t.Partitions[j].Partition = d.Int32()
+			t.Partitions[j].Records = d.Bytes()
+		}
+	}
+	return d.Err()
+}
+
+// ProducePartitionResponse is the outcome of writing to one partition
+type ProducePartitionResponse struct {
+	Partition       int32
+	ErrorCode       KError
+	BaseOffset      int64
+	LogAppendTimeMs int64
+	// LogStartOffset is only sent from v5
+	LogStartOffset int64
+}
+
+// ProduceTopicResponse holds the partition outcomes for one topic
+type ProduceTopicResponse struct {
+	Name       string
+	Partitions []ProducePartitionResponse
+}
+
+// ProduceResponse is a Produce v3 response, or v7 when Version is 7
+type ProduceResponse struct {
+	Version        int16
+	Topics         []ProduceTopicResponse
+	ThrottleTimeMs int32
+}
+
+// Encode writes the response body
+func (r *ProduceResponse) Encode(e *Encoder) {
+	e.PutArrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.PutString(t.Name)
+		e.PutArrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.PutInt32(p.Partition)
+			e.PutInt16(int16(p.ErrorCode))
+			e.PutInt64(p.BaseOffset)
+			e.PutInt64(p.LogAppendTimeMs)
+			if r.Version >= 5 {
+				e.PutInt64(p.LogStartOffset)
+			}
+		}
+	}
+	e.PutInt32(r.ThrottleTimeMs)
+}
+
+// Decode reads the response body
+func (r *ProduceResponse) Decode(d *Decoder) error {
+	r.Topics = make([]ProduceTopicResponse, max(d.ArrayLen(), 0))
+	for i := range r.Topics {
+		t := &r.Topics[i]
+		t.Name = d.String()
+		t.Partitions = make([]ProducePartitionResponse, max(d.ArrayLen(), 0))
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			p.Partition = d.Int32()
+			p.ErrorCode = KError(d.Int16())
+			p.BaseOffset = d.Int64()
+			p.LogAppendTimeMs = d.Int64()
+			if r.Version >= 5 {
+				p.LogStartOffset = d.Int64()
+			}
+		}
+	}
+	r.ThrottleTimeMs = d.Int32()
+	return d.Err()
+}
+
+// InitProducerIDRequest is an InitProducerId v0 request
+type InitProducerIDRequest struct {
+	TransactionalID      *string
+	TransactionTimeoutMs int32
+}
+
+func (r *InitProducerIDRequest) APIKey() int16     { return APIKeyInitProducerID }
+func (r *InitProducerIDRequest) APIVersion() int16 { return 0 }
+
+// Encode writes the request body
+func (r *InitProducerIDRequest) Encode(e *Encoder) {
+	e.PutNullableString(r.TransactionalID)
+	e.PutInt32(r.TransactionTimeoutMs)
+}
+
+// Decode reads the request body
+func (r *InitProducerIDRequest) Decode(d *Decoder) error {
+	r.TransactionalID = d.NullableString()
+	r.TransactionTimeoutMs = d.Int32()
+	return d.Err()
+}
+
+// InitProducerIDResponse is an InitProducerId v0 response
+type InitProducerIDResponse struct {
+	ThrottleTimeMs int32
+	ErrorCode      KError
+	ProducerID     int64
+	ProducerEpoch  int16
+}
+
+// Encode writes the response body
+func (r *InitProducerIDResponse) Encode(e *Encoder) {
+	e.PutInt32(r.ThrottleTimeMs)
+	e.PutInt16(int16(r.ErrorCode))
+	e.PutInt64(r.ProducerID)
+	e.PutInt16(r.ProducerEpoch)
+}
+
+// Decode reads the response body
+func (r *InitProducerIDResponse) Decode(d *Decoder) error {
+	r.ThrottleTimeMs = d.Int32()
+	r.ErrorCode = KError(d.Int16())
+	r.ProducerID = d.Int64()
+	r.ProducerEpoch = d.Int16()
+	return d.Err()
+}
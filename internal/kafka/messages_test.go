@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type message interface {
+	Encode(e *Encoder)
+	Decode(d *Decoder) error
+}
+
+func TestMessagesRoundTrip(t *testing.T) {
+	rack := "eu-west-1a"
+	testCases := []struct {
+		name    string
+		message message
+		empty   func() message
+	}{
+		{
+			name:    "metadata request for all topics",
+			message: &MetadataRequest{},
+			empty:   func() message { return &MetadataRequest{Topics: []string{"x"}} },
+		}, {
+			name:    "metadata request",
+			message: &MetadataRequest{Topics: []string{"orders", "payments"}},
+			empty:   func() message { return &MetadataRequest{} },
+		}, {
+			name: "metadata response",
+			message: &MetadataResponse{
+				Brokers:      []BrokerMetadata{{NodeID: 1, Host: "localhost", Port: 9092, Rack: &rack}},
+				ControllerID: 1,
+				Topics: []TopicMetadata{{
+					Name:       "orders",
+					Partitions: []PartitionMetadata{{Partition: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}},
+				}, {
+					ErrorCode:  ErrUnknownTopicOrPartition,
+					Name:       "missing",
+					Partitions: []PartitionMetadata{},
+				}},
+			},
+			empty: func() message { return &MetadataResponse{} },
+		}, {
+			name: "produce request",
+			message: &ProduceRequest{
+				Acks:      -1,
+				TimeoutMs: 1000,
+				Topics: []ProduceTopic{{
+					Name:       "orders",
+					Partitions: []ProducePartition{{Partition: 2, Records: []byte{1, 2, 3}}},
+				}},
+			},
+			empty: func() message { return &ProduceRequest{} },
+		}, {
+			name: "produce response",
+			message: &ProduceResponse{
+				Topics: []ProduceTopicResponse{{
+					Name:       "orders",
+					Partitions: []ProducePartitionResponse{{Partition: 2, ErrorCode: ErrNotLeaderOrFollower, BaseOffset: -1, LogAppendTimeMs: -1}},
+				}},
+				ThrottleTimeMs: 5,
+			},
+			empty: func() message { return &ProduceResponse{} },
+		}, {
+			name: "produce response v7",
+			message: &ProduceResponse{
+				Version: 7,
+				Topics: []ProduceTopicResponse{{
+					Name:       "orders",
+					Partitions: []ProducePartitionResponse{{Partition: 0, BaseOffset: 10, LogAppendTimeMs: -1, LogStartOffset: 3}},
+				}},
+			},
+			empty: func() message { return &ProduceResponse{Version: 7} },
+		}, {
+			name:    "init producer id request",
+			message: &InitProducerIDRequest{TransactionTimeoutMs: -1},
+			empty:   func() message { return &InitProducerIDRequest{} },
+		}, {
+			name:    "init producer id response",
+			message: &InitProducerIDResponse{ProducerID: 4000, ProducerEpoch: 2},
+			empty:   func() message { return &InitProducerIDResponse{} },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEncoder()
+			tc.message.Encode(e)
+
+			decoded := tc.empty()
+			d := NewDecoder(e.Bytes())
+			require.NoError(t, decoded.Decode(d))
+			assert.Equal(t, 0, d.Remaining())
+			assert.Equal(t, tc.message, decoded)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import "sync/atomic"
+
+// partitioner picks a partition the same way the Java client's default
+// partitioner does for keyed records, so keys land on the same partition
+// regardless of which client produced them
+type partitioner struct {
+	counter atomic.Uint32
+}
+
+// partition returns the partition index for a key among n partitions
+func (p *partitioner) partition(key []byte, n int) int32 {
+	if key == nil {
+		return int32((p.counter.Add(1) - 1) % uint32(n))
+	}
+	return int32((murmur2(key) & 0x7fffffff) % int32(n))
+}
+
+// murmur2 is the 32-bit MurmurHash2 variant used by Kafka
+func murmur2(data []byte) int32 {
+	const (
+		seed = uint32(0x9747b28c)
+		m    = uint32(0x5bd1e995)
+		r    = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMurmur2(t *testing.T) {
+	// values from the Java client's UtilsTest
+	testCases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for input, expected := range testCases {
+		assert.Equal(t, expected, murmur2([]byte(input)), input)
+	}
+}
+
+func TestPartitioner(t *testing.T) {
+	var p partitioner
+	assert.Equal(t, p.partition([]byte("key"), 6), p.partition([]byte("key"), 6))
+
+	seen := map[int32]bool{}
+	for i := 0; i < 3; i++ {
+		seen[p.partition(nil, 3)] = true
+	}
+	assert.Len(t, seen, 3, "unkeyed messages should be spread round-robin")
+}
@@ -0,0 +1,565 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var (
+	// ErrClosed is returned when producing through a closed producer
+	ErrClosed = errors.New("kafka: producer closed")
+	// ErrNoBrokers is returned when no broker could be reached
+	ErrNoBrokers = errors.New("kafka: no broker available")
+)
+
+// Acks is the number of acknowledgements the partition leader must
+// receive before answering a Produce request
+type Acks int16
+
+// Supported acknowledgement levels
+const (
+	AcksAll    Acks = -1
+	AcksNone   Acks = 0
+	AcksLeader Acks = 1
+)
+
+// ParseAcks parses "all", "-1", "1" or "0"
+func ParseAcks(s string) (Acks, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "all", "-1":
+		return AcksAll, nil
+	case "1":
+		return AcksLeader, nil
+	case "0":
+		return AcksNone, nil
+	}
+	return 0, fmt.Errorf("kafka: invalid acks %q, expected all, 1 or 0", s)
+}
+
+// ProducerConfig configures a Producer
+type ProducerConfig struct {
+	Brokers      []string
+	ClientID     string
+	Acks         Acks
+	Idempotent   bool
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// Compression is the codec of the record batches written
+	Compression Compression
+}
+
+// Message is a message to be produced
+type Message struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Headers   []RecordHeader
+	Timestamp time.Time
+}
+
+// Result describes where a message was written. Offset is -1 when the
+// broker did not report it (acks=0 or a duplicate idempotent retry).
+type Result struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// Producer writes messages to a Kafka cluster. It is safe for concurrent
+// use; batches for the same partition are sent one at a time so that
+// idempotent sequence numbers stay ordered.
+type Producer struct {
+	cfg         ProducerConfig
+	partitioner partitioner
+
+	mu         sync.Mutex
+	closed     bool
+	brokers    map[int32]string
+	leaders    map[string][]int32
+	conns      map[string]*conn
+	partitions map[topicPartition]*sync.Mutex
+
+	pidMu         sync.Mutex
+	producerID    int64
+	producerEpoch int16
+	sequences     map[topicPartition]int32
+}
+
+// NewProducer creates a producer. Brokers are contacted lazily on the
+// first call to Produce.
+func NewProducer(cfg ProducerConfig) (*Producer, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka: at least one broker is required")
+	}
+	if cfg.Idempotent && cfg.Acks != AcksAll {
+		return nil, errors.New("kafka: idempotent producer requires acks=all")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	return &Producer{
+		cfg:        cfg,
+		brokers:    make(map[int32]string),
+		leaders:    make(map[string][]int32),
+		conns:      make(map[string]*conn),
+		partitions: make(map[topicPartition]*sync.Mutex),
+		producerID: -1,
+		sequences:  make(map[topicPartition]int32),
+	}, nil
+}
+
+// Produce writes a message and waits for the configured acknowledgement
+func (p *Producer) Produce(ctx context.Context, msg Message) (*Result, error) {
+	partition, err := p.Partition(ctx, msg.Topic, msg.Key)
+	if err != nil {
+		return nil, err
+	}
+	results, err := p.ProduceBatch(ctx, msg.Topic, partition, []Message{msg})
+	if err != nil {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+// Partition returns the partition a message with key is written to; keyless
+// messages are spread over the partitions in turn
+func (p *Producer) Partition(ctx context.Context, topic string, key []byte) (int32, error) {
+	if p.isClosed() {
+		return 0, ErrClosed
+	}
+	var (
+		leaders []int32
+		err     error
+	)
+	for attempt := 0; ; attempt++ {
+		leaders, err = p.partitionsFor(ctx, topic)
+		if err == nil || !IsRetriable(err) || attempt >= p.cfg.MaxRetries {
+			break
+		}
+		if err := p.backoff(ctx); err != nil {
+			return 0, err
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.partitioner.partition(key, len(leaders)), nil
+}
+
+// ProduceBatch writes messages to one partition of a topic in a single
+// record batch and waits for the configured acknowledgement. The results
+// are in the order of the messages; the Topic of the messages is ignored.
+func (p *Producer) ProduceBatch(ctx context.Context, topic string, partition int32, msgs []Message) ([]Result, error) {
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	records := make([]Record, len(msgs))
+	now := time.Now()
+	for i, msg := range msgs {
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = now
+		}
+		records[i] = Record{Key: msg.Key, Value: msg.Value, Headers: msg.Headers, Timestamp: msg.Timestamp}
+	}
+
+	tp := topicPartition{topic: topic, partition: partition}
+	lock := p.partitionLock(tp)
+	lock.Lock()
+	defer lock.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		results, err := p.produceOnce(ctx, tp, records)
+		if err == nil {
+			return results, nil
+		}
+		if !IsRetriable(err) || attempt >= p.cfg.MaxRetries {
+			return nil, err
+		}
+		if err := p.backoff(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes every broker connection
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var errs []error
+	for addr, c := range p.conns {
+		errs = append(errs, c.close())
+		delete(p.conns, addr)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Producer) produceOnce(ctx context.Context, tp topicPartition, records []Record) ([]Result, error) {
+	batch := NewRecordBatch(records...)
+	batch.Compression = p.cfg.Compression
+	if p.cfg.Idempotent {
+		if err := p.ensureProducerID(ctx); err != nil {
+			return nil, err
+		}
+		p.pidMu.Lock()
+		batch.ProducerID, batch.ProducerEpoch, batch.BaseSequence = p.producerID, p.producerEpoch, p.sequences[tp]
+		p.pidMu.Unlock()
+	}
+	encoded, err := batch.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := p.leaderConn(ctx, tp)
+	if err != nil {
+		return nil, err
+	}
+	req := &ProduceRequest{
+		Version:   p.produceVersion(),
+		Acks:      int16(p.cfg.Acks),
+		TimeoutMs: int32(p.cfg.Timeout / time.Millisecond),
+		Topics: []ProduceTopic{{
+			Name:       tp.topic,
+			Partitions: []ProducePartition{{Partition: tp.partition, Records: encoded}},
+		}},
+	}
+	results := make([]Result, len(records))
+	for i, r := range records {
+		results[i] = Result{Topic: tp.topic, Partition: tp.partition, Offset: -1, Timestamp: r.Timestamp}
+	}
+
+	if p.cfg.Acks == AcksNone {
+		if err := c.roundTrip(ctx, req, nil); err != nil {
+			p.dropConn(c, err)
+			return nil, err
+		}
+		return results, nil
+	}
+
+	resp := &ProduceResponse{Version: req.Version}
+	if err := c.roundTrip(ctx, req, resp); err != nil {
+		p.dropConn(c, err)
+		p.invalidate(tp.topic)
+		return nil, err
+	}
+	partition, err := findPartition(resp, tp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch partition.ErrorCode {
+	case ErrNone:
+		for i := range results {
+			results[i].Offset = partition.BaseOffset + int64(i)
+			if partition.LogAppendTimeMs >= 0 {
+				results[i].Timestamp = time.UnixMilli(partition.LogAppendTimeMs)
+			}
+		}
+		p.advanceSequence(tp, batch.BaseSequence, len(records))
+		return results, nil
+	case ErrDuplicateSequenceNumber:
+		// an earlier attempt was written but its response was lost
+		p.advanceSequence(tp, batch.BaseSequence, len(records))
+		return results, nil
+	case ErrOutOfOrderSequenceNumber, ErrUnknownProducerID, ErrInvalidProducerEpoch:
+		p.resetProducerID()
+	}
+	if partition.ErrorCode.refreshesMetadata() {
+		p.invalidate(tp.topic)
+	}
+	return nil, partition.ErrorCode
+}
+
+func findPartition(resp *ProduceResponse, tp topicPartition) (*ProducePartitionResponse, error) {
+	for _, t := range resp.Topics {
+		if t.Name != tp.topic {
+			continue
+		}
+		for i := range t.Partitions {
+			if t.Partitions[i].Partition == tp.partition {
+				return &t.Partitions[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: no result for %s-%d", ErrMalformed, tp.topic, tp.partition)
+}
+
+// partitionsFor returns the leader of every partition of a topic,
+// fetching metadata when the topic is not cached
+func (p *Producer) partitionsFor(ctx context.Context, topic string) ([]int32, error) {
+	p.mu.Lock()
+	leaders, ok := p.leaders[topic]
+	p.mu.Unlock()
+	if ok {
+		return leaders, nil
+	}
+	if err := p.refreshMetadata(ctx, topic); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if leaders, ok = p.leaders[topic]; !ok {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	return leaders, nil
+}
+
+func (p *Producer) refreshMetadata(ctx context.Context, topic string) error {
+	resp := &MetadataResponse{}
+	if err := p.anyBroker(ctx, &MetadataRequest{Topics: []string{topic}}, resp); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range resp.Brokers {
+		p.brokers[b.NodeID] = net.JoinHostPort(b.Host, strconv.Itoa(int(b.Port)))
+	}
+	for _, t := range resp.Topics {
+		if t.ErrorCode != ErrNone {
+			if t.Name == topic {
+				return t.ErrorCode
+			}
+			continue
+		}
+		if len(t.Partitions) == 0 {
+			return ErrLeaderNotAvailable
+		}
+		leaders := make([]int32, len(t.Partitions))
+		for i := range leaders {
+			leaders[i] = -1
+		}
+		for _, part := range t.Partitions {
+			if part.Partition >= 0 && int(part.Partition) < len(leaders) && part.ErrorCode == ErrNone {
+				leaders[part.Partition] = part.Leader
+			}
+		}
+		p.leaders[t.Name] = leaders
+	}
+	return nil
+}
+
+// invalidate drops cached metadata for a topic
+func (p *Producer) invalidate(topic string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.leaders, topic)
+}
+
+func (p *Producer) leaderConn(ctx context.Context, tp topicPartition) (*conn, error) {
+	leaders, err := p.partitionsFor(ctx, tp.topic)
+	if err != nil {
+		return nil, err
+	}
+	if int(tp.partition) >= len(leaders) {
+		p.invalidate(tp.topic)
+		return nil, ErrUnknownTopicOrPartition
+	}
+	leader := leaders[tp.partition]
+	p.mu.Lock()
+	addr, ok := p.brokers[leader]
+	p.mu.Unlock()
+	if leader < 0 || !ok {
+		p.invalidate(tp.topic)
+		return nil, ErrLeaderNotAvailable
+	}
+	return p.connect(ctx, addr)
+}
+
+// anyBroker sends a request to the first broker that answers, trying
+// known brokers before the bootstrap list
+func (p *Producer) anyBroker(ctx context.Context, req Request, resp Response) error {
+	p.mu.Lock()
+	addrs := make([]string, 0, len(p.brokers)+len(p.cfg.Brokers))
+	for _, addr := range p.brokers {
+		addrs = append(addrs, addr)
+	}
+	p.mu.Unlock()
+	addrs = append(addrs, p.cfg.Brokers...)
+
+	errs := []error{ErrNoBrokers}
+	for _, addr := range addrs {
+		c, err := p.connect(ctx, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := c.roundTrip(ctx, req, resp); err != nil {
+			p.dropConn(c, err)
+			errs = append(errs, err)
+			continue
+		}
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Producer) connect(ctx context.Context, addr string) (*conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if c, ok := p.conns[addr]; ok {
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+
+	c, err := dial(ctx, addr, p.cfg.ClientID, p.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		_ = c.close()
+		return nil, ErrClosed
+	}
+	if existing, ok := p.conns[addr]; ok {
+		_ = c.close()
+		return existing, nil
+	}
+	p.conns[addr] = c
+	return c, nil
+}
+
+// dropConn closes a connection after a network error so the next request
+// dials again. A request given up by its caller leaves the connection to
+// the requests still in flight on it.
+func (p *Producer) dropConn(c *conn, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	p.mu.Lock()
+	if p.conns[c.addr] == c {
+		delete(p.conns, c.addr)
+	}
+	p.mu.Unlock()
+	_ = c.close()
+}
+
+func (p *Producer) partitionLock(tp topicPartition) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lock, ok := p.partitions[tp]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.partitions[tp] = lock
+	}
+	return lock
+}
+
+// ensureProducerID obtains a producer ID for idempotent writes
+func (p *Producer) ensureProducerID(ctx context.Context) error {
+	p.pidMu.Lock()
+	defer p.pidMu.Unlock()
+	if p.producerID >= 0 {
+		return nil
+	}
+	resp := &InitProducerIDResponse{}
+	if err := p.anyBroker(ctx, &InitProducerIDRequest{TransactionTimeoutMs: -1}, resp); err != nil {
+		return err
+	}
+	if err := resp.ErrorCode.asError(); err != nil {
+		return err
+	}
+	p.producerID, p.producerEpoch = resp.ProducerID, resp.ProducerEpoch
+	clear(p.sequences)
+	return nil
+}
+
+// resetProducerID forces a new producer ID after the broker lost track of
+// our sequence numbers
+func (p *Producer) resetProducerID() {
+	p.pidMu.Lock()
+	defer p.pidMu.Unlock()
+	p.producerID, p.producerEpoch = -1, -1
+	clear(p.sequences)
+}
+
+// advanceSequence moves the partition sequence past a written batch of n
+// records, wrapping around like the broker, unless the producer ID was
+// reset meanwhile
+func (p *Producer) advanceSequence(tp topicPartition, sequence int32, n int) {
+	if !p.cfg.Idempotent {
+		return
+	}
+	p.pidMu.Lock()
+	defer p.pidMu.Unlock()
+	if p.sequences[tp] != sequence {
+		return
+	}
+	if sequence > math.MaxInt32-int32(n) {
+		p.sequences[tp] = int32(n) - (math.MaxInt32 - sequence) - 1
+		return
+	}
+	p.sequences[tp] = sequence + int32(n)
+}
+
+// produceVersion is the Produce version the batches are sent with
+func (p *Producer) produceVersion() int16 {
+	if p.cfg.Compression == CompressionZstd {
+		return 7
+	}
+	return 3
+}
+
+func (p *Producer) backoff(ctx context.Context) error {
+	timer := time.NewTimer(p.cfg.RetryBackoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *Producer) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// IsRetriable reports whether an error returned by the producer may go
+// away on a later attempt
+func IsRetriable(err error) bool {
+	var kerr KError
+	switch {
+	case errors.As(err, &kerr):
+		return kerr.Retriable()
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrClosed), errors.Is(err, ErrMalformed), errors.Is(err, ErrUnsupportedCompression):
+		return false
+	}
+	// anything else is a network error
+	return true
+}
@@ -0,0 +1,371 @@
+package kafka_test
+
+import (
+	"context"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka/kafkatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseAcks(t *testing.T) {
+	for input, expected := range map[string]kafka.Acks{"all": kafka.AcksAll, "-1": kafka.AcksAll, "1": kafka.AcksLeader, "0": kafka.AcksNone} {
+		acks, err := kafka.ParseAcks(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, acks)
+	}
+	_, err := kafka.ParseAcks("2")
+	assert.Error(t, err)
+}
+
+func TestNewProducer(t *testing.T) {
+	_, err := kafka.NewProducer(kafka.ProducerConfig{})
+	assert.Error(t, err, "brokers are required")
+
+	_, err = kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{"localhost:9092"}, Acks: kafka.AcksLeader, Idempotent: true})
+	assert.Error(t, err, "idempotence requires acks=all")
+
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{"localhost:9092"}, Acks: kafka.AcksAll, Idempotent: true})
+	assert.NoError(t, err)
+	assert.NotNil(t, producer)
+}
+
+func TestProducer_Produce(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       kafka.ProducerConfig
+		setup     func(*kafkatest.Broker)
+		messages  []kafka.Message
+		assertion func(*testing.T, *kafkatest.Broker, []*kafka.Result, error)
+	}{
+		{
+			name: "should write keyed messages to a single partition with acks=all",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksAll},
+			messages: []kafka.Message{
+				{Topic: "orders", Key: []byte("order-1"), Value: []byte("a"), Headers: []kafka.RecordHeader{{Key: "source", Value: []byte("web")}}},
+				{Topic: "orders", Key: []byte("order-1"), Value: []byte("b")},
+			},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				require.NoError(t, err)
+				require.Len(t, results, 2)
+				assert.Equal(t, results[0].Partition, results[1].Partition)
+				assert.Equal(t, int64(0), results[0].Offset)
+				assert.Equal(t, int64(1), results[1].Offset)
+
+				records := broker.Records("orders", results[0].Partition)
+				require.Len(t, records, 2)
+				assert.Equal(t, []byte("order-1"), records[0].Key)
+				assert.Equal(t, []byte("a"), records[0].Value)
+				assert.Equal(t, []kafka.RecordHeader{{Key: "source", Value: []byte("web")}}, records[0].Headers)
+
+				for _, req := range broker.Requests() {
+					assert.Equal(t, "test-client", *req.ClientID)
+				}
+			},
+		}, {
+			name:     "should not wait for a response with acks=0",
+			cfg:      kafka.ProducerConfig{Acks: kafka.AcksNone},
+			messages: []kafka.Message{{Topic: "orders", Key: []byte("k"), Value: []byte("a")}},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int64(-1), results[0].Offset)
+				assert.Eventually(t, func() bool {
+					return len(broker.Records("orders", results[0].Partition)) == 1
+				}, time.Second, 10*time.Millisecond)
+			},
+		}, {
+			name: "should send producer id and sequence numbers when idempotent",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksAll, Idempotent: true},
+			messages: []kafka.Message{
+				{Topic: "orders", Key: []byte("k"), Value: []byte("a")},
+				{Topic: "orders", Key: []byte("k"), Value: []byte("b")},
+				{Topic: "orders", Key: []byte("k"), Value: []byte("c")},
+			},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				require.NoError(t, err)
+				batches := broker.Batches("orders", results[0].Partition)
+				require.Len(t, batches, 3)
+				for i, batch := range batches {
+					assert.Equal(t, int64(1000), batch.ProducerID)
+					assert.Equal(t, int32(i), batch.BaseSequence)
+				}
+				assert.Equal(t, 1, countRequests(broker, kafka.APIKeyInitProducerID))
+			},
+		}, {
+			name: "should refresh metadata and retry on retriable errors",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksLeader, MaxRetries: 3},
+			setup: func(broker *kafkatest.Broker) {
+				broker.InjectError("orders", 0, kafka.ErrNotLeaderOrFollower, kafka.ErrNotEnoughReplicas)
+			},
+			messages: []kafka.Message{{Topic: "orders", Key: keyFor(0), Value: []byte("a")}},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int32(0), results[0].Partition)
+				assert.Len(t, broker.Records("orders", 0), 1)
+				assert.Equal(t, 3, countRequests(broker, kafka.APIKeyProduce))
+				assert.Equal(t, 2, countRequests(broker, kafka.APIKeyMetadata))
+			},
+		}, {
+			name: "should give up once retries are exhausted",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksLeader, MaxRetries: 1},
+			setup: func(broker *kafkatest.Broker) {
+				broker.InjectError("orders", 0, kafka.ErrRequestTimedOut, kafka.ErrRequestTimedOut)
+			},
+			messages: []kafka.Message{{Topic: "orders", Key: keyFor(0), Value: []byte("a")}},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				assert.ErrorIs(t, err, kafka.ErrRequestTimedOut)
+				assert.Equal(t, 2, countRequests(broker, kafka.APIKeyProduce))
+			},
+		}, {
+			name: "should not retry non-retriable errors",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksLeader, MaxRetries: 3},
+			setup: func(broker *kafkatest.Broker) {
+				broker.InjectError("orders", 0, kafka.ErrMessageTooLarge)
+			},
+			messages: []kafka.Message{{Topic: "orders", Key: keyFor(0), Value: []byte("a")}},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				assert.ErrorIs(t, err, kafka.ErrMessageTooLarge)
+				assert.Equal(t, 1, countRequests(broker, kafka.APIKeyProduce))
+			},
+		}, {
+			name:     "should fail for an unknown topic",
+			cfg:      kafka.ProducerConfig{Acks: kafka.AcksLeader},
+			messages: []kafka.Message{{Topic: "missing", Value: []byte("a")}},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				assert.ErrorIs(t, err, kafka.ErrUnknownTopicOrPartition)
+			},
+		}, {
+			name: "should not duplicate a message whose response was lost",
+			cfg:  kafka.ProducerConfig{Acks: kafka.AcksAll, Idempotent: true, MaxRetries: 2},
+			setup: func(broker *kafkatest.Broker) {
+				broker.DropResponses(1)
+			},
+			messages: []kafka.Message{
+				{Topic: "orders", Key: keyFor(0), Value: []byte("a")},
+				{Topic: "orders", Key: keyFor(0), Value: []byte("b")},
+			},
+			assertion: func(t *testing.T, broker *kafkatest.Broker, results []*kafka.Result, err error) {
+				require.NoError(t, err)
+				records := broker.Records("orders", 0)
+				require.Len(t, records, 2)
+				assert.Equal(t, []byte("a"), records[0].Value)
+				assert.Equal(t, []byte("b"), records[1].Value)
+				assert.Equal(t, int64(1), results[1].Offset)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := kafkatest.NewBroker()
+			defer broker.Close()
+			broker.CreateTopic("orders", 3)
+			if tc.setup != nil {
+				tc.setup(broker)
+			}
+
+			tc.cfg.Brokers = []string{broker.Addr()}
+			tc.cfg.ClientID = "test-client"
+			tc.cfg.Timeout = time.Second
+			tc.cfg.RetryBackoff = time.Millisecond
+			producer, err := kafka.NewProducer(tc.cfg)
+			require.NoError(t, err)
+			defer producer.Close()
+
+			var results []*kafka.Result
+			for _, msg := range tc.messages {
+				var result *kafka.Result
+				result, err = producer.Produce(context.Background(), msg)
+				if err != nil {
+					break
+				}
+				results = append(results, result)
+			}
+			tc.assertion(t, broker, results, err)
+		})
+	}
+}
+
+func TestProducer_ProduceBatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		compression kafka.Compression
+		version     int16
+	}{
+		{name: "should write an uncompressed batch", compression: kafka.CompressionNone, version: 3},
+		{name: "should write a gzip batch", compression: kafka.CompressionGzip, version: 3},
+		{name: "should write a snappy batch", compression: kafka.CompressionSnappy, version: 3},
+		{name: "should write an lz4 batch", compression: kafka.CompressionLZ4, version: 3},
+		{name: "should write a zstd batch through produce v7", compression: kafka.CompressionZstd, version: 7},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := kafkatest.NewBroker()
+			defer broker.Close()
+			broker.CreateTopic("orders", 3)
+			producer, err := kafka.NewProducer(kafka.ProducerConfig{
+				Brokers:     []string{broker.Addr()},
+				Acks:        kafka.AcksAll,
+				Idempotent:  true,
+				Compression: tc.compression,
+				Timeout:     time.Second,
+			})
+			require.NoError(t, err)
+			defer producer.Close()
+
+			partition, err := producer.Partition(context.Background(), "orders", keyFor(2))
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), partition)
+			for i := 0; i < 2; i++ {
+				results, err := producer.ProduceBatch(context.Background(), "orders", partition, []kafka.Message{
+					{Key: keyFor(2), Value: []byte("a")},
+					{Key: keyFor(2), Value: []byte("b")},
+					{Key: keyFor(2), Value: []byte("c")},
+				})
+				require.NoError(t, err)
+				require.Len(t, results, 3)
+				for j, result := range results {
+					assert.Equal(t, "orders", result.Topic)
+					assert.Equal(t, int32(2), result.Partition)
+					assert.Equal(t, int64(i*3+j), result.Offset)
+				}
+			}
+
+			batches := broker.Batches("orders", 2)
+			require.Len(t, batches, 2)
+			for i, batch := range batches {
+				assert.Equal(t, tc.compression, batch.Compression)
+				assert.Equal(t, int32(i*3), batch.BaseSequence, "sequences advance by the records of a batch")
+				require.Len(t, batch.Records, 3)
+				assert.Equal(t, []byte("c"), batch.Records[2].Value)
+			}
+			for _, req := range broker.Requests() {
+				if req.APIKey == kafka.APIKeyProduce {
+					assert.Equal(t, tc.version, req.APIVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestProducer_Pipelining(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 3)
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{broker.Addr()}, Acks: kafka.AcksAll, Timeout: time.Second})
+	require.NoError(t, err)
+	defer producer.Close()
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Key: keyFor(0), Value: []byte("a")})
+	require.NoError(t, err)
+
+	// the partitions share the connection, without waiting on each other
+	broker.SetLatency(100 * time.Millisecond)
+	start := time.Now()
+	errs := make(chan error, 3)
+	for p := int32(0); p < 3; p++ {
+		go func(p int32) {
+			_, err := producer.Produce(context.Background(), kafka.Message{Topic: "orders", Key: keyFor(p), Value: []byte("b")})
+			errs <- err
+		}(p)
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+	assert.Len(t, broker.Records("orders", 2), 1)
+}
+
+func TestProducer_Cancel(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 2)
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{broker.Addr()}, Acks: kafka.AcksAll, Timeout: time.Second})
+	require.NoError(t, err)
+	defer producer.Close()
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Key: keyFor(0), Value: []byte("a")})
+	require.NoError(t, err)
+
+	// a caller giving up does not fail the requests sharing its connection
+	broker.SetLatency(100 * time.Millisecond)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := producer.Produce(context.Background(), kafka.Message{Topic: "orders", Key: keyFor(1), Value: []byte("b")})
+		errs <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = producer.Produce(ctx, kafka.Message{Topic: "orders", Key: keyFor(0), Value: []byte("c")})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, <-errs)
+
+	broker.SetLatency(0)
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Key: keyFor(1), Value: []byte("d")})
+	assert.NoError(t, err)
+	assert.Len(t, broker.Records("orders", 1), 2)
+}
+
+func TestProducer_Timeout(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 1)
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{broker.Addr()}, Acks: kafka.AcksAll, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer producer.Close()
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("a")})
+	require.NoError(t, err)
+
+	broker.SetLatency(300 * time.Millisecond)
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("b")})
+	assert.Error(t, err)
+	assert.True(t, kafka.IsRetriable(err))
+
+	// a new connection is dialled once the broker answers again
+	broker.SetLatency(0)
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("c")})
+	assert.NoError(t, err)
+}
+
+func TestProducer_Unreachable(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	addr := broker.Addr()
+	broker.Close()
+
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{addr}, Timeout: time.Second})
+	require.NoError(t, err)
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("a")})
+	assert.ErrorIs(t, err, kafka.ErrNoBrokers)
+}
+
+func TestProducer_Close(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 1)
+
+	producer, err := kafka.NewProducer(kafka.ProducerConfig{Brokers: []string{broker.Addr()}, Acks: kafka.AcksLeader})
+	require.NoError(t, err)
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("a")})
+	require.NoError(t, err)
+
+	require.NoError(t, producer.Close())
+	_, err = producer.Produce(context.Background(), kafka.Message{Topic: "orders", Value: []byte("a")})
+	assert.ErrorIs(t, err, kafka.ErrClosed)
+}
+
+func countRequests(broker *kafkatest.Broker, apiKey int16) int {
+	count := 0
+	for _, req := range broker.Requests() {
+		if req.APIKey == apiKey {
+			count++
+		}
+	}
+	return count
+}
+
+// keyFor returns a key that the default partitioner maps to partition p of a
+// three-partition topic
+func keyFor(p int32) []byte {
+	keys := map[int32]string{0: "f", 1: "a", 2: "b"}
+	return []byte(keys[p])
+}
@@ -0,0 +1,378 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// API keys used by this client
+const (
+	APIKeyProduce        int16 = 0
+	APIKeyMetadata       int16 = 3
+	APIKeyInitProducerID int16 = 22
+)
+
+// maxFrameSize bounds the size of a single request or response
+const maxFrameSize = 100 * 1024 * 1024
+
+var (
+	// ErrMalformed is returned when a message cannot be decoded
+	ErrMalformed = errors.New("kafka: malformed message")
+	// ErrFrameTooLarge is returned when a frame exceeds maxFrameSize
+	ErrFrameTooLarge = errors.New("kafka: frame too large")
+)
+
+// Request is a Kafka request body
+type Request interface {
+	APIKey() int16
+	APIVersion() int16
+	Encode(e *Encoder)
+}
+
+// Response is a Kafka response body
+type Response interface {
+	Decode(d *Decoder) error
+}
+
+// RequestHeader is the request header v1
+type RequestHeader struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      *string
+}
+
+// Encode writes the header
+func (h *RequestHeader) Encode(e *Encoder) {
+	e.PutInt16(h.APIKey)
+	e.PutInt16(h.APIVersion)
+	e.PutInt32(h.CorrelationID)
+	e.PutNullableString(h.ClientID)
+}
+
+// Decode reads the header
+func (h *RequestHeader) Decode(d *Decoder) error {
+	h.APIKey = d.Int16()
+	h.APIVersion = d.Int16()
+	h.CorrelationID = d.Int32()
+	h.ClientID = d.NullableString()
+	return d.Err()
+}
+
+// ReadFrame reads a size-prefixed frame
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int32(binary.BigEndian.Uint32(size[:]))
+	if n < 0 || n > maxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// WriteFrame writes a size-prefixed frame
+func WriteFrame(w io.Writer, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// Encoder serialises Kafka primitive types
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder creates an empty encoder
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Bytes returns the encoded bytes
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// PutInt8 writes an int8
+func (e *Encoder) PutInt8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+// PutBool writes a boolean
+func (e *Encoder) PutBool(v bool) {
+	if v {
+		e.PutInt8(1)
+		return
+	}
+	e.PutInt8(0)
+}
+
+// PutInt16 writes a big-endian int16
+func (e *Encoder) PutInt16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+// PutInt32 writes a big-endian int32
+func (e *Encoder) PutInt32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+// PutUint32 writes a big-endian uint32
+func (e *Encoder) PutUint32(v uint32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, v)
+}
+
+// PutInt64 writes a big-endian int64
+func (e *Encoder) PutInt64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+// PutVarint writes a zig-zag encoded varint
+func (e *Encoder) PutVarint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+// PutString writes an int16 length-prefixed string
+func (e *Encoder) PutString(s string) {
+	e.PutInt16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// PutNullableString writes a string or -1 for nil
+func (e *Encoder) PutNullableString(s *string) {
+	if s == nil {
+		e.PutInt16(-1)
+		return
+	}
+	e.PutString(*s)
+}
+
+// PutBytes writes int32 length-prefixed bytes or -1 for nil
+func (e *Encoder) PutBytes(b []byte) {
+	if b == nil {
+		e.PutInt32(-1)
+		return
+	}
+	e.PutInt32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// PutVarintBytes writes varint length-prefixed bytes or -1 for nil
+func (e *Encoder) PutVarintBytes(b []byte) {
+	if b == nil {
+		e.PutVarint(-1)
+		return
+	}
+	e.PutVarint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// PutRaw writes bytes without a length prefix
+func (e *Encoder) PutRaw(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+// PutArrayLen writes an int32 array length
+func (e *Encoder) PutArrayLen(n int) {
+	e.PutInt32(int32(n))
+}
+
+// PutInt32Array writes an array of int32
+func (e *Encoder) PutInt32Array(values []int32) {
+	e.PutArrayLen(len(values))
+	for _, v := range values {
+		e.PutInt32(v)
+	}
+}
+
+// Decoder reads Kafka primitive types. The first error is sticky and
+// returned by Err; later reads return zero values.
+type Decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+// NewDecoder creates a decoder over b
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Err returns the first decoding error
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Remaining returns the number of unread bytes
+func (d *Decoder) Remaining() int {
+	return len(d.buf) - d.off
+}
+
+func (d *Decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.Remaining() < n {
+		d.err = fmt.Errorf("%w: need %d bytes, have %d", ErrMalformed, n, d.Remaining())
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+// Int8 reads an int8
+func (d *Decoder) Int8() int8 {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+// Bool reads a boolean
+func (d *Decoder) Bool() bool {
+	return d.Int8() != 0
+}
+
+// Int16 reads a big-endian int16
+func (d *Decoder) Int16() int16 {
+	b := d.take(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+// Int32 reads a big-endian int32
+func (d *Decoder) Int32() int32 {
+	return int32(d.Uint32())
+}
+
+// Uint32 reads a big-endian uint32
+func (d *Decoder) Uint32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// Int64 reads a big-endian int64
+func (d *Decoder) Int64() int64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// Varint reads a zig-zag encoded varint
+func (d *Decoder) Varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf[d.off:])
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: invalid varint", ErrMalformed)
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+// String reads an int16 length-prefixed string
+func (d *Decoder) String() string {
+	s := d.NullableString()
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// NullableString reads a string that may be null
+func (d *Decoder) NullableString() *string {
+	n := d.Int16()
+	if d.err != nil || n < 0 {
+		return nil
+	}
+	s := string(d.take(int(n)))
+	if d.err != nil {
+		return nil
+	}
+	return &s
+}
+
+// Bytes reads int32 length-prefixed bytes, nil for -1
+func (d *Decoder) Bytes() []byte {
+	n := d.Int32()
+	if d.err != nil || n < 0 {
+		return nil
+	}
+	return d.copyOf(d.take(int(n)))
+}
+
+// VarintBytes reads varint length-prefixed bytes, nil for -1
+func (d *Decoder) VarintBytes() []byte {
+	n := d.Varint()
+	if d.err != nil || n < 0 {
+		return nil
+	}
+	if n > math.MaxInt32 {
+		d.err = fmt.Errorf("%w: length %d out of range", ErrMalformed, n)
+		return nil
+	}
+	return d.copyOf(d.take(int(n)))
+}
+
+// Raw reads n bytes without a length prefix
+func (d *Decoder) Raw(n int) []byte {
+	return d.copyOf(d.take(n))
+}
+
+// ArrayLen reads an int32 array length; -1 (null) is returned as-is
+func (d *Decoder) ArrayLen() int {
+	n := d.Int32()
+	if d.err != nil {
+		return 0
+	}
+	// every element takes at least one byte, so a larger count is corrupt
+	if int(n) > d.Remaining() {
+		d.err = fmt.Errorf("%w: array length %d out of range", ErrMalformed, n)
+		return 0
+	}
+	return int(n)
+}
+
+// Int32Array reads an array of int32
+func (d *Decoder) Int32Array() []int32 {
+	n := d.ArrayLen()
+	if n <= 0 {
+		return nil
+	}
+	values := make([]int32, n)
+	for i := range values {
+		values[i] = d.Int32()
+	}
+	return values
+}
+
+func (d *Decoder) copyOf(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
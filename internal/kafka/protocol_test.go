@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, []byte("payload")))
+	assert.Equal(t, []byte{0, 0, 0, 7}, buf.Bytes()[:4])
+
+	payload, err := ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), payload)
+
+	_, err = ReadFrame(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	clientID := "client"
+	e := NewEncoder()
+	e.PutInt8(-3)
+	e.PutBool(true)
+	e.PutInt16(-2)
+	e.PutInt32(1 << 20)
+	e.PutInt64(-1 << 40)
+	e.PutVarint(-300)
+	e.PutString("topic")
+	e.PutNullableString(nil)
+	e.PutNullableString(&clientID)
+	e.PutBytes(nil)
+	e.PutBytes([]byte{})
+	e.PutVarintBytes([]byte("key"))
+	e.PutInt32Array([]int32{1, 2, 3})
+
+	d := NewDecoder(e.Bytes())
+	assert.Equal(t, int8(-3), d.Int8())
+	assert.True(t, d.Bool())
+	assert.Equal(t, int16(-2), d.Int16())
+	assert.Equal(t, int32(1<<20), d.Int32())
+	assert.Equal(t, int64(-1<<40), d.Int64())
+	assert.Equal(t, int64(-300), d.Varint())
+	assert.Equal(t, "topic", d.String())
+	assert.Nil(t, d.NullableString())
+	assert.Equal(t, &clientID, d.NullableString())
+	assert.Nil(t, d.Bytes())
+	assert.Equal(t, []byte{}, d.Bytes())
+	assert.Equal(t, []byte("key"), d.VarintBytes())
+	assert.Equal(t, []int32{1, 2, 3}, d.Int32Array())
+	assert.NoError(t, d.Err())
+	assert.Equal(t, 0, d.Remaining())
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	d := NewDecoder([]byte{0, 5, 'a'})
+	assert.Equal(t, "", d.String())
+	assert.ErrorIs(t, d.Err(), ErrMalformed)
+
+	d = NewDecoder([]byte{0x7f, 0xff, 0xff, 0xff})
+	assert.Equal(t, 0, d.ArrayLen())
+	assert.ErrorIs(t, d.Err(), ErrMalformed)
+}
+
+func TestRequestHeader(t *testing.T) {
+	clientID := "msg-receiver"
+	header := RequestHeader{APIKey: APIKeyProduce, APIVersion: 3, CorrelationID: 42, ClientID: &clientID}
+	e := NewEncoder()
+	header.Encode(e)
+	assert.Equal(t, []byte{0, 0, 0, 3, 0, 0, 0, 42, 0, 12}, e.Bytes()[:10])
+
+	var decoded RequestHeader
+	require.NoError(t, decoded.Decode(NewDecoder(e.Bytes())))
+	assert.Equal(t, header, decoded)
+}
@@ -0,0 +1,234 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+const (
+	recordBatchMagic = 2
+	// recordBatchOverhead is the size of the batch header up to and
+	// including the batch length field
+	recordBatchOverhead = 12
+	// crcOffset is the position of the CRC within an encoded batch
+	crcOffset = 17
+	// attributesOffset is the first byte covered by the CRC
+	attributesOffset = 21
+	// recordBatchHeaderSize is the size of the batch header before the records
+	recordBatchHeaderSize = 61
+
+	compressionMask = 0x07
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrUnsupportedCompression is returned for batches using an unknown codec
+var ErrUnsupportedCompression = errors.New("kafka: unsupported compression codec")
+
+// Compression identifies the codec of a record batch
+type Compression int8
+
+// Supported compression codecs
+const (
+	CompressionNone   Compression = 0
+	CompressionGzip   Compression = 1
+	CompressionSnappy Compression = 2
+	CompressionLZ4    Compression = 3
+	// CompressionZstd batches are only accepted by brokers through Produce v7
+	CompressionZstd Compression = 4
+)
+
+// RecordHeader is a key/value header attached to a record
+type RecordHeader struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a single message inside a record batch
+type Record struct {
+	Key       []byte
+	Value     []byte
+	Headers   []RecordHeader
+	Timestamp time.Time
+}
+
+// RecordBatch is a magic v2 record batch
+type RecordBatch struct {
+	BaseOffset           int64
+	PartitionLeaderEpoch int32
+	Compression          Compression
+	ProducerID           int64
+	ProducerEpoch        int16
+	BaseSequence         int32
+	Records              []Record
+}
+
+// NewRecordBatch creates a batch for a non-idempotent producer
+func NewRecordBatch(records ...Record) *RecordBatch {
+	return &RecordBatch{
+		PartitionLeaderEpoch: -1,
+		ProducerID:           -1,
+		ProducerEpoch:        -1,
+		BaseSequence:         -1,
+		Records:              records,
+	}
+}
+
+// Encode serialises the batch, compressing its records with the batch codec
+func (b *RecordBatch) Encode() ([]byte, error) {
+	if len(b.Records) == 0 {
+		return nil, errors.New("kafka: empty record batch")
+	}
+
+	baseTimestamp := b.Records[0].Timestamp.UnixMilli()
+	maxTimestamp := baseTimestamp
+	records := NewEncoder()
+	for i, r := range b.Records {
+		ts := r.Timestamp.UnixMilli()
+		if ts > maxTimestamp {
+			maxTimestamp = ts
+		}
+		encodeRecord(records, r, ts-baseTimestamp, int64(i))
+	}
+	compressed, err := compress(b.Compression, records.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewEncoder()
+	e.PutInt64(b.BaseOffset)
+	e.PutInt32(0) // batch length, patched below
+	e.PutInt32(b.PartitionLeaderEpoch)
+	e.PutInt8(recordBatchMagic)
+	e.PutUint32(0) // crc, patched below
+	e.PutInt16(int16(b.Compression) & compressionMask)
+	e.PutInt32(int32(len(b.Records) - 1))
+	e.PutInt64(baseTimestamp)
+	e.PutInt64(maxTimestamp)
+	e.PutInt64(b.ProducerID)
+	e.PutInt16(b.ProducerEpoch)
+	e.PutInt32(b.BaseSequence)
+	e.PutArrayLen(len(b.Records))
+	e.PutRaw(compressed)
+
+	buf := e.Bytes()
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(buf)-recordBatchOverhead))
+	binary.BigEndian.PutUint32(buf[crcOffset:], crc32.Checksum(buf[attributesOffset:], castagnoli))
+	return buf, nil
+}
+
+func encodeRecord(e *Encoder, r Record, timestampDelta, offsetDelta int64) {
+	body := NewEncoder()
+	body.PutInt8(0) // attributes, unused
+	body.PutVarint(timestampDelta)
+	body.PutVarint(offsetDelta)
+	body.PutVarintBytes(r.Key)
+	body.PutVarintBytes(r.Value)
+	body.PutVarint(int64(len(r.Headers)))
+	for _, h := range r.Headers {
+		body.PutVarintBytes([]byte(h.Key))
+		body.PutVarintBytes(h.Value)
+	}
+	e.PutVarint(int64(len(body.Bytes())))
+	e.PutRaw(body.Bytes())
+}
+
+// DecodeRecordBatches parses every batch in a Produce records field,
+// verifying magic and CRC
+func DecodeRecordBatches(buf []byte) ([]RecordBatch, error) {
+	var batches []RecordBatch
+	for len(buf) > 0 {
+		if len(buf) < recordBatchOverhead {
+			return nil, fmt.Errorf("%w: truncated batch header", ErrMalformed)
+		}
+		length := int(int32(binary.BigEndian.Uint32(buf[8:])))
+		if length < recordBatchHeaderSize-recordBatchOverhead || len(buf) < recordBatchOverhead+length {
+			return nil, fmt.Errorf("%w: batch length %d out of range", ErrMalformed, length)
+		}
+		batch, err := decodeRecordBatch(buf[:recordBatchOverhead+length])
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *batch)
+		buf = buf[recordBatchOverhead+length:]
+	}
+	return batches, nil
+}
+
+func decodeRecordBatch(buf []byte) (*RecordBatch, error) {
+	if crc32.Checksum(buf[attributesOffset:], castagnoli) != binary.BigEndian.Uint32(buf[crcOffset:]) {
+		return nil, fmt.Errorf("%w: crc mismatch", ErrMalformed)
+	}
+
+	d := NewDecoder(buf)
+	b := &RecordBatch{}
+	b.BaseOffset = d.Int64()
+	d.Int32() // batch length
+	b.PartitionLeaderEpoch = d.Int32()
+	if magic := d.Int8(); magic != recordBatchMagic {
+		return nil, fmt.Errorf("%w: unsupported magic %d", ErrMalformed, magic)
+	}
+	d.Uint32() // crc
+	attributes := d.Int16()
+	b.Compression = Compression(attributes & compressionMask)
+	d.Int32() // last offset delta
+	baseTimestamp := d.Int64()
+	d.Int64() // max timestamp
+	b.ProducerID = d.Int64()
+	b.ProducerEpoch = d.Int16()
+	b.BaseSequence = d.Int32()
+	count := d.Int32()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	records, err := decompress(b.Compression, d.Raw(d.Remaining()))
+	if err != nil {
+		return nil, err
+	}
+	d = NewDecoder(records)
+	if count < 0 || int(count) > d.Remaining() {
+		return nil, fmt.Errorf("%w: record count %d out of range", ErrMalformed, count)
+	}
+
+	b.Records = make([]Record, count)
+	for i := range b.Records {
+		r, err := decodeRecord(d, baseTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		b.Records[i] = *r
+	}
+	return b, nil
+}
+
+func decodeRecord(d *Decoder, baseTimestamp int64) (*Record, error) {
+	length := d.Varint()
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	if length < 0 || length > int64(d.Remaining()) {
+		return nil, fmt.Errorf("%w: record length %d out of range", ErrMalformed, length)
+	}
+	rd := NewDecoder(d.Raw(int(length)))
+	rd.Int8() // attributes
+	r := &Record{}
+	r.Timestamp = time.UnixMilli(baseTimestamp + rd.Varint())
+	rd.Varint() // offset delta
+	r.Key = rd.VarintBytes()
+	r.Value = rd.VarintBytes()
+	headers := rd.Varint()
+	if rd.Err() == nil && (headers < 0 || headers > int64(rd.Remaining())) {
+		return nil, fmt.Errorf("%w: header count %d out of range", ErrMalformed, headers)
+	}
+	for i := int64(0); i < headers && rd.Err() == nil; i++ {
+		key := rd.VarintBytes()
+		r.Headers = append(r.Headers, RecordHeader{Key: string(key), Value: rd.VarintBytes()})
+	}
+	if err := rd.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
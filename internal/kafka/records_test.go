@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRecordBatch_RoundTrip(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	batch := NewRecordBatch(
+		Record{Key: []byte("k1"), Value: []byte("v1"), Headers: []RecordHeader{{Key: "h", Value: []byte("x")}}, Timestamp: now},
+		Record{Value: []byte("v2"), Timestamp: now.Add(5 * time.Millisecond)},
+	)
+	batch.ProducerID, batch.ProducerEpoch, batch.BaseSequence = 7, 1, 10
+
+	encoded, err := batch.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, int8(recordBatchMagic), int8(encoded[16]))
+
+	decoded, err := DecodeRecordBatches(append(encoded, encoded...))
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	assert.Equal(t, *batch, decoded[0])
+	assert.Nil(t, decoded[0].Records[1].Key)
+}
+
+func TestRecordBatch_Compressed(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	var records []Record
+	for i := 0; i < 100; i++ {
+		records = append(records, Record{Key: []byte("order"), Value: []byte(`{"status":"paid","total":42}`), Timestamp: now})
+	}
+	uncompressed, err := NewRecordBatch(records...).Encode()
+	require.NoError(t, err)
+
+	for _, c := range []Compression{CompressionGzip, CompressionSnappy, CompressionLZ4, CompressionZstd} {
+		t.Run(c.String(), func(t *testing.T) {
+			batch := NewRecordBatch(records...)
+			batch.Compression = c
+			encoded, err := batch.Encode()
+			require.NoError(t, err)
+			assert.Less(t, len(encoded), len(uncompressed)/2)
+
+			decoded, err := DecodeRecordBatches(encoded)
+			require.NoError(t, err)
+			require.Len(t, decoded, 1)
+			assert.Equal(t, *batch, decoded[0])
+		})
+	}
+}
+
+func TestDecodeRecordBatches_Corrupt(t *testing.T) {
+	encoded, err := NewRecordBatch(Record{Value: []byte("v"), Timestamp: time.Now()}).Encode()
+	require.NoError(t, err)
+
+	corrupt := append([]byte(nil), encoded...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	_, err = DecodeRecordBatches(corrupt)
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = DecodeRecordBatches(encoded[:len(encoded)-1])
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestRecordBatch_EncodeErrors(t *testing.T) {
+	_, err := NewRecordBatch().Encode()
+	assert.Error(t, err)
+
+	batch := NewRecordBatch(Record{Value: []byte("v")})
+	batch.Compression = 7
+	_, err = batch.Encode()
+	assert.ErrorIs(t, err, ErrUnsupportedCompression)
+}
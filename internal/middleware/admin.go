@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RequireAdminToken rejects requests whose bearer token is not the
+// configured admin token
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortAuthError(c, http.StatusUnauthorized, "invalid_request", "missing bearer token")
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(raw), []byte(token)) != 1 {
+			abortAuthError(c, http.StatusUnauthorized, "invalid_token", "admin token required")
+			return
+		}
+		c.Next()
+	}
+}
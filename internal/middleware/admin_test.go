@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdminToken(t *testing.T) {
+	testCases := []struct {
+		name               string
+		configured         string
+		authorization      string
+		expectedStatusCode int
+	}{
+		{name: "should pass the admin token", configured: "admin", authorization: "Bearer admin", expectedStatusCode: http.StatusOK},
+		{name: "should reject a wrong token", configured: "admin", authorization: "Bearer guess", expectedStatusCode: http.StatusUnauthorized},
+		{name: "should reject a missing token", configured: "admin", expectedStatusCode: http.StatusUnauthorized},
+		{name: "should reject everything when no token is configured", authorization: "Bearer ", expectedStatusCode: http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequireAdminToken(tc.configured))
+			router.GET("/admin", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SubjectKey is the gin context key holding the verified token subject
+	SubjectKey = "jwt_subject"
+	// ClaimsKey is the gin context key holding the verified jwt.MapClaims
+	ClaimsKey = "jwt_claims"
+)
+
+// RequireJWT rejects requests that do not carry a valid bearer token and
+// stores the verified subject and claims in the gin context. The token
+// scopes and tenant are added to the request context for
+// services.ScopesFromContext and services.TenantFromContext.
+func RequireJWT(jwtService services.JWTService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortAuthError(c, http.StatusUnauthorized, "invalid_request", "missing bearer token")
+			return
+		}
+
+		token, err := jwtService.ValidateToken(raw)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAudience) {
+				abortAuthError(c, http.StatusForbidden, "invalid_token", err.Error())
+				return
+			}
+			abortAuthError(c, http.StatusUnauthorized, "invalid_token", tokenErrorDescription(err))
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			abortAuthError(c, http.StatusUnauthorized, "invalid_token", services.ErrInvalidClaims.Error())
+			return
+		}
+		subject, _ := claims["sub"].(string)
+		if subject == "" {
+			abortAuthError(c, http.StatusUnauthorized, "invalid_token", "token has no subject")
+			return
+		}
+
+		c.Set(SubjectKey, subject)
+		c.Set(ClaimsKey, claims)
+		scope, _ := claims["scope"].(string)
+		// tokens without a tenant are a tenant of their own
+		tenant, _ := claims["tenant"].(string)
+		if tenant == "" {
+			tenant = subject
+		}
+		ctx := services.ContextWithScopes(c.Request.Context(), services.ParseScopes(scope))
+		c.Request = c.Request.WithContext(services.ContextWithTenant(ctx, tenant))
+		c.Next()
+	}
+}
+
+// Subject returns the verified token subject, or "" on unauthenticated routes
+func Subject(c *gin.Context) string {
+	return c.GetString(SubjectKey)
+}
+
+// Claims returns the verified token claims, or nil on unauthenticated routes
+func Claims(c *gin.Context) jwt.MapClaims {
+	claims, _ := c.Get(ClaimsKey)
+	mapClaims, _ := claims.(jwt.MapClaims)
+	return mapClaims
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// tokenErrorDescription hides parser internals behind a stable message
+func tokenErrorDescription(err error) string {
+	var serviceErr services.ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Error()
+	}
+	return "token is malformed or its signature is invalid"
+}
+
+// abortAuthError writes an RFC 6750 style error
+func abortAuthError(c *gin.Context, status int, code, description string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, code, description))
+	c.AbortWithStatusJSON(status, gin.H{"error": code, "error_description": description})
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireJWT(t *testing.T) {
+	validToken := &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "user-1", "iss": "issuer"}}
+
+	testCases := []struct {
+		name               string
+		authorization      string
+		validateToken      func(string) (*jwt.Token, error)
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{
+			name:               "should pass a valid token and expose its subject",
+			authorization:      "Bearer good",
+			validateToken:      func(string) (*jwt.Token, error) { return validToken, nil },
+			expectedStatusCode: http.StatusOK,
+		}, {
+			name:               "should accept a lowercase scheme",
+			authorization:      "bearer good",
+			validateToken:      func(string) (*jwt.Token, error) { return validToken, nil },
+			expectedStatusCode: http.StatusOK,
+		}, {
+			name:               "should return 401 when the header is missing",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_request",
+		}, {
+			name:               "should return 401 for another scheme",
+			authorization:      "Basic dXNlcjpwYXNz",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_request",
+		}, {
+			name:               "should return 401 for an expired token",
+			authorization:      "Bearer expired",
+			validateToken:      func(string) (*jwt.Token, error) { return nil, services.ErrTokenExpired },
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_token",
+		}, {
+			name:               "should return 401 for a bad signature",
+			authorization:      "Bearer forged",
+			validateToken:      func(string) (*jwt.Token, error) { return nil, jwt.ErrSignatureInvalid },
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_token",
+		}, {
+			name:               "should return 403 for a token meant for another audience",
+			authorization:      "Bearer other",
+			validateToken:      func(string) (*jwt.Token, error) { return nil, services.ErrInvalidAudience },
+			expectedStatusCode: http.StatusForbidden,
+			expectedError:      "invalid_token",
+		}, {
+			name:          "should return 401 for a token without subject",
+			authorization: "Bearer anonymous",
+			validateToken: func(string) (*jwt.Token, error) {
+				return &jwt.Token{Valid: true, Claims: jwt.MapClaims{"iss": "issuer"}}, nil
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			jwtService := &servicesfakes.FakeJWTService{ValidateTokenStub: tc.validateToken}
+
+			router := gin.New()
+			router.Use(RequireJWT(jwtService))
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"subject": Subject(c), "iss": Claims(c)["iss"]})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			var response map[string]string
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if tc.expectedError == "" {
+				assert.Equal(t, "user-1", response["subject"])
+				assert.Equal(t, "issuer", response["iss"])
+				assert.Equal(t, "good", jwtService.ValidateTokenArgsForCall(0))
+				return
+			}
+			assert.Equal(t, tc.expectedError, response["error"])
+			assert.NotEmpty(t, response["error_description"])
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), `Bearer error="`+tc.expectedError+`"`)
+		})
+	}
+}
+
+func TestRequireJWT_Scopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := &servicesfakes.FakeJWTService{}
+	jwtService.ValidateTokenReturns(&jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "orders", "scope": "produce:orders produce:payments.*"}}, nil)
+
+	var scopes []string
+	router := gin.New()
+	router.Use(RequireJWT(jwtService))
+	router.GET("/test", func(c *gin.Context) {
+		scopes = services.ScopesFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer good")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"produce:orders", "produce:payments.*"}, scopes)
+}
+
+func TestRequireJWT_Tenant(t *testing.T) {
+	testCases := []struct {
+		name           string
+		claims         jwt.MapClaims
+		expectedTenant string
+	}{
+		{
+			name:           "should use the tenant claim",
+			claims:         jwt.MapClaims{"sub": "orders", "tenant": "acme"},
+			expectedTenant: "acme",
+		}, {
+			name:           "should fall back to the subject",
+			claims:         jwt.MapClaims{"sub": "orders"},
+			expectedTenant: "orders",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			jwtService := &servicesfakes.FakeJWTService{}
+			jwtService.ValidateTokenReturns(&jwt.Token{Valid: true, Claims: tc.claims}, nil)
+
+			var tenant string
+			router := gin.New()
+			router.Use(RequireJWT(jwtService))
+			router.GET("/test", func(c *gin.Context) {
+				tenant = services.TenantFromContext(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer good")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tc.expectedTenant, tenant)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Priority is the lane of a request; lower lanes are shed first
+type Priority int
+
+// Priority lanes, from the first to the last shed
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityCritical
+)
+
+// laneShares are the share of the concurrency limit each lane may fill, so
+// the last share of the limit is kept for higher lanes
+var laneShares = map[Priority]float64{
+	PriorityNormal:   0.8,
+	PriorityHigh:     0.9,
+	PriorityCritical: 1,
+}
+
+// ConcurrencyLimiterConfig configures NewConcurrencyLimiter
+type ConcurrencyLimiterConfig struct {
+	// InitialLimit is the number of requests in flight allowed at start, 20 when zero
+	InitialLimit int
+	// MinLimit and MaxLimit bound the adapted limit, 1 and 1000 when zero
+	MinLimit int
+	MaxLimit int
+	// Algorithm adapts the limit to the measured latency, Vegas when nil
+	Algorithm LimitAlgorithm
+	// Priority returns the lane of a request, PriorityNormal when nil
+	Priority func(c *gin.Context) Priority
+	// RetryAfter is sent to shed requests, one second when zero
+	RetryAfter time.Duration
+}
+
+type concurrencyLimiter struct {
+	cfg ConcurrencyLimiterConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+}
+
+// NewConcurrencyLimiter caps the requests in flight and sheds the others
+// with 503 and Retry-After. The cap follows the latency of completed
+// requests, so it shrinks when a dependency slows down and requests would
+// otherwise pile up. Lower priority lanes are shed while higher lanes can
+// still use the rest of the limit.
+// Params: cfg ConcurrencyLimiterConfig - the limit bounds, algorithm and lanes
+func NewConcurrencyLimiter(cfg ConcurrencyLimiterConfig) gin.HandlerFunc {
+	return newConcurrencyLimiter(cfg).handle
+}
+
+func newConcurrencyLimiter(cfg ConcurrencyLimiterConfig) *concurrencyLimiter {
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 1000
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = 20
+	}
+	if cfg.Algorithm == nil {
+		cfg.Algorithm = NewVegasLimit(VegasConfig{})
+	}
+	if cfg.Priority == nil {
+		cfg.Priority = func(*gin.Context) Priority { return PriorityNormal }
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	l := &concurrencyLimiter{cfg: cfg, now: time.Now}
+	l.limit = l.clamp(cfg.InitialLimit)
+	return l
+}
+
+func (l *concurrencyLimiter) handle(c *gin.Context) {
+	priority := l.cfg.Priority(c)
+	inFlight, ok := l.acquire(priority)
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(l.cfg.RetryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "overloaded", "error_description": "too many requests in flight, retry later"})
+		return
+	}
+
+	start := l.now()
+	defer func() {
+		status := c.Writer.Status()
+		// only requests of the lowest lane that were served measure the
+		// latency of the dependencies; health checks and rejected requests
+		// are answered at once and would pass for the no-load latency
+		sampled := priority == PriorityNormal && (status < 400 || status >= 500)
+		l.release(LimitSample{
+			Latency:  l.now().Sub(start),
+			InFlight: inFlight,
+			Dropped:  status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout,
+		}, sampled)
+	}()
+	c.Next()
+}
+
+// acquire admits a request when its lane has room, returning the requests
+// in flight including it
+func (l *concurrencyLimiter) acquire(priority Priority) (int, bool) {
+	share, ok := laneShares[priority]
+	if !ok {
+		share = laneShares[PriorityNormal]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// every lane can use at least one slot, so a tiny limit does not starve it
+	if l.inFlight >= max(1, int(float64(l.limit)*share)) {
+		return 0, false
+	}
+	l.inFlight++
+	return l.inFlight, true
+}
+
+// release ends a request and adapts the limit with its sample when sampled
+func (l *concurrencyLimiter) release(sample LimitSample, sampled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if sampled {
+		l.limit = l.clamp(l.cfg.Algorithm.Update(l.limit, sample))
+	}
+}
+
+func (l *concurrencyLimiter) clamp(limit int) int {
+	return min(max(limit, l.cfg.MinLimit), l.cfg.MaxLimit)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// LimitSample is a completed request, measured by the concurrency limiter
+type LimitSample struct {
+	Latency time.Duration
+	// InFlight is the number of requests in flight when the request was admitted
+	InFlight int
+	// Dropped reports a request failed by an overloaded dependency (502, 503 or 504)
+	Dropped bool
+}
+
+// LimitAlgorithm adjusts a concurrency limit from completed requests.
+// Update is called with the limiter lock held, so implementations can
+// keep state without locking.
+type LimitAlgorithm interface {
+	Update(limit int, sample LimitSample) int
+}
+
+// AIMDConfig configures NewAIMDLimit
+type AIMDConfig struct {
+	// LatencyThreshold marks slower requests as congestion, one second when zero
+	LatencyThreshold time.Duration
+	// Backoff multiplies the limit on congestion, 0.9 when zero
+	Backoff float64
+}
+
+type aimdLimit struct {
+	cfg AIMDConfig
+}
+
+// NewAIMDLimit creates an additive increase, multiplicative decrease
+// algorithm: the limit grows by one while requests are fast and the limit
+// is in use, and shrinks by Backoff on slow or dropped requests
+// Params: cfg AIMDConfig - the congestion threshold and backoff
+func NewAIMDLimit(cfg AIMDConfig) LimitAlgorithm {
+	if cfg.LatencyThreshold <= 0 {
+		cfg.LatencyThreshold = time.Second
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+	return &aimdLimit{cfg: cfg}
+}
+
+// Update applies one sample to the limit
+func (a *aimdLimit) Update(limit int, sample LimitSample) int {
+	if sample.Dropped || sample.Latency > a.cfg.LatencyThreshold {
+		return int(float64(limit) * a.cfg.Backoff)
+	}
+	// an unused limit says nothing about the capacity
+	if sample.InFlight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// VegasConfig configures NewVegasLimit
+type VegasConfig struct {
+	// ProbeInterval is the number of samples after which the no-load latency
+	// is measured again, 1000 when zero
+	ProbeInterval int
+}
+
+type vegasLimit struct {
+	cfg        VegasConfig
+	minLatency time.Duration
+	samples    int
+}
+
+// NewVegasLimit creates a delay based algorithm in the style of TCP Vegas.
+// The queue is estimated from how much the latency exceeds the lowest
+// latency seen: the limit grows while the queue is short and shrinks as it
+// builds up, before requests start to fail.
+// Params: cfg VegasConfig - how often the no-load latency is probed
+func NewVegasLimit(cfg VegasConfig) LimitAlgorithm {
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 1000
+	}
+	return &vegasLimit{cfg: cfg}
+}
+
+// Update applies one sample to the limit
+func (v *vegasLimit) Update(limit int, sample LimitSample) int {
+	// the no-load latency drifts with the dependencies, so it is re-learned periodically
+	v.samples++
+	if v.samples >= v.cfg.ProbeInterval {
+		v.samples, v.minLatency = 0, 0
+	}
+	if sample.Latency <= 0 {
+		return limit
+	}
+	if v.minLatency == 0 || sample.Latency < v.minLatency {
+		v.minLatency = sample.Latency
+	}
+
+	step := int(math.Max(1, math.Log10(float64(limit))))
+	if sample.Dropped {
+		return limit - step
+	}
+	if sample.InFlight*2 < limit {
+		return limit
+	}
+	queue := float64(limit) * (1 - float64(v.minLatency)/float64(sample.Latency))
+	switch {
+	case queue <= float64(3*step):
+		return limit + step
+	case queue >= float64(6*step):
+		return limit - step
+	}
+	return limit
+}
+
+// ParseLimitAlgorithm returns the algorithm named "vegas" or "aimd"
+// Params: name string - the algorithm name
+// Params: latencyThreshold time.Duration - the congestion threshold of aimd
+func ParseLimitAlgorithm(name string, latencyThreshold time.Duration) (LimitAlgorithm, error) {
+	switch name {
+	case "vegas", "":
+		return NewVegasLimit(VegasConfig{}), nil
+	case "aimd":
+		return NewAIMDLimit(AIMDConfig{LatencyThreshold: latencyThreshold}), nil
+	}
+	return nil, fmt.Errorf("unknown concurrency limit algorithm %q, expected vegas or aimd", name)
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMDLimit(t *testing.T) {
+	aimd := NewAIMDLimit(AIMDConfig{LatencyThreshold: 100 * time.Millisecond, Backoff: 0.5})
+	testCases := []struct {
+		name     string
+		sample   LimitSample
+		expected int
+	}{
+		{
+			name:     "should grow while fast and in use",
+			sample:   LimitSample{Latency: 10 * time.Millisecond, InFlight: 5},
+			expected: 11,
+		}, {
+			name:     "should hold while the limit is not in use",
+			sample:   LimitSample{Latency: 10 * time.Millisecond, InFlight: 2},
+			expected: 10,
+		}, {
+			name:     "should back off on slow requests",
+			sample:   LimitSample{Latency: 200 * time.Millisecond, InFlight: 10},
+			expected: 5,
+		}, {
+			name:     "should back off on dropped requests",
+			sample:   LimitSample{Latency: time.Millisecond, InFlight: 10, Dropped: true},
+			expected: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, aimd.Update(10, tc.sample))
+		})
+	}
+}
+
+func TestVegasLimit(t *testing.T) {
+	vegas := NewVegasLimit(VegasConfig{})
+	limit := 100
+
+	// latency at no load: the queue is empty and the limit grows
+	limit = vegas.Update(limit, LimitSample{Latency: 10 * time.Millisecond, InFlight: 100})
+	assert.Equal(t, 102, limit)
+
+	// 5% slower: a short queue still grows the limit
+	limit = vegas.Update(limit, LimitSample{Latency: 10500 * time.Microsecond, InFlight: 100})
+	assert.Equal(t, 104, limit)
+
+	// twice as slow: half the requests are queued and the limit shrinks
+	limit = vegas.Update(limit, LimitSample{Latency: 20 * time.Millisecond, InFlight: 100})
+	assert.Equal(t, 102, limit)
+
+	// an unused limit holds
+	assert.Equal(t, 102, vegas.Update(limit, LimitSample{Latency: 20 * time.Millisecond, InFlight: 10}))
+	// dropped requests shrink it whatever the latency
+	assert.Equal(t, 100, vegas.Update(limit, LimitSample{Latency: time.Millisecond, InFlight: 10, Dropped: true}))
+}
+
+func TestVegasLimit_Probe(t *testing.T) {
+	vegas := NewVegasLimit(VegasConfig{ProbeInterval: 3})
+	vegas.Update(10, LimitSample{Latency: time.Millisecond, InFlight: 10})
+	vegas.Update(10, LimitSample{Latency: 50 * time.Millisecond, InFlight: 10})
+	// the third sample resets the no-load latency to the current one
+	assert.Equal(t, 11, vegas.Update(10, LimitSample{Latency: 50 * time.Millisecond, InFlight: 10}))
+}
+
+func TestParseLimitAlgorithm(t *testing.T) {
+	for _, name := range []string{"", "vegas", "aimd"} {
+		algorithm, err := ParseLimitAlgorithm(name, time.Second)
+		require.NoError(t, err)
+		assert.NotNil(t, algorithm)
+	}
+	_, err := ParseLimitAlgorithm("gradient", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedLimit keeps the limit unchanged
+type fixedLimit struct{}
+
+func (fixedLimit) Update(limit int, _ LimitSample) int {
+	return limit
+}
+
+func TestConcurrencyLimiter_Lanes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	router := gin.New()
+	router.Use(NewConcurrencyLimiter(ConcurrencyLimiterConfig{
+		InitialLimit: 10,
+		Algorithm:    fixedLimit{},
+		Priority: func(c *gin.Context) Priority {
+			switch c.Request.URL.Path {
+			case "/health":
+				return PriorityCritical
+			case "/token":
+				return PriorityHigh
+			}
+			return PriorityNormal
+		},
+		RetryAfter: 1500 * time.Millisecond,
+	}))
+	handler := func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	}
+	router.GET("/messages", handler)
+	router.GET("/token", handler)
+	router.GET("/health", handler)
+
+	var wg sync.WaitGroup
+	send := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	// block requests until the lane fills, then check it sheds
+	fill := func(path string, n int) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				send(path)
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			w := send(path)
+			return w.Code == http.StatusServiceUnavailable
+		}, time.Second, time.Millisecond, "%s should be shed", path)
+	}
+
+	fill("/messages", 8)
+	w := send("/messages")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "overloaded")
+
+	fill("/token", 1)
+	fill("/health", 1)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, send("/messages").Code)
+}
+
+func TestConcurrencyLimiter_AdaptsToLatency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newConcurrencyLimiter(ConcurrencyLimiterConfig{
+		InitialLimit: 10,
+		MinLimit:     2,
+		MaxLimit:     12,
+		Algorithm:    NewAIMDLimit(AIMDConfig{LatencyThreshold: 100 * time.Millisecond, Backoff: 0.5}),
+	})
+	latency := time.Duration(0)
+	now := time.Now()
+	limiter.now = func() time.Time {
+		now = now.Add(latency / 2)
+		return now
+	}
+	status := http.StatusOK
+	router := gin.New()
+	router.Use(limiter.handle)
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(status)
+	})
+	send := func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	}
+
+	// a dependency failing with 503 counts as congestion
+	status = http.StatusServiceUnavailable
+	send()
+	assert.Equal(t, 5, limiter.limit)
+
+	// slow requests halve the limit down to the minimum
+	status = http.StatusOK
+	latency = time.Second
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	assert.Equal(t, 2, limiter.limit)
+
+	// fast requests grow it only while at least half of it is in use
+	latency = time.Millisecond
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	assert.Equal(t, 3, limiter.limit)
+	assert.Equal(t, 0, limiter.inFlight)
+}
+
+func TestConcurrencyLimiter_MixedLatencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newConcurrencyLimiter(ConcurrencyLimiterConfig{
+		InitialLimit: 20,
+		MaxLimit:     40,
+		Algorithm:    NewVegasLimit(VegasConfig{}),
+		Priority: func(c *gin.Context) Priority {
+			if c.Request.URL.Path == "/health" {
+				return PriorityCritical
+			}
+			return PriorityNormal
+		},
+	})
+	latency := time.Duration(0)
+	now := time.Now()
+	limiter.now = func() time.Time {
+		now = now.Add(latency / 2)
+		return now
+	}
+	router := gin.New()
+	router.Use(limiter.handle)
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/messages", func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	send := func(path string, authorized bool) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// most of the limit is held by requests in flight, so it is in use
+	limiter.inFlight = 15
+	// produce requests take 20ms without load, a steady latency grows the limit
+	latency = 20 * time.Millisecond
+	send("/messages", true)
+	// health checks and unauthorized requests are answered in microseconds
+	// and do not lower the no-load latency
+	latency = 50 * time.Microsecond
+	for i := 0; i < 10; i++ {
+		send("/health", false)
+		send("/messages", false)
+	}
+	assert.Equal(t, 21, limiter.limit)
+
+	latency = 20 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		send("/messages", true)
+	}
+	assert.Equal(t, 26, limiter.limit)
+}
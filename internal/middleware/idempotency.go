@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying the idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// DefaultIdempotencyLockTTL is how long a request in progress holds its key
+// when IdempotencyConfig.LockTTL is zero
+const DefaultIdempotencyLockTTL = time.Minute
+
+// NothingWrittenKey is the gin context key set by MarkNothingWritten
+const NothingWrittenKey = "idempotency_nothing_written"
+
+// IdempotencyConfig configures Idempotency
+type IdempotencyConfig struct {
+	// Store keeps the keys and responses
+	Store services.IdempotencyStore
+	// TTL is how long a response is replayed, 24 hours when zero
+	TTL time.Duration
+	// LockTTL is how long a request in progress holds its key, one minute
+	// when zero, so the key of a crashed request is freed
+	LockTTL time.Duration
+	// MaxBodyBytes caps the request bodies read to fingerprint them, 5 MiB when zero
+	MaxBodyBytes int64
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the response of a request sent again with the same
+// Idempotency-Key header, instead of running it twice. Keys are scoped to
+// the token subject, so it must run after RequireJWT. A key sent with
+// another request is rejected with 409, as is a retry while the first
+// request is still in progress. Requests without the header run as usual.
+// Params: cfg IdempotencyConfig - the store and retention
+func Idempotency(cfg IdempotencyConfig) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = DefaultIdempotencyLockTTL
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 5 << 20
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": fmt.Sprintf("%s is longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodyBytes))
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request_too_large", "error_description": fmt.Sprintf("request body is larger than %d bytes", cfg.MaxBodyBytes)})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		subject := c.GetString(SubjectKey)
+		storeKey := fmt.Sprintf("%d:%s:%s", len(subject), subject, key)
+		fingerprint := requestFingerprint(c, body)
+		record, err := cfg.Store.Start(storeKey, fingerprint, cfg.LockTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": "failed to check idempotency key"})
+			return
+		}
+		if record != nil {
+			replay(c, record, fingerprint)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// only failures the handler marked as writing nothing free the key,
+		// such as a quota rejection or an open circuit breaker. Any other
+		// answer, such as a 502 or 504 from the broker or a batch that failed
+		// halfway, may come after messages were written, so it is replayed
+		// rather than produced twice.
+		if c.GetBool(NothingWrittenKey) {
+			_ = cfg.Store.Forget(storeKey)
+			return
+		}
+		status := writer.Status()
+		// a failed write leaves the key held until LockTTL, so retries get
+		// 409 rather than running the request again
+		_ = cfg.Store.Finish(storeKey, services.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}, cfg.TTL)
+	}
+}
+
+// MarkNothingWritten reports that a request failed before producing or
+// spooling anything, so that Idempotency frees its key and a retry runs it
+// again instead of replaying the failure
+func MarkNothingWritten(c *gin.Context) {
+	c.Set(NothingWrittenKey, true)
+}
+
+// replay answers a request whose key is known
+func replay(c *gin.Context, record *services.IdempotencyRecord, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "idempotency_key_reused", "error_description": "the idempotency key was used with a different request"})
+	case !record.Finished():
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request_in_progress", "error_description": "a request with this idempotency key is in progress"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.Status, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint hashes what makes two requests the same: the method,
+// path, content type and body
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{c.Request.Method, c.Request.URL.RequestURI(), c.ContentType()} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
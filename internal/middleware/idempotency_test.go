@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newIdempotentRouter counts the requests reaching the handler, which
+// answers with the given status and echoes the body
+func newIdempotentRouter(store services.IdempotencyStore, status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(SubjectKey, c.GetHeader("X-Subject"))
+	}, Idempotency(IdempotencyConfig{Store: store, MaxBodyBytes: 64}))
+	handler := func(c *gin.Context) {
+		if c.Query("written") == "none" {
+			MarkNothingWritten(c)
+		}
+		*calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(*status, gin.H{"call": *calls, "body": string(body)})
+	}
+	router.POST("/v1/messages", handler)
+	router.POST("/v1/other", handler)
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, path, subject, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Subject", subject)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	status, calls := http.StatusOK, 0
+	router := newIdempotentRouter(services.NewInMemoryIdempotencyStore(), &status, &calls)
+
+	first := sendIdempotent(router, "/v1/messages", "orders", "k1", `{"a":1}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// the retry gets the original response without running the handler
+	retry := sendIdempotent(router, "/v1/messages", "orders", "k1", `{"a":1}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+	assert.Equal(t, 1, calls)
+
+	// the same key with another body or path is a conflict
+	w := sendIdempotent(router, "/v1/messages", "orders", "k1", `{"a":2}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "idempotency_key_reused")
+	w = sendIdempotent(router, "/v1/other", "orders", "k1", `{"a":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 1, calls)
+
+	// keys are scoped to the subject, and requests without a key always run
+	assert.Equal(t, http.StatusOK, sendIdempotent(router, "/v1/messages", "billing", "k1", `{"a":2}`).Code)
+	assert.Equal(t, http.StatusOK, sendIdempotent(router, "/v1/messages", "orders", "", `{"a":1}`).Code)
+	assert.Equal(t, http.StatusOK, sendIdempotent(router, "/v1/messages", "orders", "", `{"a":1}`).Code)
+	assert.Equal(t, 4, calls)
+}
+
+func TestIdempotency_RetriableFailures(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		nothingWritten bool
+		expectedCalls  int
+	}{
+		{name: "should run the request again when the breaker is open", status: http.StatusServiceUnavailable, nothingWritten: true, expectedCalls: 2},
+		{name: "should replay a spool failure that may have written messages", status: http.StatusServiceUnavailable, expectedCalls: 1},
+		{name: "should replay a broker failure the message may have survived", status: http.StatusBadGateway, expectedCalls: 1},
+		{name: "should replay a produce timeout", status: http.StatusGatewayTimeout, expectedCalls: 1},
+		{name: "should replay an internal error", status: http.StatusInternalServerError, expectedCalls: 1},
+		{name: "should run the request again after a quota rejection", status: http.StatusTooManyRequests, nothingWritten: true, expectedCalls: 2},
+		{name: "should replay a batch that failed halfway", status: http.StatusMultiStatus, expectedCalls: 1},
+		{name: "should replay a client error", status: http.StatusBadRequest, expectedCalls: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, calls := tc.status, 0
+			router := newIdempotentRouter(services.NewInMemoryIdempotencyStore(), &status, &calls)
+			path := "/v1/messages"
+			if tc.nothingWritten {
+				path += "?written=none"
+			}
+			assert.Equal(t, tc.status, sendIdempotent(router, path, "orders", "k1", `{}`).Code)
+			assert.Equal(t, tc.status, sendIdempotent(router, path, "orders", "k1", `{}`).Code)
+			assert.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	started, release := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.Use(Idempotency(IdempotencyConfig{Store: services.NewInMemoryIdempotencyStore()}))
+	router.POST("/v1/messages", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		done <- sendIdempotent(router, "/v1/messages", "", "k1", `{}`).Code
+	}()
+	<-started
+	w := sendIdempotent(router, "/v1/messages", "", "k1", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "request_in_progress")
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, "true", sendIdempotent(router, "/v1/messages", "", "k1", `{}`).Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_InvalidRequests(t *testing.T) {
+	status, calls := http.StatusOK, 0
+	router := newIdempotentRouter(services.NewInMemoryIdempotencyStore(), &status, &calls)
+	assert.Equal(t, http.StatusBadRequest, sendIdempotent(router, "/v1/messages", "orders", strings.Repeat("k", 256), `{}`).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, sendIdempotent(router, "/v1/messages", "orders", "k1", strings.Repeat("a", 65)).Code)
+
+	failing := &servicesfakes.FakeIdempotencyStore{}
+	failing.StartReturns(nil, assert.AnError)
+	router = newIdempotentRouter(failing, &status, &calls)
+	assert.Equal(t, http.StatusServiceUnavailable, sendIdempotent(router, "/v1/messages", "orders", "k1", `{}`).Code)
+	assert.Equal(t, 0, calls)
+}
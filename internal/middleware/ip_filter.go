@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IPFilter is a contract for checking client addresses against rules that
+// can be reloaded while serving
+type IPFilter interface {
+	// Allowed reports whether ip may call the routes of group
+	Allowed(group string, ip netip.Addr) bool
+	// Reload re-reads the rules when their file changed, keeping the current
+	// rules when the file is invalid
+	Reload() error
+}
+
+// IPRules allow or deny networks, written as CIDRs such as "10.0.0.0/8" or
+// single addresses
+type IPRules struct {
+	// Allow rejects every address outside these networks, allows all when empty
+	Allow []string `json:"allow"`
+	// Deny rejects the addresses in these networks, even when allowed
+	Deny []string `json:"deny"`
+}
+
+// IPFilterRules are the rules of every route group
+type IPFilterRules struct {
+	// Default applies to every group
+	Default IPRules `json:"default"`
+	// Groups add rules to a route group: "public", "v1" or "admin"
+	Groups map[string]IPRules `json:"groups"`
+}
+
+// ipNetworks are parsed IPRules
+type ipNetworks struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+type ipFilter struct {
+	path string
+
+	mu       sync.RWMutex
+	fallback ipNetworks
+	groups   map[string]ipNetworks
+	size     int64
+	modTime  time.Time
+}
+
+// NewIPFilter creates a filter reading its rules from a JSON file of the form
+// {"default": {"deny": ["203.0.113.0/24"]}, "groups": {"admin": {"allow": ["10.0.0.0/8"]}}}
+// Params: path string - the rules file
+func NewIPFilter(path string) (IPFilter, error) {
+	f := &ipFilter{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Allowed checks ip against the default rules, then the rules of group
+// Params: group string - the route group
+// Params: ip netip.Addr - the client address
+func (f *ipFilter) Allowed(group string, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.fallback.allowed(ip) {
+		return false
+	}
+	networks, ok := f.groups[group]
+	return !ok || networks.allowed(ip)
+}
+
+// Reload re-reads the rules file when its size or modification time changed
+func (f *ipFilter) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	unchanged := info.Size() == f.size && info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	var rules IPFilterRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("invalid ip filter file %s: %w", f.path, err)
+	}
+	fallback, err := parseIPRules(rules.Default)
+	if err != nil {
+		return fmt.Errorf("invalid ip filter file %s: default: %w", f.path, err)
+	}
+	groups := make(map[string]ipNetworks, len(rules.Groups))
+	for group, groupRules := range rules.Groups {
+		if groups[group], err = parseIPRules(groupRules); err != nil {
+			return fmt.Errorf("invalid ip filter file %s: group %q: %w", f.path, group, err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback = fallback
+	f.groups = groups
+	f.size = info.Size()
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// RequireAllowedIP rejects requests whose client address the filter does
+// not allow for group. The address is the one gin resolves through the
+// trusted proxies.
+// Params: filter IPFilter - the rules
+// Params: group string - the route group of the requests
+func RequireAllowedIP(filter IPFilter, group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip, err := netip.ParseAddr(c.ClientIP())
+		if err != nil || !filter.Allowed(group, ip) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access_denied", "error_description": "client address not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RunIPFilterReload reloads the filter every interval until ctx is done
+// Params: interval time.Duration - the delay between reloads
+// Params: onError func(error) - called when a reload fails
+func RunIPFilterReload(ctx context.Context, filter IPFilter, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := filter.Reload(); err != nil {
+			onError(err)
+		}
+	}
+}
+
+func (n ipNetworks) allowed(ip netip.Addr) bool {
+	if containsIP(n.deny, ip) {
+		return false
+	}
+	return len(n.allow) == 0 || containsIP(n.allow, ip)
+}
+
+func containsIP(networks []netip.Prefix, ip netip.Addr) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIPRules(rules IPRules) (ipNetworks, error) {
+	allow, err := parseNetworks(rules.Allow)
+	if err != nil {
+		return ipNetworks{}, err
+	}
+	deny, err := parseNetworks(rules.Deny)
+	if err != nil {
+		return ipNetworks{}, err
+	}
+	return ipNetworks{allow: allow, deny: deny}, nil
+}
+
+// parseNetworks reads CIDRs and single addresses; IPv4-mapped IPv6
+// networks are turned into IPv4 ones as client addresses are unmapped
+func parseNetworks(values []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			addr = addr.Unmap()
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		if addr := network.Addr(); addr.Is4In6() && network.Bits() >= 96 {
+			network = netip.PrefixFrom(addr.Unmap(), network.Bits()-96)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeIPFilterFile(t *testing.T, path, rules string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(rules), 0o600))
+}
+
+func TestIPFilter_Allowed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	writeIPFilterFile(t, path, `{
+		"default": {"deny": ["203.0.113.0/24", "2001:db8:bad::/48"]},
+		"groups": {"admin": {"allow": ["10.0.0.0/8", "192.0.2.7"], "deny": ["10.6.6.0/24"]}}
+	}`)
+	filter, err := NewIPFilter(path)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		group    string
+		ip       string
+		expected bool
+	}{
+		{name: "should allow an address without rules", group: "v1", ip: "198.51.100.1", expected: true},
+		{name: "should deny a default denied network in every group", group: "v1", ip: "203.0.113.9", expected: false},
+		{name: "should deny a denied IPv6 network", group: "public", ip: "2001:db8:bad::1", expected: false},
+		{name: "should deny an IPv4-mapped address of a denied network", group: "v1", ip: "::ffff:203.0.113.9", expected: false},
+		{name: "should allow an allowed network", group: "admin", ip: "10.1.2.3", expected: true},
+		{name: "should allow a single allowed address", group: "admin", ip: "192.0.2.7", expected: true},
+		{name: "should deny an address outside the allowed networks", group: "admin", ip: "198.51.100.1", expected: false},
+		{name: "should deny a denied network inside an allowed one", group: "admin", ip: "10.6.6.6", expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, filter.Allowed(tc.group, netip.MustParseAddr(tc.ip)))
+		})
+	}
+}
+
+func TestIPFilter_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	writeIPFilterFile(t, path, `{"default": {"deny": ["203.0.113.0/24"]}}`)
+	filter, err := NewIPFilter(path)
+	require.NoError(t, err)
+	ip := netip.MustParseAddr("198.51.100.1")
+	assert.True(t, filter.Allowed("v1", ip))
+
+	writeIPFilterFile(t, path, `{"default": {"deny": ["198.51.100.0/24"]}}`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, filter.Reload())
+	assert.False(t, filter.Allowed("v1", ip))
+
+	// an invalid file keeps the current rules
+	writeIPFilterFile(t, path, `{"default": {"deny": ["not-a-network/8"]}}`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	assert.Error(t, filter.Reload())
+	assert.False(t, filter.Allowed("v1", ip))
+}
+
+func TestNewIPFilter_Invalid(t *testing.T) {
+	testCases := map[string]string{
+		"should reject invalid JSON":       `{`,
+		"should reject an invalid network": `{"groups": {"admin": {"allow": ["10.0.0.0/33"]}}}`,
+		"should reject an invalid address": `{"default": {"deny": ["10.0.0"]}}`,
+	}
+	for name, rules := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ip-filter.json")
+			writeIPFilterFile(t, path, rules)
+			_, err := NewIPFilter(path)
+			assert.Error(t, err)
+		})
+	}
+	_, err := NewIPFilter(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestRequireAllowedIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	writeIPFilterFile(t, path, `{"groups": {"admin": {"allow": ["10.0.0.0/8"]}}}`)
+	filter, err := NewIPFilter(path)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name               string
+		remoteAddr         string
+		forwardedFor       string
+		expectedStatusCode int
+	}{
+		{name: "should pass an allowed address", remoteAddr: "10.0.0.1:1234", expectedStatusCode: http.StatusOK},
+		{name: "should reject a disallowed address", remoteAddr: "198.51.100.1:1234", expectedStatusCode: http.StatusForbidden},
+		{name: "should use the address forwarded by a trusted proxy", remoteAddr: "192.0.2.1:1234", forwardedFor: "10.0.0.1", expectedStatusCode: http.StatusOK},
+		{name: "should ignore the address forwarded by an untrusted client", remoteAddr: "198.51.100.1:1234", forwardedFor: "10.0.0.1", expectedStatusCode: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			require.NoError(t, router.SetTrustedProxies([]string{"192.0.2.0/24"}))
+			router.Use(RequireAllowedIP(filter, "admin"))
+			router.GET("/admin", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestRunIPFilterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	writeIPFilterFile(t, path, `{}`)
+	filter, err := NewIPFilter(path)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunIPFilterReload(ctx, filter, time.Millisecond, func(error) {})
+		close(done)
+	}()
+
+	ip := netip.MustParseAddr("198.51.100.1")
+	writeIPFilterFile(t, path, `{"default": {"deny": ["198.51.100.1"]}}`)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	assert.Eventually(t, func() bool { return !filter.Allowed("v1", ip) }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
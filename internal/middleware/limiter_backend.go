@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultFallbackCooldown is how long a failing primary backend is skipped
+const defaultFallbackCooldown = 5 * time.Second
+
+// LimiterBackend decides whether a request is allowed under a policy.
+// Implementations must be safe for concurrent use.
+type LimiterBackend interface {
+	Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed bool
+	// Limit is the number of requests allowed at once
+	Limit int
+	// Remaining is the number of requests still allowed right now
+	Remaining int
+	// Reset is the time until the whole limit is available again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+type localLimiterBackend struct {
+	store LimiterStore
+}
+
+// NewLocalLimiterBackend creates a backend keeping a token bucket per key
+// in process memory, so every replica enforces its own limits
+// Params: store LimiterStore - the limiters, a store with the default bounds when nil
+func NewLocalLimiterBackend(store LimiterStore) LimiterBackend {
+	if store == nil {
+		store = NewLimiterStore(LimiterStoreConfig{})
+	}
+	return &localLimiterBackend{store: store}
+}
+
+// Allow takes a token from the bucket of the key. A reservation that has
+// to wait is cancelled so rejected requests do not consume tokens.
+func (b *localLimiterBackend) Allow(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	limiter := b.store.Limiter(key, policy.newLimiter)
+	now := time.Now()
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		reservation.CancelAt(now)
+		return RateLimitResult{Limit: limiter.Burst(), Reset: delay, RetryAfter: delay}, nil
+	}
+
+	tokens := limiter.TokensAt(now)
+	return RateLimitResult{
+		Allowed:   true,
+		Limit:     limiter.Burst(),
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     untilFull(limiter, tokens),
+	}, nil
+}
+
+// untilFull returns the time until the limiter refills its whole burst
+func untilFull(limiter *rate.Limiter, tokens float64) time.Duration {
+	missing := float64(limiter.Burst()) - tokens
+	if missing <= 0 || limiter.Limit() == rate.Inf || limiter.Limit() <= 0 {
+		return 0
+	}
+	return time.Duration(missing / float64(limiter.Limit()) * float64(time.Second))
+}
+
+// FallbackConfig configures NewFallbackLimiterBackend
+type FallbackConfig struct {
+	// Cooldown is how long the primary is skipped after an error, 5 seconds when zero
+	Cooldown time.Duration
+	// OnError is called with the primary error each time the backend falls back
+	OnError func(err error)
+}
+
+type fallbackLimiterBackend struct {
+	primary  LimiterBackend
+	fallback LimiterBackend
+	cfg      FallbackConfig
+	now      func() time.Time
+
+	mu        sync.Mutex
+	skipUntil time.Time
+}
+
+// NewFallbackLimiterBackend creates a backend checking the primary, usually
+// a shared store, and the fallback while the primary fails. The primary is
+// skipped for a cooldown after an error so an unreachable store does not
+// add its timeout to every request.
+// Params: primary LimiterBackend - the preferred backend
+// Params: fallback LimiterBackend - the backend used while the primary fails
+// Params: cfg FallbackConfig - the cooldown and error hook
+func NewFallbackLimiterBackend(primary, fallback LimiterBackend, cfg FallbackConfig) LimiterBackend {
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultFallbackCooldown
+	}
+	return &fallbackLimiterBackend{primary: primary, fallback: fallback, cfg: cfg, now: time.Now}
+}
+
+// Allow checks the primary, or the fallback while the primary is failing
+func (b *fallbackLimiterBackend) Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	b.mu.Lock()
+	skip := b.now().Before(b.skipUntil)
+	b.mu.Unlock()
+	if !skip {
+		result, err := b.primary.Allow(ctx, key, policy)
+		if err == nil {
+			return result, nil
+		}
+		b.mu.Lock()
+		b.skipUntil = b.now().Add(b.cfg.Cooldown)
+		b.mu.Unlock()
+		if b.cfg.OnError != nil {
+			b.cfg.OnError(err)
+		}
+	}
+	return b.fallback.Allow(ctx, key, policy)
+}
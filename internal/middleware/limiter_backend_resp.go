@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"math"
+	"strconv"
+	"time"
+)
+
+// defaultRESPKeyPrefix namespaces the rate limit counters in the shared store
+const defaultRESPKeyPrefix = "msg-receiver:ratelimit:"
+
+type respLimiterBackend struct {
+	client *resp.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewRESPLimiterBackend creates a backend sharing its counters through a
+// Redis compatible server, so every replica enforces the same limits.
+//
+// It uses a sliding window approximated from two fixed window counters,
+// updated with INCR, PEXPIRE and GET in a single pipeline: atomic commands
+// only, no Lua. A policy allowing Burst requests at Rate per second becomes
+// Burst requests per Burst/Rate seconds.
+// Params: client *resp.Client - the connection to the shared store
+// Params: prefix string - prepended to every counter key, "msg-receiver:ratelimit:" when empty
+func NewRESPLimiterBackend(client *resp.Client, prefix string) LimiterBackend {
+	if prefix == "" {
+		prefix = defaultRESPKeyPrefix
+	}
+	return &respLimiterBackend{client: client, prefix: prefix, now: time.Now}
+}
+
+// Allow counts the request in the current window and rejects it when the
+// weighted count of the current and previous windows exceeds the limit.
+// Rejected requests are taken back out of the count.
+func (b *respLimiterBackend) Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	limit := policy.burst()
+	window := time.Duration(float64(limit) / float64(policy.limit()) * float64(time.Second))
+	if window < time.Millisecond {
+		window = time.Millisecond
+	}
+
+	now := b.now()
+	index := now.UnixNano() / int64(window)
+	elapsed := float64(now.UnixNano()-index*int64(window)) / float64(window)
+	current := b.prefix + key + ":" + strconv.FormatInt(index, 10)
+	previous := b.prefix + key + ":" + strconv.FormatInt(index-1, 10)
+
+	// the counter outlives its window to weigh the next one
+	replies, err := b.client.Pipeline(ctx,
+		[]string{"INCR", current},
+		[]string{"PEXPIRE", current, strconv.FormatInt((2*window).Milliseconds()+1, 10)},
+		[]string{"GET", previous},
+	)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	count, err := resp.Int(replies[0])
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("rate limit counter %s: %w", current, err)
+	}
+	if replyErr, ok := replies[1].(resp.Error); ok {
+		return RateLimitResult{}, fmt.Errorf("rate limit counter %s: %w", current, replyErr)
+	}
+	var previousCount int64
+	if replies[2] != nil {
+		if previousCount, err = resp.Int(replies[2]); err != nil {
+			return RateLimitResult{}, fmt.Errorf("rate limit counter %s: %w", previous, err)
+		}
+	}
+
+	weighted := float64(previousCount)*(1-elapsed) + float64(count)
+	remaining := float64(window) * (1 - elapsed)
+	reset := time.Duration(remaining)
+	if count > 0 {
+		reset += window
+	}
+
+	if weighted > float64(limit) {
+		if _, err := b.client.Do(ctx, "DECR", current); err != nil {
+			return RateLimitResult{}, err
+		}
+		return RateLimitResult{
+			Limit:      limit,
+			Reset:      reset,
+			RetryAfter: retryAfter(limit, previousCount, count-1, elapsed, window),
+		}, nil
+	}
+	return RateLimitResult{
+		Allowed:   true,
+		Limit:     limit,
+		Remaining: int(math.Floor(float64(limit) - weighted)),
+		Reset:     reset,
+	}, nil
+}
+
+// retryAfter returns the time until one more request fits in the sliding
+// window, given the counts without the rejected request
+func retryAfter(limit int, previous, current int64, elapsed float64, window time.Duration) time.Duration {
+	// room left in the current window once enough of the previous one slides out
+	if room := float64(limit) - float64(current) - 1; room >= 0 && previous > 0 {
+		target := 1 - room/float64(previous)
+		return time.Duration((target - elapsed) * float64(window))
+	}
+	// otherwise the current window has to slide out
+	wait := (1 - elapsed) * float64(window)
+	if current > 0 {
+		target := 1 - float64(limit-1)/float64(current)
+		wait += math.Max(0, target) * float64(window)
+	}
+	return time.Duration(wait)
+}
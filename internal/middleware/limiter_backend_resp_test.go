@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRESPBackends creates backends sharing a server, as replicas would,
+// with a clock starting at the beginning of a 3 second window
+func newRESPBackends(t *testing.T, server *resptest.Server, n int) ([]*respLimiterBackend, *time.Time) {
+	t.Helper()
+	now := time.Unix(1700000001, 0)
+	backends := make([]*respLimiterBackend, n)
+	for i := range backends {
+		client, err := resp.NewClient(resp.ClientConfig{Addr: server.Addr(), Timeout: 200 * time.Millisecond})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = client.Close() })
+		backends[i] = NewRESPLimiterBackend(client, "").(*respLimiterBackend)
+		backends[i].now = func() time.Time { return now }
+	}
+	return backends, &now
+}
+
+func TestRESPLimiterBackend(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	backends, now := newRESPBackends(t, server, 2)
+	// 3 requests per 3 seconds
+	policy := RateLimitPolicy{Rate: 1, Burst: 3}
+	ctx := context.Background()
+
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		// alternate replicas, which must share the quota
+		result, err := backends[i%2].Allow(ctx, "sub:orders", policy)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Limit)
+		if result.Allowed {
+			allowed++
+			assert.Equal(t, 3-allowed, result.Remaining)
+		} else {
+			assert.Equal(t, 4*time.Second, result.RetryAfter)
+		}
+	}
+	assert.Equal(t, 3, allowed, "replicas should share the limit")
+
+	current, ok := server.Get("msg-receiver:ratelimit:sub:orders:566666667")
+	require.True(t, ok)
+	assert.Equal(t, "3", current, "rejected requests should not be counted")
+
+	// a third of the next window in, two thirds of the previous count still weigh
+	*now = now.Add(4 * time.Second)
+	server.FastForward(4 * time.Second)
+	result, err := backends[0].Allow(ctx, "sub:orders", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	result, err = backends[1].Allow(ctx, "sub:orders", policy)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	*now = now.Add(time.Second)
+	server.FastForward(time.Second)
+	result, err = backends[1].Allow(ctx, "sub:orders", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// counters expire once they no longer weigh on any window
+	*now = now.Add(10 * time.Second)
+	server.FastForward(10 * time.Second)
+	_, ok = server.Get("msg-receiver:ratelimit:sub:orders:566666667")
+	assert.False(t, ok)
+}
+
+func TestRESPLimiterBackend_Window(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	backends, _ := newRESPBackends(t, server, 1)
+
+	// 10 requests per minute
+	_, err := backends[0].Allow(context.Background(), "ip:10.0.0.1", RateLimitPolicy{Rate: 10, Window: time.Minute})
+	require.NoError(t, err)
+	ttl := server.TTL("msg-receiver:ratelimit:ip:10.0.0.1:28333333")
+	assert.Equal(t, 2*time.Minute+time.Millisecond, ttl)
+}
+
+func TestRESPLimiterBackend_Unreachable(t *testing.T) {
+	server := resptest.NewServer()
+	backends, _ := newRESPBackends(t, server, 1)
+	server.Close()
+
+	_, err := backends[0].Allow(context.Background(), "sub:orders", RateLimitPolicy{Rate: 1})
+	assert.Error(t, err)
+
+	backend := NewFallbackLimiterBackend(backends[0], NewLocalLimiterBackend(nil), FallbackConfig{})
+	result, err := backend.Allow(context.Background(), "sub:orders", RateLimitPolicy{Rate: 1})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "the local limiter should take over")
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingBackend fails every check
+type failingBackend struct {
+	calls int
+}
+
+func (b *failingBackend) Allow(context.Context, string, RateLimitPolicy) (RateLimitResult, error) {
+	b.calls++
+	return RateLimitResult{}, errors.New("store unreachable")
+}
+
+func TestLocalLimiterBackend(t *testing.T) {
+	backend := NewLocalLimiterBackend(nil)
+	policy := RateLimitPolicy{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	result, err := backend.Allow(ctx, "ip:10.0.0.1", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 1, result.Remaining)
+	assert.InDelta(t, time.Second, result.Reset, float64(10*time.Millisecond))
+
+	_, err = backend.Allow(ctx, "ip:10.0.0.1", policy)
+	require.NoError(t, err)
+	result, err = backend.Allow(ctx, "ip:10.0.0.1", policy)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.InDelta(t, time.Second, result.RetryAfter, float64(10*time.Millisecond))
+
+	result, err = backend.Allow(ctx, "ip:10.0.0.2", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "keys should not share a bucket")
+}
+
+func TestFallbackLimiterBackend(t *testing.T) {
+	primary := &failingBackend{}
+	var reported []error
+	backend := NewFallbackLimiterBackend(primary, NewLocalLimiterBackend(nil), FallbackConfig{
+		Cooldown: time.Minute,
+		OnError:  func(err error) { reported = append(reported, err) },
+	}).(*fallbackLimiterBackend)
+	now := time.Now()
+	backend.now = func() time.Time { return now }
+	policy := RateLimitPolicy{Rate: 1, Burst: 1}
+
+	result, err := backend.Allow(context.Background(), "sub:orders", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "the fallback should answer while the primary fails")
+	result, err = backend.Allow(context.Background(), "sub:orders", policy)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "the fallback should enforce the policy")
+	assert.Equal(t, 1, primary.calls, "the primary should be skipped during the cooldown")
+	assert.Len(t, reported, 1)
+
+	now = now.Add(time.Minute)
+	_, err = backend.Allow(context.Background(), "sub:orders", policy)
+	require.NoError(t, err)
+	assert.Equal(t, 2, primary.calls, "the primary should be retried after the cooldown")
+}
+
+func TestNewRateLimiter_FailsOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRateLimiter(RateLimiterConfig{
+		Default: RateLimitPolicy{Rate: 1},
+		Backend: &failingBackend{},
+	}))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
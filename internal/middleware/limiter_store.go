@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"container/list"
+	"golang.org/x/time/rate"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// LimiterStore is a contract for keeping one rate limiter per client key
+type LimiterStore interface {
+	// Limiter returns the limiter of key, creating it with newLimiter when missing
+	Limiter(key string, newLimiter func() *rate.Limiter) *rate.Limiter
+	// Len returns the number of limiters currently kept
+	Len() int
+}
+
+// LimiterStoreConfig bounds the memory used by a LimiterStore
+type LimiterStoreConfig struct {
+	// MaxEntries caps the number of limiters kept, 100000 when zero. The
+	// least recently used limiter is evicted once the cap is reached.
+	MaxEntries int
+	// IdleTTL evicts limiters not used for this long, 10 minutes when zero.
+	// Keep it above the time a limiter needs to refill its burst, otherwise a
+	// client could reset its limiter by pausing.
+	IdleTTL time.Duration
+	// Shards splits the store to reduce lock contention, 16 when zero
+	Shards int
+}
+
+type limiterEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterShard is an LRU list of limiters guarded by its own lock; the
+// front of the list is the most recently used entry
+type limiterShard struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+}
+
+type limiterStore struct {
+	shards  []*limiterShard
+	seed    maphash.Seed
+	idleTTL time.Duration
+	now     func() time.Time
+}
+
+// NewLimiterStore creates a sharded in-memory limiter store with LRU and
+// idle-time eviction
+// Params: cfg LimiterStoreConfig - the store bounds
+func NewLimiterStore(cfg LimiterStoreConfig) LimiterStore {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 100000
+	}
+	if cfg.IdleTTL <= 0 {
+		cfg.IdleTTL = 10 * time.Minute
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = 16
+	}
+	if cfg.Shards > cfg.MaxEntries {
+		cfg.Shards = cfg.MaxEntries
+	}
+
+	s := &limiterStore{
+		shards:  make([]*limiterShard, cfg.Shards),
+		seed:    maphash.MakeSeed(),
+		idleTTL: cfg.IdleTTL,
+		now:     time.Now,
+	}
+	// spread the cap over the shards, rounding up so the total is never below MaxEntries
+	perShard := (cfg.MaxEntries + cfg.Shards - 1) / cfg.Shards
+	for i := range s.shards {
+		s.shards[i] = &limiterShard{
+			entries:    make(map[string]*list.Element),
+			lru:        list.New(),
+			maxEntries: perShard,
+		}
+	}
+	return s
+}
+
+// Limiter returns the limiter of key, creating it with newLimiter when missing
+// Params: key string - the client key
+// Params: newLimiter func() *rate.Limiter - builds the limiter of a new key
+func (s *limiterStore) Limiter(key string, newLimiter func() *rate.Limiter) *rate.Limiter {
+	shard := s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+	now := s.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.evictIdle(now.Add(-s.idleTTL))
+	if element, ok := shard.entries[key]; ok {
+		entry := element.Value.(*limiterEntry)
+		entry.lastSeen = now
+		shard.lru.MoveToFront(element)
+		return entry.limiter
+	}
+
+	entry := &limiterEntry{key: key, limiter: newLimiter(), lastSeen: now}
+	shard.entries[key] = shard.lru.PushFront(entry)
+	for shard.lru.Len() > shard.maxEntries {
+		shard.remove(shard.lru.Back())
+	}
+	return entry.limiter
+}
+
+// Len returns the number of limiters currently kept
+func (s *limiterStore) Len() int {
+	total := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		total += shard.lru.Len()
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// evictIdle drops the entries last used before cutoff. The list is ordered
+// by use, so only its tail is visited; callers must hold the lock.
+func (s *limiterShard) evictIdle(cutoff time.Time) {
+	for element := s.lru.Back(); element != nil; element = s.lru.Back() {
+		if !element.Value.(*limiterEntry).lastSeen.Before(cutoff) {
+			return
+		}
+		s.remove(element)
+	}
+}
+
+func (s *limiterShard) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*limiterEntry).key)
+}
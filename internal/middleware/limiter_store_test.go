@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLimiter() *rate.Limiter {
+	return rate.NewLimiter(1, 1)
+}
+
+func TestLimiterStore_Limiter(t *testing.T) {
+	store := NewLimiterStore(LimiterStoreConfig{})
+
+	first := store.Limiter("10.0.0.1", newTestLimiter)
+	assert.Same(t, first, store.Limiter("10.0.0.1", newTestLimiter))
+	assert.NotSame(t, first, store.Limiter("10.0.0.2", newTestLimiter))
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestLimiterStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewLimiterStore(LimiterStoreConfig{MaxEntries: 2, Shards: 1})
+
+	first := store.Limiter("a", newTestLimiter)
+	store.Limiter("b", newTestLimiter)
+	// using "a" again makes "b" the least recently used
+	store.Limiter("a", newTestLimiter)
+	store.Limiter("c", newTestLimiter)
+
+	assert.Equal(t, 2, store.Len())
+	assert.Same(t, first, store.Limiter("a", newTestLimiter))
+	shard := store.(*limiterStore).shards[0]
+	assert.NotContains(t, shard.entries, "b")
+}
+
+func TestLimiterStore_EvictsIdleEntries(t *testing.T) {
+	store := NewLimiterStore(LimiterStoreConfig{IdleTTL: time.Minute, Shards: 1})
+	now := time.Now()
+	store.(*limiterStore).now = func() time.Time { return now }
+
+	idle := store.Limiter("idle", newTestLimiter)
+	now = now.Add(30 * time.Second)
+	store.Limiter("active", newTestLimiter)
+	now = now.Add(45 * time.Second)
+
+	assert.NotSame(t, idle, store.Limiter("idle", newTestLimiter), "idle limiters should be recreated")
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestLimiterStore_Concurrent(t *testing.T) {
+	store := NewLimiterStore(LimiterStoreConfig{MaxEntries: 1000})
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				store.Limiter(fmt.Sprintf("10.%d.%d.%d", worker, i/256, i%256), newTestLimiter).Allow()
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, store.Len(), 1000+16, "the store should stay bounded")
+}
@@ -1,36 +1,265 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
+	"math"
 	"net/http"
-	"sync"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// RateLimiter creates a rate limiter for each client IP
+// RateLimitPolicy is the sustained rate and burst allowed to one key
+type RateLimitPolicy struct {
+	// Rate is the number of requests per Window
+	Rate rate.Limit `json:"rate"`
+	// Burst is the number of requests allowed at once, the rate rounded down (at least one) when zero
+	Burst int `json:"burst"`
+	// Window is the period Rate is measured over, one second when zero;
+	// written as a duration string such as "1m" in the policies file
+	Window time.Duration `json:"window"`
+}
+
+// RouteRateLimit applies its own policy to the requests of a route, with
+// limiters separate from the other routes
+type RouteRateLimit struct {
+	// Method matches every method when empty
+	Method string `json:"method"`
+	// Path is a route path such as "/token", or a prefix ending with "*" such as "/v1/*"
+	Path string `json:"path"`
+	// Policy applies to every key without a policy in Keys
+	Policy RateLimitPolicy `json:"policy"`
+	// Keys override the route policy for specific keys
+	Keys map[string]RateLimitPolicy `json:"keys"`
+}
+
+// RateLimitPolicies are the policies overriding the default one
+type RateLimitPolicies struct {
+	// Keys override the default for specific keys, e.g. "tenant:acme"
+	Keys map[string]RateLimitPolicy `json:"keys"`
+	// Routes override the default for matching routes, the first match wins
+	Routes []RouteRateLimit `json:"routes"`
+}
+
+// RateLimiterConfig configures NewRateLimiter
+type RateLimiterConfig struct {
+	// Default applies to requests without a policy of their own
+	Default RateLimitPolicy
+	// Policies override the default for specific keys and routes
+	Policies RateLimitPolicies
+	// Key extracts the key of a request, the client IP when nil
+	Key KeyFunc
+	// Backend checks the requests, a local backend keeping its limiters in Store when nil
+	Backend LimiterBackend
+	// Store keeps the limiter of each key of the local backend, a store with the default bounds when nil
+	Store LimiterStore
+	// Name prefixes the keys in the backend, so limiters sharing it do not
+	// share tokens
+	Name string
+}
+
+// RateLimiter creates a rate limiter for each client IP, kept in a store
+// with the default bounds
 func RateLimiter(limit rate.Limit) gin.HandlerFunc {
-	// Map to store rate limiters for each client IP
-	limiterMap := make(map[string]*rate.Limiter)
-	var mu sync.Mutex
+	return NewRateLimiter(RateLimiterConfig{Default: RateLimitPolicy{Rate: limit}})
+}
 
-	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
+// NewRateLimiter creates a rate limiter for each request key
+// Params: cfg RateLimiterConfig - the policies, key function and store
+func NewRateLimiter(cfg RateLimiterConfig) gin.HandlerFunc {
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP()
+	}
+	if cfg.Backend == nil {
+		cfg.Backend = NewLocalLimiterBackend(cfg.Store)
+	}
 
-		// Ensure thread-safe access to the map
-		mu.Lock()
-		if _, exists := limiterMap[clientIP]; !exists {
-			limiterMap[clientIP] = rate.NewLimiter(limit, int(limit))
+	return func(c *gin.Context) {
+		key, _ := cfg.Key(c)
+		policy, storeKey := cfg.policy(c, key)
+		if cfg.Name != "" {
+			storeKey = cfg.Name + " " + storeKey
 		}
-		mu.Unlock()
 
-		// Check if the client is allowed to proceed
-		if !limiterMap[clientIP].Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		// Check if the client is allowed to proceed; requests are let
+		// through when the backend fails, as rejecting every request would
+		// turn a limiter outage into a full outage
+		result, err := cfg.Backend.Allow(c.Request.Context(), storeKey, policy)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			setRateLimitHeaders(c, result.Limit, 0, retryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate_limited", "error_description": "rate limit exceeded"})
 			c.Abort()
 			return
 		}
+		setRateLimitHeaders(c, result.Limit, result.Remaining, int(math.Ceil(result.Reset.Seconds())))
 
 		// Continue with the request
 		c.Next()
 	}
 }
+
+// LoadRateLimitPolicies reads policies from a JSON file of the form
+// {"keys": {"tenant:acme": {"rate": 100, "burst": 200}},
+// "routes": [{"method": "POST", "path": "/token", "policy": {"rate": 10, "window": "1m"}}]}
+// Params: path string - the policies file
+func LoadRateLimitPolicies(path string) (RateLimitPolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RateLimitPolicies{}, err
+	}
+	var policies RateLimitPolicies
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return RateLimitPolicies{}, fmt.Errorf("invalid rate limit file %s: %w", path, err)
+	}
+	if err := policies.validate(); err != nil {
+		return RateLimitPolicies{}, fmt.Errorf("invalid rate limit file %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// policy returns the policy of a request and the key of its limiter in the
+// store; route limiters are prefixed with the route so routes do not share tokens
+func (cfg RateLimiterConfig) policy(c *gin.Context, key string) (RateLimitPolicy, string) {
+	for _, route := range cfg.Policies.Routes {
+		if !route.matches(c.Request.Method, c.FullPath()) {
+			continue
+		}
+		storeKey := route.Method + " " + route.Path + " " + key
+		if policy, ok := route.Keys[key]; ok {
+			return policy, storeKey
+		}
+		return route.Policy, storeKey
+	}
+	if policy, ok := cfg.Policies.Keys[key]; ok {
+		return policy, key
+	}
+	return cfg.Default, key
+}
+
+// matches reports whether the route applies to a request
+func (r RouteRateLimit) matches(method, path string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == r.Path
+}
+
+// hasKey reports whether a key has a policy of its own, on any route
+func (p RateLimitPolicies) hasKey(key string) bool {
+	if _, ok := p.Keys[key]; ok {
+		return true
+	}
+	for _, route := range p.Routes {
+		if _, ok := route.Keys[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RateLimitPolicies) validate() error {
+	for key, policy := range p.Keys {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+	}
+	for _, route := range p.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route %q: path should start with /", route.Path)
+		}
+		if err := route.Policy.validate(); err != nil {
+			return fmt.Errorf("route %s %s: %w", route.Method, route.Path, err)
+		}
+		for key, policy := range route.Keys {
+			if err := policy.validate(); err != nil {
+				return fmt.Errorf("route %s %s key %q: %w", route.Method, route.Path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p RateLimitPolicy) validate() error {
+	if p.Rate <= 0 || p.Burst < 0 || p.Window < 0 {
+		return errors.New("policy needs a positive rate")
+	}
+	return nil
+}
+
+// UnmarshalJSON reads the window as a duration string
+func (p *RateLimitPolicy) UnmarshalJSON(data []byte) error {
+	type policy RateLimitPolicy
+	var raw struct {
+		policy
+		Window string `json:"window"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = RateLimitPolicy(raw.policy)
+	p.Window = 0
+	if raw.Window != "" {
+		window, err := time.ParseDuration(raw.Window)
+		if err != nil {
+			return fmt.Errorf("invalid window: %w", err)
+		}
+		p.Window = window
+	}
+	return nil
+}
+
+// setRateLimitHeaders writes the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of draft-ietf-httpapi-ratelimit-headers
+func setRateLimitHeaders(c *gin.Context, limit, remaining, reset int) {
+	c.Header("RateLimit-Limit", strconv.Itoa(limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(reset))
+}
+
+// ceilSeconds rounds a delay up to whole seconds, at least one, as clients
+// retrying before the delay elapses would be rejected again
+func ceilSeconds(delay time.Duration) int {
+	seconds := int(math.Ceil(delay.Seconds()))
+	if seconds < 1 || delay == rate.InfDuration {
+		seconds = 1
+	}
+	return seconds
+}
+
+func (p RateLimitPolicy) newLimiter() *rate.Limiter {
+	return rate.NewLimiter(p.limit(), p.burst())
+}
+
+// limit converts the rate per window into a rate per second
+func (p RateLimitPolicy) limit() rate.Limit {
+	if p.Window <= 0 {
+		return p.Rate
+	}
+	return p.Rate / rate.Limit(p.Window.Seconds())
+}
+
+// burst defaults to the rate; a burst below one would reject every request
+func (p RateLimitPolicy) burst() int {
+	burst := p.Burst
+	if burst == 0 {
+		burst = int(p.Rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return burst
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// KeyFunc extracts the rate limit key of a request. Keys are prefixed with
+// their source, e.g. "sub:orders", "tenant:acme" or "ip:10.0.0.1"; ok is
+// false when the request carries no such identity.
+type KeyFunc func(c *gin.Context) (key string, ok bool)
+
+// KeyBySubject keys requests by the verified token subject; it only finds a
+// key on routes behind RequireJWT
+func KeyBySubject() KeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		subject := Subject(c)
+		return "sub:" + subject, subject != ""
+	}
+}
+
+// KeyByClaim keys requests by a string claim of the verified token, e.g. "tenant"
+// Params: claim string - the claim name
+func KeyByClaim(claim string) KeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		value, _ := Claims(c)[claim].(string)
+		return claim + ":" + value, value != ""
+	}
+}
+
+// KeyByHeader keys requests by a header value, e.g. an API key. The header
+// is not verified, so only values with a policy of their own are honoured;
+// any other value finds no key, or clients could reset their limit by
+// sending a new value with every request.
+// Params: header string - the header name
+// Params: policies RateLimitPolicies - the policies listing the known values
+func KeyByHeader(header string, policies RateLimitPolicies) KeyFunc {
+	prefix := strings.ToLower(header) + ":"
+	return func(c *gin.Context) (string, bool) {
+		value := c.GetHeader(header)
+		key := prefix + value
+		return key, value != "" && policies.hasKey(key)
+	}
+}
+
+// KeyByIP keys requests by client IP
+func KeyByIP() KeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		return "ip:" + c.ClientIP(), true
+	}
+}
+
+// FirstKey returns the key of the first function that finds one, falling
+// back to the client IP
+// Params: funcs ...KeyFunc - the key functions, in order of preference
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	byIP := KeyByIP()
+	return func(c *gin.Context) (string, bool) {
+		for _, keyFunc := range funcs {
+			if key, ok := keyFunc(c); ok {
+				return key, true
+			}
+		}
+		return byIP(c)
+	}
+}
+
+// ParseKeyFuncs builds a key function from source names, tried in order:
+// "sub", "tenant", "claim:<name>", "header:<name>" and "ip". The client IP
+// is always the last resort.
+// Params: sources []string - the key sources
+// Params: policies RateLimitPolicies - the policies listing the header values honoured
+func ParseKeyFuncs(sources []string, policies RateLimitPolicies) (KeyFunc, error) {
+	funcs := make([]KeyFunc, 0, len(sources))
+	for _, source := range sources {
+		kind, name, _ := strings.Cut(strings.TrimSpace(source), ":")
+		switch {
+		case kind == "sub" && name == "":
+			funcs = append(funcs, KeyBySubject())
+		case kind == "tenant" && name == "":
+			funcs = append(funcs, KeyByClaim("tenant"))
+		case kind == "claim" && name != "":
+			funcs = append(funcs, KeyByClaim(name))
+		case kind == "header" && name != "":
+			funcs = append(funcs, KeyByHeader(name, policies))
+		case kind == "ip" && name == "":
+			funcs = append(funcs, KeyByIP())
+		default:
+			return nil, fmt.Errorf("unknown rate limit key %q", source)
+		}
+	}
+	return FirstKey(funcs...), nil
+}
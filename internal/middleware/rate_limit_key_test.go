@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseKeyFuncs(t *testing.T) {
+	testCases := []struct {
+		name        string
+		sources     []string
+		subject     string
+		claims      jwt.MapClaims
+		headers     map[string]string
+		expectedKey string
+	}{
+		{
+			name:        "should key by subject",
+			sources:     []string{"sub", "ip"},
+			subject:     "orders",
+			expectedKey: "sub:orders",
+		}, {
+			name:        "should prefer the tenant claim",
+			sources:     []string{"tenant", "sub"},
+			subject:     "orders",
+			claims:      jwt.MapClaims{"tenant": "acme"},
+			expectedKey: "tenant:acme",
+		}, {
+			name:        "should skip a missing tenant claim",
+			sources:     []string{"tenant", "sub"},
+			subject:     "orders",
+			claims:      jwt.MapClaims{},
+			expectedKey: "sub:orders",
+		}, {
+			name:        "should key by any claim",
+			sources:     []string{"claim:org"},
+			claims:      jwt.MapClaims{"org": "payments"},
+			expectedKey: "org:payments",
+		}, {
+			name:        "should key by an API key header",
+			sources:     []string{"header:X-API-Key", "sub"},
+			subject:     "orders",
+			headers:     map[string]string{"X-API-Key": "key-1"},
+			expectedKey: "x-api-key:key-1",
+		}, {
+			name:        "should ignore API keys without a policy",
+			sources:     []string{"header:X-API-Key", "sub"},
+			subject:     "orders",
+			headers:     map[string]string{"X-API-Key": "random"},
+			expectedKey: "sub:orders",
+		}, {
+			name:        "should fall back to the client IP",
+			sources:     []string{"sub", "header:X-API-Key"},
+			headers:     map[string]string{"X-API-Key": "random"},
+			expectedKey: "ip:10.0.0.1",
+		}, {
+			name:        "should fall back to the client IP without sources",
+			expectedKey: "ip:10.0.0.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyFunc, err := ParseKeyFuncs(tc.sources, RateLimitPolicies{
+				Routes: []RouteRateLimit{{Path: "/v1/*", Keys: map[string]RateLimitPolicy{"x-api-key:key-1": {Rate: 10}}}},
+			})
+			require.NoError(t, err)
+
+			gin.SetMode(gin.TestMode)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("X-Real-IP", "10.0.0.1")
+			for k, v := range tc.headers {
+				c.Request.Header.Set(k, v)
+			}
+			if tc.subject != "" {
+				c.Set(SubjectKey, tc.subject)
+			}
+			if tc.claims != nil {
+				c.Set(ClaimsKey, tc.claims)
+			}
+
+			key, ok := keyFunc(c)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedKey, key)
+		})
+	}
+}
+
+func TestParseKeyFuncs_Errors(t *testing.T) {
+	for _, source := range []string{"user", "claim", "header:", "ip:x"} {
+		_, err := ParseKeyFuncs([]string{source}, RateLimitPolicies{})
+		assert.Error(t, err, source)
+	}
+}
@@ -1,14 +1,18 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
 )
 
@@ -47,6 +51,7 @@ func TestRateLimiter(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":"rate_limited","error_description":"rate limit exceeded"}`, w.Body.String())
 }
 
 func TestRateLimiter_ConcurrentRequests(t *testing.T) {
@@ -140,3 +145,293 @@ func TestRateLimiter_ResetAfterDuration(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestNewRateLimiter_ManyClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewLimiterStore(LimiterStoreConfig{MaxEntries: 100, Shards: 4})
+	router := gin.New()
+	router.Use(NewRateLimiter(RateLimiterConfig{Default: RateLimitPolicy{Rate: 1}, Store: store}))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 1000; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("X-Real-IP", fmt.Sprintf("192.168.%d.%d", i/256, i%256))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.LessOrEqual(t, store.Len(), 100)
+}
+
+func TestRateLimiter_FractionalLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimiter(rate.Limit(0.5)))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "limits below one request per second should still allow a burst of one")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRateLimiter_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimiter(rate.Limit(0.5)))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// a rate of 0.5 gets a burst of 1, refilled every 2 seconds
+	testCases := []struct {
+		name              string
+		expectedCode      int
+		expectedRemaining string
+		expectedReset     string
+		expectedRetry     string
+	}{
+		{
+			name:              "should report the remaining quota",
+			expectedCode:      http.StatusOK,
+			expectedRemaining: "0",
+			expectedReset:     "2",
+		}, {
+			name:              "should tell rejected clients when to retry",
+			expectedCode:      http.StatusTooManyRequests,
+			expectedRemaining: "0",
+			expectedReset:     "2",
+			expectedRetry:     "2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, tc.expectedRemaining, w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, tc.expectedReset, w.Header().Get("RateLimit-Reset"))
+			assert.Equal(t, tc.expectedRetry, w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestRateLimiter_RemainingDecreases(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRateLimiter(RateLimiterConfig{Default: RateLimitPolicy{Rate: 1, Burst: 3}}))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, remaining := range []string{"2", "1", "0"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, remaining, w.Header().Get("RateLimit-Remaining"))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"), "rejected requests should not consume tokens")
+}
+
+func TestNewRateLimiter_Name(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := NewLocalLimiterBackend(nil)
+	router := gin.New()
+	// both limiters key the request by IP in the same backend
+	router.Use(
+		NewRateLimiter(RateLimiterConfig{Default: RateLimitPolicy{Rate: 1, Burst: 2}, Backend: backend, Name: "ip"}),
+		NewRateLimiter(RateLimiterConfig{Default: RateLimitPolicy{Rate: 1, Burst: 2}, Backend: backend}),
+	)
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		assert.Equal(t, expected, w.Code)
+	}
+}
+
+func TestNewRateLimiter_PerKeyPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(SubjectKey, c.GetHeader("X-Subject"))
+	}, NewRateLimiter(RateLimiterConfig{
+		Default:  RateLimitPolicy{Rate: 1},
+		Policies: RateLimitPolicies{Keys: map[string]RateLimitPolicy{"sub:premium": {Rate: 1, Burst: 3}}},
+		Key:      FirstKey(KeyBySubject()),
+	}))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(subject string) int {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		// every client shares the same IP, as behind a NAT
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		req.Header.Set("X-Subject", subject)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("basic"))
+	assert.Equal(t, http.StatusTooManyRequests, send("basic"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("premium"), "premium should get its own burst")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send("premium"))
+	assert.Equal(t, http.StatusOK, send("other"), "clients behind the same IP should not share a limit")
+}
+
+func TestNewRateLimiter_PerRoutePolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRateLimiter(RateLimiterConfig{
+		Default: RateLimitPolicy{Rate: 1, Burst: 2},
+		Policies: RateLimitPolicies{
+			Keys: map[string]RateLimitPolicy{"ip:10.0.0.2": {Rate: 1, Burst: 4}},
+			Routes: []RouteRateLimit{
+				{Method: http.MethodPost, Path: "/token", Policy: RateLimitPolicy{Rate: 5, Window: time.Minute, Burst: 1}},
+				{Path: "/v1/*", Policy: RateLimitPolicy{Rate: 100, Burst: 5}, Keys: map[string]RateLimitPolicy{"ip:10.0.0.2": {Rate: 100, Burst: 6}}},
+			},
+		},
+	}))
+	handler := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	router.POST("/token", handler)
+	router.GET("/token", handler)
+	router.POST("/v1/messages", handler)
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		ip       string
+		expected int
+	}{
+		{
+			name:     "should apply the strict token policy",
+			method:   http.MethodPost,
+			path:     "/token",
+			ip:       "10.0.0.1",
+			expected: 1,
+		}, {
+			name:     "should apply the default to other methods",
+			method:   http.MethodGet,
+			path:     "/token",
+			ip:       "10.0.0.1",
+			expected: 2,
+		}, {
+			name:     "should apply the bulk policy to a route prefix",
+			method:   http.MethodPost,
+			path:     "/v1/messages",
+			ip:       "10.0.0.1",
+			expected: 5,
+		}, {
+			name:     "should apply route key overrides",
+			method:   http.MethodPost,
+			path:     "/v1/messages",
+			ip:       "10.0.0.2",
+			expected: 6,
+		}, {
+			name:     "should apply key policies outside route policies",
+			method:   http.MethodGet,
+			path:     "/token",
+			ip:       "10.0.0.2",
+			expected: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(tc.method, tc.path, nil)
+				req.Header.Set("X-Real-IP", tc.ip)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code == http.StatusOK {
+					allowed++
+				}
+			}
+			assert.Equal(t, tc.expected, allowed)
+		})
+	}
+}
+
+func TestRateLimitPolicy_Window(t *testing.T) {
+	policy := RateLimitPolicy{Rate: 60, Window: time.Minute}
+	assert.Equal(t, rate.Limit(1), policy.limit())
+	assert.Equal(t, 60, policy.burst(), "the burst should default to the requests of a window")
+	assert.Equal(t, rate.Limit(2), RateLimitPolicy{Rate: 2}.limit())
+}
+
+func TestLoadRateLimitPolicies(t *testing.T) {
+	testCases := []struct {
+		name          string
+		file          string
+		expected      RateLimitPolicies
+		expectedError string
+	}{
+		{
+			name: "should load key and route policies",
+			file: `{"keys":{"tenant:acme":{"rate":100,"burst":200}},
+				"routes":[{"method":"POST","path":"/token","policy":{"rate":10,"window":"1m"}}]}`,
+			expected: RateLimitPolicies{
+				Keys: map[string]RateLimitPolicy{"tenant:acme": {Rate: 100, Burst: 200}},
+				Routes: []RouteRateLimit{
+					{Method: "POST", Path: "/token", Policy: RateLimitPolicy{Rate: 10, Window: time.Minute}},
+				},
+			},
+		}, {
+			name:          "should reject a key policy without a rate",
+			file:          `{"keys":{"tenant:acme":{"burst":200}}}`,
+			expectedError: "positive rate",
+		}, {
+			name:          "should reject a route policy without a rate",
+			file:          `{"routes":[{"path":"/token","policy":{"burst":1}}]}`,
+			expectedError: "positive rate",
+		}, {
+			name:          "should reject a relative route path",
+			file:          `{"routes":[{"path":"token","policy":{"rate":1}}]}`,
+			expectedError: "should start with /",
+		}, {
+			name:          "should reject an invalid window",
+			file:          `{"routes":[{"path":"/token","policy":{"rate":1,"window":"often"}}]}`,
+			expectedError: "invalid window",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rate_limits.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.file), 0o600))
+			policies, err := LoadRateLimitPolicies(path)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, policies)
+		})
+	}
+
+	_, err := LoadRateLimitPolicies(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout  = time.Second
+	defaultPoolSize = 10
+)
+
+// ErrClosed is returned when sending commands through a closed client
+var ErrClosed = errors.New("resp: client closed")
+
+// ClientConfig configures a Client
+type ClientConfig struct {
+	// Addr is the host:port of the server
+	Addr string
+	// Password is sent with AUTH on every new connection when set
+	Password string
+	// DB is selected on every new connection when not zero
+	DB int
+	// Timeout bounds dialing and every round trip, one second when zero
+	Timeout time.Duration
+	// PoolSize is the number of idle connections kept open, 10 when zero
+	PoolSize int
+}
+
+// Client sends commands to a RESP server. It is safe for concurrent use;
+// every call takes a connection from a pool, so pipelines are not
+// interleaved with other commands.
+type Client struct {
+	cfg ClientConfig
+
+	mu     sync.Mutex
+	closed bool
+	idle   []*conn
+}
+
+// conn is a connection with buffered reads and writes
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// NewClient creates a client. The server is contacted lazily on the first command.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("resp: server address is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+	return &Client{cfg: cfg}, nil
+}
+
+// Do sends a command and returns its reply; error replies are returned as err
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := replies[0].(Error); ok {
+		return nil, replyErr
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends several commands in a single round trip and returns their
+// replies in order. Error replies are returned in the replies, not as err.
+func (c *Client) Pipeline(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := cn.roundTrip(ctx, c.cfg.Timeout, commands)
+	if err != nil {
+		// the connection state is unknown after a failed round trip
+		_ = cn.nc.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+// Close closes the idle connections; connections in use are closed when returned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	var errs []error
+	for _, cn := range c.idle {
+		errs = append(errs, cn.nc.Close())
+	}
+	c.idle = nil
+	return errors.Join(errs...)
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+// put returns a connection to the pool, closing it when the pool is full
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.cfg.PoolSize {
+		_ = cn.nc.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.cfg.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	if c.cfg.Password != "" {
+		setup = append(setup, []string{"AUTH", c.cfg.Password})
+	}
+	if c.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.cfg.DB)})
+	}
+	if len(setup) == 0 {
+		return cn, nil
+	}
+	replies, err := cn.roundTrip(ctx, c.cfg.Timeout, setup)
+	if err == nil {
+		for _, reply := range replies {
+			if replyErr, ok := reply.(Error); ok {
+				err = replyErr
+				break
+			}
+		}
+	}
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// roundTrip writes the commands and reads one reply per command
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, commands [][]string) ([]interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.nc.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	for _, args := range commands {
+		if err := WriteCommand(cn.w, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range replies {
+		reply, err := ReadReply(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
@@ -0,0 +1,111 @@
+package resp_test
+
+import (
+	"context"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, cfg resp.ClientConfig) *resp.Client {
+	t.Helper()
+	client, err := resp.NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestClient_Do(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client := newClient(t, resp.ClientConfig{Addr: server.Addr()})
+	ctx := context.Background()
+
+	reply, err := client.Do(ctx, "PING")
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	reply, err = client.Do(ctx, "INCR", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reply)
+
+	reply, err = client.Do(ctx, "GET", "missing")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+
+	_, err = client.Do(ctx, "FLUSHALL")
+	assert.ErrorContains(t, err, "unknown command")
+}
+
+func TestClient_Pipeline(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client := newClient(t, resp.ClientConfig{Addr: server.Addr()})
+
+	replies, err := client.Pipeline(context.Background(),
+		[]string{"INCR", "counter"},
+		[]string{"PEXPIRE", "counter", "1500"},
+		[]string{"SET", "name", "value", "EX", "1"},
+		[]string{"GET", "counter"},
+	)
+	require.NoError(t, err)
+	require.Len(t, replies, 4)
+	assert.Equal(t, int64(1), replies[0])
+	assert.Equal(t, int64(1), replies[1])
+	assert.IsType(t, resp.Error(""), replies[2], "error replies should not fail the pipeline")
+	assert.Equal(t, []byte("1"), replies[3])
+	assert.Equal(t, 1500*time.Millisecond, server.TTL("counter"))
+
+	server.FastForward(2 * time.Second)
+	_, ok := server.Get("counter")
+	assert.False(t, ok, "the counter should expire")
+}
+
+func TestClient_AuthAndSelect(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	server.RequirePass("secret")
+	ctx := context.Background()
+
+	client := newClient(t, resp.ClientConfig{Addr: server.Addr(), Password: "secret", DB: 2})
+	_, err := client.Do(ctx, "SET", "key", "value")
+	require.NoError(t, err)
+	_, ok := server.Get("key")
+	assert.False(t, ok, "the key should be written to the selected database")
+
+	wrongPassword := newClient(t, resp.ClientConfig{Addr: server.Addr(), Password: "wrong"})
+	_, err = wrongPassword.Do(ctx, "PING")
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	noPassword := newClient(t, resp.ClientConfig{Addr: server.Addr()})
+	_, err = noPassword.Do(ctx, "PING")
+	assert.ErrorContains(t, err, "NOAUTH")
+}
+
+func TestClient_Unreachable(t *testing.T) {
+	server := resptest.NewServer()
+	client := newClient(t, resp.ClientConfig{Addr: server.Addr(), Timeout: 100 * time.Millisecond})
+	_, err := client.Do(context.Background(), "PING")
+	require.NoError(t, err)
+
+	// the pooled connection is broken once the server goes away
+	server.Close()
+	_, err = client.Do(context.Background(), "PING")
+	assert.Error(t, err)
+	_, err = client.Do(context.Background(), "PING")
+	assert.Error(t, err)
+}
+
+func TestClient_Closed(t *testing.T) {
+	_, err := resp.NewClient(resp.ClientConfig{})
+	assert.Error(t, err)
+
+	client, err := resp.NewClient(resp.ClientConfig{Addr: "127.0.0.1:1"})
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+	_, err = client.Do(context.Background(), "PING")
+	assert.ErrorIs(t, err, resp.ErrClosed)
+}
@@ -0,0 +1,4 @@
+// Package resp provides a minimal client for the Redis serialization
+// protocol (RESP2), enough to share counters between replicas through
+// Redis or any compatible server such as Valkey, KeyDB or Dragonfly.
+package resp
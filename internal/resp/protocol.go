@@ -0,0 +1,135 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxBulkSize bounds the size of a single bulk string, the Redis default
+const maxBulkSize = 512 * 1024 * 1024
+
+// maxArrayLen bounds the number of elements of a single array
+const maxArrayLen = 1024 * 1024
+
+// ErrMalformed is returned when a reply cannot be decoded
+var ErrMalformed = errors.New("resp: malformed reply")
+
+// ErrNil is returned when a command expecting a value gets a null reply
+var ErrNil = errors.New("resp: nil reply")
+
+// Error is an error reply sent by the server, such as "ERR unknown command"
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// WriteCommand writes a command as an array of bulk strings
+func WriteCommand(w *bufio.Writer, args ...string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadReply reads a single reply. Simple strings are returned as string,
+// integers as int64, bulk strings as []byte, arrays as []interface{} and
+// null replies as nil. Error replies are returned as an Error value, not
+// as err, so the remaining replies of a pipeline can still be read.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty line", ErrMalformed)
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid integer %q", ErrMalformed, line[1:])
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 || n > maxBulkSize {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", ErrMalformed, line[1:])
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if data[n] != '\r' || data[n+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrMalformed)
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 || n > maxArrayLen {
+			return nil, fmt.Errorf("%w: invalid array length %q", ErrMalformed, line[1:])
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrMalformed, line[0])
+}
+
+// Int converts an integer reply, or a bulk string holding an integer, as
+// returned by GET on a counter
+func Int(reply interface{}) (int64, error) {
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("resp: %q is not an integer", v)
+		}
+		return n, nil
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, v
+	}
+	return 0, fmt.Errorf("resp: unexpected reply %T", reply)
+}
+
+// readLine reads a CRLF terminated line without the CRLF
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("%w: line too long", ErrMalformed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF", ErrMalformed)
+	}
+	return line[:len(line)-2], nil
+}
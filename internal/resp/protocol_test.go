@@ -0,0 +1,97 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestWriteCommand(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	require.NoError(t, WriteCommand(w, "SET", "key", ""))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n", buf.String())
+}
+
+func TestReadReply(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expected      interface{}
+		expectedError error
+	}{
+		{
+			name:     "should read a simple string",
+			input:    "+OK\r\n",
+			expected: "OK",
+		}, {
+			name:     "should read an error reply as a value",
+			input:    "-ERR unknown command\r\n",
+			expected: Error("ERR unknown command"),
+		}, {
+			name:     "should read an integer",
+			input:    ":-42\r\n",
+			expected: int64(-42),
+		}, {
+			name:     "should read a bulk string",
+			input:    "$5\r\nhe\r\nl\r\n",
+			expected: []byte("he\r\nl"),
+		}, {
+			name:     "should read a null bulk string",
+			input:    "$-1\r\n",
+			expected: nil,
+		}, {
+			name:     "should read nested arrays",
+			input:    "*2\r\n:1\r\n*1\r\n$1\r\na\r\n",
+			expected: []interface{}{int64(1), []interface{}{[]byte("a")}},
+		}, {
+			name:          "should reject an unknown type",
+			input:         "?1\r\n",
+			expectedError: ErrMalformed,
+		}, {
+			name:          "should reject a line without CRLF",
+			input:         ":1\n",
+			expectedError: ErrMalformed,
+		}, {
+			name:          "should reject an unterminated bulk string",
+			input:         "$2\r\nabcd",
+			expectedError: ErrMalformed,
+		}, {
+			name:          "should reject a negative bulk length",
+			input:         "$-2\r\n",
+			expectedError: ErrMalformed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply, err := ReadReply(bufio.NewReader(strings.NewReader(tc.input)))
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reply)
+		})
+	}
+}
+
+func TestInt(t *testing.T) {
+	n, err := Int(int64(3))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	n, err = Int([]byte("12"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), n)
+
+	_, err = Int(nil)
+	assert.ErrorIs(t, err, ErrNil)
+	_, err = Int([]byte("twelve"))
+	assert.Error(t, err)
+	_, err = Int(Error("WRONGTYPE"))
+	assert.Equal(t, Error("WRONGTYPE"), err)
+}
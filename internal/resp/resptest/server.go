@@ -0,0 +1,371 @@
+// Package resptest provides an in-process RESP server for tests.
+//
+// The server listens on a loopback port and implements the string and
+// expiry commands used by this module: PING, AUTH, SELECT, GET, SET (with
+// PX and NX), INCR, INCRBY, DECR, DEL, EXISTS, PEXPIRE and PTTL. Time only moves
+// forward with FastForward, so expiries are deterministic.
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a single-node fake RESP server
+type Server struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	now      time.Time
+	dbs      map[int]map[string]*entry
+	commands [][]string
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+type entry struct {
+	value    string
+	expireAt time.Time
+}
+
+// session is the state of a client connection
+type session struct {
+	db            int
+	authenticated bool
+}
+
+// NewServer starts a server on a random loopback port
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("resptest: failed to listen on a port: " + err.Error())
+	}
+	s := &Server{
+		listener: listener,
+		now:      time.Now(),
+		dbs:      make(map[int]map[string]*entry),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes every client connection
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// RequirePass makes new connections authenticate with AUTH before any other command
+func (s *Server) RequirePass(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// FastForward moves the server clock, expiring keys whose TTL elapsed
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// Get returns the value of a key in database 0
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(0, key)
+	if e == nil {
+		return "", false
+	}
+	return e.value, true
+}
+
+// TTL returns the remaining time to live of a key in database 0, zero
+// when the key does not exist or has no expiry
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(0, key)
+	if e == nil || e.expireAt.IsZero() {
+		return 0
+	}
+	return e.expireAt.Sub(s.now)
+}
+
+// Commands returns every command received so far
+func (s *Server) Commands() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	sess := &session{}
+	for {
+		request, err := resp.ReadReply(r)
+		if err != nil {
+			return
+		}
+		args, ok := commandArgs(request)
+		if !ok {
+			return
+		}
+		writeReply(w, s.dispatch(sess, args))
+		// replies of pipelined commands are flushed together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// commandArgs converts a request, an array of bulk strings, to its arguments
+func commandArgs(request interface{}) ([]string, bool) {
+	values, ok := request.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, false
+	}
+	args := make([]string, len(values))
+	for i, v := range values {
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, false
+		}
+		args[i] = string(b)
+	}
+	return args, true
+}
+
+// dispatch runs a command and returns its reply
+func (s *Server) dispatch(sess *session, args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, args)
+
+	name := strings.ToUpper(args[0])
+	if s.password != "" && !sess.authenticated && name != "AUTH" {
+		return resp.Error("NOAUTH Authentication required.")
+	}
+
+	switch name {
+	case "PING":
+		return "PONG"
+	case "AUTH":
+		if len(args) != 2 {
+			return wrongArity(name)
+		}
+		if s.password == "" || args[1] != s.password {
+			return resp.Error("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		sess.authenticated = true
+		return "OK"
+	case "SELECT":
+		if len(args) != 2 {
+			return wrongArity(name)
+		}
+		db, err := strconv.Atoi(args[1])
+		if err != nil || db < 0 || db > 15 {
+			return resp.Error("ERR DB index is out of range")
+		}
+		sess.db = db
+		return "OK"
+	case "GET":
+		if len(args) != 2 {
+			return wrongArity(name)
+		}
+		if e := s.lookup(sess.db, args[1]); e != nil {
+			return []byte(e.value)
+		}
+		return nil
+	case "SET":
+		return s.set(sess.db, args)
+	case "INCR", "DECR", "INCRBY":
+		return s.incr(sess.db, name, args)
+	case "DEL", "EXISTS":
+		if len(args) < 2 {
+			return wrongArity(name)
+		}
+		var n int64
+		for _, key := range args[1:] {
+			if s.lookup(sess.db, key) != nil {
+				n++
+				if name == "DEL" {
+					delete(s.db(sess.db), key)
+				}
+			}
+		}
+		return n
+	case "PEXPIRE":
+		if len(args) != 3 {
+			return wrongArity(name)
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		e := s.lookup(sess.db, args[1])
+		if e == nil {
+			return int64(0)
+		}
+		e.expireAt = s.now.Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+	case "PTTL":
+		if len(args) != 2 {
+			return wrongArity(name)
+		}
+		e := s.lookup(sess.db, args[1])
+		switch {
+		case e == nil:
+			return int64(-2)
+		case e.expireAt.IsZero():
+			return int64(-1)
+		}
+		return e.expireAt.Sub(s.now).Milliseconds()
+	}
+	return resp.Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+func (s *Server) set(db int, args []string) interface{} {
+	if len(args) < 3 {
+		return resp.Error("ERR syntax error")
+	}
+	e := &entry{value: args[2]}
+	onlyNew := false
+	for i := 3; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "NX"):
+			onlyNew = true
+		case strings.EqualFold(args[i], "PX") && i+1 < len(args):
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || ms <= 0 {
+				return resp.Error("ERR syntax error")
+			}
+			e.expireAt = s.now.Add(time.Duration(ms) * time.Millisecond)
+			i++
+		default:
+			return resp.Error("ERR syntax error")
+		}
+	}
+	if onlyNew && s.lookup(db, args[1]) != nil {
+		return nil
+	}
+	s.db(db)[args[1]] = e
+	return "OK"
+}
+
+func (s *Server) incr(db int, name string, args []string) interface{} {
+	delta := int64(1)
+	switch {
+	case name == "INCRBY" && len(args) == 3:
+		var err error
+		if delta, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+	case name != "INCRBY" && len(args) == 2:
+		if name == "DECR" {
+			delta = -1
+		}
+	default:
+		return wrongArity(name)
+	}
+
+	e := s.lookup(db, args[1])
+	if e == nil {
+		e = &entry{value: "0"}
+		s.db(db)[args[1]] = e
+	}
+	n, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return resp.Error("ERR value is not an integer or out of range")
+	}
+	n += delta
+	e.value = strconv.FormatInt(n, 10)
+	return n
+}
+
+// lookup returns a live key, deleting it when expired; callers hold s.mu
+func (s *Server) lookup(db int, key string) *entry {
+	e, ok := s.db(db)[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !s.now.Before(e.expireAt) {
+		delete(s.db(db), key)
+		return nil
+	}
+	return e
+}
+
+func (s *Server) db(index int) map[string]*entry {
+	keys, ok := s.dbs[index]
+	if !ok {
+		keys = make(map[string]*entry)
+		s.dbs[index] = keys
+	}
+	return keys
+}
+
+func wrongArity(name string) resp.Error {
+	return resp.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+// writeReply encodes a reply; write errors surface on Flush
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case string:
+		_, _ = fmt.Fprintf(w, "+%s\r\n", v)
+	case resp.Error:
+		_, _ = fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		_, _ = fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		_, _ = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	}
+}
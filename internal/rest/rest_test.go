@@ -1,14 +1,30 @@
 package rest
 
 import (
+	"github.com/gin-gonic/gin"
 	"github.com/nathaliaguayos/msg-receiver/internal/handlers/handlersfakes"
+	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+	"github.com/nathaliaguayos/msg-receiver/internal/services/servicesfakes"
 	"github.com/rs/zerolog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestNewRestClient(t *testing.T) {
 	t.Run("should return an error when logger is nil", func(t *testing.T) {
-		_, err := NewRestClient(nil, nil, 0)
+		_, err := NewRestClient(nil, nil, nil, nil, Options{})
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("should return an error when jwtService is nil", func(t *testing.T) {
+		log := zerolog.Nop()
+		_, err := NewRestClient(&log, nil, nil, nil, Options{})
 		if err == nil {
 			t.Error("expected an error, got nil")
 		}
@@ -16,7 +32,15 @@ func TestNewRestClient(t *testing.T) {
 
 	t.Run("should return an error when jwtHandler is nil", func(t *testing.T) {
 		log := zerolog.Nop()
-		_, err := NewRestClient(&log, nil, 0)
+		_, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, nil, nil, Options{})
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("should return an error when messageHandler is nil", func(t *testing.T) {
+		log := zerolog.Nop()
+		_, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, &handlersfakes.FakeJWTHandler{}, nil, Options{})
 		if err == nil {
 			t.Error("expected an error, got nil")
 		}
@@ -25,7 +49,8 @@ func TestNewRestClient(t *testing.T) {
 	t.Run("should return a new rest client", func(t *testing.T) {
 		log := zerolog.Nop()
 		jwtHandler := &handlersfakes.FakeJWTHandler{}
-		client, err := NewRestClient(&log, jwtHandler, 0)
+		messageHandler := &handlersfakes.FakeMessageHandler{}
+		client, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, jwtHandler, messageHandler, Options{})
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -34,3 +59,155 @@ func TestNewRestClient(t *testing.T) {
 		}
 	})
 }
+
+func TestRouter_MessagesRequireToken(t *testing.T) {
+	log := zerolog.Nop()
+	messageHandler := &handlersfakes.FakeMessageHandler{}
+	client, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, &handlersfakes.FakeJWTHandler{}, messageHandler, Options{RateLimit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	client.Router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if messageHandler.ProduceCallCount() != 0 {
+		t.Error("message handler should not be called without a token")
+	}
+}
+
+func TestRouter_IPRateLimit(t *testing.T) {
+	log := zerolog.Nop()
+	jwtService := &servicesfakes.FakeJWTService{}
+	jwtService.ValidateTokenReturns(nil, services.ErrUnknownKey)
+	client, err := NewRestClient(&log, jwtService, &handlersfakes.FakeJWTHandler{}, &handlersfakes.FakeMessageHandler{}, Options{RateLimit: 100, IPRateLimit: 1, IPRateLimitBurst: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// forged tokens and unknown routes use up the limit of the address
+	// before their signatures are checked
+	for i, path := range []string{"/v1/messages", "/unknown", "/v1/messages", "/unknown"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer forged")
+		w := httptest.NewRecorder()
+		client.Router.ServeHTTP(w, req)
+		if i >= 2 && w.Code != http.StatusTooManyRequests {
+			t.Errorf("request %d to %s: expected status %d, got %d", i, path, http.StatusTooManyRequests, w.Code)
+		}
+	}
+	if jwtService.ValidateTokenCallCount() != 1 {
+		t.Errorf("expected 1 token validation, got %d", jwtService.ValidateTokenCallCount())
+	}
+
+	w := httptest.NewRecorder()
+	client.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("health checks should not be limited, got status %d", w.Code)
+	}
+}
+
+func TestRouter_Health(t *testing.T) {
+	log := zerolog.Nop()
+	client, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, &handlersfakes.FakeJWTHandler{}, &handlersfakes.FakeMessageHandler{}, Options{RateLimit: 10, ConcurrencyLimit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	client.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := map[string]middleware.Priority{
+		"/health":                middleware.PriorityCritical,
+		"/token":                 middleware.PriorityHigh,
+		"/token/refresh":         middleware.PriorityHigh,
+		"/.well-known/jwks.json": middleware.PriorityHigh,
+		"/v1/messages":           middleware.PriorityNormal,
+	}
+	for path, expected := range testCases {
+		router := gin.New()
+		var priority middleware.Priority
+		router.GET(path, func(c *gin.Context) {
+			priority = routePriority(c)
+		})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if priority != expected {
+			t.Errorf("%s: expected priority %d, got %d", path, expected, priority)
+		}
+	}
+}
+
+func TestRouter_TrustedProxies(t *testing.T) {
+	log := zerolog.Nop()
+	_, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, &handlersfakes.FakeJWTHandler{}, &handlersfakes.FakeMessageHandler{}, Options{TrustedProxies: []string{"10.0.0.0/33"}})
+	if err == nil {
+		t.Error("expected an error for an invalid trusted proxy")
+	}
+}
+
+func TestRouter_IPFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	if err := os.WriteFile(path, []byte(`{"groups": {"public": {"deny": ["198.51.100.0/24"]}}}`), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filter, err := middleware.NewIPFilter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log := zerolog.Nop()
+	jwtHandler := &handlersfakes.FakeJWTHandler{}
+	client, err := NewRestClient(&log, &servicesfakes.FakeJWTService{}, jwtHandler, &handlersfakes.FakeMessageHandler{}, Options{
+		RateLimit:      10,
+		TrustedProxies: []string{"192.0.2.0/24"},
+		IPFilter:       filter,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	w := httptest.NewRecorder()
+	client.Router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if jwtHandler.GenerateTokenCallCount() != 0 {
+		t.Error("token handler should not be called for a denied address")
+	}
+}
+
+func TestCustomMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/messages", func(c *gin.Context) { c.String(http.StatusOK, "single") })
+	router.POST("/v1/messages:method", customMethods("method", map[string]gin.HandlerFunc{
+		":batch": func(c *gin.Context) { c.String(http.StatusOK, "batch") },
+	}))
+
+	testCases := map[string]struct {
+		status int
+		body   string
+	}{
+		"/v1/messages":        {status: http.StatusOK, body: "single"},
+		"/v1/messages:batch":  {status: http.StatusOK, body: "batch"},
+		"/v1/messages:delete": {status: http.StatusNotFound},
+		"/v1/messagesbatch":   {status: http.StatusNotFound},
+	}
+	for path, expected := range testCases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != expected.status || w.Body.String() != expected.body {
+			t.Errorf("%s: expected %d %q, got %d %q", path, expected.status, expected.body, w.Code, w.Body.String())
+		}
+	}
+}
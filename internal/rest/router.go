@@ -2,39 +2,240 @@ package rest
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/nathaliaguayos/msg-receiver/internal/handlers"
 	"github.com/nathaliaguayos/msg-receiver/internal/middleware"
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
 	"github.com/rs/zerolog"
 	"golang.org/x/time/rate"
+	"net/http"
+	"strings"
+	"time"
 )
 
 // Client represents a REST client.
 type Client struct {
-	Logger     *zerolog.Logger
-	Router     *gin.Engine
-	jwtHandler handlers.JWTHandler
+	Logger         *zerolog.Logger
+	Router         *gin.Engine
+	jwtHandler     handlers.JWTHandler
+	messageHandler handlers.MessageHandler
+}
+
+// Options holds the tunable settings of the REST client.
+type Options struct {
+	// RateLimit is the number of requests per second allowed per client
+	RateLimit float64
+	// RateLimitBurst is the number of requests allowed at once, RateLimit when zero
+	RateLimitBurst int
+	// RateLimitMaxEntries caps the number of clients tracked by the rate limiter
+	RateLimitMaxEntries int
+	// RateLimitIdleTTL forgets clients that sent no request for this long
+	RateLimitIdleTTL time.Duration
+	// RateLimitKey identifies the client of a request, the client IP when nil
+	RateLimitKey middleware.KeyFunc
+	// RateLimitPolicies override RateLimit for specific keys and routes
+	RateLimitPolicies middleware.RateLimitPolicies
+	// RateLimitBackend shares the limits between replicas; the local limiters
+	// take over while it fails, and are the only ones used when nil
+	RateLimitBackend middleware.LimiterBackend
+	// IPRateLimit is the number of requests per second allowed per client IP
+	// on every route, checked before authentication; disabled when zero
+	IPRateLimit float64
+	// IPRateLimitBurst is the number of requests allowed at once per client
+	// IP, IPRateLimit when zero
+	IPRateLimitBurst int
+	// IdempotencyStore keeps the Idempotency-Key of /v1 requests, which is
+	// ignored when nil
+	IdempotencyStore services.IdempotencyStore
+	// IdempotencyTTL is how long responses are replayed, 24 hours when zero
+	IdempotencyTTL time.Duration
+	// IdempotencyMaxBodyBytes caps the bodies of requests with an Idempotency-Key
+	IdempotencyMaxBodyBytes int64
+	// TrustedProxies are the proxy CIDRs whose forwarding headers give the
+	// client IP; the peer address is the client IP when empty
+	TrustedProxies []string
+	// IPFilter allows and denies client networks per route group: "public",
+	// "v1" and "admin"; every address is allowed when nil
+	IPFilter middleware.IPFilter
+	// ConcurrencyLimit is the initial number of requests in flight, load
+	// shedding is disabled when zero
+	ConcurrencyLimit int
+	// ConcurrencyMinLimit and ConcurrencyMaxLimit bound the adapted limit
+	ConcurrencyMinLimit int
+	ConcurrencyMaxLimit int
+	// ConcurrencyAlgorithm adapts the limit to the latency, Vegas when nil
+	ConcurrencyAlgorithm middleware.LimitAlgorithm
+	// AdminToken protects the /admin routes, which are disabled when empty
+	AdminToken string
+	// QuotaHandler serves the /admin/quotas routes, which are disabled when nil
+	QuotaHandler handlers.QuotaHandler
+	// DeadLetterHandler serves the /admin/deadletters routes, which are disabled when nil
+	DeadLetterHandler handlers.DeadLetterHandler
+	// CircuitBreakerHandler serves the /admin/breaker route, which is disabled when nil
+	CircuitBreakerHandler handlers.CircuitBreakerHandler
 }
 
 // NewRestClient creates a new REST client.
-func NewRestClient(log *zerolog.Logger, jwtHandler handlers.JWTHandler, rateLimit float64) (*Client, error) {
+func NewRestClient(log *zerolog.Logger, jwtService services.JWTService, jwtHandler handlers.JWTHandler, messageHandler handlers.MessageHandler, opts Options) (*Client, error) {
 	if log == nil {
 		return nil, errors.New("logger should not be null")
 	}
 
+	if jwtService == nil {
+		return nil, errors.New("jwtService should not be null")
+	}
+
 	if jwtHandler == nil {
 		return nil, errors.New("jwtHandler should not be null")
 	}
+
+	if messageHandler == nil {
+		return nil, errors.New("messageHandler should not be null")
+	}
 	var instance = Client{
-		Logger:     log,
-		jwtHandler: jwtHandler,
+		Logger:         log,
+		jwtHandler:     jwtHandler,
+		messageHandler: messageHandler,
 	}
 
 	router := gin.Default()
-	router.Use(middleware.RateLimiter(rate.Limit(rateLimit)))
-	log.Info().Int("rate_limit", int(rateLimit)).Msg("configured rate limit")
-	router.POST("/token", jwtHandler.GenerateToken)
+	// the rate limiter, the IP filter and the access log all key on the
+	// client IP, so only the configured proxies may set it
+	if err := router.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if opts.ConcurrencyLimit > 0 {
+		// shedding runs first, so an overloaded replica rejects requests
+		// before spending anything on them
+		router.Use(middleware.NewConcurrencyLimiter(middleware.ConcurrencyLimiterConfig{
+			InitialLimit: opts.ConcurrencyLimit,
+			MinLimit:     opts.ConcurrencyMinLimit,
+			MaxLimit:     opts.ConcurrencyMaxLimit,
+			Algorithm:    opts.ConcurrencyAlgorithm,
+			Priority:     routePriority,
+		}))
+		log.Info().Int("concurrency_limit", opts.ConcurrencyLimit).Int("concurrency_min_limit", opts.ConcurrencyMinLimit).
+			Int("concurrency_max_limit", opts.ConcurrencyMaxLimit).Msg("configured load shedding")
+	}
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	limiters := middleware.NewLocalLimiterBackend(middleware.NewLimiterStore(middleware.LimiterStoreConfig{
+		MaxEntries: opts.RateLimitMaxEntries,
+		IdleTTL:    opts.RateLimitIdleTTL,
+	}))
+	if opts.RateLimitBackend != nil {
+		limiters = middleware.NewFallbackLimiterBackend(opts.RateLimitBackend, limiters, middleware.FallbackConfig{
+			OnError: func(err error) {
+				log.Warn().Err(err).Msg("shared rate limit store failed, falling back to local limits")
+			},
+		})
+	}
+	if opts.IPRateLimit > 0 {
+		// every route, unmatched ones included, is limited per client IP
+		// before any work is done on the request, such as checking a token
+		// signature; health checks are left out
+		router.Use(middleware.NewRateLimiter(middleware.RateLimiterConfig{
+			Default: middleware.RateLimitPolicy{Rate: rate.Limit(opts.IPRateLimit), Burst: opts.IPRateLimitBurst},
+			Key:     middleware.KeyByIP(),
+			Backend: limiters,
+			Name:    "ip",
+		}))
+		log.Info().Float64("ip_rate_limit", opts.IPRateLimit).Int("ip_rate_limit_burst", opts.IPRateLimitBurst).Msg("configured ip rate limit")
+	}
+	// the second limiter runs after authentication on /v1 so clients are
+	// keyed by identity; unauthenticated routes fall back to the client IP
+	limit := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		Default:  middleware.RateLimitPolicy{Rate: rate.Limit(opts.RateLimit), Burst: opts.RateLimitBurst},
+		Policies: opts.RateLimitPolicies,
+		Key:      opts.RateLimitKey,
+		Backend:  limiters,
+	})
+	log.Info().Float64("rate_limit", opts.RateLimit).Int("rate_limit_burst", opts.RateLimitBurst).
+		Int("rate_limit_key_policies", len(opts.RateLimitPolicies.Keys)).
+		Int("rate_limit_route_policies", len(opts.RateLimitPolicies.Routes)).Msg("configured rate limit")
+
+	// the IP filter runs before the other middleware of each group, so
+	// blocked networks only use up their per IP limit
+	allowed := func(group string) gin.HandlersChain {
+		if opts.IPFilter == nil {
+			return nil
+		}
+		return gin.HandlersChain{middleware.RequireAllowedIP(opts.IPFilter, group)}
+	}
+
+	public := router.Group("", append(allowed("public"), limit)...)
+	public.POST("/token", jwtHandler.GenerateToken)
+	public.POST("/token/refresh", jwtHandler.RefreshToken)
+	public.POST("/token/revoke", jwtHandler.RevokeToken)
+	public.POST("/token/introspect", jwtHandler.IntrospectToken)
+	public.GET("/.well-known/jwks.json", jwtHandler.JWKS)
+
+	v1 := router.Group("/v1", append(allowed("v1"), middleware.RequireJWT(jwtService), limit)...)
+	if opts.IdempotencyStore != nil {
+		// keys are scoped to the token subject, so this runs after RequireJWT
+		v1.Use(middleware.Idempotency(middleware.IdempotencyConfig{
+			Store:        opts.IdempotencyStore,
+			TTL:          opts.IdempotencyTTL,
+			MaxBodyBytes: opts.IdempotencyMaxBodyBytes,
+		}))
+	}
+	v1.POST("/messages", messageHandler.Produce)
+	v1.GET("/messages/:id", messageHandler.Status)
+	v1.POST("/messages:method", customMethods("method", map[string]gin.HandlerFunc{
+		":batch": messageHandler.ProduceBatch,
+	}))
+
+	if opts.AdminToken == "" {
+		log.Warn().Msg("admin token not configured, admin routes are disabled")
+	} else {
+		admin := router.Group("/admin", append(allowed("admin"), limit, middleware.RequireAdminToken(opts.AdminToken))...)
+		admin.POST("/keys/rotate", jwtHandler.RotateSigningKey)
+		if opts.QuotaHandler != nil {
+			admin.GET("/quotas/:tenant", opts.QuotaHandler.Usage)
+			admin.POST("/quotas/:tenant/reset", opts.QuotaHandler.Reset)
+		}
+		if opts.DeadLetterHandler != nil {
+			admin.GET("/deadletters", opts.DeadLetterHandler.List)
+			admin.DELETE("/deadletters", opts.DeadLetterHandler.Purge)
+			admin.GET("/deadletters/:id", opts.DeadLetterHandler.Get)
+			admin.DELETE("/deadletters/:id", opts.DeadLetterHandler.Remove)
+			admin.POST("/deadletters/:id/replay", opts.DeadLetterHandler.Replay)
+		}
+		if opts.CircuitBreakerHandler != nil {
+			admin.GET("/breaker", opts.CircuitBreakerHandler.State)
+		}
+	}
 
 	instance.Router = router
 	return &instance, nil
 }
+
+// customMethods serves the custom methods of a collection, such as
+// "/messages:batch"; gin reads the ":batch" suffix as a path parameter,
+// colon included, so the methods are dispatched on its value
+func customMethods(param string, methods map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler, ok := methods[c.Param(param)]
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		handler(c)
+	}
+}
+
+// routePriority sheds health checks last, so an overloaded replica is not
+// restarted, then token requests, so clients can keep authenticating
+func routePriority(c *gin.Context) middleware.Priority {
+	path := c.FullPath()
+	switch {
+	case path == "/health":
+		return middleware.PriorityCritical
+	case strings.HasPrefix(path, "/token"), path == "/.well-known/jwks.json":
+		return middleware.PriorityHigh
+	default:
+		return middleware.PriorityNormal
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed lets every call through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails every call at once
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets one probe call through to decide whether to close
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreaker is a contract for failing calls fast while the service
+// behind them is failing
+//
+//counterfeiter:generate . CircuitBreaker
+type CircuitBreaker interface {
+	// Allow returns the call to report with Record when a call may go
+	// ahead, and a *CircuitOpenError otherwise
+	Allow() (CircuitCall, error)
+	// Record reports the outcome of an allowed call
+	Record(call CircuitCall, err error)
+	// State returns a snapshot of the breaker
+	State() CircuitBreakerState
+}
+
+// CircuitBreakerConfig tunes NewCircuitBreaker
+type CircuitBreakerConfig struct {
+	// Window is the period failures are counted over, 10 seconds when zero
+	Window time.Duration
+	// MinRequests is the calls a window needs before it can trip the
+	// breaker, 20 when zero
+	MinRequests int
+	// FailureRatio is the share of failed calls in a window that trips the
+	// breaker, 0.5 when zero
+	FailureRatio float64
+	// OpenTimeout is how long the breaker stays open before a probe call
+	// is let through, 30 seconds when zero
+	OpenTimeout time.Duration
+}
+
+// CircuitBreakerState is a snapshot of a circuit breaker
+type CircuitBreakerState struct {
+	State CircuitState `json:"state"`
+	// Requests and Failures are counted in the current window
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// Trips is how many times the breaker opened since it started
+	Trips     int64      `json:"trips"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	ProbeAt   *time.Time `json:"probe_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// CircuitCall identifies a call let through by a circuit breaker, so that
+// only the probe decides the outcome of the half open state and calls let
+// through before the breaker changed state are ignored
+type CircuitCall struct {
+	generation uint64
+	probe      bool
+}
+
+// CircuitOpenError is returned by calls failed by an open breaker
+type CircuitOpenError struct {
+	// RetryAfter is when the breaker lets a call through again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+// Unwrap makes the error match ErrCircuitOpen
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type circuitBreaker struct {
+	mu          sync.Mutex
+	cfg         CircuitBreakerConfig
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	trips       int64
+	openedAt    time.Time
+	probing     bool
+	// generation changes with the state, telling current calls from stale ones
+	generation uint64
+	lastError  string
+	now        func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker that opens when at least
+// FailureRatio of the calls of a window fail with retriable errors, such as
+// an unavailable broker; errors caused by the request, such as an unknown
+// topic, count as successes. Once OpenTimeout has passed a single probe call
+// is let through, closing the breaker when it succeeds and opening it again
+// when it fails.
+// Params: cfg CircuitBreakerConfig - the trip conditions
+func NewCircuitBreaker(cfg CircuitBreakerConfig) CircuitBreaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.FailureRatio <= 0 || cfg.FailureRatio > 1 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	return &circuitBreaker{cfg: cfg, state: CircuitClosed, now: time.Now}
+}
+
+// Allow lets a call through unless the breaker is open, or half open with
+// a probe in flight
+func (b *circuitBreaker) Allow() (CircuitCall, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case CircuitOpen:
+		probeAt := b.openedAt.Add(b.cfg.OpenTimeout)
+		if now.Before(probeAt) {
+			return CircuitCall{}, &CircuitOpenError{RetryAfter: probeAt.Sub(now)}
+		}
+		b.state, b.probing = CircuitHalfOpen, true
+		b.generation++
+		return CircuitCall{generation: b.generation, probe: true}, nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return CircuitCall{}, &CircuitOpenError{RetryAfter: time.Second}
+		}
+		b.probing = true
+		return CircuitCall{generation: b.generation, probe: true}, nil
+	}
+	return CircuitCall{generation: b.generation}, nil
+}
+
+// Record counts the outcome of a call and moves the breaker accordingly; a
+// call let through before the breaker last changed state is ignored
+// Params: call CircuitCall - the call returned by Allow
+// Params: err error - the error the call returned, nil on success
+func (b *circuitBreaker) Record(call CircuitCall, err error) {
+	failed := err != nil && Retriable(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if call.generation != b.generation {
+		return
+	}
+	now := b.now()
+	if failed {
+		b.lastError = err.Error()
+	}
+
+	if b.state == CircuitHalfOpen {
+		if !call.probe {
+			return
+		}
+		b.probing = false
+		if failed {
+			b.open(now)
+			return
+		}
+		b.state = CircuitClosed
+		b.generation++
+		b.windowStart, b.requests, b.failures = now, 0, 0
+		return
+	}
+	if b.state == CircuitOpen {
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures) >= b.cfg.FailureRatio*float64(b.requests) {
+		b.open(now)
+	}
+}
+
+// open trips the breaker; callers must hold the lock
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.trips++
+	b.generation++
+}
+
+// State returns a snapshot of the breaker
+func (b *circuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := CircuitBreakerState{
+		State:     b.state,
+		Trips:     b.trips,
+		LastError: b.lastError,
+	}
+	if b.state == CircuitClosed && b.now().Sub(b.windowStart) < b.cfg.Window {
+		state.Requests, state.Failures = b.requests, b.failures
+	}
+	if b.state != CircuitClosed {
+		openedAt, probeAt := b.openedAt, b.openedAt.Add(b.cfg.OpenTimeout)
+		state.OpenedAt, state.ProbeAt = &openedAt, &probeAt
+	}
+	return state
+}
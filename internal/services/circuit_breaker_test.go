@@ -0,0 +1,72 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{Window: time.Minute, MinRequests: 4, FailureRatio: 0.5, OpenTimeout: 30 * time.Second})
+	now := time.Now()
+	breaker.(*circuitBreaker).now = func() time.Time { return now }
+	call := func(err error) error {
+		allowed, allowErr := breaker.Allow()
+		if allowErr != nil {
+			return allowErr
+		}
+		breaker.Record(allowed, err)
+		return nil
+	}
+
+	// errors caused by the request do not count, and too few calls cannot trip it
+	require.NoError(t, call(ErrUnknownTopic))
+	require.NoError(t, call(ErrBrokerUnavailable))
+	require.NoError(t, call(ErrBrokerUnavailable))
+	assert.Equal(t, CircuitBreakerState{State: CircuitClosed, Requests: 3, Failures: 2, LastError: "broker is unavailable"}, breaker.State())
+
+	// half the calls of the window failed
+	stale, err := breaker.Allow()
+	require.NoError(t, err)
+	require.NoError(t, call(nil))
+	state := breaker.State()
+	assert.Equal(t, CircuitOpen, state.State)
+	assert.Equal(t, int64(1), state.Trips)
+	assert.Equal(t, now.Add(30*time.Second), *state.ProbeAt)
+
+	_, err = breaker.Allow()
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.True(t, Retriable(err))
+	assert.Equal(t, 30*time.Second, openErr.RetryAfter)
+
+	// one probe is let through once the timeout passed, and a failed one opens it again
+	now = now.Add(30 * time.Second)
+	probe, err := breaker.Allow()
+	require.NoError(t, err)
+	assert.Equal(t, CircuitHalfOpen, breaker.State().State)
+	_, err = breaker.Allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	// only the probe decides, not a call let through before the breaker opened
+	breaker.Record(stale, nil)
+	assert.Equal(t, CircuitHalfOpen, breaker.State().State)
+	breaker.Record(probe, ErrBrokerUnavailable)
+	assert.Equal(t, CircuitOpen, breaker.State().State)
+	assert.Equal(t, int64(2), breaker.State().Trips)
+
+	// a successful probe closes it
+	now = now.Add(30 * time.Second)
+	require.NoError(t, call(nil))
+	assert.Equal(t, CircuitBreakerState{State: CircuitClosed, Trips: 2, LastError: "broker is unavailable"}, breaker.State())
+
+	// failures of an old window are forgotten
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(ErrBrokerUnavailable))
+	}
+	now = now.Add(time.Minute)
+	require.NoError(t, call(ErrBrokerUnavailable))
+	assert.Equal(t, CircuitClosed, breaker.State().State)
+	assert.Equal(t, 1, breaker.State().Failures)
+}
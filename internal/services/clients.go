@@ -0,0 +1,316 @@
+package services
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientAuthenticator is a contract for authenticating registered OAuth2 clients
+//
+//counterfeiter:generate . ClientAuthenticator
+type ClientAuthenticator interface {
+	Authenticate(clientID, secret, sourceIP string) (*Client, error)
+}
+
+// Client is a registered OAuth2 client. SecretHash is a bcrypt hash
+// ($2a$, $2b$ or $2y$) or an argon2 PHC string ($argon2id$v=19$m=...,t=...,p=...$salt$hash).
+type Client struct {
+	ID         string `json:"client_id"`
+	SecretHash string `json:"secret_hash"`
+	// Scopes are the scopes the client may request, e.g. "produce:orders" or "produce:payments.*"
+	Scopes []string `json:"scopes"`
+	// Tenant is added to the client tokens, e.g. to rate limit every client of a tenant together
+	Tenant string `json:"tenant"`
+}
+
+// GrantScopes returns the requested scopes when the client holds all of
+// them, or every client scope when none are requested
+// Params: requested []string - the requested scopes
+func (c *Client) GrantScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return c.Scopes, nil
+	}
+	for _, scope := range requested {
+		if err := ValidateScope(scope); err != nil {
+			return nil, err
+		}
+		if !ScopesAllow(c.Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q is not granted to the client", ErrInvalidScope, scope)
+		}
+	}
+	return requested, nil
+}
+
+// RetainScopes returns the scopes of held the client is still granted, so
+// that a scope removed from the client is dropped from refreshed tokens
+// Params: held []string - the scopes of the token being refreshed
+func (c *Client) RetainScopes(held []string) []string {
+	var retained []string
+	for _, scope := range held {
+		if ScopesAllow(c.Scopes, scope) {
+			retained = append(retained, scope)
+		}
+	}
+	return retained
+}
+
+// clientsFile is the layout of the clients config file
+type clientsFile struct {
+	Clients []Client `json:"clients"`
+}
+
+// LockoutConfig controls how failed authentication attempts lock a client
+// out of the address they come from, or of every address
+type LockoutConfig struct {
+	// MaxAttempts is the number of failures allowed within Duration, 5 when zero
+	MaxAttempts int
+	// MaxClientAttempts is the number of failures allowed within Duration
+	// over every address, 50 when zero, so that spreading guesses over many
+	// addresses does not get past the lockout
+	MaxClientAttempts int
+	// Duration is both the window failures are counted in and how long a client stays locked, 15 minutes when zero
+	Duration time.Duration
+}
+
+// attemptKey identifies the failures of a client ID from one address, so
+// that bad secrets sent from elsewhere cannot lock a legitimate client out,
+// or from every address when sourceIP is anyAddress
+type attemptKey struct {
+	clientID string
+	sourceIP string
+}
+
+// anyAddress is the sourceIP of the failures of a client ID counted over
+// every address
+const anyAddress = "*"
+
+// attempts tracks the failures of one client ID from one address
+type attempts struct {
+	failures    int
+	firstFailed time.Time
+	lockedUntil time.Time
+}
+
+type clientAuthenticator struct {
+	clients   map[string]Client
+	lockout   LockoutConfig
+	mu        sync.Mutex
+	attempts  map[attemptKey]*attempts
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// dummyHash is compared against when the client is unknown, so unknown and
+// known client IDs take the same time to reject
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("msg-receiver"), bcrypt.DefaultCost)
+
+// LoadClients reads the registered clients from a JSON file
+// Params: path string - the clients file
+func LoadClients(path string) ([]Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file clientsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid clients file %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(file.Clients))
+	for _, client := range file.Clients {
+		if client.ID == "" {
+			return nil, fmt.Errorf("invalid clients file %s: client_id is required", path)
+		}
+		if seen[client.ID] {
+			return nil, fmt.Errorf("invalid clients file %s: duplicate client %q", path, client.ID)
+		}
+		seen[client.ID] = true
+		if _, err := verifySecret(client.SecretHash, ""); err != nil {
+			return nil, fmt.Errorf("invalid clients file %s: client %q: %w", path, client.ID, err)
+		}
+		for _, scope := range client.Scopes {
+			if err := ValidateScope(scope); err != nil {
+				return nil, fmt.Errorf("invalid clients file %s: client %q: %w", path, client.ID, err)
+			}
+		}
+	}
+	return file.Clients, nil
+}
+
+// NewClientAuthenticator creates a new client authenticator
+// Params: clients []Client - the registered clients
+// Params: lockout LockoutConfig - the failed attempts policy
+func NewClientAuthenticator(clients []Client, lockout LockoutConfig) ClientAuthenticator {
+	if lockout.MaxAttempts <= 0 {
+		lockout.MaxAttempts = 5
+	}
+	if lockout.MaxClientAttempts <= 0 {
+		lockout.MaxClientAttempts = 50
+	}
+	if lockout.Duration <= 0 {
+		lockout.Duration = 15 * time.Minute
+	}
+	registered := make(map[string]Client, len(clients))
+	for _, client := range clients {
+		registered[client.ID] = client
+	}
+	return &clientAuthenticator{
+		clients:  registered,
+		lockout:  lockout,
+		attempts: make(map[attemptKey]*attempts),
+		now:      time.Now,
+	}
+}
+
+// Authenticate checks a client secret. Failures are counted per client ID,
+// known or not, and source address, and the ID is locked out of the
+// address once MaxAttempts is reached, and out of every address once
+// MaxClientAttempts is reached over all of them.
+// Params: clientID string - the client ID
+// Params: secret string - the client secret
+// Params: sourceIP string - the address the request comes from
+func (a *clientAuthenticator) Authenticate(clientID, secret, sourceIP string) (*Client, error) {
+	key := attemptKey{clientID: clientID, sourceIP: sourceIP}
+	clientKey := attemptKey{clientID: clientID, sourceIP: anyAddress}
+	if err := a.checkLocked(key); err != nil {
+		return nil, err
+	}
+	if err := a.checkLocked(clientKey); err != nil {
+		return nil, err
+	}
+
+	client, ok := a.clients[clientID]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(secret))
+		a.fail(key, a.lockout.MaxAttempts)
+		a.fail(clientKey, a.lockout.MaxClientAttempts)
+		return nil, ErrInvalidClient
+	}
+	valid, err := verifySecret(client.SecretHash, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		a.fail(key, a.lockout.MaxAttempts)
+		a.fail(clientKey, a.lockout.MaxClientAttempts)
+		return nil, ErrInvalidClient
+	}
+
+	// the failures over every address are kept, or the successes of the
+	// client would clear the guesses made meanwhile
+	a.mu.Lock()
+	delete(a.attempts, key)
+	a.mu.Unlock()
+	return &client, nil
+}
+
+func (a *clientAuthenticator) checkLocked(key attemptKey) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.attempts[key]
+	if ok && a.now().Before(entry.lockedUntil) {
+		return &ClientLockedError{Until: entry.lockedUntil}
+	}
+	return nil
+}
+
+func (a *clientAuthenticator) fail(key attemptKey, maxAttempts int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	a.sweep(now)
+	entry, ok := a.attempts[key]
+	if !ok || now.Sub(entry.firstFailed) > a.lockout.Duration {
+		entry = &attempts{firstFailed: now}
+		a.attempts[key] = entry
+	}
+	entry.failures++
+	if entry.failures >= maxAttempts {
+		entry.lockedUntil = now.Add(a.lockout.Duration)
+		entry.failures = 0
+		entry.firstFailed = now
+	}
+}
+
+// sweep drops attempts older than the lockout window; callers must hold the lock
+func (a *clientAuthenticator) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < sweepInterval {
+		return
+	}
+	a.lastSweep = now
+	for key, entry := range a.attempts {
+		if now.Sub(entry.firstFailed) > a.lockout.Duration && !now.Before(entry.lockedUntil) {
+			delete(a.attempts, key)
+		}
+	}
+}
+
+// ClientLockedError is returned while a client is locked after too many
+// failed attempts
+type ClientLockedError struct {
+	Until time.Time
+}
+
+func (e *ClientLockedError) Error() string {
+	return ErrClientLocked.Error()
+}
+
+// Unwrap makes the error match ErrClientLocked
+func (e *ClientLockedError) Unwrap() error {
+	return ErrClientLocked
+}
+
+// verifySecret compares a secret with a bcrypt or argon2 hash
+func verifySecret(hash, secret string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, "$argon2"):
+		return verifyArgon2(hash, secret)
+	default:
+		return false, ErrUnsupportedSecretHash
+	}
+}
+
+// verifyArgon2 checks a secret against an argon2i or argon2id PHC string
+func verifyArgon2(hash, secret string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return false, ErrUnsupportedSecretHash
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, ErrUnsupportedSecretHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnsupportedSecretHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(expected) == 0 {
+		return false, ErrUnsupportedSecretHash
+	}
+
+	var actual []byte
+	switch parts[1] {
+	case "argon2id":
+		actual = argon2.IDKey([]byte(secret), salt, iterations, memory, threads, uint32(len(expected)))
+	case "argon2i":
+		actual = argon2.Key([]byte(secret), salt, iterations, memory, threads, uint32(len(expected)))
+	default:
+		return false, ErrUnsupportedSecretHash
+	}
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
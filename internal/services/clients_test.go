@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadClients(t *testing.T) {
+	bcryptHash := bcryptHash(t, "secret")
+	testCases := []struct {
+		name      string
+		content   string
+		assertion func(t *testing.T, clients []Client, err error)
+	}{
+		{
+			name:    "should load bcrypt and argon2 clients",
+			content: fmt.Sprintf(`{"clients":[{"client_id":"orders","secret_hash":%q},{"client_id":"billing","secret_hash":%q}]}`, bcryptHash, argon2Hash("secret")),
+			assertion: func(t *testing.T, clients []Client, err error) {
+				require.NoError(t, err)
+				require.Len(t, clients, 2)
+				assert.Equal(t, "orders", clients[0].ID)
+				assert.Equal(t, "billing", clients[1].ID)
+			},
+		}, {
+			name:    "should reject duplicated clients",
+			content: fmt.Sprintf(`{"clients":[{"client_id":"orders","secret_hash":%q},{"client_id":"orders","secret_hash":%q}]}`, bcryptHash, bcryptHash),
+			assertion: func(t *testing.T, clients []Client, err error) {
+				assert.ErrorContains(t, err, "duplicate client")
+			},
+		}, {
+			name:    "should reject clients without an ID",
+			content: fmt.Sprintf(`{"clients":[{"secret_hash":%q}]}`, bcryptHash),
+			assertion: func(t *testing.T, clients []Client, err error) {
+				assert.ErrorContains(t, err, "client_id is required")
+			},
+		}, {
+			name:    "should reject plain text secrets",
+			content: `{"clients":[{"client_id":"orders","secret_hash":"secret"}]}`,
+			assertion: func(t *testing.T, clients []Client, err error) {
+				assert.ErrorIs(t, err, ErrUnsupportedSecretHash)
+			},
+		}, {
+			name:    "should reject malformed scopes",
+			content: fmt.Sprintf(`{"clients":[{"client_id":"orders","secret_hash":%q,"scopes":["orders"]}]}`, bcryptHash),
+			assertion: func(t *testing.T, clients []Client, err error) {
+				assert.ErrorIs(t, err, ErrInvalidScope)
+			},
+		}, {
+			name:    "should reject invalid JSON",
+			content: `{"clients":`,
+			assertion: func(t *testing.T, clients []Client, err error) {
+				assert.ErrorContains(t, err, "invalid clients file")
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "clients.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+			clients, err := LoadClients(path)
+			tc.assertion(t, clients, err)
+		})
+	}
+}
+
+func TestClientAuthenticator_Authenticate(t *testing.T) {
+	authenticator := NewClientAuthenticator([]Client{
+		{ID: "orders", SecretHash: bcryptHash(t, "secret")},
+		{ID: "billing", SecretHash: argon2Hash("secret")},
+	}, LockoutConfig{})
+
+	client, err := authenticator.Authenticate("orders", "secret", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "orders", client.ID)
+	client, err = authenticator.Authenticate("billing", "secret", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", client.ID)
+
+	_, err = authenticator.Authenticate("orders", "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+	_, err = authenticator.Authenticate("billing", "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+	_, err = authenticator.Authenticate("unknown", "secret", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func TestClientAuthenticator_Lockout(t *testing.T) {
+	authenticator := NewClientAuthenticator([]Client{{ID: "orders", SecretHash: bcryptHash(t, "secret")}}, LockoutConfig{MaxAttempts: 3, Duration: time.Minute})
+	now := time.Now()
+	authenticator.(*clientAuthenticator).now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := authenticator.Authenticate("orders", "wrong", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrInvalidClient)
+	}
+	_, err := authenticator.Authenticate("orders", "secret", "192.0.2.1")
+	var lockedErr *ClientLockedError
+	require.ErrorAs(t, err, &lockedErr)
+	assert.ErrorIs(t, err, ErrClientLocked)
+	assert.Equal(t, now.Add(time.Minute), lockedErr.Until)
+
+	t.Run("should not lock the client out of other addresses", func(t *testing.T) {
+		client, err := authenticator.Authenticate("orders", "secret", "192.0.2.2")
+		require.NoError(t, err)
+		assert.Equal(t, "orders", client.ID)
+	})
+
+	t.Run("should lock unknown clients too", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, _ = authenticator.Authenticate("unknown", "wrong", "192.0.2.1")
+		}
+		_, err := authenticator.Authenticate("unknown", "wrong", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrClientLocked)
+	})
+
+	t.Run("should unlock after the lockout duration", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		client, err := authenticator.Authenticate("orders", "secret", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, "orders", client.ID)
+	})
+
+	t.Run("should lock the client out of every address after failures from many", func(t *testing.T) {
+		authenticator := NewClientAuthenticator([]Client{{ID: "orders", SecretHash: bcryptHash(t, "secret")}}, LockoutConfig{MaxAttempts: 3, MaxClientAttempts: 4, Duration: time.Minute})
+		clock := now
+		authenticator.(*clientAuthenticator).now = func() time.Time { return clock }
+		for i := 0; i < 4; i++ {
+			_, err := authenticator.Authenticate("orders", "wrong", fmt.Sprintf("198.51.100.%d", i))
+			assert.ErrorIs(t, err, ErrInvalidClient)
+		}
+		_, err := authenticator.Authenticate("orders", "secret", "192.0.2.9")
+		assert.ErrorIs(t, err, ErrClientLocked)
+		clock = clock.Add(time.Minute)
+		_, err = authenticator.Authenticate("orders", "secret", "192.0.2.9")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reset failures outside the window", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, _ = authenticator.Authenticate("orders", "wrong", "192.0.2.1")
+		}
+		now = now.Add(2 * time.Minute)
+		_, err := authenticator.Authenticate("orders", "wrong", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrInvalidClient)
+		_, err = authenticator.Authenticate("orders", "secret", "192.0.2.1")
+		assert.NoError(t, err)
+	})
+}
+
+func TestClient_GrantScopes(t *testing.T) {
+	client := &Client{ID: "orders", Scopes: []string{"produce:orders", "produce:payments.*"}}
+
+	scopes, err := client.GrantScopes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, client.Scopes, scopes)
+
+	scopes, err = client.GrantScopes([]string{"produce:payments.eu"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"produce:payments.eu"}, scopes)
+
+	_, err = client.GrantScopes([]string{"produce:orders", "produce:billing"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	_, err = client.GrantScopes([]string{"orders"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func TestClient_RetainScopes(t *testing.T) {
+	client := &Client{ID: "orders", Scopes: []string{"produce:orders", "produce:payments.*"}}
+
+	assert.Equal(t, []string{"produce:orders", "produce:payments.eu"}, client.RetainScopes([]string{"produce:orders", "produce:billing", "produce:payments.eu"}))
+	assert.Empty(t, client.RetainScopes([]string{"produce:billing"}))
+	assert.Empty(t, client.RetainScopes(nil))
+}
+
+func bcryptHash(t *testing.T, secret string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func argon2Hash(secret string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(secret), salt, 1, 1024, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=1024,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"time"
+)
+
+// DeadLetterWriter is a contract for handing off the accepted messages that
+// could not be delivered
+//
+//counterfeiter:generate . DeadLetterWriter
+type DeadLetterWriter interface {
+	// Add stores a message that could not be delivered
+	Add(letter DeadLetter) error
+}
+
+// DeadLetterQueue is a contract for keeping the accepted messages that
+// could not be delivered, so they can be inspected and replayed
+//
+//counterfeiter:generate . DeadLetterQueue
+type DeadLetterQueue interface {
+	DeadLetterWriter
+	// List returns up to limit messages, oldest first, of topic or of every topic when empty
+	List(topic string, limit int) ([]DeadLetter, error)
+	// Get returns a message, or nil when it is unknown
+	Get(id string) (*DeadLetter, error)
+	// Remove drops a message, reporting whether it was known
+	Remove(id string) (bool, error)
+	// Purge drops the messages of topic, or every message when empty, and returns how many were dropped
+	Purge(topic string) (int, error)
+}
+
+// DeadLetter is a message given up by the spool forwarder, with why
+type DeadLetter struct {
+	ID         string    `json:"id"`
+	Topic      string    `json:"topic"`
+	Key        []byte    `json:"key,omitempty"`
+	Headers    []Header  `json:"headers,omitempty"`
+	Value      []byte    `json:"value,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	FailedAt   time.Time `json:"failed_at"`
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// deadLetterLockStale is when a lock left on the dead-letter file by a
+// crashed replica is broken
+const deadLetterLockStale = 30 * time.Second
+
+type fileDeadLetterQueue struct {
+	mu      sync.Mutex
+	path    string
+	letters []DeadLetter
+	// size and modTime of the file when it was last read
+	size    int64
+	modTime time.Time
+}
+
+// NewFileDeadLetterQueue creates a dead-letter queue backed by a file of
+// JSON lines. Messages are appended as they are added, and the file is
+// rewritten when some are removed; changes made by other replicas sharing
+// the file are picked up on the next call. Appends and rewrites hold a lock
+// file next to it, so a rewrite does not drop messages another replica is
+// appending.
+// Params: path string - the dead-letter file, created when missing
+func NewFileDeadLetterQueue(path string) (DeadLetterQueue, error) {
+	q := &fileDeadLetterQueue{path: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Add appends a message to the file
+// Params: letter DeadLetter - the message and why it was given up
+func (q *fileDeadLetterQueue) Add(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	unlock, err := q.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := q.load(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	// a torn last line from a crashed writer is ended first, or the message
+	// would be appended to it and skipped with it
+	torn, err := tornLastLine(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if torn {
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	q.letters = appendLetter(q.letters, letter)
+	return q.stat()
+}
+
+// List returns up to limit messages of topic, oldest first
+// Params: topic string - the original topic, every topic when empty
+// Params: limit int - the most messages returned
+func (q *fileDeadLetterQueue) List(topic string, limit int) ([]DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	letters := []DeadLetter{}
+	for _, letter := range q.letters {
+		if len(letters) == limit {
+			break
+		}
+		if topic == "" || letter.Topic == topic {
+			letters = append(letters, letter)
+		}
+	}
+	return letters, nil
+}
+
+// Get returns a message, or nil when it is unknown
+// Params: id string - the message ID
+func (q *fileDeadLetterQueue) Get(id string) (*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	for _, letter := range q.letters {
+		if letter.ID == id {
+			return &letter, nil
+		}
+	}
+	return nil, nil
+}
+
+// Remove drops a message
+// Params: id string - the message ID
+func (q *fileDeadLetterQueue) Remove(id string) (bool, error) {
+	removed, err := q.drop(func(letter DeadLetter) bool { return letter.ID == id })
+	return removed > 0, err
+}
+
+// Purge drops the messages of topic
+// Params: topic string - the original topic, every topic when empty
+func (q *fileDeadLetterQueue) Purge(topic string) (int, error) {
+	return q.drop(func(letter DeadLetter) bool { return topic == "" || letter.Topic == topic })
+}
+
+// drop rewrites the file without the matching messages
+func (q *fileDeadLetterQueue) drop(match func(DeadLetter) bool) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	unlock, err := q.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := q.load(); err != nil {
+		return 0, err
+	}
+	var kept []DeadLetter
+	for _, letter := range q.letters {
+		if !match(letter) {
+			kept = append(kept, letter)
+		}
+	}
+	dropped := len(q.letters) - len(kept)
+	if dropped == 0 {
+		return 0, nil
+	}
+	if err := q.rewrite(kept); err != nil {
+		return 0, err
+	}
+	q.letters = kept
+	return dropped, nil
+}
+
+// lock creates the lock file of the dead-letter file, waiting while another
+// replica holds it and breaking it when it is stale
+func (q *fileDeadLetterQueue) lock() (func(), error) {
+	path := q.path + ".lock"
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, err
+		case time.Since(info.ModTime()) >= deadLetterLockStale:
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// tornLastLine reports whether the file does not end with a newline
+func tornLastLine(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// appendLetter adds letter, replacing a message with the same ID, which a
+// restart can dead-letter twice
+func appendLetter(letters []DeadLetter, letter DeadLetter) []DeadLetter {
+	for i := range letters {
+		if letters[i].ID == letter.ID {
+			letters[i] = letter
+			return letters
+		}
+	}
+	return append(letters, letter)
+}
+
+// load reads the file when its size or modification time changed; callers
+// must hold the lock
+func (q *fileDeadLetterQueue) load() error {
+	info, err := os.Stat(q.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		q.letters, q.size, q.modTime = nil, 0, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == q.size && info.ModTime().Equal(q.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(q.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var letter DeadLetter
+		// a torn last line from a crashed writer is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil || letter.ID == "" {
+			continue
+		}
+		letters = appendLetter(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	q.letters = letters
+	q.size = info.Size()
+	q.modTime = info.ModTime()
+	return nil
+}
+
+// rewrite replaces the file atomically with letters; callers must hold the lock
+func (q *fileDeadLetterQueue) rewrite(letters []DeadLetter) error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), ".deadletters-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, letter := range letters {
+		line, err := json.Marshal(letter)
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return err
+	}
+	return q.stat()
+}
+
+// stat records the size and modification time of the file written by
+// this queue, so it is not read back; callers must hold the lock
+func (q *fileDeadLetterQueue) stat() error {
+	info, err := os.Stat(q.path)
+	if err != nil {
+		return err
+	}
+	q.size = info.Size()
+	q.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileDeadLetterQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq", "deadletters.jsonl")
+	queue, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+
+	failedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	letters := []DeadLetter{
+		{ID: "m1", Topic: "orders", Key: []byte("k"), Headers: []Header{{Key: "h", Value: []byte("v")}}, Value: []byte("1"), Error: "unknown topic", Attempts: 1, FailedAt: failedAt},
+		{ID: "m2", Topic: "billing", Value: []byte("2"), Error: "message too large", Attempts: 1, FailedAt: failedAt},
+		{ID: "m3", Topic: "orders", Value: []byte("3"), Error: "producer is closed", Attempts: 5, FailedAt: failedAt},
+	}
+	for _, letter := range letters {
+		require.NoError(t, queue.Add(letter))
+	}
+
+	listed, err := queue.List("", 10)
+	require.NoError(t, err)
+	assert.Equal(t, letters, listed)
+	listed, err = queue.List("orders", 1)
+	require.NoError(t, err)
+	assert.Equal(t, letters[:1], listed)
+	letter, err := queue.Get("m3")
+	require.NoError(t, err)
+	require.NotNil(t, letter)
+	assert.Equal(t, letters[2], *letter)
+	letter, err = queue.Get("unknown")
+	require.NoError(t, err)
+	assert.Nil(t, letter)
+
+	// a message dead-lettered again replaces the first copy
+	again := letters[0]
+	again.Attempts = 2
+	require.NoError(t, queue.Add(again))
+
+	// another replica sharing the file sees the changes, and its own
+	other, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+	letter, err = other.Get("m1")
+	require.NoError(t, err)
+	require.NotNil(t, letter)
+	assert.Equal(t, 2, letter.Attempts)
+	removed, err := other.Remove("m2")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = other.Remove("m2")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	letter, err = queue.Get("m2")
+	require.NoError(t, err)
+	assert.Nil(t, letter)
+
+	purged, err := queue.Purge("orders")
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	listed, err = other.List("", 10)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestFileDeadLetterQueue_TornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"m1","topic":"orders","error":"unknown topic"}`+"\n"+`{"id":"m2","top`), 0o600))
+	queue, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+	listed, err := queue.List("", 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "m1", listed[0].ID)
+
+	// the next message starts on a line of its own
+	require.NoError(t, queue.Add(DeadLetter{ID: "m3", Topic: "orders"}))
+	other, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+	listed, err = other.List("", 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, "m3", listed[1].ID)
+}
+
+func TestFileDeadLetterQueue_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	queue, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+
+	// another replica holds the lock, the message is added once it is released
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0o600))
+	added := make(chan error, 1)
+	go func() {
+		added <- queue.Add(DeadLetter{ID: "m1", Topic: "orders"})
+	}()
+	select {
+	case <-added:
+		t.Fatal("the message should wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, os.Remove(path+".lock"))
+	require.NoError(t, <-added)
+	assert.NoFileExists(t, path+".lock")
+
+	// a lock left by a crashed replica is broken
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0o600))
+	stale := time.Now().Add(-deadLetterLockStale)
+	require.NoError(t, os.Chtimes(path+".lock", stale, stale))
+	purged, err := queue.Purge("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Dead-letter headers added to the messages produced to the dead-letter topic
+const (
+	DeadLetterIDHeader       = "x-dead-letter-id"
+	DeadLetterTopicHeader    = "x-dead-letter-topic"
+	DeadLetterErrorHeader    = "x-dead-letter-error"
+	DeadLetterAttemptsHeader = "x-dead-letter-attempts"
+	DeadLetterFailedAtHeader = "x-dead-letter-failed-at"
+)
+
+type topicDeadLetterQueue struct {
+	producer Producer
+	topic    string
+	timeout  time.Duration
+}
+
+// NewTopicDeadLetterQueue creates a dead-letter writer producing messages to
+// a topic, with their original key, value and headers and the x-dead-letter
+// headers telling where they were going and why they failed. The topic is
+// read with the broker tools.
+// Params: producer Producer - the producer messages are handed to
+// Params: topic string - the dead-letter topic
+func NewTopicDeadLetterQueue(producer Producer, topic string) DeadLetterWriter {
+	return &topicDeadLetterQueue{producer: producer, topic: topic, timeout: 30 * time.Second}
+}
+
+// Add produces a message to the dead-letter topic
+// Params: letter DeadLetter - the message and why it was given up
+func (q *topicDeadLetterQueue) Add(letter DeadLetter) error {
+	headers := append(append([]Header(nil), letter.Headers...),
+		Header{Key: DeadLetterIDHeader, Value: []byte(letter.ID)},
+		Header{Key: DeadLetterTopicHeader, Value: []byte(letter.Topic)},
+		Header{Key: DeadLetterErrorHeader, Value: []byte(letter.Error)},
+		Header{Key: DeadLetterAttemptsHeader, Value: []byte(strconv.Itoa(letter.Attempts))},
+		Header{Key: DeadLetterFailedAtHeader, Value: []byte(letter.FailedAt.UTC().Format(time.RFC3339Nano))},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+	_, err := q.producer.Produce(ctx, q.topic, letter.Key, headers, letter.Value)
+	return err
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTopicDeadLetterQueue(t *testing.T) {
+	producer := NewInMemoryProducer()
+	queue := NewTopicDeadLetterQueue(producer, "orders.dlq")
+	require.NoError(t, queue.Add(DeadLetter{
+		ID:       "m1",
+		Topic:    "orders",
+		Key:      []byte("k"),
+		Headers:  []Header{{Key: "h", Value: []byte("v")}},
+		Value:    []byte("1"),
+		Error:    "message too large",
+		Attempts: 1,
+		FailedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}))
+
+	messages := producer.(*inMemoryProducer).messages("orders.dlq")
+	require.Len(t, messages, 1)
+	assert.Equal(t, []byte("k"), messages[0].Key)
+	assert.Equal(t, []byte("1"), messages[0].Value)
+	assert.Equal(t, []Header{
+		{Key: "h", Value: []byte("v")},
+		{Key: DeadLetterIDHeader, Value: []byte("m1")},
+		{Key: DeadLetterTopicHeader, Value: []byte("orders")},
+		{Key: DeadLetterErrorHeader, Value: []byte("message too large")},
+		{Key: DeadLetterAttemptsHeader, Value: []byte("1")},
+		{Key: DeadLetterFailedAtHeader, Value: []byte("2024-05-01T12:00:00Z")},
+	}, messages[0].Headers)
+}
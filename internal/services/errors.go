@@ -1,9 +1,82 @@
 package services
 
+import (
+	"context"
+	"errors"
+	"github.com/nathaliaguayos/msg-receiver/internal/spool"
+)
+
 type ServiceError string
 
 func (e ServiceError) Error() string {
 	return string(e)
 }
 
-const ()
+// Retriable reports whether an operation failing with e may succeed later
+func (e ServiceError) Retriable() bool {
+	switch e {
+	case ErrBrokerUnavailable, ErrProducerClosed, ErrCircuitOpen, ErrSpoolUnavailable:
+		return true
+	}
+	return false
+}
+
+// Retriable reports whether an operation failing with err may succeed if
+// tried again: service errors tell for themselves, deadlines are retriable
+// and anything else is not
+// Params: err error - the error returned by the operation
+func Retriable(err error) bool {
+	var serviceErr ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Retriable()
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Ambiguous reports whether a produce call failing with err may still have
+// written the message: the broker failed or the call timed out once the
+// request could have been sent, so producing it again may duplicate it
+// Params: err error - the error returned by the produce call
+func Ambiguous(err error) bool {
+	return errors.Is(err, ErrBrokerUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Unwritten reports whether a produce or spool call failing with err wrote
+// nothing, so running it again cannot duplicate the message: the circuit
+// breaker was open, the producer closed, or the spool full or closed
+// Params: err error - the error returned by the call
+func Unwritten(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrProducerClosed) ||
+		errors.Is(err, spool.ErrFull) || errors.Is(err, spool.ErrClosed)
+}
+
+const (
+	ErrInvalidTopic      ServiceError = "invalid topic name"
+	ErrUnknownTopic      ServiceError = "unknown topic"
+	ErrMessageTooLarge   ServiceError = "message too large"
+	ErrProducerClosed    ServiceError = "producer is closed"
+	ErrBrokerUnavailable ServiceError = "broker is unavailable"
+	ErrCircuitOpen       ServiceError = "circuit breaker is open, the broker is failing"
+	ErrTopicForbidden    ServiceError = "token scopes do not allow this topic"
+
+	ErrTokenExpired    ServiceError = "token is expired"
+	ErrInvalidIssuer   ServiceError = "token issuer is not trusted"
+	ErrInvalidAudience ServiceError = "token audience does not match"
+	ErrInvalidClaims   ServiceError = "token claims are invalid"
+	ErrUnknownKey      ServiceError = "token signing key is unknown"
+	ErrUnsupportedKey  ServiceError = "unsupported signing key"
+	ErrTokenRevoked    ServiceError = "token has been revoked"
+
+	ErrInvalidRefreshToken ServiceError = "refresh token is invalid"
+	ErrRefreshTokenExpired ServiceError = "refresh token is expired"
+	ErrRefreshTokenReused  ServiceError = "refresh token was already used, the session has been revoked"
+
+	ErrInvalidClient         ServiceError = "client authentication failed"
+	ErrClientLocked          ServiceError = "client is locked after too many failed attempts"
+	ErrUnsupportedSecretHash ServiceError = "unsupported client secret hash"
+	ErrInvalidScope          ServiceError = "invalid scope"
+
+	ErrQuotaExceeded ServiceError = "tenant quota exceeded"
+
+	ErrSpoolUnavailable ServiceError = "spool is unavailable"
+)
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"time"
+)
+
+// IdempotencyStore is a contract for remembering the requests sent with an
+// idempotency key, and their responses
+//
+//counterfeiter:generate . IdempotencyStore
+type IdempotencyStore interface {
+	// Start records key as in progress for ttl, unless key is known, in which
+	// case the known record is returned and nothing is changed
+	Start(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error)
+	// Finish stores the response of key for ttl
+	Finish(key string, record IdempotencyRecord, ttl time.Duration) error
+	// Forget drops key, so its request can be sent again
+	Forget(key string) error
+}
+
+// IdempotencyRecord is a request sent with an idempotency key and, once
+// finished, its response
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request, so a key reused for another request is detected
+	Fingerprint string `json:"fingerprint"`
+	// Status is the response status code, zero while the request is in progress
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Finished reports whether the response of the request is stored
+func (r *IdempotencyRecord) Finished() bool {
+	return r.Status != 0
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// idempotencyEntry is a record with the time it is dropped
+type idempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+type inMemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryIdempotencyStore creates an idempotency store that keeps the
+// records in memory. Records do not survive a restart and are not shared
+// between replicas.
+func NewInMemoryIdempotencyStore() IdempotencyStore {
+	return &inMemoryIdempotencyStore{
+		records: make(map[string]idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Start records key as in progress unless it is known
+// Params: key string - the idempotency key
+// Params: fingerprint string - identifies the request
+// Params: ttl time.Duration - how long the key is held
+func (s *inMemoryIdempotencyStore) Start(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	if entry, ok := s.records[key]; ok && now.Before(entry.expiresAt) {
+		record := entry.record
+		return &record, nil
+	}
+	s.records[key] = idempotencyEntry{record: IdempotencyRecord{Fingerprint: fingerprint}, expiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+// Finish stores the response of key
+// Params: key string - the idempotency key
+// Params: record IdempotencyRecord - the request fingerprint and response
+// Params: ttl time.Duration - how long the response is kept
+func (s *inMemoryIdempotencyStore) Finish(key string, record IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = idempotencyEntry{record: record, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+// Forget drops key
+// Params: key string - the idempotency key
+func (s *inMemoryIdempotencyStore) Forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep drops expired records; callers must hold the lock
+func (s *inMemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.records {
+		if !now.Before(entry.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	store := NewInMemoryIdempotencyStore()
+	now := time.Now()
+	store.(*inMemoryIdempotencyStore).now = func() time.Time { return now }
+
+	record, err := store.Start("orders/k1", "f1", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	// a second request sees the first one in progress
+	record, err = store.Start("orders/k1", "f2", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, "f1", record.Fingerprint)
+	assert.False(t, record.Finished())
+
+	require.NoError(t, store.Finish("orders/k1", IdempotencyRecord{Fingerprint: "f1", Status: 200, ContentType: "application/json", Body: []byte(`{}`)}, time.Hour))
+	record, err = store.Start("orders/k1", "f1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.True(t, record.Finished())
+	assert.Equal(t, []byte(`{}`), record.Body)
+
+	// forgotten and expired keys can be claimed again
+	require.NoError(t, store.Forget("orders/k1"))
+	record, err = store.Start("orders/k1", "f3", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+	now = now.Add(2 * time.Minute)
+	record, err = store.Start("orders/k1", "f4", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
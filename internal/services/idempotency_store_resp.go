@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"strconv"
+	"time"
+)
+
+// defaultIdempotencyKeyPrefix namespaces the idempotency records in the shared store
+const defaultIdempotencyKeyPrefix = "msg-receiver:idempotency:"
+
+type respIdempotencyStore struct {
+	client *resp.Client
+	prefix string
+}
+
+// NewRESPIdempotencyStore creates an idempotency store keeping the records
+// in a Redis compatible server, so a retry reaching another replica is
+// still recognised. Keys are claimed with SET NX, so only one replica runs
+// a request.
+// Params: client *resp.Client - the connection to the shared store
+// Params: prefix string - prepended to every key, "msg-receiver:idempotency:" when empty
+func NewRESPIdempotencyStore(client *resp.Client, prefix string) IdempotencyStore {
+	if prefix == "" {
+		prefix = defaultIdempotencyKeyPrefix
+	}
+	return &respIdempotencyStore{client: client, prefix: prefix}
+}
+
+// Start claims key with SET NX, or reads the record holding it
+// Params: key string - the idempotency key
+// Params: fingerprint string - identifies the request
+// Params: ttl time.Duration - how long the key is held
+func (s *respIdempotencyStore) Start(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	value, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	// the holder can expire between SET and GET, in which case the key is
+	// claimed again
+	for attempt := 0; attempt < 2; attempt++ {
+		replies, err := s.client.Pipeline(context.Background(),
+			[]string{"SET", s.prefix + key, string(value), "NX", "PX", milliseconds(ttl)},
+			[]string{"GET", s.prefix + key},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if replyErr, ok := replies[0].(resp.Error); ok {
+			return nil, fmt.Errorf("idempotency key %s: %w", key, replyErr)
+		}
+		if replies[0] != nil {
+			return nil, nil
+		}
+		if replies[1] == nil {
+			continue
+		}
+		raw, ok := replies[1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("idempotency key %s: %w", key, resp.ErrMalformed)
+		}
+		var record IdempotencyRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, fmt.Errorf("idempotency key %s: %w", key, err)
+		}
+		return &record, nil
+	}
+	return nil, fmt.Errorf("idempotency key %s: expired while claimed", key)
+}
+
+// Finish stores the response of key
+// Params: key string - the idempotency key
+// Params: record IdempotencyRecord - the request fingerprint and response
+// Params: ttl time.Duration - how long the response is kept
+func (s *respIdempotencyStore) Finish(key string, record IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	reply, err := s.client.Do(context.Background(), "SET", s.prefix+key, string(value), "PX", milliseconds(ttl))
+	if err != nil {
+		return err
+	}
+	if replyErr, ok := reply.(resp.Error); ok {
+		return fmt.Errorf("idempotency key %s: %w", key, replyErr)
+	}
+	return nil
+}
+
+// Forget drops key
+// Params: key string - the idempotency key
+func (s *respIdempotencyStore) Forget(key string) error {
+	_, err := s.client.Do(context.Background(), "DEL", s.prefix+key)
+	return err
+}
+
+// milliseconds formats a TTL for PX, at least one millisecond
+func milliseconds(ttl time.Duration) string {
+	return strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRESPIdempotencyStore(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client, err := resp.NewClient(resp.ClientConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// replicas sharing the server see each other's keys
+	first, second := NewRESPIdempotencyStore(client, ""), NewRESPIdempotencyStore(client, "")
+	record, err := first.Start("orders/k1", "f1", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+	record, err = second.Start("orders/k1", "f2", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, IdempotencyRecord{Fingerprint: "f1"}, *record)
+
+	finished := IdempotencyRecord{Fingerprint: "f1", Status: 202, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+	require.NoError(t, first.Finish("orders/k1", finished, time.Hour))
+	record, err = second.Start("orders/k1", "f1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, finished, *record)
+	assert.Greater(t, server.TTL("msg-receiver:idempotency:orders/k1"), 59*time.Minute)
+
+	require.NoError(t, second.Forget("orders/k1"))
+	record, err = first.Start("orders/k1", "f3", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	// claims expire, so the key of a crashed request is freed
+	server.FastForward(time.Minute)
+	record, err = second.Start("orders/k1", "f4", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	server.Close()
+	_, err = first.Start("orders/k2", "f1", time.Minute)
+	assert.Error(t, err)
+}
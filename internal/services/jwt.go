@@ -1,7 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"strings"
 	"time"
 )
 
@@ -9,43 +12,198 @@ import (
 //
 //counterfeiter:generate . JWTService
 type JWTService interface {
-	GenerateToken(userID string) (string, error)
+	GenerateToken(claims TokenClaims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
+	JWKS() JWKS
+	RevokeToken(token, clientID string) error
+	RotateSigningKey() (string, error)
+	TokenTTL() time.Duration
+}
+
+// TokenClaims are the caller specific claims of an issued token
+type TokenClaims struct {
+	Subject string
+	// Scopes are stored space-delimited in the scope claim
+	Scopes []string
+	// Tenant is stored in the tenant claim when set
+	Tenant string
+}
+
+// JWTConfig holds the claims settings of issued tokens
+type JWTConfig struct {
+	Issuer string
+	// Audience is added to issued tokens and required on validation when set
+	Audience string
+	// TokenTTL is the lifetime of access tokens, 24 hours when zero
+	TokenTTL time.Duration
+	// Revocations records revoked token IDs, kept in memory when nil
+	Revocations RevocationStore
 }
 
 type jwtService struct {
-	secretKey string
-	issuer    string
+	keyring     Keyring
+	issuer      string
+	audience    string
+	ttl         time.Duration
+	revocations RevocationStore
 }
 
 // NewJWTService creates a new JWT service
-func NewJWTService(secretKey, issuer string) JWTService {
+// Params: keyring Keyring - the keys used to sign and verify tokens
+// Params: cfg JWTConfig - issuer, audience and token lifetime
+func NewJWTService(keyring Keyring, cfg JWTConfig) JWTService {
+	ttl := cfg.TokenTTL
+	if ttl <= 0 {
+		ttl = time.Hour * 24
+	}
+	revocations := cfg.Revocations
+	if revocations == nil {
+		revocations = NewInMemoryRevocationStore()
+	}
 	return &jwtService{
-		secretKey: secretKey,
-		issuer:    issuer,
+		keyring:     keyring,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		ttl:         ttl,
+		revocations: revocations,
 	}
 }
 
 // GenerateToken generates a new JWT token
-// Params: userID string - the user ID
-func (s *jwtService) GenerateToken(userID string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub": userID,
+// Params: claims TokenClaims - the subject, scopes and tenant of the token
+func (s *jwtService) GenerateToken(claims TokenClaims) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	mapClaims := jwt.MapClaims{
+		"jti": jti,
+		"sub": claims.Subject,
 		"iss": s.issuer,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"exp": time.Now().Add(s.ttl).Unix(),
 		"iat": time.Now().Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	if s.audience != "" {
+		mapClaims["aud"] = s.audience
+	}
+	if len(claims.Scopes) > 0 {
+		mapClaims["scope"] = strings.Join(claims.Scopes, " ")
+	}
+	if claims.Tenant != "" {
+		mapClaims["tenant"] = claims.Tenant
+	}
+	key := s.keyring.SigningKey()
+	token := jwt.NewWithClaims(key.Method, mapClaims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
 }
 
-// ValidateToken validates a JWT token
+// ValidateToken validates a JWT token: signature, expiry, issuer,
+// revocation and, when configured, audience
 // Params: token string - the JWT token
 func (s *jwtService) ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+	parsed, claims, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrTokenExpired
+	}
+	if !claims.VerifyIssuer(s.issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if s.audience != "" && !claims.VerifyAudience(s.audience, true) {
+		return nil, ErrInvalidAudience
+	}
+	// tokens issued before token IDs were introduced cannot be revoked
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := s.revocations.IsRevoked(jti)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return parsed, nil
+}
+
+// RevokeToken revokes a token issued by this service to a client until it
+// expires. Expired tokens, tokens without an ID and tokens issued to other
+// clients are ignored.
+// Params: token string - the JWT token
+// Params: clientID string - the authenticated client revoking it
+func (s *jwtService) RevokeToken(token, clientID string) error {
+	_, claims, err := s.parse(token)
+	if errors.Is(err, ErrTokenExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !claims.VerifyIssuer(s.issuer, true) {
+		return ErrInvalidIssuer
+	}
+	jti, _ := claims["jti"].(string)
+	exp, ok := claims["exp"].(float64)
+	if jti == "" || !ok || claims["sub"] != clientID {
+		return nil
+	}
+	return s.revocations.Revoke(jti, time.Unix(int64(exp), 0))
+}
+
+// parse verifies the token signature against the keyring
+func (s *jwtService) parse(token string) (*jwt.Token, jwt.MapClaims, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		// tokens issued before key IDs were introduced are checked against the active key
+		key := s.keyring.SigningKey()
+		if kid, ok := t.Header["kid"]; ok {
+			id, _ := kid.(string)
+			var err error
+			if key, err = s.keyring.VerificationKey(id); err != nil {
+				return nil, fmt.Errorf("%w: %w", jwt.ErrSignatureInvalid, err)
+			}
+		}
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(s.secretKey), nil
+		return key.public, nil
 	})
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, nil, ErrTokenExpired
+		}
+		return nil, nil, err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, nil, ErrInvalidClaims
+	}
+	return parsed, claims, nil
+}
+
+// JWKS returns the public verification keys; HMAC keys are not included
+func (s *jwtService) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keyring.VerificationKeys() {
+		if jwk, ok := key.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// RotateSigningKey replaces the signing key and returns the new key ID
+func (s *jwtService) RotateSigningKey() (string, error) {
+	key, err := s.keyring.Rotate()
+	if err != nil {
+		return "", err
+	}
+	return key.ID, nil
+}
+
+// TokenTTL returns the lifetime of issued tokens
+func (s *jwtService) TokenTTL() time.Duration {
+	return s.ttl
 }
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"crypto"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestNewJWTService(t *testing.T) {
@@ -12,9 +16,20 @@ func TestNewJWTService(t *testing.T) {
 
 func TestGenerateToken(t *testing.T) {
 	jwtService := createJWTService()
-	token, err := jwtService.GenerateToken("123")
+	token, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
 	assert.Nil(t, err)
 	assert.NotNil(t, token)
+	tok, err := jwtService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.NotContains(t, tok.Claims, "scope")
+	assert.NotContains(t, tok.Claims, "tenant")
+
+	token, err = jwtService.GenerateToken(TokenClaims{Subject: "123", Scopes: []string{"produce:orders", "produce:payments.*"}, Tenant: "acme"})
+	require.NoError(t, err)
+	tok, err = jwtService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "produce:orders produce:payments.*", tok.Claims.(jwt.MapClaims)["scope"])
+	assert.Equal(t, "acme", tok.Claims.(jwt.MapClaims)["tenant"])
 }
 
 func TestValidateToken(t *testing.T) {
@@ -26,7 +41,7 @@ func TestValidateToken(t *testing.T) {
 		{
 			name: "Valid token",
 			getToken: func(userID string, jwtService JWTService) string {
-				token, _ := jwtService.GenerateToken(userID)
+				token, _ := jwtService.GenerateToken(TokenClaims{Subject: userID})
 				return token
 			},
 			assertion: func(t *testing.T, token string, jwtService JWTService) {
@@ -81,6 +96,43 @@ func TestValidateToken(t *testing.T) {
 				_, err := jwtService.ValidateToken(token)
 				assert.Containsf(t, err.Error(), "signature is invalid", "error message: %s", err.Error())
 			},
+		}, {
+			name: "should fail with an expired token",
+			getToken: func(userID string, jwtService JWTService) string {
+				return signToken(jwt.MapClaims{"sub": userID, "iss": "issuer", "aud": "audience", "exp": time.Now().Add(-time.Minute).Unix()})
+			},
+			assertion: func(t *testing.T, token string, jwtService JWTService) {
+				tok, err := jwtService.ValidateToken(token)
+				assert.ErrorIs(t, err, ErrTokenExpired)
+				assert.Nil(t, tok)
+			},
+		}, {
+			name: "should fail with a token without expiry",
+			getToken: func(userID string, jwtService JWTService) string {
+				return signToken(jwt.MapClaims{"sub": userID, "iss": "issuer", "aud": "audience"})
+			},
+			assertion: func(t *testing.T, token string, jwtService JWTService) {
+				_, err := jwtService.ValidateToken(token)
+				assert.ErrorIs(t, err, ErrTokenExpired)
+			},
+		}, {
+			name: "should fail with a token from another issuer",
+			getToken: func(userID string, jwtService JWTService) string {
+				return signToken(jwt.MapClaims{"sub": userID, "iss": "other", "aud": "audience", "exp": time.Now().Add(time.Minute).Unix()})
+			},
+			assertion: func(t *testing.T, token string, jwtService JWTService) {
+				_, err := jwtService.ValidateToken(token)
+				assert.ErrorIs(t, err, ErrInvalidIssuer)
+			},
+		}, {
+			name: "should fail with a token for another audience",
+			getToken: func(userID string, jwtService JWTService) string {
+				return signToken(jwt.MapClaims{"sub": userID, "iss": "issuer", "aud": "other", "exp": time.Now().Add(time.Minute).Unix()})
+			},
+			assertion: func(t *testing.T, token string, jwtService JWTService) {
+				_, err := jwtService.ValidateToken(token)
+				assert.ErrorIs(t, err, ErrInvalidAudience)
+			},
 		},
 	}
 
@@ -93,9 +145,150 @@ func TestValidateToken(t *testing.T) {
 	}
 }
 
+func TestJWTService_AsymmetricKeys(t *testing.T) {
+	keys := map[string]crypto.Signer{
+		"RS256": mustRSAKey(t),
+		"ES256": mustECKey(t),
+		"EdDSA": mustEd25519Key(t),
+	}
+	for alg, private := range keys {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewSigningKey("", private)
+			require.NoError(t, err)
+			jwtService := NewJWTService(memoryKeyring(key), JWTConfig{Issuer: "issuer", Audience: "audience"})
+
+			token, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+			require.NoError(t, err)
+			tok, err := jwtService.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, alg, tok.Method.Alg())
+			assert.Equal(t, key.ID, tok.Header["kid"])
+
+			jwks := jwtService.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, key.ID, jwks.Keys[0].KeyID)
+			assert.Equal(t, alg, jwks.Keys[0].Algorithm)
+
+			// a token signed by another key with the same kid must be rejected
+			other, err := NewSigningKey(key.ID, mustEd25519Key(t))
+			require.NoError(t, err)
+			forged, err := NewJWTService(memoryKeyring(other), JWTConfig{Issuer: "issuer", Audience: "audience"}).GenerateToken(TokenClaims{Subject: "123"})
+			require.NoError(t, err)
+			_, err = jwtService.ValidateToken(forged)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWTService_UnknownKeyID(t *testing.T) {
+	jwtService := createJWTService()
+	other := NewJWTService(memoryKeyring(NewHMACKey("other", []byte("secret"))), JWTConfig{Issuer: "issuer", Audience: "audience"})
+	token, err := other.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+
+	_, err = jwtService.ValidateToken(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestJWTService_HMACKeyIsNotPublished(t *testing.T) {
+	assert.Empty(t, createJWTService().JWKS().Keys)
+}
+
 func createJWTService() JWTService {
 	secretKey := "secret"
 	issuer := "issuer"
-	jwtService := NewJWTService(secretKey, issuer)
+	audience := "audience"
+	jwtService := NewJWTService(memoryKeyring(NewHMACKey("default", []byte(secretKey))), JWTConfig{Issuer: issuer, Audience: audience})
 	return jwtService
 }
+
+func TestJWTService_RotateSigningKey(t *testing.T) {
+	jwtService := createJWTService()
+	before, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+
+	kid, err := jwtService.RotateSigningKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, "default", kid)
+
+	after, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+	tok, err := jwtService.ValidateToken(after)
+	require.NoError(t, err)
+	assert.Equal(t, kid, tok.Header["kid"])
+
+	_, err = jwtService.ValidateToken(before)
+	assert.NoError(t, err, "tokens signed by the previous key should still be valid")
+}
+
+func TestJWTService_TokenTTL(t *testing.T) {
+	assert.Equal(t, 24*time.Hour, createJWTService().TokenTTL())
+
+	jwtService := NewJWTService(memoryKeyring(NewHMACKey("default", []byte("secret"))), JWTConfig{Issuer: "issuer", TokenTTL: 15 * time.Minute})
+	assert.Equal(t, 15*time.Minute, jwtService.TokenTTL())
+	token, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+	tok, err := jwtService.ValidateToken(token)
+	require.NoError(t, err)
+	exp := int64(tok.Claims.(jwt.MapClaims)["exp"].(float64))
+	assert.InDelta(t, time.Now().Add(15*time.Minute).Unix(), exp, 2)
+}
+
+func TestJWTService_RevokeToken(t *testing.T) {
+	jwtService := createJWTService()
+	token, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+	other, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+
+	tok, err := jwtService.ValidateToken(token)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tok.Claims.(jwt.MapClaims)["jti"])
+
+	require.NoError(t, jwtService.RevokeToken(token, "123"))
+	_, err = jwtService.ValidateToken(token)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, err = jwtService.ValidateToken(other)
+	assert.NoError(t, err, "other tokens of the subject should stay valid")
+
+	t.Run("should ignore tokens of other clients", func(t *testing.T) {
+		require.NoError(t, jwtService.RevokeToken(other, "456"))
+		_, err := jwtService.ValidateToken(other)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should ignore expired tokens", func(t *testing.T) {
+		expired := signToken(jwt.MapClaims{"jti": "1", "sub": "123", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()})
+		assert.NoError(t, jwtService.RevokeToken(expired, "123"))
+	})
+
+	t.Run("should reject tokens with an invalid signature", func(t *testing.T) {
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"jti": "1", "iss": "issuer"}).SignedString([]byte("other"))
+		assert.Error(t, jwtService.RevokeToken(forged, "123"))
+	})
+
+	t.Run("should return revocation store errors", func(t *testing.T) {
+		jwtService := NewJWTService(memoryKeyring(NewHMACKey("default", []byte("secret"))), JWTConfig{Issuer: "issuer", Revocations: failingRevocationStore{}})
+		token, err := jwtService.GenerateToken(TokenClaims{Subject: "123"})
+		require.NoError(t, err)
+		_, err = jwtService.ValidateToken(token)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+// failingRevocationStore fails every lookup
+type failingRevocationStore struct {
+	RevocationStore
+}
+
+func (failingRevocationStore) IsRevoked(string) (bool, error) { return false, assert.AnError }
+
+func memoryKeyring(key *SigningKey) Keyring {
+	keyring, _ := NewKeyring(key, KeyringConfig{Overlap: time.Hour})
+	return keyring
+}
+
+func signToken(claims jwt.MapClaims) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	return token
+}
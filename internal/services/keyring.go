@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keyring is a contract for the set of keys used to sign and verify tokens
+//
+//counterfeiter:generate . Keyring
+type Keyring interface {
+	SigningKey() *SigningKey
+	VerificationKey(kid string) (*SigningKey, error)
+	VerificationKeys() []*SigningKey
+	Rotate() (*SigningKey, error)
+	RotateExpired(maxAge time.Duration) (*SigningKey, error)
+	Reload() error
+}
+
+// rotationLockStale is when a rotation lock left by a crashed replica is
+// broken
+const rotationLockStale = time.Minute
+
+// KeyringConfig configures a Keyring
+type KeyringConfig struct {
+	// Overlap is how long a key keeps verifying tokens after it is replaced;
+	// it should be at least the token lifetime
+	Overlap time.Duration
+	// Dir persists generated keys as PEM files so that they survive restarts
+	// and can be shared by every replica; keys only live in memory when empty
+	Dir string
+}
+
+type keyEntry struct {
+	key *SigningKey
+	// retired is when the key stopped signing; zero for the active key
+	retired time.Time
+}
+
+type keyring struct {
+	mu   sync.RWMutex
+	cfg  KeyringConfig
+	base *SigningKey
+	// keys is ordered by creation, the last entry is the active key
+	keys []keyEntry
+	// started is when the keyring was created, the age of the configured key
+	started time.Time
+	now     func() time.Time
+}
+
+// NewKeyring creates a keyring. The configured key is active until a newer
+// key is found in Dir or generated by Rotate.
+// Params: key *SigningKey - the configured key
+// Params: cfg KeyringConfig - overlap and persistence settings
+func NewKeyring(key *SigningKey, cfg KeyringConfig) (Keyring, error) {
+	k := &keyring{
+		cfg:  cfg,
+		base: key,
+		keys: []keyEntry{{key: key}},
+		now:  time.Now,
+	}
+	k.started = k.now()
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, err
+		}
+		if err := k.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// SigningKey returns the key used to sign new tokens
+func (k *keyring) SigningKey() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[len(k.keys)-1].key
+}
+
+// VerificationKey returns the key with the given kid if it still verifies tokens
+// Params: kid string - the key ID from the token header
+func (k *keyring) VerificationKey(kid string) (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	for _, entry := range k.keys {
+		if entry.key.ID == kid && k.valid(entry, now) {
+			return entry.key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// VerificationKeys returns every key that still verifies tokens, newest first
+func (k *keyring) VerificationKeys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		if k.valid(k.keys[i], now) {
+			keys = append(keys, k.keys[i].key)
+		}
+	}
+	return keys
+}
+
+// Rotate generates a new signing key with the same algorithm as the active
+// one. The previous key keeps verifying tokens for the configured overlap.
+func (k *keyring) Rotate() (*SigningKey, error) {
+	key, err := GenerateSigningKey(k.SigningKey())
+	if err != nil {
+		return nil, err
+	}
+	if k.cfg.Dir != "" {
+		if err := k.persist(key); err != nil {
+			return nil, err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	k.keys[len(k.keys)-1].retired = now
+	k.keys = append(k.keys, keyEntry{key: key})
+	k.prune(now)
+	return key, nil
+}
+
+// RotateExpired rotates the signing key when it is older than maxAge. The
+// configured key counts as created at startup. Replicas sharing Dir take a
+// lock file and reload first, so only one of them rotates an expired key;
+// it returns nil when no key was generated.
+// Params: maxAge time.Duration - the signing key lifetime
+func (k *keyring) RotateExpired(maxAge time.Duration) (*SigningKey, error) {
+	if k.cfg.Dir != "" {
+		unlock, ok, err := k.lockRotation()
+		if err != nil || !ok {
+			// another replica is rotating, its key is picked up on reload
+			return nil, err
+		}
+		defer unlock()
+		if err := k.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	k.mu.RLock()
+	active := k.keys[len(k.keys)-1].key
+	created := active.Created
+	if active == k.base && created.IsZero() {
+		created = k.started
+	}
+	expired := !k.now().Before(created.Add(maxAge))
+	k.mu.RUnlock()
+	if !expired {
+		return nil, nil
+	}
+	return k.Rotate()
+}
+
+// lockRotation creates the rotation lock file in Dir, breaking it when it
+// is stale. It reports false when another replica holds it.
+func (k *keyring) lockRotation() (func(), bool, error) {
+	path := filepath.Join(k.cfg.Dir, ".rotate.lock")
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, false, err
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if time.Since(info.ModTime()) < rotationLockStale {
+			return nil, false, nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Reload reads the keys persisted in Dir, picking up keys rotated by other
+// replicas. It is a no-op for in-memory keyrings.
+func (k *keyring) Reload() error {
+	if k.cfg.Dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(k.cfg.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	keys := []*SigningKey{k.base}
+	for _, path := range paths {
+		key, err := readPersistedKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].Created.Before(keys[j].Created)
+	})
+
+	entries := make([]keyEntry, len(keys))
+	for i, key := range keys {
+		entries[i].key = key
+		// a key retires when its successor is created
+		if i+1 < len(keys) {
+			entries[i].retired = keys[i+1].Created
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = entries
+	k.prune(k.now())
+	return nil
+}
+
+// RunKeyRotation reloads the keyring and rotates it whenever its signing
+// key is older than interval, until ctx is done
+// Params: interval time.Duration - the signing key lifetime
+// Params: onError func(error) - called when a rotation or reload fails
+func RunKeyRotation(ctx context.Context, keyring Keyring, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(min(interval, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := keyring.Reload(); err != nil {
+			onError(err)
+			continue
+		}
+		if _, err := keyring.RotateExpired(interval); err != nil {
+			onError(err)
+		}
+	}
+}
+
+// valid reports whether a key still verifies tokens
+func (k *keyring) valid(entry keyEntry, now time.Time) bool {
+	return entry.retired.IsZero() || now.Before(entry.retired.Add(k.cfg.Overlap))
+}
+
+// prune forgets keys whose overlap has elapsed and deletes their files;
+// callers must hold the write lock
+func (k *keyring) prune(now time.Time) {
+	kept := k.keys[:0]
+	for _, entry := range k.keys {
+		if k.valid(entry, now) {
+			kept = append(kept, entry)
+			continue
+		}
+		if k.cfg.Dir != "" && entry.key != k.base {
+			_ = os.Remove(k.keyPath(entry.key))
+		}
+	}
+	k.keys = kept
+}
+
+// persist writes a key atomically so other replicas never read a partial file
+func (k *keyring) persist(key *SigningKey) error {
+	block, err := key.pemBlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(k.cfg.Dir, ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := pem.Encode(tmp, block); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.keyPath(key))
+}
+
+func (k *keyring) keyPath(key *SigningKey) string {
+	return filepath.Join(k.cfg.Dir, key.Created.Format("20060102T150405.000000000Z")+"-"+key.ID+".pem")
+}
+
+func readPersistedKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	kid := strings.TrimSpace(block.Headers["Kid"])
+	created, err := time.Parse(time.RFC3339Nano, block.Headers["Created"])
+	if kid == "" || err != nil {
+		return nil, errors.New("missing Kid or Created header")
+	}
+	key, err := parseSigningKey(kid, block)
+	if err != nil {
+		return nil, err
+	}
+	key.Created = created
+	return key, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyring_Rotate(t *testing.T) {
+	base := NewHMACKey("base", []byte("secret"))
+	k, err := NewKeyring(base, KeyringConfig{Overlap: time.Hour})
+	require.NoError(t, err)
+	ring := k.(*keyring)
+	now := time.Now()
+	ring.now = func() time.Time { return now }
+
+	assert.Equal(t, base, ring.SigningKey())
+
+	rotated, err := ring.Rotate()
+	require.NoError(t, err)
+	assert.NotEqual(t, base.ID, rotated.ID)
+	assert.Equal(t, base.Method, rotated.Method)
+	assert.Equal(t, rotated, ring.SigningKey())
+
+	key, err := ring.VerificationKey("base")
+	require.NoError(t, err)
+	assert.Equal(t, base, key)
+	assert.Equal(t, []*SigningKey{rotated, base}, ring.VerificationKeys())
+
+	// once the overlap has elapsed the old key no longer verifies
+	now = now.Add(time.Hour + time.Second)
+	_, err = ring.VerificationKey("base")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, []*SigningKey{rotated}, ring.VerificationKeys())
+}
+
+func TestKeyring_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	base, err := NewSigningKey("base", mustEd25519Key(t))
+	require.NoError(t, err)
+
+	first, err := NewKeyring(base, KeyringConfig{Overlap: time.Hour, Dir: dir})
+	require.NoError(t, err)
+	rotated, err := first.Rotate()
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// a replica sharing the directory picks up the rotated key
+	second, err := NewKeyring(base, KeyringConfig{Overlap: time.Hour, Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, rotated.ID, second.SigningKey().ID)
+	assert.Equal(t, "EdDSA", second.SigningKey().Method.Alg())
+	_, err = second.VerificationKey("base")
+	assert.NoError(t, err)
+
+	// tokens signed by one replica verify on the other
+	token, err := NewJWTService(first, JWTConfig{Issuer: "issuer"}).GenerateToken(TokenClaims{Subject: "123"})
+	require.NoError(t, err)
+	_, err = NewJWTService(second, JWTConfig{Issuer: "issuer"}).ValidateToken(token)
+	assert.NoError(t, err)
+}
+
+func TestKeyring_PruneDeletesExpiredKeys(t *testing.T) {
+	dir := t.TempDir()
+	k, err := NewKeyring(NewHMACKey("base", []byte("secret")), KeyringConfig{Overlap: time.Minute, Dir: dir})
+	require.NoError(t, err)
+	ring := k.(*keyring)
+
+	first, err := ring.Rotate()
+	require.NoError(t, err)
+	_, err = ring.Rotate()
+	require.NoError(t, err)
+
+	ring.now = func() time.Time { return time.Now().Add(time.Hour) }
+	require.NoError(t, ring.Reload())
+
+	_, err = os.Stat(ring.keyPath(first))
+	assert.True(t, os.IsNotExist(err), "expired key file should be deleted")
+	assert.Len(t, ring.VerificationKeys(), 1)
+}
+
+func TestKeyring_Reload_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0o600))
+	_, err := NewKeyring(NewHMACKey("base", []byte("secret")), KeyringConfig{Dir: dir})
+	assert.Error(t, err)
+}
+
+func TestKeyring_RotateExpired(t *testing.T) {
+	dir := t.TempDir()
+	base := NewHMACKey("base", []byte("secret"))
+	replicas := make([]*keyring, 3)
+	for i := range replicas {
+		k, err := NewKeyring(base, KeyringConfig{Overlap: time.Hour, Dir: dir})
+		require.NoError(t, err)
+		replicas[i] = k.(*keyring)
+	}
+
+	// the configured key counts as created at startup
+	rotated, err := replicas[0].RotateExpired(time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, rotated)
+	assert.Equal(t, base, replicas[0].SigningKey())
+
+	// a held lock leaves the rotation to its holder
+	lock := filepath.Join(dir, ".rotate.lock")
+	require.NoError(t, os.WriteFile(lock, nil, 0o600))
+	rotated, err = replicas[0].RotateExpired(0)
+	require.NoError(t, err)
+	assert.Nil(t, rotated)
+
+	// a stale lock is broken
+	stale := time.Now().Add(-2 * rotationLockStale)
+	require.NoError(t, os.Chtimes(lock, stale, stale))
+	first, err := replicas[0].RotateExpired(0)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	_, err = os.Stat(lock)
+	assert.True(t, os.IsNotExist(err), "the lock should be released")
+
+	// the other replicas see the fresh key and keep it
+	for _, replica := range replicas[1:] {
+		rotated, err := replica.RotateExpired(time.Hour)
+		require.NoError(t, err)
+		assert.Nil(t, rotated)
+		assert.Equal(t, first.ID, replica.SigningKey().ID)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestRunKeyRotation(t *testing.T) {
+	base := NewHMACKey("base", []byte("secret"))
+	keyring, err := NewKeyring(base, KeyringConfig{Overlap: time.Hour})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunKeyRotation(ctx, keyring, 10*time.Millisecond, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return keyring.SigningKey().ID != "base"
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
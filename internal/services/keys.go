@@ -0,0 +1,275 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"time"
+)
+
+const (
+	// hmacPEMType is the PEM block type used to persist HMAC secrets
+	hmacPEMType = "HMAC SECRET"
+	// hmacSecretSize is the size of generated HMAC secrets
+	hmacSecretSize = 32
+	// rsaKeySize is the size of generated RSA keys
+	rsaKeySize = 2048
+)
+
+// SigningKey is a key used to sign tokens and verify their signature
+type SigningKey struct {
+	// ID is sent as the "kid" header of issued tokens
+	ID     string
+	Method jwt.SigningMethod
+	// Created is when the key was generated; zero for configured keys
+	Created time.Time
+	// private is passed to Method.Sign and public to Method.Verify
+	private interface{}
+	public  interface{}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKey creates an HS256 key from a shared secret. HMAC keys are
+// never published in the JWKS.
+// Params: id string - the key ID
+// Params: secret []byte - the shared secret
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{
+		ID:      id,
+		Method:  jwt.SigningMethodHS256,
+		private: secret,
+		public:  secret,
+	}
+}
+
+// NewSigningKey creates a key from an RSA, ECDSA or Ed25519 private key.
+// The algorithm is RS256 for RSA, ES256/ES384/ES512 depending on the curve
+// and EdDSA for Ed25519. When id is empty the RFC 7638 thumbprint is used.
+// Params: id string - the key ID
+// Params: private crypto.Signer - the private key
+func NewSigningKey(id string, private crypto.Signer) (*SigningKey, error) {
+	key := &SigningKey{ID: id, private: private, public: private.Public()}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, ErrUnsupportedKey
+		}
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+	return key, nil
+}
+
+// LoadSigningKey reads a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1)
+// Params: id string - the key ID, the thumbprint is used when empty
+// Params: path string - the PEM file
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block in %s", ErrUnsupportedKey, path)
+	}
+	return parseSigningKey(id, block)
+}
+
+// parseSigningKey builds a key from a PEM block
+func parseSigningKey(id string, block *pem.Block) (*SigningKey, error) {
+	var (
+		private interface{}
+		err     error
+	)
+	switch block.Type {
+	case hmacPEMType:
+		return NewHMACKey(id, block.Bytes), nil
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return NewSigningKey(id, signer)
+}
+
+// GenerateSigningKey creates a new random key using the same algorithm as
+// like, so a rotated keyring keeps issuing the same kind of tokens
+// Params: like *SigningKey - the key whose algorithm is reused
+func GenerateSigningKey(like *SigningKey) (*SigningKey, error) {
+	var (
+		key *SigningKey
+		err error
+	)
+	switch private := like.private.(type) {
+	case []byte:
+		secret := make([]byte, hmacSecretSize)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, err
+		}
+		id := make([]byte, 12)
+		if _, err = rand.Read(id); err != nil {
+			return nil, err
+		}
+		key = NewHMACKey(encodeSegment(id), secret)
+	case *rsa.PrivateKey:
+		var generated *rsa.PrivateKey
+		if generated, err = rsa.GenerateKey(rand.Reader, max(private.N.BitLen(), rsaKeySize)); err == nil {
+			key, err = NewSigningKey("", generated)
+		}
+	case *ecdsa.PrivateKey:
+		var generated *ecdsa.PrivateKey
+		if generated, err = ecdsa.GenerateKey(private.Curve, rand.Reader); err == nil {
+			key, err = NewSigningKey("", generated)
+		}
+	case ed25519.PrivateKey:
+		var generated ed25519.PrivateKey
+		if _, generated, err = ed25519.GenerateKey(rand.Reader); err == nil {
+			key, err = NewSigningKey("", generated)
+		}
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.Created = time.Now().UTC()
+	return key, nil
+}
+
+// pemBlock encodes the private key for persistence
+func (k *SigningKey) pemBlock() (*pem.Block, error) {
+	headers := map[string]string{"Kid": k.ID, "Created": k.Created.Format(time.RFC3339Nano)}
+	if secret, ok := k.private.([]byte); ok {
+		return &pem.Block{Type: hmacPEMType, Headers: headers, Bytes: secret}, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der}, nil
+}
+
+// JWK returns the public key in JWK format; false for HMAC keys
+func (k *SigningKey) JWK() (JWK, bool) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeSegment(pub.N.Bytes())
+		jwk.E = encodeSegment(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = encodeSegment(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeSegment(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeSegment(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// thumbprint computes the RFC 7638 SHA-256 thumbprint of the public key
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk, ok := k.JWK()
+	if !ok {
+		return "", ErrUnsupportedKey
+	}
+	// the required members in lexicographic order, see RFC 7638 section 3.2
+	var members interface{}
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encodeSegment(sum[:]), nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
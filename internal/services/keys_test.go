@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	rsaKey := mustRSAKey(t)
+	ecKey := mustECKey(t)
+	pkcs1 := x509.MarshalPKCS1PrivateKey(rsaKey)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		pemType   string
+		der       []byte
+		assertion func(*testing.T, *SigningKey, error)
+	}{
+		{
+			name:    "should load a PKCS#8 Ed25519 key",
+			pemType: "PRIVATE KEY",
+			der:     mustPKCS8(t, mustEd25519Key(t)),
+			assertion: func(t *testing.T, key *SigningKey, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, jwt.SigningMethodEdDSA, key.Method)
+				assert.NotEmpty(t, key.ID)
+			},
+		}, {
+			name:    "should load a PKCS#1 RSA key",
+			pemType: "RSA PRIVATE KEY",
+			der:     pkcs1,
+			assertion: func(t *testing.T, key *SigningKey, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, jwt.SigningMethodRS256, key.Method)
+			},
+		}, {
+			name:    "should load a SEC 1 EC key",
+			pemType: "EC PRIVATE KEY",
+			der:     sec1,
+			assertion: func(t *testing.T, key *SigningKey, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, jwt.SigningMethodES256, key.Method)
+				jwk, ok := key.JWK()
+				require.True(t, ok)
+				assert.Equal(t, "P-256", jwk.Curve)
+				assert.Len(t, jwk.X, 43)
+			},
+		}, {
+			name:    "should reject a public key",
+			pemType: "PUBLIC KEY",
+			der:     []byte("irrelevant"),
+			assertion: func(t *testing.T, key *SigningKey, err error) {
+				assert.ErrorIs(t, err, ErrUnsupportedKey)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key.pem")
+			require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: tc.pemType, Bytes: tc.der}), 0o600))
+			key, err := LoadSigningKey("", path)
+			tc.assertion(t, key, err)
+		})
+	}
+
+	t.Run("should fail when the file is missing", func(t *testing.T) {
+		_, err := LoadSigningKey("", filepath.Join(t.TempDir(), "missing.pem"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should keep an explicit key ID", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1}), 0o600))
+		key, err := LoadSigningKey("2024-01", path)
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01", key.ID)
+	})
+}
+
+func TestSigningKey_Thumbprint(t *testing.T) {
+	// example from RFC 7638 section 3.1
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+	key := &SigningKey{
+		Method: jwt.SigningMethodRS256,
+		public: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537},
+	}
+	thumbprint, err := key.thumbprint()
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
+
+func TestNewSigningKey_UnsupportedCurve(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewSigningKey("", private)
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func mustEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func mustPKCS8(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return der
+}
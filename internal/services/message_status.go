@@ -0,0 +1,42 @@
+package services
+
+import (
+	"time"
+)
+
+// MessageState is where an accepted message is on its way to the broker
+type MessageState string
+
+const (
+	// MessageQueued messages are spooled and not produced yet
+	MessageQueued MessageState = "queued"
+	// MessageDelivered messages are stored by the broker
+	MessageDelivered MessageState = "delivered"
+	// MessageFailed messages were given up, for the reason given
+	MessageFailed MessageState = "failed"
+)
+
+// MessageStatusStore is a contract for tracking the messages accepted
+// asynchronously, so clients can look them up by ID
+//
+//counterfeiter:generate . MessageStatusStore
+type MessageStatusStore interface {
+	// Put stores statuses for ttl, replacing those with the same IDs
+	Put(statuses []MessageStatus, ttl time.Duration) error
+	// Get returns the status of a message, or nil when it is unknown or expired
+	Get(id string) (*MessageStatus, error)
+}
+
+// MessageStatus is the state of an accepted message. Partition and Offset
+// are set once delivered, Reason once failed.
+type MessageStatus struct {
+	ID         string       `json:"id"`
+	Tenant     string       `json:"tenant,omitempty"`
+	State      MessageState `json:"status"`
+	Topic      string       `json:"topic"`
+	Partition  *int32       `json:"partition,omitempty"`
+	Offset     *int64       `json:"offset,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	AcceptedAt time.Time    `json:"accepted_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// messageStatusEntry is a status with the time it is dropped
+type messageStatusEntry struct {
+	status    MessageStatus
+	expiresAt time.Time
+}
+
+type inMemoryMessageStatusStore struct {
+	mu        sync.Mutex
+	statuses  map[string]messageStatusEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryMessageStatusStore creates a message status store that keeps
+// the statuses in memory. Statuses do not survive a restart and are not
+// shared between replicas.
+func NewInMemoryMessageStatusStore() MessageStatusStore {
+	return &inMemoryMessageStatusStore{
+		statuses: make(map[string]messageStatusEntry),
+		now:      time.Now,
+	}
+}
+
+// Put stores statuses for ttl
+// Params: statuses []MessageStatus - the statuses to store
+// Params: ttl time.Duration - how long they are kept
+func (s *inMemoryMessageStatusStore) Put(statuses []MessageStatus, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	for _, status := range statuses {
+		s.statuses[status.ID] = messageStatusEntry{status: status, expiresAt: now.Add(ttl)}
+	}
+	return nil
+}
+
+// Get returns the status of a message, or nil when it is unknown
+// Params: id string - the message ID
+func (s *inMemoryMessageStatusStore) Get(id string) (*MessageStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.statuses[id]
+	if !ok || !s.now().Before(entry.expiresAt) {
+		return nil, nil
+	}
+	status := entry.status
+	return &status, nil
+}
+
+// sweep drops expired statuses; callers must hold the lock
+func (s *inMemoryMessageStatusStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for id, entry := range s.statuses {
+		if !now.Before(entry.expiresAt) {
+			delete(s.statuses, id)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInMemoryMessageStatusStore(t *testing.T) {
+	store := NewInMemoryMessageStatusStore()
+	now := time.Now()
+	store.(*inMemoryMessageStatusStore).now = func() time.Time { return now }
+
+	status, err := store.Get("m1")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	require.NoError(t, store.Put([]MessageStatus{{ID: "m1", State: MessageQueued}, {ID: "m2", State: MessageQueued}}, time.Minute))
+	partition, offset := int32(0), int64(7)
+	require.NoError(t, store.Put([]MessageStatus{{ID: "m1", State: MessageDelivered, Partition: &partition, Offset: &offset}}, time.Hour))
+	status, err = store.Get("m1")
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, MessageDelivered, status.State)
+	assert.Equal(t, int64(7), *status.Offset)
+
+	// statuses expire after their own ttl
+	now = now.Add(2 * time.Minute)
+	status, err = store.Get("m2")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+	status, err = store.Get("m1")
+	require.NoError(t, err)
+	assert.NotNil(t, status)
+
+	require.NoError(t, store.Put(nil, time.Minute))
+	assert.Len(t, store.(*inMemoryMessageStatusStore).statuses, 1, "expired statuses should be swept")
+}
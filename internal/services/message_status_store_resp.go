@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"time"
+)
+
+// defaultMessageStatusKeyPrefix namespaces the message statuses in the shared store
+const defaultMessageStatusKeyPrefix = "msg-receiver:message:"
+
+type respMessageStatusStore struct {
+	client *resp.Client
+	prefix string
+}
+
+// NewRESPMessageStatusStore creates a message status store keeping the
+// statuses in a Redis compatible server, so a message accepted by one
+// replica can be looked up on another.
+// Params: client *resp.Client - the connection to the shared store
+// Params: prefix string - prepended to every key, "msg-receiver:message:" when empty
+func NewRESPMessageStatusStore(client *resp.Client, prefix string) MessageStatusStore {
+	if prefix == "" {
+		prefix = defaultMessageStatusKeyPrefix
+	}
+	return &respMessageStatusStore{client: client, prefix: prefix}
+}
+
+// Put stores statuses for ttl in one round trip
+// Params: statuses []MessageStatus - the statuses to store
+// Params: ttl time.Duration - how long they are kept
+func (s *respMessageStatusStore) Put(statuses []MessageStatus, ttl time.Duration) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+	commands := make([][]string, len(statuses))
+	for i, status := range statuses {
+		value, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		commands[i] = []string{"SET", s.prefix + status.ID, string(value), "PX", milliseconds(ttl)}
+	}
+	replies, err := s.client.Pipeline(context.Background(), commands...)
+	if err != nil {
+		return err
+	}
+	for i, reply := range replies {
+		if replyErr, ok := reply.(resp.Error); ok {
+			return fmt.Errorf("message status %s: %w", statuses[i].ID, replyErr)
+		}
+	}
+	return nil
+}
+
+// Get returns the status of a message, or nil when it is unknown
+// Params: id string - the message ID
+func (s *respMessageStatusStore) Get(id string) (*MessageStatus, error) {
+	reply, err := s.client.Do(context.Background(), "GET", s.prefix+id)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(resp.Error); ok {
+		return nil, fmt.Errorf("message status %s: %w", id, replyErr)
+	}
+	raw, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("message status %s: %w", id, resp.ErrMalformed)
+	}
+	var status MessageStatus
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, fmt.Errorf("message status %s: %w", id, err)
+	}
+	return &status, nil
+}
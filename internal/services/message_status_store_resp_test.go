@@ -0,0 +1,49 @@
+package services
+
+import (
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRESPMessageStatusStore(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client, err := resp.NewClient(resp.ClientConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// replicas sharing the server see each other's statuses
+	first, second := NewRESPMessageStatusStore(client, ""), NewRESPMessageStatusStore(client, "")
+	acceptedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	queued := []MessageStatus{
+		{ID: "m1", Tenant: "acme", State: MessageQueued, Topic: "orders", AcceptedAt: acceptedAt, UpdatedAt: acceptedAt},
+		{ID: "m2", Tenant: "acme", State: MessageQueued, Topic: "orders", AcceptedAt: acceptedAt, UpdatedAt: acceptedAt},
+	}
+	require.NoError(t, first.Put(queued, time.Hour))
+	status, err := second.Get("m2")
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, queued[1], *status)
+	assert.Greater(t, server.TTL("msg-receiver:message:m1"), 59*time.Minute)
+
+	failed := MessageStatus{ID: "m1", Tenant: "acme", State: MessageFailed, Topic: "orders", Reason: "unknown topic", AcceptedAt: acceptedAt, UpdatedAt: acceptedAt.Add(time.Second)}
+	require.NoError(t, second.Put([]MessageStatus{failed}, time.Hour))
+	status, err = first.Get("m1")
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, failed, *status)
+
+	server.FastForward(time.Hour)
+	status, err = first.Get("m1")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	server.Close()
+	_, err = first.Get("m1")
+	assert.Error(t, err)
+	assert.Error(t, first.Put(queued, time.Hour))
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// maxTopicLength is the longest topic name accepted by Kafka
+const maxTopicLength = 249
+
+var topicPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// Producer is a contract for producing messages to a topic
+//
+//counterfeiter:generate . Producer
+type Producer interface {
+	Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error)
+	Close() error
+}
+
+// TopicChecker is implemented by the producers that can tell whether a topic
+// exists without producing to it
+type TopicChecker interface {
+	CheckTopic(ctx context.Context, topic string) error
+}
+
+// Header is a key/value pair attached to a message
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// DeliveryResult describes where a produced message has been stored
+type DeliveryResult struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ValidateTopic checks that a topic name is legal
+// Params: topic string - the topic name
+func ValidateTopic(topic string) error {
+	if topic == "" || len(topic) > maxTopicLength || topic == "." || topic == ".." {
+		return ErrInvalidTopic
+	}
+	if !topicPattern.MatchString(topic) {
+		return ErrInvalidTopic
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+)
+
+type authorizedProducer struct {
+	next Producer
+}
+
+// NewAuthorizedProducer creates a producer that only publishes to topics the
+// caller holds a "produce:<topic>" scope for. Scopes are read from the
+// context set by ContextWithScopes; calls without scopes are rejected.
+// Params: next Producer - the producer messages are handed to
+func NewAuthorizedProducer(next Producer) Producer {
+	return &authorizedProducer{
+		next: next,
+	}
+}
+
+// Produce checks the caller scopes and publishes the message
+// Params: ctx context.Context - the request context carrying the scopes
+// Params: topic string - the destination topic
+// Params: key []byte - the message key, may be nil
+// Params: headers []Header - the message headers
+// Params: value []byte - the message payload
+func (p *authorizedProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	if err := AuthorizeTopic(ctx, topic); err != nil {
+		return nil, err
+	}
+	return p.next.Produce(ctx, topic, key, headers, value)
+}
+
+// AuthorizeTopic checks that the caller scopes allow producing to topic,
+// for callers that accept a message before producing it
+// Params: ctx context.Context - the request context carrying the scopes
+// Params: topic string - the destination topic
+func AuthorizeTopic(ctx context.Context, topic string) error {
+	if !ScopesAllow(ScopesFromContext(ctx), ScopeProduce+":"+topic) {
+		return ErrTopicForbidden
+	}
+	return nil
+}
+
+// Close closes the underlying producer
+func (p *authorizedProducer) Close() error {
+	return p.next.Close()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAuthorizedProducer_Produce(t *testing.T) {
+	next := NewInMemoryProducer()
+	producer := NewAuthorizedProducer(next)
+	ctx := ContextWithScopes(context.Background(), []string{"produce:orders", "produce:payments.*"})
+
+	_, err := producer.Produce(ctx, "orders", nil, nil, []byte("1"))
+	require.NoError(t, err)
+	_, err = producer.Produce(ctx, "payments.eu", nil, nil, []byte("2"))
+	require.NoError(t, err)
+
+	_, err = producer.Produce(ctx, "billing", nil, nil, []byte("3"))
+	assert.ErrorIs(t, err, ErrTopicForbidden)
+	_, err = producer.Produce(context.Background(), "orders", nil, nil, []byte("4"))
+	assert.ErrorIs(t, err, ErrTopicForbidden, "calls without scopes should be rejected")
+
+	memory := next.(*inMemoryProducer)
+	assert.Len(t, memory.messages("orders"), 1)
+	assert.Len(t, memory.messages("payments.eu"), 1)
+	assert.Empty(t, memory.messages("billing"))
+
+	require.NoError(t, producer.Close())
+	_, err = producer.Produce(ctx, "orders", nil, nil, []byte("5"))
+	assert.ErrorIs(t, err, ErrProducerClosed)
+}
+
+func TestAuthorizeTopic(t *testing.T) {
+	ctx := ContextWithScopes(context.Background(), []string{"produce:orders"})
+	assert.NoError(t, AuthorizeTopic(ctx, "orders"))
+	assert.ErrorIs(t, AuthorizeTopic(ctx, "billing"), ErrTopicForbidden)
+	assert.ErrorIs(t, AuthorizeTopic(context.Background(), "orders"), ErrTopicForbidden)
+}
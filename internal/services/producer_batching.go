@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka"
+	"slices"
+	"sync"
+	"time"
+)
+
+// recordOverhead is roughly what a record adds to its key, value and
+// headers in a batch
+const recordOverhead = 20
+
+// defaultLookupTimeout bounds a partition lookup when the producer config
+// sets no timeout, as kafka.NewProducer does
+const defaultLookupTimeout = 10 * time.Second
+
+// BatchConfig tunes NewBatchingKafkaProducer
+type BatchConfig struct {
+	// Linger is how long a batch waits for more messages after its first
+	// one; a batch is sent as soon as its partition is free when zero, and
+	// fills while the previous one is in flight
+	Linger time.Duration
+	// MaxBatchBytes bounds the keys, values and headers of a batch before
+	// compression, 512 KiB when zero, which keeps batches under the 1 MB
+	// brokers accept by default; a larger message is sent on its own
+	MaxBatchBytes int
+	// MaxInFlight bounds the batches being sent at once over every
+	// partition, 5 when zero; a partition sends one batch at a time
+	MaxInFlight int
+	// IdleTimeout stops the goroutine of a partition that had no message for
+	// that long, one minute when zero; it starts again with the next message
+	IdleTimeout time.Duration
+}
+
+type batchingProducer struct {
+	producer *kafka.Producer
+	cfg      BatchConfig
+	inFlight chan struct{}
+	// lookupTimeout bounds the metadata requests of a partition lookup
+	lookupTimeout time.Duration
+	wg            sync.WaitGroup
+
+	mu         sync.Mutex
+	closed     bool
+	partitions map[batchKey]*partitionBatcher
+	sticky     map[string]stickyPartition
+}
+
+type batchKey struct {
+	topic     string
+	partition int32
+}
+
+// stickyPartition is the partition the keyless messages of a topic join
+// until the batch they join is sent, so they fill batches rather than
+// being spread one per partition
+type stickyPartition struct {
+	partition int32
+	batch     uint64
+}
+
+// pendingMessage is a message waiting in a batch for its outcome
+type pendingMessage struct {
+	message kafka.Message
+	size    int
+	done    chan batchOutcome
+}
+
+type batchOutcome struct {
+	result *DeliveryResult
+	err    error
+}
+
+// partitionBatcher gathers the messages of one partition, which its
+// goroutine sends one batch at a time
+type partitionBatcher struct {
+	key  batchKey
+	wake chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	pending []*pendingMessage
+	bytes   int
+	// since is when the first pending message arrived
+	since time.Time
+	// batches counts the batches taken so far
+	batches uint64
+}
+
+// NewBatchingKafkaProducer creates a producer that writes to a Kafka
+// cluster in record batches, gathering the messages sent concurrently to
+// each partition
+// Params: cfg kafka.ProducerConfig - brokers, client ID, acks, retries and compression
+// Params: batch BatchConfig - the linger, batch size and batches in flight
+func NewBatchingKafkaProducer(cfg kafka.ProducerConfig, batch BatchConfig) (Producer, error) {
+	producer, err := kafka.NewProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if batch.MaxBatchBytes <= 0 {
+		batch.MaxBatchBytes = 512 << 10
+	}
+	if batch.MaxInFlight <= 0 {
+		batch.MaxInFlight = 5
+	}
+	if batch.IdleTimeout <= 0 {
+		batch.IdleTimeout = time.Minute
+	}
+	lookupTimeout := cfg.Timeout
+	if lookupTimeout <= 0 {
+		lookupTimeout = defaultLookupTimeout
+	}
+	return &batchingProducer{
+		producer:      producer,
+		cfg:           batch,
+		inFlight:      make(chan struct{}, batch.MaxInFlight),
+		lookupTimeout: lookupTimeout,
+		partitions:    make(map[batchKey]*partitionBatcher),
+		sticky:        make(map[string]stickyPartition),
+	}, nil
+}
+
+// Produce adds a message to the batch of its partition and waits until the
+// batch is written. A message still waiting when ctx is done is left out of
+// its batch.
+// Params: ctx context.Context - the request context
+// Params: topic string - the destination topic
+// Params: key []byte - the message key, used to pick the partition
+// Params: headers []Header - the message headers
+// Params: value []byte - the message payload
+func (p *batchingProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	if err := ValidateTopic(topic); err != nil {
+		return nil, err
+	}
+	pending := &pendingMessage{
+		message: kafka.Message{Key: key, Value: value, Timestamp: time.Now()},
+		size:    len(key) + len(value) + recordOverhead,
+		done:    make(chan batchOutcome, 1),
+	}
+	for _, h := range headers {
+		pending.message.Headers = append(pending.message.Headers, kafka.RecordHeader{Key: h.Key, Value: h.Value})
+		pending.size += len(h.Key) + len(h.Value)
+	}
+	// a batcher stopped for being idle refuses the message, which goes to
+	// the one started in its place; once closed, batcherFor fails
+	var batcher *partitionBatcher
+	for {
+		var err error
+		if batcher, err = p.batcherFor(topic, key); err != nil {
+			return nil, translateKafkaError(err)
+		}
+		if batcher.add(pending) {
+			break
+		}
+	}
+
+	select {
+	case outcome := <-pending.done:
+		return outcome.result, outcome.err
+	case <-ctx.Done():
+		// a message already taken into a batch may still be written
+		batcher.remove(pending)
+		return nil, ctx.Err()
+	}
+}
+
+// CheckTopic fails with ErrUnknownTopic when the brokers do not know topic
+// Params: ctx context.Context - bounds the metadata request
+// Params: topic string - the topic name
+func (p *batchingProducer) CheckTopic(ctx context.Context, topic string) error {
+	if err := ValidateTopic(topic); err != nil {
+		return err
+	}
+	if _, err := p.producer.Partition(ctx, topic, nil); err != nil {
+		return translateKafkaError(err)
+	}
+	return nil
+}
+
+// Close sends the pending batches and closes the broker connections
+func (p *batchingProducer) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	for _, batcher := range p.partitions {
+		batcher.close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return p.producer.Close()
+}
+
+// batcherFor returns the batcher of the partition a message goes to,
+// starting it on first use. The partition lookup does not use the request
+// context, like the batches it feeds.
+func (p *batchingProducer) batcherFor(topic string, key []byte) (*partitionBatcher, error) {
+	if key == nil {
+		p.mu.Lock()
+		sticky, ok := p.sticky[topic]
+		batcher := p.partitions[batchKey{topic: topic, partition: sticky.partition}]
+		p.mu.Unlock()
+		if ok && batcher != nil && batcher.taken() == sticky.batch {
+			return batcher, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.lookupTimeout)
+	partition, err := p.producer.Partition(ctx, topic, key)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, kafka.ErrClosed
+	}
+	k := batchKey{topic: topic, partition: partition}
+	batcher, ok := p.partitions[k]
+	if !ok {
+		batcher = &partitionBatcher{key: k, wake: make(chan struct{}, 1)}
+		p.partitions[k] = batcher
+		p.wg.Add(1)
+		go p.run(batcher)
+	}
+	if key == nil {
+		p.sticky[topic] = stickyPartition{partition: partition, batch: batcher.taken()}
+	}
+	return batcher, nil
+}
+
+// run sends the batches of a partition until it is closed and drained, or
+// stopped for being idle
+func (p *batchingProducer) run(batcher *partitionBatcher) {
+	defer p.wg.Done()
+	for {
+		batch, ok := batcher.next(p.cfg)
+		if !ok {
+			return
+		}
+		if batch == nil {
+			if p.retire(batcher) {
+				return
+			}
+			continue
+		}
+		p.send(batcher.key, batch)
+	}
+}
+
+// retire closes an idle batcher and forgets it, unless a message arrived
+// in the meantime
+func (p *batchingProducer) retire(batcher *partitionBatcher) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	if len(batcher.pending) > 0 {
+		return false
+	}
+	batcher.closed = true
+	delete(p.partitions, batcher.key)
+	if sticky, ok := p.sticky[batcher.key.topic]; ok && sticky.partition == batcher.key.partition {
+		delete(p.sticky, batcher.key.topic)
+	}
+	return true
+}
+
+// send writes a batch once fewer than MaxInFlight batches are in flight,
+// and hands every message its outcome
+func (p *batchingProducer) send(key batchKey, batch []*pendingMessage) {
+	p.inFlight <- struct{}{}
+	defer func() { <-p.inFlight }()
+
+	messages := make([]kafka.Message, len(batch))
+	for i, pending := range batch {
+		messages[i] = pending.message
+	}
+	// the producer timeout and retries bound the call, which the waiting
+	// callers cannot cancel for each other
+	results, err := p.producer.ProduceBatch(context.Background(), key.topic, key.partition, messages)
+	if err != nil {
+		err = translateKafkaError(err)
+	}
+	for i, pending := range batch {
+		if err != nil {
+			pending.done <- batchOutcome{err: err}
+			continue
+		}
+		pending.done <- batchOutcome{result: &DeliveryResult{
+			Topic:     results[i].Topic,
+			Partition: results[i].Partition,
+			Offset:    results[i].Offset,
+			Timestamp: results[i].Timestamp,
+		}}
+	}
+}
+
+// add appends a message to the pending batch, failing once closed
+func (b *partitionBatcher) add(pending *pendingMessage) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	if len(b.pending) == 0 {
+		b.since = time.Now()
+	}
+	b.pending = append(b.pending, pending)
+	b.bytes += pending.size
+	b.signal()
+	return true
+}
+
+// remove takes a message out of the pending batch, if it is still there
+func (b *partitionBatcher) remove(pending *pendingMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := slices.Index(b.pending, pending); i >= 0 {
+		b.pending = slices.Delete(b.pending, i, i+1)
+		b.bytes -= pending.size
+	}
+}
+
+// close makes the goroutine send what is pending and stop
+func (b *partitionBatcher) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.signal()
+}
+
+// taken returns the number of batches taken so far
+func (b *partitionBatcher) taken() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batches
+}
+
+// signal wakes the goroutine up, without blocking when it is awake
+func (b *partitionBatcher) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next waits until a batch is full, has lingered long enough or the
+// batcher is closed, and takes it; it returns false once closed and drained,
+// and no batch once nothing arrived for IdleTimeout
+func (b *partitionBatcher) next(cfg BatchConfig) ([]*pendingMessage, bool) {
+	for {
+		b.mu.Lock()
+		wait := time.Duration(-1)
+		switch {
+		case len(b.pending) == 0 && b.closed:
+			b.mu.Unlock()
+			return nil, false
+		case len(b.pending) == 0:
+		case b.closed || b.bytes >= cfg.MaxBatchBytes:
+			wait = 0
+		default:
+			wait = max(cfg.Linger-time.Since(b.since), 0)
+		}
+		if wait == 0 {
+			batch := b.take(cfg.MaxBatchBytes)
+			b.mu.Unlock()
+			return batch, true
+		}
+		b.mu.Unlock()
+
+		if wait < 0 {
+			idle := time.NewTimer(cfg.IdleTimeout)
+			select {
+			case <-b.wake:
+				idle.Stop()
+				continue
+			case <-idle.C:
+				return nil, true
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-b.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// take removes the oldest pending messages fitting in maxBytes, at least
+// one; b.mu must be held
+func (b *partitionBatcher) take(maxBytes int) []*pendingMessage {
+	n, size := 1, b.pending[0].size
+	for n < len(b.pending) && size+b.pending[n].size <= maxBytes {
+		size += b.pending[n].size
+		n++
+	}
+	batch := slices.Clone(b.pending[:n])
+	b.pending = slices.Delete(b.pending, 0, n)
+	b.bytes -= size
+	b.since = time.Now()
+	b.batches++
+	return batch
+}
+
+// translateKafkaError maps broker errors the handlers care about onto
+// service errors, keeping the original error in the chain
+func translateKafkaError(err error) error {
+	switch {
+	case errors.Is(err, kafka.ErrClosed):
+		return ErrProducerClosed
+	case errors.Is(err, kafka.ErrUnknownTopicOrPartition):
+		return fmt.Errorf("%w: %w", ErrUnknownTopic, err)
+	case errors.Is(err, kafka.ErrInvalidTopic):
+		return fmt.Errorf("%w: %w", ErrInvalidTopic, err)
+	case errors.Is(err, kafka.ErrMessageTooLarge), errors.Is(err, kafka.ErrRecordListTooLarge):
+		return fmt.Errorf("%w: %w", ErrMessageTooLarge, err)
+	case kafka.IsRetriable(err):
+		return fmt.Errorf("%w: %w", ErrBrokerUnavailable, err)
+	}
+	return err
+}
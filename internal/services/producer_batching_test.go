@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka"
+	"github.com/nathaliaguayos/msg-receiver/internal/kafka/kafkatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBatchingProducer(t testing.TB, broker *kafkatest.Broker, compression kafka.Compression, batch BatchConfig) Producer {
+	t.Helper()
+	producer, err := NewBatchingKafkaProducer(kafka.ProducerConfig{
+		Brokers:     []string{broker.Addr()},
+		Acks:        kafka.AcksAll,
+		Idempotent:  true,
+		Timeout:     5 * time.Second,
+		Compression: compression,
+	}, batch)
+	require.NoError(t, err)
+	return producer
+}
+
+// produceConcurrently sends n messages at once, keyed by key(i) when set
+func produceConcurrently(producer Producer, n int, key func(i int) []byte) ([]*DeliveryResult, []error) {
+	results, errs := make([]*DeliveryResult, n), make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var k []byte
+			if key != nil {
+				k = key(i)
+			}
+			results[i], errs[i] = producer.Produce(context.Background(), "orders", k, []Header{{Key: "n", Value: []byte(fmt.Sprint(i))}}, []byte(fmt.Sprintf("value-%03d", i)))
+		}(i)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestNewBatchingKafkaProducer(t *testing.T) {
+	_, err := NewBatchingKafkaProducer(kafka.ProducerConfig{}, BatchConfig{})
+	assert.Error(t, err)
+
+	producer, err := NewBatchingKafkaProducer(kafka.ProducerConfig{Brokers: []string{"localhost:9092"}}, BatchConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 512<<10, producer.(*batchingProducer).cfg.MaxBatchBytes)
+	assert.Equal(t, 5, cap(producer.(*batchingProducer).inFlight))
+}
+
+func TestBatchingProducer_Produce(t *testing.T) {
+	testCases := []struct {
+		name        string
+		batch       BatchConfig
+		compression kafka.Compression
+		key         func(i int) []byte
+		assertion   func(t *testing.T, broker *kafkatest.Broker)
+	}{
+		{
+			name:        "should gather the messages of each partition into compressed batches",
+			batch:       BatchConfig{Linger: 50 * time.Millisecond},
+			compression: kafka.CompressionLZ4,
+			key:         func(i int) []byte { return []byte(fmt.Sprint(i % 3)) },
+			assertion: func(t *testing.T, broker *kafkatest.Broker) {
+				batches := 0
+				for partition := int32(0); partition < 3; partition++ {
+					for _, batch := range broker.Batches("orders", partition) {
+						assert.Equal(t, kafka.CompressionLZ4, batch.Compression)
+						batches++
+					}
+				}
+				assert.Less(t, batches, 20)
+			},
+		}, {
+			name:  "should keep batches under the maximum size",
+			batch: BatchConfig{Linger: 50 * time.Millisecond, MaxBatchBytes: 3 * (recordOverhead + 11)},
+			key:   func(int) []byte { return []byte("k") },
+			assertion: func(t *testing.T, broker *kafkatest.Broker) {
+				for partition := int32(0); partition < 3; partition++ {
+					for _, batch := range broker.Batches("orders", partition) {
+						assert.LessOrEqual(t, len(batch.Records), 2)
+					}
+				}
+			},
+		}, {
+			name:  "should stick keyless messages to a partition until their batch is sent",
+			batch: BatchConfig{Linger: 50 * time.Millisecond},
+			assertion: func(t *testing.T, broker *kafkatest.Broker) {
+				batches := 0
+				for partition := int32(0); partition < 3; partition++ {
+					batches += len(broker.Batches("orders", partition))
+				}
+				assert.Less(t, batches, 20)
+			},
+		}, {
+			name:  "should send each message at once without linger",
+			batch: BatchConfig{MaxInFlight: 1},
+			key:   func(i int) []byte { return []byte(fmt.Sprint(i)) },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := kafkatest.NewBroker()
+			defer broker.Close()
+			broker.CreateTopic("orders", 3)
+			producer := newBatchingProducer(t, broker, tc.compression, tc.batch)
+			defer producer.Close()
+
+			results, errs := produceConcurrently(producer, 100, tc.key)
+
+			// every message is written once, where its result says
+			records := 0
+			for partition := int32(0); partition < 3; partition++ {
+				records += len(broker.Records("orders", partition))
+			}
+			assert.Equal(t, 100, records)
+			for i, result := range results {
+				require.NoError(t, errs[i])
+				written := broker.Records("orders", result.Partition)
+				require.Less(t, result.Offset, int64(len(written)))
+				assert.Equal(t, fmt.Sprintf("value-%03d", i), string(written[result.Offset].Value))
+				assert.Equal(t, []kafka.RecordHeader{{Key: "n", Value: []byte(fmt.Sprint(i))}}, written[result.Offset].Headers)
+				if tc.key != nil {
+					assert.Equal(t, tc.key(i), written[result.Offset].Key)
+				}
+			}
+			if tc.assertion != nil {
+				tc.assertion(t, broker)
+			}
+		})
+	}
+}
+
+func TestBatchingProducer_Errors(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 1)
+	producer := newBatchingProducer(t, broker, kafka.CompressionNone, BatchConfig{Linger: 20 * time.Millisecond})
+	defer producer.Close()
+
+	_, err := producer.Produce(context.Background(), "orders/eu", nil, nil, []byte("v"))
+	assert.ErrorIs(t, err, ErrInvalidTopic)
+	_, err = producer.Produce(context.Background(), "missing", nil, nil, []byte("v"))
+	assert.ErrorIs(t, err, ErrUnknownTopic)
+
+	// the whole batch fails together
+	broker.InjectError("orders", 0, kafka.ErrMessageTooLarge)
+	_, errs := produceConcurrently(producer, 5, nil)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrMessageTooLarge)
+	}
+	assert.Empty(t, broker.Records("orders", 0))
+
+	broker.InjectError("orders", 0, kafka.ErrNotEnoughReplicas)
+	_, err = producer.Produce(context.Background(), "orders", nil, nil, []byte("v"))
+	assert.ErrorIs(t, err, ErrBrokerUnavailable)
+	assert.ErrorIs(t, err, kafka.ErrNotEnoughReplicas)
+	assert.True(t, Retriable(err))
+}
+
+func TestBatchingProducer_CheckTopic(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 1)
+	producer := newBatchingProducer(t, broker, kafka.CompressionNone, BatchConfig{})
+	defer producer.Close()
+	checker := producer.(TopicChecker)
+
+	assert.NoError(t, checker.CheckTopic(context.Background(), "orders"))
+	assert.ErrorIs(t, checker.CheckTopic(context.Background(), "missing"), ErrUnknownTopic)
+	assert.ErrorIs(t, checker.CheckTopic(context.Background(), "orders/eu"), ErrInvalidTopic)
+	assert.Empty(t, broker.Records("orders", 0))
+}
+
+func TestBatchingProducer_Cancel(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 1)
+	producer := newBatchingProducer(t, broker, kafka.CompressionNone, BatchConfig{Linger: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := producer.Produce(ctx, "orders", nil, nil, []byte("abandoned"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the abandoned message is left out of the batch sent on close
+	done := make(chan *DeliveryResult)
+	go func() {
+		result, err := producer.Produce(context.Background(), "orders", nil, nil, []byte("pending"))
+		assert.NoError(t, err)
+		done <- result
+	}()
+	batcher := producer.(*batchingProducer).partitions[batchKey{topic: "orders"}]
+	require.Eventually(t, func() bool {
+		batcher.mu.Lock()
+		defer batcher.mu.Unlock()
+		return len(batcher.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, producer.Close())
+	result := <-done
+	assert.Equal(t, int64(0), result.Offset)
+	records := broker.Records("orders", 0)
+	require.Len(t, records, 1)
+	assert.Equal(t, []byte("pending"), records[0].Value)
+
+	_, err = producer.Produce(context.Background(), "orders", nil, nil, []byte("late"))
+	assert.ErrorIs(t, err, ErrProducerClosed)
+}
+
+func TestBatchingProducer_IdlePartitions(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	defer broker.Close()
+	broker.CreateTopic("orders", 4)
+	producer := newBatchingProducer(t, broker, kafka.CompressionNone, BatchConfig{IdleTimeout: 20 * time.Millisecond})
+	defer producer.Close()
+	batching := producer.(*batchingProducer)
+	batchers := func() int {
+		batching.mu.Lock()
+		defer batching.mu.Unlock()
+		return len(batching.partitions)
+	}
+
+	_, errs := produceConcurrently(producer, 20, func(i int) []byte { return []byte(fmt.Sprint(i)) })
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Positive(t, batchers())
+
+	// the goroutines of idle partitions stop, and start again when needed
+	require.Eventually(t, func() bool { return batchers() == 0 }, time.Second, time.Millisecond)
+	result, err := producer.Produce(context.Background(), "orders", []byte("1"), nil, []byte("again"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("again"), broker.Records("orders", result.Partition)[result.Offset].Value)
+}
+
+// The benchmarks produce 1 KiB JSON messages from many concurrent callers,
+// like the handlers do, to a broker answering after a millisecond, like a
+// nearby cluster. Run them with
+// go test -run '^$' -bench BatchingProducer ./internal/services
+func BenchmarkBatchingProducer(b *testing.B) {
+	for _, compression := range []kafka.Compression{kafka.CompressionNone, kafka.CompressionGzip, kafka.CompressionSnappy, kafka.CompressionLZ4, kafka.CompressionZstd} {
+		b.Run(compression.String(), func(b *testing.B) {
+			broker := newBenchmarkBroker()
+			defer broker.Close()
+			producer := newBatchingProducer(b, broker, compression, BatchConfig{Linger: 5 * time.Millisecond})
+			defer producer.Close()
+			benchmarkProducer(b, producer)
+		})
+	}
+}
+
+func newBenchmarkBroker() *kafkatest.Broker {
+	broker := kafkatest.NewBroker()
+	broker.CreateTopic("orders", 3)
+	broker.SetLatency(time.Millisecond)
+	return broker
+}
+
+func benchmarkProducer(b *testing.B, producer Producer) {
+	value := bytes.Repeat([]byte(`{"order":12345,"customer":"acme","total":42.5}`), 22)
+	b.SetBytes(int64(len(value)))
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := producer.Produce(context.Background(), "orders", []byte(fmt.Sprint(i)), nil, value); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msgs/s")
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Message is a message stored by the in-memory producer
+type Message struct {
+	Topic     string
+	Key       []byte
+	Headers   []Header
+	Value     []byte
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+}
+
+type inMemoryProducer struct {
+	mu     sync.RWMutex
+	topics map[string][]Message
+	closed bool
+}
+
+// NewInMemoryProducer creates a producer that keeps every message in memory.
+// Each topic has a single partition and offsets start at zero.
+func NewInMemoryProducer() Producer {
+	return &inMemoryProducer{
+		topics: make(map[string][]Message),
+	}
+}
+
+// Produce appends a message to the topic log
+// Params: ctx context.Context - the request context
+// Params: topic string - the destination topic
+// Params: key []byte - the message key, may be nil
+// Params: headers []Header - the message headers
+// Params: value []byte - the message payload
+func (p *inMemoryProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := ValidateTopic(topic); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrProducerClosed
+	}
+
+	msg := Message{
+		Topic:     topic,
+		Key:       key,
+		Headers:   headers,
+		Value:     value,
+		Offset:    int64(len(p.topics[topic])),
+		Timestamp: time.Now(),
+	}
+	p.topics[topic] = append(p.topics[topic], msg)
+
+	return &DeliveryResult{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+	}, nil
+}
+
+// Close stops the producer; later calls to Produce fail
+func (p *inMemoryProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// messages returns a copy of the messages stored for a topic
+func (p *inMemoryProducer) messages(topic string) []Message {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Message(nil), p.topics[topic]...)
+}
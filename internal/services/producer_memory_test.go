@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewInMemoryProducer(t *testing.T) {
+	producer := NewInMemoryProducer()
+	assert.NotNil(t, producer)
+}
+
+func TestInMemoryProducer_Produce(t *testing.T) {
+	testCases := []struct {
+		name      string
+		ctx       func() context.Context
+		topic     string
+		setup     func(Producer)
+		assertion func(*testing.T, Producer, *DeliveryResult, error)
+	}{
+		{
+			name:  "should store messages with increasing offsets",
+			ctx:   context.Background,
+			topic: "orders",
+			setup: func(p Producer) {
+				_, err := p.Produce(context.Background(), "orders", nil, nil, []byte("first"))
+				require.NoError(t, err)
+			},
+			assertion: func(t *testing.T, p Producer, result *DeliveryResult, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, "orders", result.Topic)
+				assert.Equal(t, int64(1), result.Offset)
+				assert.False(t, result.Timestamp.IsZero())
+
+				messages := p.(*inMemoryProducer).messages("orders")
+				require.Len(t, messages, 2)
+				assert.Equal(t, []byte("key"), messages[1].Key)
+				assert.Equal(t, []Header{{Key: "h", Value: []byte("v")}}, messages[1].Headers)
+				assert.Equal(t, []byte("value"), messages[1].Value)
+			},
+		}, {
+			name:  "should fail with an invalid topic",
+			ctx:   context.Background,
+			topic: "orders/eu",
+			setup: func(Producer) {},
+			assertion: func(t *testing.T, p Producer, result *DeliveryResult, err error) {
+				assert.ErrorIs(t, err, ErrInvalidTopic)
+				assert.Nil(t, result)
+			},
+		}, {
+			name: "should fail when the context is done",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			topic: "orders",
+			setup: func(Producer) {},
+			assertion: func(t *testing.T, p Producer, result *DeliveryResult, err error) {
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.Empty(t, p.(*inMemoryProducer).messages("orders"))
+			},
+		}, {
+			name:  "should fail once the producer is closed",
+			ctx:   context.Background,
+			topic: "orders",
+			setup: func(p Producer) {
+				require.NoError(t, p.Close())
+			},
+			assertion: func(t *testing.T, p Producer, result *DeliveryResult, err error) {
+				assert.ErrorIs(t, err, ErrProducerClosed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := NewInMemoryProducer()
+			tc.setup(producer)
+			result, err := producer.Produce(tc.ctx(), tc.topic, []byte("key"), []Header{{Key: "h", Value: []byte("v")}}, []byte("value"))
+			tc.assertion(t, producer, result, err)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+)
+
+type circuitBreakerProducer struct {
+	next    Producer
+	breaker CircuitBreaker
+}
+
+// NewCircuitBreakerProducer creates a producer that fails at once with a
+// *CircuitOpenError while breaker is open, instead of waiting on a failing
+// broker
+// Params: next Producer - the producer messages are handed to
+// Params: breaker CircuitBreaker - the breaker, may be shared between producers
+func NewCircuitBreakerProducer(next Producer, breaker CircuitBreaker) Producer {
+	return &circuitBreakerProducer{next: next, breaker: breaker}
+}
+
+// Produce publishes the message unless the breaker is open
+// Params: ctx context.Context - the request context
+// Params: topic string - the destination topic
+// Params: key []byte - the message key, may be nil
+// Params: headers []Header - the message headers
+// Params: value []byte - the message payload
+func (p *circuitBreakerProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	call, err := p.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.next.Produce(ctx, topic, key, headers, value)
+	p.breaker.Record(call, err)
+	return result, err
+}
+
+// Close closes the underlying producer
+func (p *circuitBreakerProducer) Close() error {
+	return p.next.Close()
+}
+
+type retryingProducer struct {
+	next   Producer
+	policy RetryPolicy
+}
+
+// NewRetryingProducer creates a producer that produces a message again
+// after a retriable error, following policy. Unless policy says otherwise,
+// ambiguous errors are not retried: the Kafka producer already retries
+// broker failures with the sequence numbers that keep them from being
+// written twice, and a message it gives up on may have been written.
+// Params: next Producer - the producer messages are handed to
+// Params: policy RetryPolicy - the attempts, delays and overall time
+func NewRetryingProducer(next Producer, policy RetryPolicy) Producer {
+	if policy.IsRetriable == nil {
+		policy.IsRetriable = func(err error) bool {
+			return Retriable(err) && !Ambiguous(err)
+		}
+	}
+	return &retryingProducer{next: next, policy: policy}
+}
+
+// Produce publishes the message, retrying until the policy gives up or ctx is done
+// Params: ctx context.Context - the request context
+// Params: topic string - the destination topic
+// Params: key []byte - the message key, may be nil
+// Params: headers []Header - the message headers
+// Params: value []byte - the message payload
+func (p *retryingProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	var result *DeliveryResult
+	_, err := p.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = p.next.Produce(ctx, topic, key, headers, value)
+		return err
+	}, nil)
+	return result, err
+}
+
+// Close closes the underlying producer
+func (p *retryingProducer) Close() error {
+	return p.next.Close()
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerProducer_Produce(t *testing.T) {
+	next := &flakyProducer{Producer: NewInMemoryProducer(), failures: 2, err: ErrBrokerUnavailable}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 2})
+	producer := NewCircuitBreakerProducer(next, breaker)
+
+	for i := 0; i < 2; i++ {
+		_, err := producer.Produce(context.Background(), "orders", nil, nil, []byte("1"))
+		assert.ErrorIs(t, err, ErrBrokerUnavailable)
+	}
+	// the broker is not called while the breaker is open
+	_, err := producer.Produce(context.Background(), "orders", nil, nil, []byte("2"))
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Empty(t, next.Producer.(*inMemoryProducer).messages("orders"))
+	assert.Equal(t, CircuitOpen, breaker.State().State)
+	require.NoError(t, producer.Close())
+}
+
+func TestRetryingProducer_Produce(t *testing.T) {
+	testCases := []struct {
+		name             string
+		failures         int
+		err              error
+		expectedErr      error
+		expectedMessages int
+	}{
+		{name: "should retry while the breaker is open", failures: 2, err: ErrCircuitOpen, expectedMessages: 1},
+		{name: "should give up after the max attempts", failures: 3, err: ErrCircuitOpen, expectedErr: ErrCircuitOpen},
+		{name: "should not retry a broker failure the message may have survived", failures: 1, err: ErrBrokerUnavailable, expectedErr: ErrBrokerUnavailable},
+		{name: "should not retry a timeout", failures: 1, err: context.DeadlineExceeded, expectedErr: context.DeadlineExceeded},
+		{name: "should not retry an unknown topic", failures: 1, err: ErrUnknownTopic, expectedErr: ErrUnknownTopic},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &flakyProducer{Producer: NewInMemoryProducer(), failures: tc.failures, err: tc.err}
+			producer := NewRetryingProducer(next, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+			result, err := producer.Produce(context.Background(), "orders", nil, nil, []byte("1"))
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr == nil {
+				require.NotNil(t, result)
+				assert.Equal(t, "orders", result.Topic)
+			}
+			assert.Len(t, next.Producer.(*inMemoryProducer).messages("orders"), tc.expectedMessages)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateTopic(t *testing.T) {
+	testCases := []struct {
+		name  string
+		topic string
+		err   error
+	}{
+		{name: "should accept a simple topic", topic: "orders"},
+		{name: "should accept dots, dashes and underscores", topic: "payments.v1_eu-west"},
+		{name: "should reject an empty topic", topic: "", err: ErrInvalidTopic},
+		{name: "should reject '.'", topic: ".", err: ErrInvalidTopic},
+		{name: "should reject '..'", topic: "..", err: ErrInvalidTopic},
+		{name: "should reject illegal characters", topic: "orders/eu", err: ErrInvalidTopic},
+		{name: "should reject topics that are too long", topic: strings.Repeat("a", maxTopicLength+1), err: ErrInvalidTopic},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, ValidateTopic(tc.topic))
+		})
+	}
+}
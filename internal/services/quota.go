@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Quota periods
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+)
+
+// defaultSoftLimit is the share of a limit past which usage is reported
+const defaultSoftLimit = 0.8
+
+// quotaRetention keeps counters past the end of their period so usage
+// stays visible for a while after the period rolls over
+const quotaRetention = 24 * time.Hour
+
+// QuotaService is a contract for the daily and monthly message quotas of tenants
+//
+//counterfeiter:generate . QuotaService
+type QuotaService interface {
+	// Reserve counts messages against the tenant quotas, failing with a
+	// *QuotaExceededError when a hard limit would be passed
+	Reserve(tenant string, messages, bytes int64) (*QuotaReservation, error)
+	// Release gives back a reservation whose messages were not produced
+	Release(reservation *QuotaReservation) error
+	Usage(tenant string) (*QuotaUsage, error)
+	// Reset clears the usage of the current periods of a tenant
+	Reset(tenant string) error
+}
+
+// QuotaStore is a contract for the usage counters of the quotas
+//
+//counterfeiter:generate . QuotaStore
+type QuotaStore interface {
+	// Add adds delta to a counter and returns its new value; the counter can
+	// be dropped once expiresAt has passed
+	Add(key string, delta QuotaCounter, expiresAt time.Time) (QuotaCounter, error)
+	Get(key string) (QuotaCounter, error)
+}
+
+// QuotaCounter is the usage of one tenant during one period
+type QuotaCounter struct {
+	Messages int64 `json:"messages"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// QuotaLimits are the hard limits of a tenant; zero means unlimited
+type QuotaLimits struct {
+	DailyMessages   int64 `json:"daily_messages"`
+	DailyBytes      int64 `json:"daily_bytes"`
+	MonthlyMessages int64 `json:"monthly_messages"`
+	MonthlyBytes    int64 `json:"monthly_bytes"`
+}
+
+// QuotaConfig configures NewQuotaService
+type QuotaConfig struct {
+	// Default applies to tenants without limits of their own
+	Default QuotaLimits `json:"default"`
+	// Tenants override the default for specific tenants
+	Tenants map[string]QuotaLimits `json:"tenants"`
+	// SoftLimit is the share of a limit, between 0 and 1, past which usage
+	// is reported as a warning; 0.8 when zero
+	SoftLimit float64 `json:"soft_limit"`
+}
+
+// QuotaPeriodUsage is the usage of a tenant during the current period
+type QuotaPeriodUsage struct {
+	// Period identifies the period, e.g. "2024-05-31" or "2024-05"
+	Period        string    `json:"period"`
+	Messages      int64     `json:"messages"`
+	Bytes         int64     `json:"bytes"`
+	MessagesLimit int64     `json:"messages_limit,omitempty"`
+	BytesLimit    int64     `json:"bytes_limit,omitempty"`
+	ResetsAt      time.Time `json:"resets_at"`
+}
+
+// QuotaUsage is the usage of a tenant during the current day and month
+type QuotaUsage struct {
+	Tenant  string           `json:"tenant"`
+	Daily   QuotaPeriodUsage `json:"daily"`
+	Monthly QuotaPeriodUsage `json:"monthly"`
+	// Warnings list the limits past the soft limit, e.g. "daily messages at 85%"
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// QuotaReservation is a successful Reserve call
+type QuotaReservation struct {
+	Usage QuotaUsage
+	keys  []string
+	// expiresAt holds the expiry of each key, so a release does not cut
+	// short the retention of the periods
+	expiresAt []time.Time
+	delta     QuotaCounter
+}
+
+// QuotaExceededError is returned when a request would pass a hard limit
+type QuotaExceededError struct {
+	// Period is QuotaDaily or QuotaMonthly
+	Period string
+	// Metric is "messages" or "bytes"
+	Metric   string
+	Limit    int64
+	ResetsAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s %s limit of %d reached", ErrQuotaExceeded, e.Period, e.Metric, e.Limit)
+}
+
+// Unwrap makes the error match ErrQuotaExceeded
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+type quotaService struct {
+	cfg   QuotaConfig
+	store QuotaStore
+	now   func() time.Time
+}
+
+// LoadQuotaConfig reads the quota limits from a JSON file of the form
+// {"default": {"daily_messages": 10000}, "tenants": {"acme": {"monthly_bytes": 1073741824}}}
+// Params: path string - the quotas file
+func LoadQuotaConfig(path string) (QuotaConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return QuotaConfig{}, err
+	}
+	var file QuotaConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return QuotaConfig{}, fmt.Errorf("invalid quotas file %s: %w", path, err)
+	}
+	if file.SoftLimit < 0 || file.SoftLimit > 1 {
+		return QuotaConfig{}, fmt.Errorf("invalid quotas file %s: soft_limit should be between 0 and 1", path)
+	}
+	if !file.Default.valid() {
+		return QuotaConfig{}, fmt.Errorf("invalid quotas file %s: default limits should not be negative", path)
+	}
+	for tenant, limits := range file.Tenants {
+		if tenant == "" || !limits.valid() {
+			return QuotaConfig{}, fmt.Errorf("invalid quotas file %s: tenant %q limits should not be negative", path, tenant)
+		}
+	}
+	return file, nil
+}
+
+// NewQuotaService creates a new quota service. Periods follow UTC days and months.
+// Params: cfg QuotaConfig - the limits of the tenants
+// Params: store QuotaStore - the usage counters
+func NewQuotaService(cfg QuotaConfig, store QuotaStore) QuotaService {
+	if cfg.SoftLimit <= 0 {
+		cfg.SoftLimit = defaultSoftLimit
+	}
+	return &quotaService{cfg: cfg, store: store, now: time.Now}
+}
+
+// Reserve adds the messages to the current day and month of the tenant,
+// and takes them back out when a hard limit is passed
+// Params: tenant string - the tenant producing the messages
+// Params: messages int64 - the number of messages
+// Params: bytes int64 - the size of the message values
+func (s *quotaService) Reserve(tenant string, messages, bytes int64) (*QuotaReservation, error) {
+	periods := s.periods(tenant)
+	delta := QuotaCounter{Messages: messages, Bytes: bytes}
+	counters := make([]QuotaCounter, len(periods))
+	for i, p := range periods {
+		counter, err := s.store.Add(p.key, delta, p.ResetsAt.Add(quotaRetention))
+		if err != nil {
+			s.rollback(periods[:i], delta)
+			return nil, err
+		}
+		counters[i] = counter
+	}
+
+	usage := s.usage(tenant, periods, counters)
+	// monthly limits are checked first as they take longer to reset
+	for _, name := range []string{QuotaMonthly, QuotaDaily} {
+		if err := usage.period(name).exceeded(name); err != nil {
+			s.rollback(periods, delta)
+			return nil, err
+		}
+	}
+
+	keys, expiresAt := make([]string, len(periods)), make([]time.Time, len(periods))
+	for i, p := range periods {
+		keys[i], expiresAt[i] = p.key, p.ResetsAt.Add(quotaRetention)
+	}
+	return &QuotaReservation{Usage: *usage, keys: keys, expiresAt: expiresAt, delta: delta}, nil
+}
+
+// Part returns a reservation of some of the messages of r, so the messages
+// of a batch that failed can be released alone
+// Params: messages int64 - the messages to take out of r
+// Params: bytes int64 - the bytes of those messages
+func (r *QuotaReservation) Part(messages, bytes int64) *QuotaReservation {
+	return &QuotaReservation{Usage: r.Usage, keys: r.keys, expiresAt: r.expiresAt, delta: QuotaCounter{Messages: messages, Bytes: bytes}}
+}
+
+// Release takes the reserved messages back out of the periods they were counted in
+// Params: reservation *QuotaReservation - the reservation returned by Reserve
+func (s *quotaService) Release(reservation *QuotaReservation) error {
+	negative := QuotaCounter{Messages: -reservation.delta.Messages, Bytes: -reservation.delta.Bytes}
+	for i, key := range reservation.keys {
+		if _, err := s.store.Add(key, negative, reservation.expiresAt[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage returns the usage and limits of the current day and month
+// Params: tenant string - the tenant
+func (s *quotaService) Usage(tenant string) (*QuotaUsage, error) {
+	periods := s.periods(tenant)
+	counters := make([]QuotaCounter, len(periods))
+	for i, p := range periods {
+		counter, err := s.store.Get(p.key)
+		if err != nil {
+			return nil, err
+		}
+		counters[i] = counter
+	}
+	return s.usage(tenant, periods, counters), nil
+}
+
+// Reset clears the current day and month of a tenant
+// Params: tenant string - the tenant
+func (s *quotaService) Reset(tenant string) error {
+	for _, p := range s.periods(tenant) {
+		counter, err := s.store.Get(p.key)
+		if err != nil {
+			return err
+		}
+		if counter == (QuotaCounter{}) {
+			continue
+		}
+		negative := QuotaCounter{Messages: -counter.Messages, Bytes: -counter.Bytes}
+		if _, err := s.store.Add(p.key, negative, p.ResetsAt.Add(quotaRetention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quotaPeriod is a current period of a tenant with its store key
+type quotaPeriod struct {
+	QuotaPeriodUsage
+	key string
+}
+
+// periods returns the current day and month of a tenant, in that order
+func (s *quotaService) periods(tenant string) []quotaPeriod {
+	limits, ok := s.cfg.Tenants[tenant]
+	if !ok {
+		limits = s.cfg.Default
+	}
+	now := s.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	daily := QuotaPeriodUsage{
+		Period:        day.Format("2006-01-02"),
+		MessagesLimit: limits.DailyMessages,
+		BytesLimit:    limits.DailyBytes,
+		ResetsAt:      day.AddDate(0, 0, 1),
+	}
+	monthly := QuotaPeriodUsage{
+		Period:        month.Format("2006-01"),
+		MessagesLimit: limits.MonthlyMessages,
+		BytesLimit:    limits.MonthlyBytes,
+		ResetsAt:      month.AddDate(0, 1, 0),
+	}
+	return []quotaPeriod{
+		{QuotaPeriodUsage: daily, key: tenant + "/" + daily.Period},
+		{QuotaPeriodUsage: monthly, key: tenant + "/" + monthly.Period},
+	}
+}
+
+// usage combines the periods with their counters and lists the warnings
+func (s *quotaService) usage(tenant string, periods []quotaPeriod, counters []QuotaCounter) *QuotaUsage {
+	usage := &QuotaUsage{Tenant: tenant, Daily: periods[0].QuotaPeriodUsage, Monthly: periods[1].QuotaPeriodUsage}
+	usage.Daily.Messages, usage.Daily.Bytes = counters[0].Messages, counters[0].Bytes
+	usage.Monthly.Messages, usage.Monthly.Bytes = counters[1].Messages, counters[1].Bytes
+	for _, name := range []string{QuotaDaily, QuotaMonthly} {
+		for _, metric := range usage.period(name).metrics() {
+			if metric.limit > 0 && float64(metric.used) >= s.cfg.SoftLimit*float64(metric.limit) {
+				usage.Warnings = append(usage.Warnings, fmt.Sprintf("%s %s at %d%%", name, metric.name, metric.used*100/metric.limit))
+			}
+		}
+	}
+	return usage
+}
+
+// rollback takes a delta back out of periods it was added to; failures
+// are ignored as the counters only err on the side of the tenant
+func (s *quotaService) rollback(periods []quotaPeriod, delta QuotaCounter) {
+	negative := QuotaCounter{Messages: -delta.Messages, Bytes: -delta.Bytes}
+	for _, p := range periods {
+		_, _ = s.store.Add(p.key, negative, p.ResetsAt.Add(quotaRetention))
+	}
+}
+
+// period returns the usage of QuotaDaily or QuotaMonthly
+func (u *QuotaUsage) period(name string) *QuotaPeriodUsage {
+	if name == QuotaMonthly {
+		return &u.Monthly
+	}
+	return &u.Daily
+}
+
+type quotaMetric struct {
+	name        string
+	used, limit int64
+}
+
+func (u *QuotaPeriodUsage) metrics() []quotaMetric {
+	return []quotaMetric{{"messages", u.Messages, u.MessagesLimit}, {"bytes", u.Bytes, u.BytesLimit}}
+}
+
+// exceeded returns a *QuotaExceededError when a limit is passed
+func (u *QuotaPeriodUsage) exceeded(period string) error {
+	for _, metric := range u.metrics() {
+		if metric.limit > 0 && metric.used > metric.limit {
+			return &QuotaExceededError{Period: period, Metric: metric.name, Limit: metric.limit, ResetsAt: u.ResetsAt}
+		}
+	}
+	return nil
+}
+
+func (l QuotaLimits) valid() bool {
+	return l.DailyMessages >= 0 && l.DailyBytes >= 0 && l.MonthlyMessages >= 0 && l.MonthlyBytes >= 0
+}
+
+// tenantContextKey is the context key holding the tenant of the authenticated token
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant of the caller
+// Params: ctx context.Context - the parent context
+// Params: tenant string - the tenant of the caller
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored by ContextWithTenant, or ""
+// Params: ctx context.Context - the request context
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// quotaCompactEvery is the number of appended lines that trigger a compaction
+const quotaCompactEvery = 10000
+
+// quotaFileEntry is one line of the quota usage file, a delta of a counter
+type quotaFileEntry struct {
+	Key       string    `json:"key"`
+	Messages  int64     `json:"messages"`
+	Bytes     int64     `json:"bytes"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+type fileQuotaStore struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	counters map[string]quotaEntry
+	appended int
+	// size is where the file ends after the last complete line, and torn
+	// tells that a failed write left part of a line after it
+	size int64
+	torn bool
+	now  func() time.Time
+	// write appends to the file; tests replace it to fail partway
+	write func(f *os.File, data []byte) (int, error)
+}
+
+// NewFileQuotaStore creates a quota store backed by an append-only file of
+// JSON lines, one per change. The file is compacted to one line per
+// counter when opened and as it grows. It is owned by a single process;
+// replicas sharing usage need a shared store such as NewRESPQuotaStore.
+// Params: path string - the usage file, created when missing
+func NewFileQuotaStore(path string) (QuotaStore, error) {
+	s := &fileQuotaStore{
+		path:     path,
+		counters: make(map[string]quotaEntry),
+		now:      time.Now,
+		write: func(f *os.File, data []byte) (int, error) {
+			return f.Write(data)
+		},
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add appends the delta to the file and returns the new counter value
+// Params: key string - the counter key
+// Params: delta QuotaCounter - the messages and bytes to add, may be negative
+// Params: expiresAt time.Time - when the counter can be dropped
+func (s *fileQuotaStore) Add(key string, delta QuotaCounter, expiresAt time.Time) (QuotaCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(quotaFileEntry{Key: key, Messages: delta.Messages, Bytes: delta.Bytes, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		return QuotaCounter{}, err
+	}
+	// the write reaches the OS before the counter changes, so usage
+	// survives a process crash; a crashed writer leaves at most a torn line
+	data := append(line, '\n')
+	if s.torn {
+		data = append([]byte{'\n'}, data...)
+	}
+	if n, err := s.write(s.file, data); err != nil {
+		// a partial line is dropped, or ended by the next write, so the next
+		// line is not merged into it and lost with it
+		if n > 0 || s.torn {
+			s.torn = s.file.Truncate(s.size) != nil
+		}
+		return QuotaCounter{}, err
+	}
+	s.size += int64(len(data))
+	s.torn = false
+	counter := s.apply(quotaFileEntry{Key: key, Messages: delta.Messages, Bytes: delta.Bytes, ExpiresAt: expiresAt})
+
+	s.appended++
+	if s.appended >= quotaCompactEvery {
+		if err := s.compact(); err != nil {
+			return QuotaCounter{}, err
+		}
+	}
+	return counter, nil
+}
+
+// Get returns a counter, zero when unknown
+// Params: key string - the counter key
+func (s *fileQuotaStore) Get(key string) (QuotaCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key].counter, nil
+}
+
+// apply adds an entry to the counters; callers must hold the lock
+func (s *fileQuotaStore) apply(e quotaFileEntry) QuotaCounter {
+	entry := s.counters[e.Key]
+	entry.counter.Messages += e.Messages
+	entry.counter.Bytes += e.Bytes
+	if e.ExpiresAt.After(entry.expiresAt) {
+		entry.expiresAt = e.ExpiresAt
+	}
+	s.counters[e.Key] = entry
+	return entry.counter
+}
+
+// load sums the deltas of the file
+func (s *fileQuotaStore) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry quotaFileEntry
+		// a torn last line from a crashed writer is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Key == "" {
+			continue
+		}
+		s.apply(entry)
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file atomically with one line per unexpired
+// counter and reopens it for appending; callers must hold the lock
+func (s *fileQuotaStore) compact() error {
+	now := s.now()
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".quotas-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for key, entry := range s.counters {
+		if now.After(entry.expiresAt) {
+			delete(s.counters, key)
+			continue
+		}
+		line, err := json.Marshal(quotaFileEntry{
+			Key:       key,
+			Messages:  entry.counter.Messages,
+			Bytes:     entry.counter.Bytes,
+			ExpiresAt: entry.expiresAt.UTC(),
+		})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	s.file = f
+	s.appended, s.size, s.torn = 0, info.Size(), false
+	return nil
+}
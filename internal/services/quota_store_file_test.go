@@ -0,0 +1,92 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileQuotaStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas", "usage.jsonl")
+	store, err := NewFileQuotaStore(path)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	_, err = store.Add("acme/2024-05", QuotaCounter{Messages: 3, Bytes: 30}, expiresAt)
+	require.NoError(t, err)
+	counter, err := store.Add("acme/2024-05", QuotaCounter{Messages: -1, Bytes: -10}, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 2, Bytes: 20}, counter)
+
+	// a crashed writer can leave a torn line behind
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"key":"acme/2024-05","mess`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, err := NewFileQuotaStore(path)
+	require.NoError(t, err)
+	counter, err = reopened.Get("acme/2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 2, Bytes: 20}, counter, "usage should survive a restart")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"), "the file should be compacted to one line per counter")
+}
+
+func TestFileQuotaStore_FailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	store, err := NewFileQuotaStore(path)
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour)
+
+	// a write failing partway leaves no torn line for the next one to join
+	store.(*fileQuotaStore).write = func(f *os.File, data []byte) (int, error) {
+		n, _ := f.Write(data[:len(data)/2])
+		return n, assert.AnError
+	}
+	_, err = store.Add("acme/2024-05", QuotaCounter{Messages: 1, Bytes: 10}, expiresAt)
+	require.ErrorIs(t, err, assert.AnError)
+	store.(*fileQuotaStore).write = func(f *os.File, data []byte) (int, error) {
+		return f.Write(data)
+	}
+	_, err = store.Add("acme/2024-05", QuotaCounter{Messages: 2, Bytes: 20}, expiresAt)
+	require.NoError(t, err)
+	_, err = store.Add("acme/2024-06", QuotaCounter{Messages: 3, Bytes: 30}, expiresAt)
+	require.NoError(t, err)
+
+	reopened, err := NewFileQuotaStore(path)
+	require.NoError(t, err)
+	counter, err := reopened.Get("acme/2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 2, Bytes: 20}, counter)
+	counter, err = reopened.Get("acme/2024-06")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 3, Bytes: 30}, counter)
+}
+
+func TestFileQuotaStore_DropsExpiredCounters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"key":"acme/2024-04","messages":5,"bytes":50,"exp":"2024-05-02T00:00:00Z"}`+"\n"+
+			`{"key":"acme/2999-01","messages":1,"bytes":10,"exp":"2999-02-02T00:00:00Z"}`+"\n"), 0o600))
+
+	store, err := NewFileQuotaStore(path)
+	require.NoError(t, err)
+	counter, err := store.Get("acme/2024-04")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{}, counter)
+	counter, err = store.Get("acme/2999-01")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 1, Bytes: 10}, counter)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "2024-04")
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// quotaEntry is a counter with the time it can be dropped
+type quotaEntry struct {
+	counter   QuotaCounter
+	expiresAt time.Time
+}
+
+type inMemoryQuotaStore struct {
+	mu        sync.Mutex
+	counters  map[string]quotaEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryQuotaStore creates a quota store that keeps the counters in
+// memory. Usage does not survive a restart.
+func NewInMemoryQuotaStore() QuotaStore {
+	return &inMemoryQuotaStore{
+		counters: make(map[string]quotaEntry),
+		now:      time.Now,
+	}
+}
+
+// Add adds delta to a counter and returns its new value
+// Params: key string - the counter key
+// Params: delta QuotaCounter - the messages and bytes to add, may be negative
+// Params: expiresAt time.Time - when the counter can be dropped
+func (s *inMemoryQuotaStore) Add(key string, delta QuotaCounter, expiresAt time.Time) (QuotaCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	entry := s.counters[key]
+	entry.counter.Messages += delta.Messages
+	entry.counter.Bytes += delta.Bytes
+	if expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	s.counters[key] = entry
+	return entry.counter, nil
+}
+
+// Get returns a counter, zero when unknown
+// Params: key string - the counter key
+func (s *inMemoryQuotaStore) Get(key string) (QuotaCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key].counter, nil
+}
+
+// sweep drops expired counters; callers must hold the lock
+func (s *inMemoryQuotaStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.counters {
+		if now.After(entry.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
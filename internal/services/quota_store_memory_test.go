@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInMemoryQuotaStore(t *testing.T) {
+	store := NewInMemoryQuotaStore()
+	now := time.Now()
+	store.(*inMemoryQuotaStore).now = func() time.Time { return now }
+
+	counter, err := store.Add("acme/2024-05", QuotaCounter{Messages: 2, Bytes: 20}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 2, Bytes: 20}, counter)
+	counter, err = store.Add("acme/2024-05", QuotaCounter{Messages: -1, Bytes: -5}, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 1, Bytes: 15}, counter)
+
+	counter, err = store.Get("unknown")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{}, counter)
+
+	// the latest expiry is kept and expired counters are swept on writes
+	now = now.Add(2 * time.Hour)
+	_, err = store.Add("acme/2024-06", QuotaCounter{Messages: 1}, now.Add(time.Hour))
+	require.NoError(t, err)
+	counter, err = store.Get("acme/2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{}, counter)
+}
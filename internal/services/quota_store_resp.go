@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"strconv"
+	"time"
+)
+
+// defaultQuotaKeyPrefix namespaces the quota counters in the shared store
+const defaultQuotaKeyPrefix = "msg-receiver:quota:"
+
+type respQuotaStore struct {
+	client *resp.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewRESPQuotaStore creates a quota store keeping the counters in a Redis
+// compatible server, so replicas share the usage of every tenant. Each
+// counter is a pair of keys updated with INCRBY and PEXPIRE.
+// Params: client *resp.Client - the connection to the shared store
+// Params: prefix string - prepended to every counter key, "msg-receiver:quota:" when empty
+func NewRESPQuotaStore(client *resp.Client, prefix string) QuotaStore {
+	if prefix == "" {
+		prefix = defaultQuotaKeyPrefix
+	}
+	return &respQuotaStore{client: client, prefix: prefix, now: time.Now}
+}
+
+// Add increments the counter keys and returns their new values
+// Params: key string - the counter key
+// Params: delta QuotaCounter - the messages and bytes to add, may be negative
+// Params: expiresAt time.Time - when the counter can be dropped
+func (s *respQuotaStore) Add(key string, delta QuotaCounter, expiresAt time.Time) (QuotaCounter, error) {
+	messages, bytes := s.prefix+key+":messages", s.prefix+key+":bytes"
+	ttl := strconv.FormatInt(expiresAt.Sub(s.now()).Milliseconds(), 10)
+	replies, err := s.client.Pipeline(context.Background(),
+		[]string{"INCRBY", messages, strconv.FormatInt(delta.Messages, 10)},
+		[]string{"INCRBY", bytes, strconv.FormatInt(delta.Bytes, 10)},
+		[]string{"PEXPIRE", messages, ttl},
+		[]string{"PEXPIRE", bytes, ttl},
+	)
+	if err != nil {
+		return QuotaCounter{}, err
+	}
+	for _, reply := range replies[2:] {
+		if replyErr, ok := reply.(resp.Error); ok {
+			return QuotaCounter{}, fmt.Errorf("quota counter %s: %w", key, replyErr)
+		}
+	}
+	return s.counter(key, replies[0], replies[1])
+}
+
+// Get returns a counter, zero when unknown
+// Params: key string - the counter key
+func (s *respQuotaStore) Get(key string) (QuotaCounter, error) {
+	replies, err := s.client.Pipeline(context.Background(),
+		[]string{"GET", s.prefix + key + ":messages"},
+		[]string{"GET", s.prefix + key + ":bytes"},
+	)
+	if err != nil {
+		return QuotaCounter{}, err
+	}
+	return s.counter(key, replies[0], replies[1])
+}
+
+// counter converts the replies of the counter keys, missing keys being zero
+func (s *respQuotaStore) counter(key string, messages, bytes interface{}) (QuotaCounter, error) {
+	var counter QuotaCounter
+	var err error
+	if messages != nil {
+		if counter.Messages, err = resp.Int(messages); err != nil {
+			return QuotaCounter{}, fmt.Errorf("quota counter %s: %w", key, err)
+		}
+	}
+	if bytes != nil {
+		if counter.Bytes, err = resp.Int(bytes); err != nil {
+			return QuotaCounter{}, fmt.Errorf("quota counter %s: %w", key, err)
+		}
+	}
+	return counter, nil
+}
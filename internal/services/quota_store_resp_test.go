@@ -0,0 +1,69 @@
+package services
+
+import (
+	"github.com/nathaliaguayos/msg-receiver/internal/resp"
+	"github.com/nathaliaguayos/msg-receiver/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRESPQuotaStore(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client, err := resp.NewClient(resp.ClientConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// replicas sharing the server see each other's usage
+	first, second := NewRESPQuotaStore(client, ""), NewRESPQuotaStore(client, "")
+	now := time.Now()
+	first.(*respQuotaStore).now = func() time.Time { return now }
+
+	counter, err := first.Add("acme/2024-05", QuotaCounter{Messages: 2, Bytes: 20}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 2, Bytes: 20}, counter)
+	counter, err = second.Add("acme/2024-05", QuotaCounter{Messages: 1, Bytes: 5}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 3, Bytes: 25}, counter)
+
+	counter, err = first.Get("acme/2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 3, Bytes: 25}, counter)
+	assert.Greater(t, server.TTL("msg-receiver:quota:acme/2024-05:messages"), 59*time.Minute)
+
+	counter, err = first.Get("unknown")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{}, counter)
+
+	server.Close()
+	_, err = first.Add("acme/2024-05", QuotaCounter{Messages: 1}, now.Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestRESPQuotaStore_Release(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+	client, err := resp.NewClient(resp.ClientConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	store := NewRESPQuotaStore(client, "")
+	store.(*respQuotaStore).now = func() time.Time { return now }
+	service := NewQuotaService(QuotaConfig{}, store).(*quotaService)
+	service.now = func() time.Time { return now }
+
+	reservation, err := service.Reserve("acme", 2, 20)
+	require.NoError(t, err)
+	require.NoError(t, service.Release(reservation.Part(1, 10)))
+
+	// a release keeps the monthly counter until the month is over
+	counter, err := store.Get("acme/2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{Messages: 1, Bytes: 10}, counter)
+	resetsAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, resetsAt.Add(quotaRetention).Sub(now), server.TTL("msg-receiver:quota:acme/2024-05:messages"))
+	assert.Equal(t, resetsAt.Add(quotaRetention).Sub(now), server.TTL("msg-receiver:quota:acme/2024-05:bytes"))
+}
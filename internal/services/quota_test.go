@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// failingQuotaStore fails every call
+type failingQuotaStore struct {
+	QuotaStore
+}
+
+func (failingQuotaStore) Add(string, QuotaCounter, time.Time) (QuotaCounter, error) {
+	return QuotaCounter{}, errors.New("store unreachable")
+}
+
+func newTestQuotaService(cfg QuotaConfig, now *time.Time) *quotaService {
+	service := NewQuotaService(cfg, NewInMemoryQuotaStore()).(*quotaService)
+	service.now = func() time.Time { return *now }
+	return service
+}
+
+func TestQuotaService_Reserve(t *testing.T) {
+	now := time.Date(2024, 5, 31, 22, 0, 0, 0, time.UTC)
+	service := newTestQuotaService(QuotaConfig{
+		Default: QuotaLimits{DailyMessages: 10, MonthlyBytes: 1000},
+		Tenants: map[string]QuotaLimits{"acme": {DailyMessages: 2}},
+	}, &now)
+
+	reservation, err := service.Reserve("orders", 7, 100)
+	require.NoError(t, err)
+	assert.Equal(t, QuotaPeriodUsage{
+		Period:        "2024-05-31",
+		Messages:      7,
+		Bytes:         100,
+		MessagesLimit: 10,
+		ResetsAt:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}, reservation.Usage.Daily)
+	assert.Equal(t, QuotaPeriodUsage{
+		Period:     "2024-05",
+		Messages:   7,
+		Bytes:      100,
+		BytesLimit: 1000,
+		ResetsAt:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}, reservation.Usage.Monthly)
+	assert.Empty(t, reservation.Usage.Warnings)
+
+	reservation, err = service.Reserve("orders", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"daily messages at 80%"}, reservation.Usage.Warnings)
+
+	_, err = service.Reserve("orders", 3, 0)
+	var exceededErr *QuotaExceededError
+	require.ErrorAs(t, err, &exceededErr)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, &QuotaExceededError{Period: QuotaDaily, Metric: "messages", Limit: 10, ResetsAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}, exceededErr)
+
+	usage, err := service.Usage("orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), usage.Daily.Messages, "rejected messages should not be counted")
+	assert.Equal(t, int64(8), usage.Monthly.Messages)
+
+	_, err = service.Reserve("orders", 1, 901)
+	require.ErrorAs(t, err, &exceededErr)
+	assert.Equal(t, QuotaMonthly, exceededErr.Period)
+	assert.Equal(t, "bytes", exceededErr.Metric)
+
+	_, err = service.Reserve("acme", 3, 0)
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "tenant limits should override the default")
+
+	// a new day starts with a fresh daily quota
+	now = now.Add(3 * time.Hour)
+	reservation, err = service.Reserve("orders", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06-01", reservation.Usage.Daily.Period)
+	assert.Equal(t, "2024-06", reservation.Usage.Monthly.Period)
+}
+
+func TestQuotaService_Release(t *testing.T) {
+	now := time.Date(2024, 5, 31, 23, 59, 0, 0, time.UTC)
+	service := newTestQuotaService(QuotaConfig{Default: QuotaLimits{DailyMessages: 1}}, &now)
+
+	reservation, err := service.Reserve("orders", 1, 10)
+	require.NoError(t, err)
+	_, err = service.Reserve("orders", 1, 10)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// the release lands in the periods the reservation was counted in
+	now = now.Add(2 * time.Minute)
+	_, err = service.Reserve("orders", 1, 10)
+	require.NoError(t, err)
+	require.NoError(t, service.Release(reservation))
+
+	usage, err := service.Usage("orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Daily.Messages)
+	assert.Equal(t, int64(1), usage.Monthly.Messages)
+	counter, err := service.store.Get("orders/2024-05-31")
+	require.NoError(t, err)
+	assert.Equal(t, QuotaCounter{}, counter)
+}
+
+func TestQuotaReservation_Part(t *testing.T) {
+	now := time.Now()
+	service := newTestQuotaService(QuotaConfig{}, &now)
+
+	reservation, err := service.Reserve("orders", 5, 50)
+	require.NoError(t, err)
+	require.NoError(t, service.Release(reservation.Part(2, 15)))
+
+	usage, err := service.Usage("orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), usage.Daily.Messages)
+	assert.Equal(t, int64(35), usage.Monthly.Bytes)
+}
+
+func TestQuotaService_Reset(t *testing.T) {
+	now := time.Now()
+	service := newTestQuotaService(QuotaConfig{Default: QuotaLimits{MonthlyMessages: 5}}, &now)
+
+	_, err := service.Reserve("orders", 5, 50)
+	require.NoError(t, err)
+	require.NoError(t, service.Reset("orders"))
+	require.NoError(t, service.Reset("unknown"))
+
+	usage, err := service.Usage("orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.Monthly.Messages)
+	assert.Equal(t, int64(0), usage.Monthly.Bytes)
+	_, err = service.Reserve("orders", 5, 50)
+	assert.NoError(t, err)
+}
+
+func TestQuotaService_StoreError(t *testing.T) {
+	service := NewQuotaService(QuotaConfig{}, failingQuotaStore{})
+	_, err := service.Reserve("orders", 1, 1)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestLoadQuotaConfig(t *testing.T) {
+	testCases := []struct {
+		name          string
+		file          string
+		expected      QuotaConfig
+		expectedError string
+	}{
+		{
+			name: "should load the default and tenant limits",
+			file: `{"soft_limit":0.9,"default":{"daily_messages":100},"tenants":{"acme":{"monthly_bytes":1024}}}`,
+			expected: QuotaConfig{
+				Default:   QuotaLimits{DailyMessages: 100},
+				Tenants:   map[string]QuotaLimits{"acme": {MonthlyBytes: 1024}},
+				SoftLimit: 0.9,
+			},
+		}, {
+			name:          "should reject a soft limit above 1",
+			file:          `{"soft_limit":80}`,
+			expectedError: "soft_limit",
+		}, {
+			name:          "should reject negative limits",
+			file:          `{"tenants":{"acme":{"daily_bytes":-1}}}`,
+			expectedError: "should not be negative",
+		}, {
+			name:          "should reject invalid JSON",
+			file:          `{"default":`,
+			expectedError: "invalid quotas file",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "quotas.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.file), 0o600))
+			cfg, err := LoadQuotaConfig(path)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg)
+		})
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	assert.Equal(t, "", TenantFromContext(context.Background()))
+	assert.Equal(t, "acme", TenantFromContext(ContextWithTenant(context.Background(), "acme")))
+}
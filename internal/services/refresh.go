@@ -0,0 +1,157 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// refreshTokenSize is the number of random bytes in a refresh token
+const refreshTokenSize = 32
+
+// RefreshTokenService is a contract for issuing and rotating opaque refresh tokens
+//
+//counterfeiter:generate . RefreshTokenService
+type RefreshTokenService interface {
+	Issue(claims TokenClaims) (string, error)
+	Rotate(refreshToken, clientID string) (record *RefreshToken, next string, err error)
+	Revoke(refreshToken, clientID string) error
+}
+
+// RefreshToken is the server-side record of an issued refresh token. Only
+// the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	Hash     string
+	FamilyID string
+	// TokenClaims are the claims of the access tokens issued in exchange
+	TokenClaims
+	ExpiresAt time.Time
+	// Used is set once the token has been exchanged for a new one
+	Used bool
+}
+
+// RefreshTokenStore is a contract for persisting refresh tokens
+//
+//counterfeiter:generate . RefreshTokenStore
+type RefreshTokenStore interface {
+	Save(token RefreshToken) error
+	Get(hash string) (*RefreshToken, error)
+	// MarkUsed flags a token as exchanged and reports whether it already was
+	MarkUsed(hash string) (alreadyUsed bool, err error)
+	RevokeFamily(familyID string, until time.Time) error
+	FamilyRevoked(familyID string) (bool, error)
+}
+
+type refreshTokenService struct {
+	store RefreshTokenStore
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewRefreshTokenService creates a new refresh token service
+// Params: store RefreshTokenStore - where token hashes are kept
+// Params: ttl time.Duration - the lifetime of each refresh token
+func NewRefreshTokenService(store RefreshTokenStore, ttl time.Duration) RefreshTokenService {
+	return &refreshTokenService{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Issue starts a new token family
+// Params: claims TokenClaims - the claims of the access tokens issued in exchange
+func (s *refreshTokenService) Issue(claims TokenClaims) (string, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return s.issue(RefreshToken{FamilyID: familyID, TokenClaims: claims})
+}
+
+// Rotate exchanges a refresh token for a new one in the same family. A
+// token can be exchanged once; presenting it again is treated as theft and
+// revokes the whole family, including the token issued in exchange. Only
+// the client the family was issued to can exchange its tokens.
+// Params: refreshToken string - the presented refresh token
+// Params: clientID string - the authenticated client presenting it
+func (s *refreshTokenService) Rotate(refreshToken, clientID string) (*RefreshToken, string, error) {
+	hash := hashToken(refreshToken)
+	record, err := s.store.Get(hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if record == nil || record.Subject != clientID {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	revoked, err := s.store.FamilyRevoked(record.FamilyID)
+	if err != nil {
+		return nil, "", err
+	}
+	if revoked {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if !s.now().Before(record.ExpiresAt) {
+		return nil, "", ErrRefreshTokenExpired
+	}
+
+	alreadyUsed, err := s.store.MarkUsed(hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if alreadyUsed {
+		// the family stays revoked as long as any of its tokens could be presented
+		if err := s.store.RevokeFamily(record.FamilyID, s.now().Add(s.ttl)); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	next, err := s.issue(RefreshToken{FamilyID: record.FamilyID, TokenClaims: record.TokenClaims})
+	if err != nil {
+		return nil, "", err
+	}
+	return record, next, nil
+}
+
+// Revoke revokes the family of a refresh token, ending the session it
+// belongs to. Unknown tokens and tokens issued to other clients are ignored.
+// Params: refreshToken string - the refresh token
+// Params: clientID string - the authenticated client revoking it
+func (s *refreshTokenService) Revoke(refreshToken, clientID string) error {
+	record, err := s.store.Get(hashToken(refreshToken))
+	if err != nil || record == nil || record.Subject != clientID {
+		return err
+	}
+	return s.store.RevokeFamily(record.FamilyID, s.now().Add(s.ttl))
+}
+
+// issue stores a new token for the family and claims of record
+func (s *refreshTokenService) issue(record RefreshToken) (string, error) {
+	token, err := randomToken(refreshTokenSize)
+	if err != nil {
+		return "", err
+	}
+	record.Hash = hashToken(token)
+	record.ExpiresAt = s.now().Add(s.ttl)
+	if err := s.store.Save(record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encodeSegment(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired records are dropped from memory
+const sweepInterval = time.Minute
+
+type inMemoryRefreshTokenStore struct {
+	mu        sync.Mutex
+	tokens    map[string]RefreshToken
+	revoked   map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryRefreshTokenStore creates a refresh token store that keeps
+// every record in memory. Tokens do not survive a restart.
+func NewInMemoryRefreshTokenStore() RefreshTokenStore {
+	return &inMemoryRefreshTokenStore{
+		tokens:  make(map[string]RefreshToken),
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Save stores a token record
+// Params: token RefreshToken - the record to store
+func (s *inMemoryRefreshTokenStore) Save(token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.tokens[token.Hash] = token
+	return nil
+}
+
+// Get returns the record for a token hash, or nil when unknown
+// Params: hash string - the token hash
+func (s *inMemoryRefreshTokenStore) Get(hash string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// MarkUsed flags a token as exchanged and reports whether it already was
+// Params: hash string - the token hash
+func (s *inMemoryRefreshTokenStore) MarkUsed(hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok {
+		return false, ErrInvalidRefreshToken
+	}
+	if token.Used {
+		return true, nil
+	}
+	token.Used = true
+	s.tokens[hash] = token
+	return false, nil
+}
+
+// RevokeFamily invalidates every token of a family
+// Params: familyID string - the family to revoke
+// Params: until time.Time - when the revocation record can be forgotten
+func (s *inMemoryRefreshTokenStore) RevokeFamily(familyID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[familyID] = until
+	return nil
+}
+
+// FamilyRevoked reports whether a family has been revoked
+// Params: familyID string - the family ID
+func (s *inMemoryRefreshTokenStore) FamilyRevoked(familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[familyID]
+	return ok, nil
+}
+
+// sweep drops expired records; callers must hold the lock
+func (s *inMemoryRefreshTokenStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	for family, until := range s.revoked {
+		if now.After(until) {
+			delete(s.revoked, family)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInMemoryRefreshTokenStore(t *testing.T) {
+	store := NewInMemoryRefreshTokenStore()
+	now := time.Now()
+	store.(*inMemoryRefreshTokenStore).now = func() time.Time { return now }
+
+	require.NoError(t, store.Save(RefreshToken{Hash: "a", FamilyID: "f", TokenClaims: TokenClaims{Subject: "user-1"}, ExpiresAt: now.Add(time.Minute)}))
+	token, err := store.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", token.Subject)
+
+	used, err := store.MarkUsed("a")
+	require.NoError(t, err)
+	assert.False(t, used)
+	used, err = store.MarkUsed("a")
+	require.NoError(t, err)
+	assert.True(t, used)
+	_, err = store.MarkUsed("missing")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	require.NoError(t, store.RevokeFamily("f", now.Add(time.Minute)))
+	revoked, err := store.FamilyRevoked("f")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// expired records are swept on the next save
+	now = now.Add(time.Hour)
+	require.NoError(t, store.Save(RefreshToken{Hash: "b", ExpiresAt: now.Add(time.Minute)}))
+	token, err = store.Get("a")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+	revoked, err = store.FamilyRevoked("f")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
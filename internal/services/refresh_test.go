@@ -0,0 +1,105 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenService_Rotate(t *testing.T) {
+	service := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+
+	first, err := service.Issue(TokenClaims{Subject: "user-1", Scopes: []string{"produce:orders"}, Tenant: "acme"})
+	require.NoError(t, err)
+	assert.Len(t, first, 43)
+
+	record, second, err := service.Rotate(first, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", record.Subject)
+	assert.Equal(t, []string{"produce:orders"}, record.Scopes)
+	assert.NotEqual(t, first, second)
+
+	record, third, err := service.Rotate(second, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", record.Subject)
+	assert.Equal(t, []string{"produce:orders"}, record.Scopes, "scopes should be kept across rotations")
+	assert.Equal(t, "acme", record.Tenant)
+	assert.NotEmpty(t, third)
+
+	// other clients cannot exchange the tokens of the family
+	_, _, err = service.Rotate(third, "user-2")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, _, err = service.Rotate(third, "user-1")
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenService_ReuseRevokesFamily(t *testing.T) {
+	service := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+	first, err := service.Issue(TokenClaims{Subject: "user-1"})
+	require.NoError(t, err)
+	_, second, err := service.Rotate(first, "user-1")
+	require.NoError(t, err)
+
+	_, _, err = service.Rotate(first, "user-1")
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// the token handed out in exchange is revoked as well
+	_, _, err = service.Rotate(second, "user-1")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	// other families are not affected
+	other, err := service.Issue(TokenClaims{Subject: "user-1"})
+	require.NoError(t, err)
+	_, _, err = service.Rotate(other, "user-1")
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenService_Revoke(t *testing.T) {
+	service := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+	first, err := service.Issue(TokenClaims{Subject: "user-1"})
+	require.NoError(t, err)
+	_, second, err := service.Rotate(first, "user-1")
+	require.NoError(t, err)
+
+	// tokens of other clients are left alone
+	require.NoError(t, service.Revoke(first, "user-2"))
+	_, third, err := service.Rotate(second, "user-1")
+	require.NoError(t, err)
+
+	require.NoError(t, service.Revoke(first, "user-1"))
+	_, _, err = service.Rotate(third, "user-1")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	assert.NoError(t, service.Revoke("unknown", "user-1"))
+}
+
+func TestRefreshTokenService_Errors(t *testing.T) {
+	t.Run("should reject an unknown token", func(t *testing.T) {
+		service := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+		_, _, err := service.Rotate("unknown", "user-1")
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		service := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+		token, err := service.Issue(TokenClaims{Subject: "user-1"})
+		require.NoError(t, err)
+		service.(*refreshTokenService).now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		_, _, err = service.Rotate(token, "user-1")
+		assert.ErrorIs(t, err, ErrRefreshTokenExpired)
+	})
+
+	t.Run("should return store errors", func(t *testing.T) {
+		service := NewRefreshTokenService(failingRefreshTokenStore{}, time.Hour)
+		_, err := service.Issue(TokenClaims{Subject: "user-1"})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+// failingRefreshTokenStore fails every write
+type failingRefreshTokenStore struct {
+	RefreshTokenStore
+}
+
+func (failingRefreshTokenStore) Save(RefreshToken) error { return assert.AnError }
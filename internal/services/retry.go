@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy retries failing operations with jittered exponential backoff
+type RetryPolicy struct {
+	// MaxAttempts bounds the calls made, including the first one; calls go
+	// on until the context is done when zero
+	MaxAttempts int
+	// InitialBackoff is the delay after the first failure, 100 milliseconds
+	// when zero, multiplied by Multiplier (2 when zero) after each one up to
+	// MaxBackoff (30 seconds when zero)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter spreads each delay by up to that fraction either way, so
+	// clients failing together do not retry together; 0.2 when zero
+	Jitter float64
+	// IsRetriable tells the errors worth another call, Retriable when nil
+	IsRetriable func(err error) bool
+	// MaxElapsed bounds the calls and delays together; unbounded when zero
+	MaxElapsed time.Duration
+}
+
+// withDefaults fills the zero fields
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = 0.2
+	}
+	if p.IsRetriable == nil {
+		p.IsRetriable = Retriable
+	}
+	return p
+}
+
+// Backoff returns the delay before the call following attempt failed ones
+// Params: attempt int - the number of failed calls, from 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	p = p.withDefaults()
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(max(attempt, 1)-1))
+	delay = min(delay, float64(p.MaxBackoff))
+	delay *= 1 - p.Jitter + 2*p.Jitter*rand.Float64()
+	return time.Duration(min(delay, float64(p.MaxBackoff)))
+}
+
+// Do calls fn until it succeeds, fails with an error that is not
+// retriable, runs out of attempts or ctx is done, and returns the number of
+// calls made and the last error. onRetry, when set, is told about each
+// failure followed by another call.
+// Params: ctx context.Context - bounds the calls and delays
+// Params: fn func(ctx context.Context) error - the operation
+// Params: onRetry func(attempt int, err error, delay time.Duration) - called before each delay, may be nil
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error, onRetry func(attempt int, err error, delay time.Duration)) (int, error) {
+	p = p.withDefaults()
+	if p.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.MaxElapsed)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !p.IsRetriable(err) || (p.MaxAttempts > 0 && attempt >= p.MaxAttempts) {
+			return attempt, err
+		}
+		delay := p.Backoff(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+		if !sleep(ctx, delay) {
+			return attempt, err
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetriable(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: ErrBrokerUnavailable, expected: true},
+		{err: fmt.Errorf("%w: connection refused", ErrBrokerUnavailable), expected: true},
+		{err: ErrCircuitOpen, expected: true},
+		{err: ErrProducerClosed, expected: true},
+		{err: context.DeadlineExceeded, expected: true},
+		{err: fmt.Errorf("%w: kafka: unknown topic or partition", ErrUnknownTopic), expected: false},
+		{err: ErrMessageTooLarge, expected: false},
+		{err: ErrTopicForbidden, expected: false},
+		{err: context.Canceled, expected: false},
+		{err: assert.AnError, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, Retriable(tc.err))
+		})
+	}
+}
+
+func TestAmbiguous(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: fmt.Errorf("%w: kafka: REQUEST_TIMED_OUT", ErrBrokerUnavailable), expected: true},
+		{err: context.DeadlineExceeded, expected: true},
+		{err: ErrCircuitOpen, expected: false},
+		{err: ErrProducerClosed, expected: false},
+		{err: ErrUnknownTopic, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, Ambiguous(tc.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.1}
+	for attempt, expected := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 4: 800 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			assert.InDelta(t, float64(expected), float64(policy.Backoff(attempt)), float64(expected)/10)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		delay := policy.Backoff(10)
+		assert.LessOrEqual(t, delay, time.Second, "delays should not exceed the max backoff")
+		assert.GreaterOrEqual(t, delay, 900*time.Millisecond)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	testCases := []struct {
+		name             string
+		policy           RetryPolicy
+		errs             []error
+		expectedAttempts int
+		expectedErr      error
+		expectedRetries  int
+	}{
+		{name: "should succeed at once", errs: []error{nil}, expectedAttempts: 1},
+		{name: "should retry retriable errors", errs: []error{ErrBrokerUnavailable, context.DeadlineExceeded, nil}, expectedAttempts: 3, expectedRetries: 2},
+		{name: "should stop at an error that is not retriable", errs: []error{ErrBrokerUnavailable, ErrUnknownTopic, nil}, expectedAttempts: 2, expectedErr: ErrUnknownTopic, expectedRetries: 1},
+		{name: "should stop after the max attempts", policy: RetryPolicy{MaxAttempts: 2}, errs: []error{ErrBrokerUnavailable, ErrBrokerUnavailable, nil}, expectedAttempts: 2, expectedErr: ErrBrokerUnavailable, expectedRetries: 1},
+		{name: "should use the given classification", policy: RetryPolicy{IsRetriable: func(error) bool { return true }}, errs: []error{assert.AnError, nil}, expectedAttempts: 2, expectedRetries: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.policy.InitialBackoff = time.Millisecond
+			calls, retries := 0, 0
+			attempts, err := tc.policy.Do(context.Background(), func(context.Context) error {
+				calls++
+				return tc.errs[calls-1]
+			}, func(attempt int, err error, delay time.Duration) {
+				retries++
+				assert.Equal(t, retries, attempt)
+				assert.Error(t, err)
+				assert.Greater(t, delay, time.Duration(0))
+			})
+			assert.Equal(t, tc.expectedAttempts, attempts)
+			assert.Equal(t, tc.expectedAttempts, calls)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedRetries, retries)
+		})
+	}
+
+	t.Run("should stop when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts, err := RetryPolicy{InitialBackoff: time.Hour}.Do(ctx, func(context.Context) error {
+			return ErrBrokerUnavailable
+		}, func(int, error, time.Duration) { cancel() })
+		assert.Equal(t, 1, attempts)
+		assert.ErrorIs(t, err, ErrBrokerUnavailable)
+	})
+
+	t.Run("should stop when the max elapsed time is over", func(t *testing.T) {
+		policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxElapsed: 50 * time.Millisecond}
+		start := time.Now()
+		attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+			return ErrCircuitOpen
+		}, nil)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Greater(t, attempts, 1)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationStore is a contract for recording revoked token IDs (jti)
+//
+//counterfeiter:generate . RevocationStore
+type RevocationStore interface {
+	// Revoke records a token ID; the record can be dropped once the token expires
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+	// Reload picks up token IDs recorded by other replicas; a no-op for
+	// stores that are not shared
+	Reload() error
+}
+
+// RunRevocationReload reloads the store every interval until ctx is done
+// Params: interval time.Duration - the delay between reloads
+// Params: onError func(error) - called when a reload fails
+func RunRevocationReload(ctx context.Context, store RevocationStore, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := store.Reload(); err != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// revocationEntry is one line of the revocation file
+type revocationEntry struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+type fileRevocationStore struct {
+	mu      sync.Mutex
+	path    string
+	revoked map[string]time.Time
+	// size and modTime of the file when it was last read
+	size    int64
+	modTime time.Time
+	now     func() time.Time
+}
+
+// NewFileRevocationStore creates a revocation store backed by an
+// append-only file of JSON lines. Expired entries are compacted away when
+// the store is opened, and entries appended by other replicas sharing the
+// file are picked up on Reload.
+// Params: path string - the revocation file, created when missing
+func NewFileRevocationStore(path string) (RevocationStore, error) {
+	s := &fileRevocationStore{
+		path:    path,
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Revoke appends a token ID to the file
+// Params: jti string - the token ID
+// Params: expiresAt time.Time - the token expiry
+func (s *fileRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	line, err := json.Marshal(revocationEntry{JTI: jti, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked
+// Params: jti string - the token ID
+func (s *fileRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+// Reload re-reads the file when it changed since it was last read
+func (s *fileRevocationStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// load reads the file when its size or modification time changed; callers
+// must hold the lock
+func (s *fileRevocationStore) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == s.size && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	revoked := make(map[string]time.Time)
+	now := s.now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry revocationEntry
+		// a torn last line from a crashed writer is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.JTI == "" {
+			continue
+		}
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		revoked[entry.JTI] = entry.ExpiresAt
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.revoked = revoked
+	s.size = info.Size()
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// compact rewrites the file atomically with the unexpired entries only
+func (s *fileRevocationStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".revoked-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for jti, expiresAt := range s.revoked {
+		line, err := json.Marshal(revocationEntry{JTI: jti, ExpiresAt: expiresAt.UTC()})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.size = info.Size()
+	s.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileRevocationStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations", "revoked.jsonl")
+	store, err := NewFileRevocationStore(path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke("a", time.Now().Add(time.Hour)))
+	revoked, err := store.IsRevoked("a")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	t.Run("should keep revocations across restarts", func(t *testing.T) {
+		reopened, err := NewFileRevocationStore(path)
+		require.NoError(t, err)
+		revoked, err := reopened.IsRevoked("a")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should pick up revocations of other replicas", func(t *testing.T) {
+		other, err := NewFileRevocationStore(path)
+		require.NoError(t, err)
+		require.NoError(t, other.Revoke("b", time.Now().Add(time.Hour)))
+		revoked, err := store.IsRevoked("b")
+		require.NoError(t, err)
+		assert.False(t, revoked, "lookups should not read the file")
+
+		require.NoError(t, store.Reload())
+		revoked, err = store.IsRevoked("b")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should compact expired entries on open", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "revoked.jsonl")
+		expired := `{"jti":"old","exp":"` + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339) + `"}` + "\n"
+		valid := `{"jti":"new","exp":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}` + "\n"
+		require.NoError(t, os.WriteFile(path, []byte(expired+valid), 0o600))
+
+		compacted, err := NewFileRevocationStore(path)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), "\n"))
+		revoked, err := compacted.IsRevoked("old")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+		revoked, err = compacted.IsRevoked("new")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should skip torn lines", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"jti":"c","ex`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		reopened, err := NewFileRevocationStore(path)
+		require.NoError(t, err)
+		revoked, err := reopened.IsRevoked("a")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+}
+
+func TestRunRevocationReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.jsonl")
+	store, err := NewFileRevocationStore(path)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunRevocationReload(ctx, store, time.Millisecond, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	other, err := NewFileRevocationStore(path)
+	require.NoError(t, err)
+	require.NoError(t, other.Revoke("a", time.Now().Add(time.Hour)))
+	assert.Eventually(t, func() bool {
+		revoked, err := store.IsRevoked("a")
+		return err == nil && revoked
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+type inMemoryRevocationStore struct {
+	mu        sync.Mutex
+	revoked   map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryRevocationStore creates a revocation store that keeps revoked
+// token IDs in memory. Revocations do not survive a restart.
+func NewInMemoryRevocationStore() RevocationStore {
+	return &inMemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Revoke records a token ID until the token expires
+// Params: jti string - the token ID
+// Params: expiresAt time.Time - the token expiry
+func (s *inMemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked
+// Params: jti string - the token ID
+func (s *inMemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+// Reload is a no-op, the store is not shared
+func (s *inMemoryRevocationStore) Reload() error {
+	return nil
+}
+
+// sweep drops records of expired tokens; callers must hold the lock
+func (s *inMemoryRevocationStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for jti, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInMemoryRevocationStore(t *testing.T) {
+	store := NewInMemoryRevocationStore()
+	now := time.Now()
+	store.(*inMemoryRevocationStore).now = func() time.Time { return now }
+
+	require.NoError(t, store.Revoke("a", now.Add(time.Minute)))
+	revoked, err := store.IsRevoked("a")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.IsRevoked("b")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// records of expired tokens are swept on the next revocation
+	now = now.Add(time.Hour)
+	require.NoError(t, store.Revoke("b", now.Add(time.Minute)))
+	revoked, err = store.IsRevoked("a")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ScopeProduce is the scope action that allows publishing to a topic, as in "produce:orders"
+const ScopeProduce = "produce"
+
+// scopesContextKey is the context key holding the scopes of the authenticated token
+type scopesContextKey struct{}
+
+// ContextWithScopes returns a copy of ctx carrying the granted scopes
+// Params: ctx context.Context - the parent context
+// Params: scopes []string - the scopes granted to the caller
+func ContextWithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesContextKey{}, scopes)
+}
+
+// ScopesFromContext returns the scopes stored by ContextWithScopes, or nil
+// Params: ctx context.Context - the request context
+func ScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesContextKey{}).([]string)
+	return scopes
+}
+
+// ParseScopes splits a space-delimited scope claim (RFC 6749 section 3.3)
+// Params: scope string - the scope claim
+func ParseScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// ValidateScope checks that a scope has the "<action>:<pattern>" form
+// Params: scope string - the scope to check
+func ValidateScope(scope string) error {
+	action, pattern, found := strings.Cut(scope, ":")
+	if !found || action == "" || pattern == "" || strings.ContainsAny(scope, " \t\n") {
+		return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+	return nil
+}
+
+// ScopesAllow reports whether any granted scope matches the scope. A "*" in
+// a granted scope matches any sequence of characters, so "produce:payments.*"
+// allows "produce:payments.eu" and "produce:*" allows every topic.
+// Params: granted []string - the granted scopes
+// Params: scope string - the required scope
+func ScopesAllow(granted []string, scope string) bool {
+	for _, pattern := range granted {
+		if matchWildcard(pattern, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches s against a pattern where "*" matches any sequence
+func matchWildcard(pattern, s string) bool {
+	// position of the last "*" and of the input it was tried against
+	star, next := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, i
+			p++
+		case p < len(pattern) && pattern[p] == s[i]:
+			p++
+			i++
+		case star >= 0:
+			next++
+			p, i = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
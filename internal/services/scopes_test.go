@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScopesAllow(t *testing.T) {
+	testCases := []struct {
+		name    string
+		granted []string
+		scope   string
+		allowed bool
+	}{
+		{name: "should allow an exact match", granted: []string{"produce:orders"}, scope: "produce:orders", allowed: true},
+		{name: "should reject another topic", granted: []string{"produce:orders"}, scope: "produce:orders.eu", allowed: false},
+		{name: "should allow a suffix wildcard", granted: []string{"produce:payments.*"}, scope: "produce:payments.eu", allowed: true},
+		{name: "should allow nested topics under a wildcard", granted: []string{"produce:payments.*"}, scope: "produce:payments.eu.refunds", allowed: true},
+		{name: "should reject the wildcard prefix alone", granted: []string{"produce:payments.*"}, scope: "produce:payments", allowed: false},
+		{name: "should allow a wildcard in the middle", granted: []string{"produce:*.audit"}, scope: "produce:orders.audit", allowed: true},
+		{name: "should reject a wildcard in the middle that does not match", granted: []string{"produce:*.audit"}, scope: "produce:orders.audits", allowed: false},
+		{name: "should allow every topic", granted: []string{"produce:*"}, scope: "produce:anything", allowed: true},
+		{name: "should reject another action", granted: []string{"produce:*"}, scope: "consume:orders", allowed: false},
+		{name: "should check every granted scope", granted: []string{"produce:orders", "produce:payments.*"}, scope: "produce:payments.us", allowed: true},
+		{name: "should reject when nothing is granted", granted: nil, scope: "produce:orders", allowed: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, ScopesAllow(tc.granted, tc.scope))
+		})
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	assert.NoError(t, ValidateScope("produce:orders"))
+	assert.NoError(t, ValidateScope("produce:payments.*"))
+	assert.ErrorIs(t, ValidateScope("orders"), ErrInvalidScope)
+	assert.ErrorIs(t, ValidateScope("produce:"), ErrInvalidScope)
+	assert.ErrorIs(t, ValidateScope(":orders"), ErrInvalidScope)
+}
+
+func TestScopesContext(t *testing.T) {
+	assert.Nil(t, ScopesFromContext(context.Background()))
+	ctx := ContextWithScopes(context.Background(), ParseScopes(" produce:orders  produce:payments.* "))
+	assert.Equal(t, []string{"produce:orders", "produce:payments.*"}, ScopesFromContext(ctx))
+}
@@ -0,0 +1,213 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeCircuitBreaker struct {
+	AllowStub        func() (services.CircuitCall, error)
+	allowMutex       sync.RWMutex
+	allowArgsForCall []struct {
+	}
+	allowReturns struct {
+		result1 services.CircuitCall
+		result2 error
+	}
+	allowReturnsOnCall map[int]struct {
+		result1 services.CircuitCall
+		result2 error
+	}
+	RecordStub        func(services.CircuitCall, error)
+	recordMutex       sync.RWMutex
+	recordArgsForCall []struct {
+		arg1 services.CircuitCall
+		arg2 error
+	}
+	StateStub        func() services.CircuitBreakerState
+	stateMutex       sync.RWMutex
+	stateArgsForCall []struct {
+	}
+	stateReturns struct {
+		result1 services.CircuitBreakerState
+	}
+	stateReturnsOnCall map[int]struct {
+		result1 services.CircuitBreakerState
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCircuitBreaker) Allow() (services.CircuitCall, error) {
+	fake.allowMutex.Lock()
+	ret, specificReturn := fake.allowReturnsOnCall[len(fake.allowArgsForCall)]
+	fake.allowArgsForCall = append(fake.allowArgsForCall, struct {
+	}{})
+	stub := fake.AllowStub
+	fakeReturns := fake.allowReturns
+	fake.recordInvocation("Allow", []interface{}{})
+	fake.allowMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCircuitBreaker) AllowCallCount() int {
+	fake.allowMutex.RLock()
+	defer fake.allowMutex.RUnlock()
+	return len(fake.allowArgsForCall)
+}
+
+func (fake *FakeCircuitBreaker) AllowCalls(stub func() (services.CircuitCall, error)) {
+	fake.allowMutex.Lock()
+	defer fake.allowMutex.Unlock()
+	fake.AllowStub = stub
+}
+
+func (fake *FakeCircuitBreaker) AllowReturns(result1 services.CircuitCall, result2 error) {
+	fake.allowMutex.Lock()
+	defer fake.allowMutex.Unlock()
+	fake.AllowStub = nil
+	fake.allowReturns = struct {
+		result1 services.CircuitCall
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCircuitBreaker) AllowReturnsOnCall(i int, result1 services.CircuitCall, result2 error) {
+	fake.allowMutex.Lock()
+	defer fake.allowMutex.Unlock()
+	fake.AllowStub = nil
+	if fake.allowReturnsOnCall == nil {
+		fake.allowReturnsOnCall = make(map[int]struct {
+			result1 services.CircuitCall
+			result2 error
+		})
+	}
+	fake.allowReturnsOnCall[i] = struct {
+		result1 services.CircuitCall
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCircuitBreaker) Record(arg1 services.CircuitCall, arg2 error) {
+	fake.recordMutex.Lock()
+	fake.recordArgsForCall = append(fake.recordArgsForCall, struct {
+		arg1 services.CircuitCall
+		arg2 error
+	}{arg1, arg2})
+	stub := fake.RecordStub
+	fake.recordInvocation("Record", []interface{}{arg1, arg2})
+	fake.recordMutex.Unlock()
+	if stub != nil {
+		fake.RecordStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeCircuitBreaker) RecordCallCount() int {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return len(fake.recordArgsForCall)
+}
+
+func (fake *FakeCircuitBreaker) RecordCalls(stub func(services.CircuitCall, error)) {
+	fake.recordMutex.Lock()
+	defer fake.recordMutex.Unlock()
+	fake.RecordStub = stub
+}
+
+func (fake *FakeCircuitBreaker) RecordArgsForCall(i int) (services.CircuitCall, error) {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	argsForCall := fake.recordArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCircuitBreaker) State() services.CircuitBreakerState {
+	fake.stateMutex.Lock()
+	ret, specificReturn := fake.stateReturnsOnCall[len(fake.stateArgsForCall)]
+	fake.stateArgsForCall = append(fake.stateArgsForCall, struct {
+	}{})
+	stub := fake.StateStub
+	fakeReturns := fake.stateReturns
+	fake.recordInvocation("State", []interface{}{})
+	fake.stateMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeCircuitBreaker) StateCallCount() int {
+	fake.stateMutex.RLock()
+	defer fake.stateMutex.RUnlock()
+	return len(fake.stateArgsForCall)
+}
+
+func (fake *FakeCircuitBreaker) StateCalls(stub func() services.CircuitBreakerState) {
+	fake.stateMutex.Lock()
+	defer fake.stateMutex.Unlock()
+	fake.StateStub = stub
+}
+
+func (fake *FakeCircuitBreaker) StateReturns(result1 services.CircuitBreakerState) {
+	fake.stateMutex.Lock()
+	defer fake.stateMutex.Unlock()
+	fake.StateStub = nil
+	fake.stateReturns = struct {
+		result1 services.CircuitBreakerState
+	}{result1}
+}
+
+func (fake *FakeCircuitBreaker) StateReturnsOnCall(i int, result1 services.CircuitBreakerState) {
+	fake.stateMutex.Lock()
+	defer fake.stateMutex.Unlock()
+	fake.StateStub = nil
+	if fake.stateReturnsOnCall == nil {
+		fake.stateReturnsOnCall = make(map[int]struct {
+			result1 services.CircuitBreakerState
+		})
+	}
+	fake.stateReturnsOnCall[i] = struct {
+		result1 services.CircuitBreakerState
+	}{result1}
+}
+
+func (fake *FakeCircuitBreaker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.allowMutex.RLock()
+	defer fake.allowMutex.RUnlock()
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	fake.stateMutex.RLock()
+	defer fake.stateMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCircuitBreaker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.CircuitBreaker = new(FakeCircuitBreaker)
@@ -0,0 +1,273 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeIdempotencyStore struct {
+	FinishStub        func(string, services.IdempotencyRecord, time.Duration) error
+	finishMutex       sync.RWMutex
+	finishArgsForCall []struct {
+		arg1 string
+		arg2 services.IdempotencyRecord
+		arg3 time.Duration
+	}
+	finishReturns struct {
+		result1 error
+	}
+	finishReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ForgetStub        func(string) error
+	forgetMutex       sync.RWMutex
+	forgetArgsForCall []struct {
+		arg1 string
+	}
+	forgetReturns struct {
+		result1 error
+	}
+	forgetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	StartStub        func(string, string, time.Duration) (*services.IdempotencyRecord, error)
+	startMutex       sync.RWMutex
+	startArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 time.Duration
+	}
+	startReturns struct {
+		result1 *services.IdempotencyRecord
+		result2 error
+	}
+	startReturnsOnCall map[int]struct {
+		result1 *services.IdempotencyRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeIdempotencyStore) Finish(arg1 string, arg2 services.IdempotencyRecord, arg3 time.Duration) error {
+	fake.finishMutex.Lock()
+	ret, specificReturn := fake.finishReturnsOnCall[len(fake.finishArgsForCall)]
+	fake.finishArgsForCall = append(fake.finishArgsForCall, struct {
+		arg1 string
+		arg2 services.IdempotencyRecord
+		arg3 time.Duration
+	}{arg1, arg2, arg3})
+	stub := fake.FinishStub
+	fakeReturns := fake.finishReturns
+	fake.recordInvocation("Finish", []interface{}{arg1, arg2, arg3})
+	fake.finishMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeIdempotencyStore) FinishCallCount() int {
+	fake.finishMutex.RLock()
+	defer fake.finishMutex.RUnlock()
+	return len(fake.finishArgsForCall)
+}
+
+func (fake *FakeIdempotencyStore) FinishCalls(stub func(string, services.IdempotencyRecord, time.Duration) error) {
+	fake.finishMutex.Lock()
+	defer fake.finishMutex.Unlock()
+	fake.FinishStub = stub
+}
+
+func (fake *FakeIdempotencyStore) FinishArgsForCall(i int) (string, services.IdempotencyRecord, time.Duration) {
+	fake.finishMutex.RLock()
+	defer fake.finishMutex.RUnlock()
+	argsForCall := fake.finishArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeIdempotencyStore) FinishReturns(result1 error) {
+	fake.finishMutex.Lock()
+	defer fake.finishMutex.Unlock()
+	fake.FinishStub = nil
+	fake.finishReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeIdempotencyStore) FinishReturnsOnCall(i int, result1 error) {
+	fake.finishMutex.Lock()
+	defer fake.finishMutex.Unlock()
+	fake.FinishStub = nil
+	if fake.finishReturnsOnCall == nil {
+		fake.finishReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.finishReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeIdempotencyStore) Forget(arg1 string) error {
+	fake.forgetMutex.Lock()
+	ret, specificReturn := fake.forgetReturnsOnCall[len(fake.forgetArgsForCall)]
+	fake.forgetArgsForCall = append(fake.forgetArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ForgetStub
+	fakeReturns := fake.forgetReturns
+	fake.recordInvocation("Forget", []interface{}{arg1})
+	fake.forgetMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeIdempotencyStore) ForgetCallCount() int {
+	fake.forgetMutex.RLock()
+	defer fake.forgetMutex.RUnlock()
+	return len(fake.forgetArgsForCall)
+}
+
+func (fake *FakeIdempotencyStore) ForgetCalls(stub func(string) error) {
+	fake.forgetMutex.Lock()
+	defer fake.forgetMutex.Unlock()
+	fake.ForgetStub = stub
+}
+
+func (fake *FakeIdempotencyStore) ForgetArgsForCall(i int) string {
+	fake.forgetMutex.RLock()
+	defer fake.forgetMutex.RUnlock()
+	argsForCall := fake.forgetArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeIdempotencyStore) ForgetReturns(result1 error) {
+	fake.forgetMutex.Lock()
+	defer fake.forgetMutex.Unlock()
+	fake.ForgetStub = nil
+	fake.forgetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeIdempotencyStore) ForgetReturnsOnCall(i int, result1 error) {
+	fake.forgetMutex.Lock()
+	defer fake.forgetMutex.Unlock()
+	fake.ForgetStub = nil
+	if fake.forgetReturnsOnCall == nil {
+		fake.forgetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.forgetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeIdempotencyStore) Start(arg1 string, arg2 string, arg3 time.Duration) (*services.IdempotencyRecord, error) {
+	fake.startMutex.Lock()
+	ret, specificReturn := fake.startReturnsOnCall[len(fake.startArgsForCall)]
+	fake.startArgsForCall = append(fake.startArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 time.Duration
+	}{arg1, arg2, arg3})
+	stub := fake.StartStub
+	fakeReturns := fake.startReturns
+	fake.recordInvocation("Start", []interface{}{arg1, arg2, arg3})
+	fake.startMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeIdempotencyStore) StartCallCount() int {
+	fake.startMutex.RLock()
+	defer fake.startMutex.RUnlock()
+	return len(fake.startArgsForCall)
+}
+
+func (fake *FakeIdempotencyStore) StartCalls(stub func(string, string, time.Duration) (*services.IdempotencyRecord, error)) {
+	fake.startMutex.Lock()
+	defer fake.startMutex.Unlock()
+	fake.StartStub = stub
+}
+
+func (fake *FakeIdempotencyStore) StartArgsForCall(i int) (string, string, time.Duration) {
+	fake.startMutex.RLock()
+	defer fake.startMutex.RUnlock()
+	argsForCall := fake.startArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeIdempotencyStore) StartReturns(result1 *services.IdempotencyRecord, result2 error) {
+	fake.startMutex.Lock()
+	defer fake.startMutex.Unlock()
+	fake.StartStub = nil
+	fake.startReturns = struct {
+		result1 *services.IdempotencyRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeIdempotencyStore) StartReturnsOnCall(i int, result1 *services.IdempotencyRecord, result2 error) {
+	fake.startMutex.Lock()
+	defer fake.startMutex.Unlock()
+	fake.StartStub = nil
+	if fake.startReturnsOnCall == nil {
+		fake.startReturnsOnCall = make(map[int]struct {
+			result1 *services.IdempotencyRecord
+			result2 error
+		})
+	}
+	fake.startReturnsOnCall[i] = struct {
+		result1 *services.IdempotencyRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeIdempotencyStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.finishMutex.RLock()
+	defer fake.finishMutex.RUnlock()
+	fake.forgetMutex.RLock()
+	defer fake.forgetMutex.RUnlock()
+	fake.startMutex.RLock()
+	defer fake.startMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeIdempotencyStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.IdempotencyStore = new(FakeIdempotencyStore)
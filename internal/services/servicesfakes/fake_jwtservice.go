@@ -3,16 +3,17 @@ package servicesfakes
 
 import (
 	"sync"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/nathaliaguayos/msg-receiver/internal/services"
 )
 
 type FakeJWTService struct {
-	GenerateTokenStub        func(string) (string, error)
+	GenerateTokenStub        func(services.TokenClaims) (string, error)
 	generateTokenMutex       sync.RWMutex
 	generateTokenArgsForCall []struct {
-		arg1 string
+		arg1 services.TokenClaims
 	}
 	generateTokenReturns struct {
 		result1 string
@@ -22,6 +23,50 @@ type FakeJWTService struct {
 		result1 string
 		result2 error
 	}
+	JWKSStub        func() services.JWKS
+	jWKSMutex       sync.RWMutex
+	jWKSArgsForCall []struct {
+	}
+	jWKSReturns struct {
+		result1 services.JWKS
+	}
+	jWKSReturnsOnCall map[int]struct {
+		result1 services.JWKS
+	}
+	RevokeTokenStub        func(string, string) error
+	revokeTokenMutex       sync.RWMutex
+	revokeTokenArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	revokeTokenReturns struct {
+		result1 error
+	}
+	revokeTokenReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RotateSigningKeyStub        func() (string, error)
+	rotateSigningKeyMutex       sync.RWMutex
+	rotateSigningKeyArgsForCall []struct {
+	}
+	rotateSigningKeyReturns struct {
+		result1 string
+		result2 error
+	}
+	rotateSigningKeyReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	TokenTTLStub        func() time.Duration
+	tokenTTLMutex       sync.RWMutex
+	tokenTTLArgsForCall []struct {
+	}
+	tokenTTLReturns struct {
+		result1 time.Duration
+	}
+	tokenTTLReturnsOnCall map[int]struct {
+		result1 time.Duration
+	}
 	ValidateTokenStub        func(string) (*jwt.Token, error)
 	validateTokenMutex       sync.RWMutex
 	validateTokenArgsForCall []struct {
@@ -39,11 +84,11 @@ type FakeJWTService struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeJWTService) GenerateToken(arg1 string) (string, error) {
+func (fake *FakeJWTService) GenerateToken(arg1 services.TokenClaims) (string, error) {
 	fake.generateTokenMutex.Lock()
 	ret, specificReturn := fake.generateTokenReturnsOnCall[len(fake.generateTokenArgsForCall)]
 	fake.generateTokenArgsForCall = append(fake.generateTokenArgsForCall, struct {
-		arg1 string
+		arg1 services.TokenClaims
 	}{arg1})
 	stub := fake.GenerateTokenStub
 	fakeReturns := fake.generateTokenReturns
@@ -64,13 +109,13 @@ func (fake *FakeJWTService) GenerateTokenCallCount() int {
 	return len(fake.generateTokenArgsForCall)
 }
 
-func (fake *FakeJWTService) GenerateTokenCalls(stub func(string) (string, error)) {
+func (fake *FakeJWTService) GenerateTokenCalls(stub func(services.TokenClaims) (string, error)) {
 	fake.generateTokenMutex.Lock()
 	defer fake.generateTokenMutex.Unlock()
 	fake.GenerateTokenStub = stub
 }
 
-func (fake *FakeJWTService) GenerateTokenArgsForCall(i int) string {
+func (fake *FakeJWTService) GenerateTokenArgsForCall(i int) services.TokenClaims {
 	fake.generateTokenMutex.RLock()
 	defer fake.generateTokenMutex.RUnlock()
 	argsForCall := fake.generateTokenArgsForCall[i]
@@ -103,6 +148,230 @@ func (fake *FakeJWTService) GenerateTokenReturnsOnCall(i int, result1 string, re
 	}{result1, result2}
 }
 
+func (fake *FakeJWTService) JWKS() services.JWKS {
+	fake.jWKSMutex.Lock()
+	ret, specificReturn := fake.jWKSReturnsOnCall[len(fake.jWKSArgsForCall)]
+	fake.jWKSArgsForCall = append(fake.jWKSArgsForCall, struct {
+	}{})
+	stub := fake.JWKSStub
+	fakeReturns := fake.jWKSReturns
+	fake.recordInvocation("JWKS", []interface{}{})
+	fake.jWKSMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeJWTService) JWKSCallCount() int {
+	fake.jWKSMutex.RLock()
+	defer fake.jWKSMutex.RUnlock()
+	return len(fake.jWKSArgsForCall)
+}
+
+func (fake *FakeJWTService) JWKSCalls(stub func() services.JWKS) {
+	fake.jWKSMutex.Lock()
+	defer fake.jWKSMutex.Unlock()
+	fake.JWKSStub = stub
+}
+
+func (fake *FakeJWTService) JWKSReturns(result1 services.JWKS) {
+	fake.jWKSMutex.Lock()
+	defer fake.jWKSMutex.Unlock()
+	fake.JWKSStub = nil
+	fake.jWKSReturns = struct {
+		result1 services.JWKS
+	}{result1}
+}
+
+func (fake *FakeJWTService) JWKSReturnsOnCall(i int, result1 services.JWKS) {
+	fake.jWKSMutex.Lock()
+	defer fake.jWKSMutex.Unlock()
+	fake.JWKSStub = nil
+	if fake.jWKSReturnsOnCall == nil {
+		fake.jWKSReturnsOnCall = make(map[int]struct {
+			result1 services.JWKS
+		})
+	}
+	fake.jWKSReturnsOnCall[i] = struct {
+		result1 services.JWKS
+	}{result1}
+}
+
+func (fake *FakeJWTService) RevokeToken(arg1 string, arg2 string) error {
+	fake.revokeTokenMutex.Lock()
+	ret, specificReturn := fake.revokeTokenReturnsOnCall[len(fake.revokeTokenArgsForCall)]
+	fake.revokeTokenArgsForCall = append(fake.revokeTokenArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.RevokeTokenStub
+	fakeReturns := fake.revokeTokenReturns
+	fake.recordInvocation("RevokeToken", []interface{}{arg1, arg2})
+	fake.revokeTokenMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeJWTService) RevokeTokenCallCount() int {
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	return len(fake.revokeTokenArgsForCall)
+}
+
+func (fake *FakeJWTService) RevokeTokenCalls(stub func(string, string) error) {
+	fake.revokeTokenMutex.Lock()
+	defer fake.revokeTokenMutex.Unlock()
+	fake.RevokeTokenStub = stub
+}
+
+func (fake *FakeJWTService) RevokeTokenArgsForCall(i int) (string, string) {
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	argsForCall := fake.revokeTokenArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeJWTService) RevokeTokenReturns(result1 error) {
+	fake.revokeTokenMutex.Lock()
+	defer fake.revokeTokenMutex.Unlock()
+	fake.RevokeTokenStub = nil
+	fake.revokeTokenReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeJWTService) RevokeTokenReturnsOnCall(i int, result1 error) {
+	fake.revokeTokenMutex.Lock()
+	defer fake.revokeTokenMutex.Unlock()
+	fake.RevokeTokenStub = nil
+	if fake.revokeTokenReturnsOnCall == nil {
+		fake.revokeTokenReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.revokeTokenReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeJWTService) RotateSigningKey() (string, error) {
+	fake.rotateSigningKeyMutex.Lock()
+	ret, specificReturn := fake.rotateSigningKeyReturnsOnCall[len(fake.rotateSigningKeyArgsForCall)]
+	fake.rotateSigningKeyArgsForCall = append(fake.rotateSigningKeyArgsForCall, struct {
+	}{})
+	stub := fake.RotateSigningKeyStub
+	fakeReturns := fake.rotateSigningKeyReturns
+	fake.recordInvocation("RotateSigningKey", []interface{}{})
+	fake.rotateSigningKeyMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeJWTService) RotateSigningKeyCallCount() int {
+	fake.rotateSigningKeyMutex.RLock()
+	defer fake.rotateSigningKeyMutex.RUnlock()
+	return len(fake.rotateSigningKeyArgsForCall)
+}
+
+func (fake *FakeJWTService) RotateSigningKeyCalls(stub func() (string, error)) {
+	fake.rotateSigningKeyMutex.Lock()
+	defer fake.rotateSigningKeyMutex.Unlock()
+	fake.RotateSigningKeyStub = stub
+}
+
+func (fake *FakeJWTService) RotateSigningKeyReturns(result1 string, result2 error) {
+	fake.rotateSigningKeyMutex.Lock()
+	defer fake.rotateSigningKeyMutex.Unlock()
+	fake.RotateSigningKeyStub = nil
+	fake.rotateSigningKeyReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeJWTService) RotateSigningKeyReturnsOnCall(i int, result1 string, result2 error) {
+	fake.rotateSigningKeyMutex.Lock()
+	defer fake.rotateSigningKeyMutex.Unlock()
+	fake.RotateSigningKeyStub = nil
+	if fake.rotateSigningKeyReturnsOnCall == nil {
+		fake.rotateSigningKeyReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.rotateSigningKeyReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeJWTService) TokenTTL() time.Duration {
+	fake.tokenTTLMutex.Lock()
+	ret, specificReturn := fake.tokenTTLReturnsOnCall[len(fake.tokenTTLArgsForCall)]
+	fake.tokenTTLArgsForCall = append(fake.tokenTTLArgsForCall, struct {
+	}{})
+	stub := fake.TokenTTLStub
+	fakeReturns := fake.tokenTTLReturns
+	fake.recordInvocation("TokenTTL", []interface{}{})
+	fake.tokenTTLMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeJWTService) TokenTTLCallCount() int {
+	fake.tokenTTLMutex.RLock()
+	defer fake.tokenTTLMutex.RUnlock()
+	return len(fake.tokenTTLArgsForCall)
+}
+
+func (fake *FakeJWTService) TokenTTLCalls(stub func() time.Duration) {
+	fake.tokenTTLMutex.Lock()
+	defer fake.tokenTTLMutex.Unlock()
+	fake.TokenTTLStub = stub
+}
+
+func (fake *FakeJWTService) TokenTTLReturns(result1 time.Duration) {
+	fake.tokenTTLMutex.Lock()
+	defer fake.tokenTTLMutex.Unlock()
+	fake.TokenTTLStub = nil
+	fake.tokenTTLReturns = struct {
+		result1 time.Duration
+	}{result1}
+}
+
+func (fake *FakeJWTService) TokenTTLReturnsOnCall(i int, result1 time.Duration) {
+	fake.tokenTTLMutex.Lock()
+	defer fake.tokenTTLMutex.Unlock()
+	fake.TokenTTLStub = nil
+	if fake.tokenTTLReturnsOnCall == nil {
+		fake.tokenTTLReturnsOnCall = make(map[int]struct {
+			result1 time.Duration
+		})
+	}
+	fake.tokenTTLReturnsOnCall[i] = struct {
+		result1 time.Duration
+	}{result1}
+}
+
 func (fake *FakeJWTService) ValidateToken(arg1 string) (*jwt.Token, error) {
 	fake.validateTokenMutex.Lock()
 	ret, specificReturn := fake.validateTokenReturnsOnCall[len(fake.validateTokenArgsForCall)]
@@ -172,6 +441,14 @@ func (fake *FakeJWTService) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.generateTokenMutex.RLock()
 	defer fake.generateTokenMutex.RUnlock()
+	fake.jWKSMutex.RLock()
+	defer fake.jWKSMutex.RUnlock()
+	fake.revokeTokenMutex.RLock()
+	defer fake.revokeTokenMutex.RUnlock()
+	fake.rotateSigningKeyMutex.RLock()
+	defer fake.rotateSigningKeyMutex.RUnlock()
+	fake.tokenTTLMutex.RLock()
+	defer fake.tokenTTLMutex.RUnlock()
 	fake.validateTokenMutex.RLock()
 	defer fake.validateTokenMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
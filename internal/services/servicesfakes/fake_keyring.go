@@ -0,0 +1,461 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeKeyring struct {
+	ReloadStub        func() error
+	reloadMutex       sync.RWMutex
+	reloadArgsForCall []struct {
+	}
+	reloadReturns struct {
+		result1 error
+	}
+	reloadReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RotateStub        func() (*services.SigningKey, error)
+	rotateMutex       sync.RWMutex
+	rotateArgsForCall []struct {
+	}
+	rotateReturns struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	rotateReturnsOnCall map[int]struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	RotateExpiredStub        func(time.Duration) (*services.SigningKey, error)
+	rotateExpiredMutex       sync.RWMutex
+	rotateExpiredArgsForCall []struct {
+		arg1 time.Duration
+	}
+	rotateExpiredReturns struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	rotateExpiredReturnsOnCall map[int]struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	SigningKeyStub        func() *services.SigningKey
+	signingKeyMutex       sync.RWMutex
+	signingKeyArgsForCall []struct {
+	}
+	signingKeyReturns struct {
+		result1 *services.SigningKey
+	}
+	signingKeyReturnsOnCall map[int]struct {
+		result1 *services.SigningKey
+	}
+	VerificationKeyStub        func(string) (*services.SigningKey, error)
+	verificationKeyMutex       sync.RWMutex
+	verificationKeyArgsForCall []struct {
+		arg1 string
+	}
+	verificationKeyReturns struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	verificationKeyReturnsOnCall map[int]struct {
+		result1 *services.SigningKey
+		result2 error
+	}
+	VerificationKeysStub        func() []*services.SigningKey
+	verificationKeysMutex       sync.RWMutex
+	verificationKeysArgsForCall []struct {
+	}
+	verificationKeysReturns struct {
+		result1 []*services.SigningKey
+	}
+	verificationKeysReturnsOnCall map[int]struct {
+		result1 []*services.SigningKey
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeKeyring) Reload() error {
+	fake.reloadMutex.Lock()
+	ret, specificReturn := fake.reloadReturnsOnCall[len(fake.reloadArgsForCall)]
+	fake.reloadArgsForCall = append(fake.reloadArgsForCall, struct {
+	}{})
+	stub := fake.ReloadStub
+	fakeReturns := fake.reloadReturns
+	fake.recordInvocation("Reload", []interface{}{})
+	fake.reloadMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeKeyring) ReloadCallCount() int {
+	fake.reloadMutex.RLock()
+	defer fake.reloadMutex.RUnlock()
+	return len(fake.reloadArgsForCall)
+}
+
+func (fake *FakeKeyring) ReloadCalls(stub func() error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = stub
+}
+
+func (fake *FakeKeyring) ReloadReturns(result1 error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = nil
+	fake.reloadReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeKeyring) ReloadReturnsOnCall(i int, result1 error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = nil
+	if fake.reloadReturnsOnCall == nil {
+		fake.reloadReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.reloadReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeKeyring) Rotate() (*services.SigningKey, error) {
+	fake.rotateMutex.Lock()
+	ret, specificReturn := fake.rotateReturnsOnCall[len(fake.rotateArgsForCall)]
+	fake.rotateArgsForCall = append(fake.rotateArgsForCall, struct {
+	}{})
+	stub := fake.RotateStub
+	fakeReturns := fake.rotateReturns
+	fake.recordInvocation("Rotate", []interface{}{})
+	fake.rotateMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeKeyring) RotateCallCount() int {
+	fake.rotateMutex.RLock()
+	defer fake.rotateMutex.RUnlock()
+	return len(fake.rotateArgsForCall)
+}
+
+func (fake *FakeKeyring) RotateCalls(stub func() (*services.SigningKey, error)) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = stub
+}
+
+func (fake *FakeKeyring) RotateReturns(result1 *services.SigningKey, result2 error) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = nil
+	fake.rotateReturns = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) RotateReturnsOnCall(i int, result1 *services.SigningKey, result2 error) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = nil
+	if fake.rotateReturnsOnCall == nil {
+		fake.rotateReturnsOnCall = make(map[int]struct {
+			result1 *services.SigningKey
+			result2 error
+		})
+	}
+	fake.rotateReturnsOnCall[i] = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) RotateExpired(arg1 time.Duration) (*services.SigningKey, error) {
+	fake.rotateExpiredMutex.Lock()
+	ret, specificReturn := fake.rotateExpiredReturnsOnCall[len(fake.rotateExpiredArgsForCall)]
+	fake.rotateExpiredArgsForCall = append(fake.rotateExpiredArgsForCall, struct {
+		arg1 time.Duration
+	}{arg1})
+	stub := fake.RotateExpiredStub
+	fakeReturns := fake.rotateExpiredReturns
+	fake.recordInvocation("RotateExpired", []interface{}{arg1})
+	fake.rotateExpiredMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeKeyring) RotateExpiredCallCount() int {
+	fake.rotateExpiredMutex.RLock()
+	defer fake.rotateExpiredMutex.RUnlock()
+	return len(fake.rotateExpiredArgsForCall)
+}
+
+func (fake *FakeKeyring) RotateExpiredCalls(stub func(time.Duration) (*services.SigningKey, error)) {
+	fake.rotateExpiredMutex.Lock()
+	defer fake.rotateExpiredMutex.Unlock()
+	fake.RotateExpiredStub = stub
+}
+
+func (fake *FakeKeyring) RotateExpiredArgsForCall(i int) time.Duration {
+	fake.rotateExpiredMutex.RLock()
+	defer fake.rotateExpiredMutex.RUnlock()
+	argsForCall := fake.rotateExpiredArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeKeyring) RotateExpiredReturns(result1 *services.SigningKey, result2 error) {
+	fake.rotateExpiredMutex.Lock()
+	defer fake.rotateExpiredMutex.Unlock()
+	fake.RotateExpiredStub = nil
+	fake.rotateExpiredReturns = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) RotateExpiredReturnsOnCall(i int, result1 *services.SigningKey, result2 error) {
+	fake.rotateExpiredMutex.Lock()
+	defer fake.rotateExpiredMutex.Unlock()
+	fake.RotateExpiredStub = nil
+	if fake.rotateExpiredReturnsOnCall == nil {
+		fake.rotateExpiredReturnsOnCall = make(map[int]struct {
+			result1 *services.SigningKey
+			result2 error
+		})
+	}
+	fake.rotateExpiredReturnsOnCall[i] = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) SigningKey() *services.SigningKey {
+	fake.signingKeyMutex.Lock()
+	ret, specificReturn := fake.signingKeyReturnsOnCall[len(fake.signingKeyArgsForCall)]
+	fake.signingKeyArgsForCall = append(fake.signingKeyArgsForCall, struct {
+	}{})
+	stub := fake.SigningKeyStub
+	fakeReturns := fake.signingKeyReturns
+	fake.recordInvocation("SigningKey", []interface{}{})
+	fake.signingKeyMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeKeyring) SigningKeyCallCount() int {
+	fake.signingKeyMutex.RLock()
+	defer fake.signingKeyMutex.RUnlock()
+	return len(fake.signingKeyArgsForCall)
+}
+
+func (fake *FakeKeyring) SigningKeyCalls(stub func() *services.SigningKey) {
+	fake.signingKeyMutex.Lock()
+	defer fake.signingKeyMutex.Unlock()
+	fake.SigningKeyStub = stub
+}
+
+func (fake *FakeKeyring) SigningKeyReturns(result1 *services.SigningKey) {
+	fake.signingKeyMutex.Lock()
+	defer fake.signingKeyMutex.Unlock()
+	fake.SigningKeyStub = nil
+	fake.signingKeyReturns = struct {
+		result1 *services.SigningKey
+	}{result1}
+}
+
+func (fake *FakeKeyring) SigningKeyReturnsOnCall(i int, result1 *services.SigningKey) {
+	fake.signingKeyMutex.Lock()
+	defer fake.signingKeyMutex.Unlock()
+	fake.SigningKeyStub = nil
+	if fake.signingKeyReturnsOnCall == nil {
+		fake.signingKeyReturnsOnCall = make(map[int]struct {
+			result1 *services.SigningKey
+		})
+	}
+	fake.signingKeyReturnsOnCall[i] = struct {
+		result1 *services.SigningKey
+	}{result1}
+}
+
+func (fake *FakeKeyring) VerificationKey(arg1 string) (*services.SigningKey, error) {
+	fake.verificationKeyMutex.Lock()
+	ret, specificReturn := fake.verificationKeyReturnsOnCall[len(fake.verificationKeyArgsForCall)]
+	fake.verificationKeyArgsForCall = append(fake.verificationKeyArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.VerificationKeyStub
+	fakeReturns := fake.verificationKeyReturns
+	fake.recordInvocation("VerificationKey", []interface{}{arg1})
+	fake.verificationKeyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeKeyring) VerificationKeyCallCount() int {
+	fake.verificationKeyMutex.RLock()
+	defer fake.verificationKeyMutex.RUnlock()
+	return len(fake.verificationKeyArgsForCall)
+}
+
+func (fake *FakeKeyring) VerificationKeyCalls(stub func(string) (*services.SigningKey, error)) {
+	fake.verificationKeyMutex.Lock()
+	defer fake.verificationKeyMutex.Unlock()
+	fake.VerificationKeyStub = stub
+}
+
+func (fake *FakeKeyring) VerificationKeyArgsForCall(i int) string {
+	fake.verificationKeyMutex.RLock()
+	defer fake.verificationKeyMutex.RUnlock()
+	argsForCall := fake.verificationKeyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeKeyring) VerificationKeyReturns(result1 *services.SigningKey, result2 error) {
+	fake.verificationKeyMutex.Lock()
+	defer fake.verificationKeyMutex.Unlock()
+	fake.VerificationKeyStub = nil
+	fake.verificationKeyReturns = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) VerificationKeyReturnsOnCall(i int, result1 *services.SigningKey, result2 error) {
+	fake.verificationKeyMutex.Lock()
+	defer fake.verificationKeyMutex.Unlock()
+	fake.VerificationKeyStub = nil
+	if fake.verificationKeyReturnsOnCall == nil {
+		fake.verificationKeyReturnsOnCall = make(map[int]struct {
+			result1 *services.SigningKey
+			result2 error
+		})
+	}
+	fake.verificationKeyReturnsOnCall[i] = struct {
+		result1 *services.SigningKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeyring) VerificationKeys() []*services.SigningKey {
+	fake.verificationKeysMutex.Lock()
+	ret, specificReturn := fake.verificationKeysReturnsOnCall[len(fake.verificationKeysArgsForCall)]
+	fake.verificationKeysArgsForCall = append(fake.verificationKeysArgsForCall, struct {
+	}{})
+	stub := fake.VerificationKeysStub
+	fakeReturns := fake.verificationKeysReturns
+	fake.recordInvocation("VerificationKeys", []interface{}{})
+	fake.verificationKeysMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeKeyring) VerificationKeysCallCount() int {
+	fake.verificationKeysMutex.RLock()
+	defer fake.verificationKeysMutex.RUnlock()
+	return len(fake.verificationKeysArgsForCall)
+}
+
+func (fake *FakeKeyring) VerificationKeysCalls(stub func() []*services.SigningKey) {
+	fake.verificationKeysMutex.Lock()
+	defer fake.verificationKeysMutex.Unlock()
+	fake.VerificationKeysStub = stub
+}
+
+func (fake *FakeKeyring) VerificationKeysReturns(result1 []*services.SigningKey) {
+	fake.verificationKeysMutex.Lock()
+	defer fake.verificationKeysMutex.Unlock()
+	fake.VerificationKeysStub = nil
+	fake.verificationKeysReturns = struct {
+		result1 []*services.SigningKey
+	}{result1}
+}
+
+func (fake *FakeKeyring) VerificationKeysReturnsOnCall(i int, result1 []*services.SigningKey) {
+	fake.verificationKeysMutex.Lock()
+	defer fake.verificationKeysMutex.Unlock()
+	fake.VerificationKeysStub = nil
+	if fake.verificationKeysReturnsOnCall == nil {
+		fake.verificationKeysReturnsOnCall = make(map[int]struct {
+			result1 []*services.SigningKey
+		})
+	}
+	fake.verificationKeysReturnsOnCall[i] = struct {
+		result1 []*services.SigningKey
+	}{result1}
+}
+
+func (fake *FakeKeyring) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.reloadMutex.RLock()
+	defer fake.reloadMutex.RUnlock()
+	fake.rotateMutex.RLock()
+	defer fake.rotateMutex.RUnlock()
+	fake.rotateExpiredMutex.RLock()
+	defer fake.rotateExpiredMutex.RUnlock()
+	fake.signingKeyMutex.RLock()
+	defer fake.signingKeyMutex.RUnlock()
+	fake.verificationKeyMutex.RLock()
+	defer fake.verificationKeyMutex.RUnlock()
+	fake.verificationKeysMutex.RLock()
+	defer fake.verificationKeysMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeKeyring) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.Keyring = new(FakeKeyring)
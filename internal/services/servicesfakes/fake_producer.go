@@ -0,0 +1,205 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeProducer struct {
+	CloseStub        func() error
+	closeMutex       sync.RWMutex
+	closeArgsForCall []struct {
+	}
+	closeReturns struct {
+		result1 error
+	}
+	closeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ProduceStub        func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error)
+	produceMutex       sync.RWMutex
+	produceArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+		arg4 []services.Header
+		arg5 []byte
+	}
+	produceReturns struct {
+		result1 *services.DeliveryResult
+		result2 error
+	}
+	produceReturnsOnCall map[int]struct {
+		result1 *services.DeliveryResult
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeProducer) Close() error {
+	fake.closeMutex.Lock()
+	ret, specificReturn := fake.closeReturnsOnCall[len(fake.closeArgsForCall)]
+	fake.closeArgsForCall = append(fake.closeArgsForCall, struct {
+	}{})
+	stub := fake.CloseStub
+	fakeReturns := fake.closeReturns
+	fake.recordInvocation("Close", []interface{}{})
+	fake.closeMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeProducer) CloseCallCount() int {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	return len(fake.closeArgsForCall)
+}
+
+func (fake *FakeProducer) CloseCalls(stub func() error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = stub
+}
+
+func (fake *FakeProducer) CloseReturns(result1 error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = nil
+	fake.closeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeProducer) CloseReturnsOnCall(i int, result1 error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = nil
+	if fake.closeReturnsOnCall == nil {
+		fake.closeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.closeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeProducer) Produce(arg1 context.Context, arg2 string, arg3 []byte, arg4 []services.Header, arg5 []byte) (*services.DeliveryResult, error) {
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	var arg4Copy []services.Header
+	if arg4 != nil {
+		arg4Copy = make([]services.Header, len(arg4))
+		copy(arg4Copy, arg4)
+	}
+	var arg5Copy []byte
+	if arg5 != nil {
+		arg5Copy = make([]byte, len(arg5))
+		copy(arg5Copy, arg5)
+	}
+	fake.produceMutex.Lock()
+	ret, specificReturn := fake.produceReturnsOnCall[len(fake.produceArgsForCall)]
+	fake.produceArgsForCall = append(fake.produceArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+		arg4 []services.Header
+		arg5 []byte
+	}{arg1, arg2, arg3Copy, arg4Copy, arg5Copy})
+	stub := fake.ProduceStub
+	fakeReturns := fake.produceReturns
+	fake.recordInvocation("Produce", []interface{}{arg1, arg2, arg3Copy, arg4Copy, arg5Copy})
+	fake.produceMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeProducer) ProduceCallCount() int {
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	return len(fake.produceArgsForCall)
+}
+
+func (fake *FakeProducer) ProduceCalls(stub func(context.Context, string, []byte, []services.Header, []byte) (*services.DeliveryResult, error)) {
+	fake.produceMutex.Lock()
+	defer fake.produceMutex.Unlock()
+	fake.ProduceStub = stub
+}
+
+func (fake *FakeProducer) ProduceArgsForCall(i int) (context.Context, string, []byte, []services.Header, []byte) {
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	argsForCall := fake.produceArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeProducer) ProduceReturns(result1 *services.DeliveryResult, result2 error) {
+	fake.produceMutex.Lock()
+	defer fake.produceMutex.Unlock()
+	fake.ProduceStub = nil
+	fake.produceReturns = struct {
+		result1 *services.DeliveryResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeProducer) ProduceReturnsOnCall(i int, result1 *services.DeliveryResult, result2 error) {
+	fake.produceMutex.Lock()
+	defer fake.produceMutex.Unlock()
+	fake.ProduceStub = nil
+	if fake.produceReturnsOnCall == nil {
+		fake.produceReturnsOnCall = make(map[int]struct {
+			result1 *services.DeliveryResult
+			result2 error
+		})
+	}
+	fake.produceReturnsOnCall[i] = struct {
+		result1 *services.DeliveryResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeProducer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	fake.produceMutex.RLock()
+	defer fake.produceMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeProducer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.Producer = new(FakeProducer)
@@ -0,0 +1,347 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeQuotaService struct {
+	ReleaseStub        func(*services.QuotaReservation) error
+	releaseMutex       sync.RWMutex
+	releaseArgsForCall []struct {
+		arg1 *services.QuotaReservation
+	}
+	releaseReturns struct {
+		result1 error
+	}
+	releaseReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReserveStub        func(string, int64, int64) (*services.QuotaReservation, error)
+	reserveMutex       sync.RWMutex
+	reserveArgsForCall []struct {
+		arg1 string
+		arg2 int64
+		arg3 int64
+	}
+	reserveReturns struct {
+		result1 *services.QuotaReservation
+		result2 error
+	}
+	reserveReturnsOnCall map[int]struct {
+		result1 *services.QuotaReservation
+		result2 error
+	}
+	ResetStub        func(string) error
+	resetMutex       sync.RWMutex
+	resetArgsForCall []struct {
+		arg1 string
+	}
+	resetReturns struct {
+		result1 error
+	}
+	resetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UsageStub        func(string) (*services.QuotaUsage, error)
+	usageMutex       sync.RWMutex
+	usageArgsForCall []struct {
+		arg1 string
+	}
+	usageReturns struct {
+		result1 *services.QuotaUsage
+		result2 error
+	}
+	usageReturnsOnCall map[int]struct {
+		result1 *services.QuotaUsage
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeQuotaService) Release(arg1 *services.QuotaReservation) error {
+	fake.releaseMutex.Lock()
+	ret, specificReturn := fake.releaseReturnsOnCall[len(fake.releaseArgsForCall)]
+	fake.releaseArgsForCall = append(fake.releaseArgsForCall, struct {
+		arg1 *services.QuotaReservation
+	}{arg1})
+	stub := fake.ReleaseStub
+	fakeReturns := fake.releaseReturns
+	fake.recordInvocation("Release", []interface{}{arg1})
+	fake.releaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeQuotaService) ReleaseCallCount() int {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return len(fake.releaseArgsForCall)
+}
+
+func (fake *FakeQuotaService) ReleaseCalls(stub func(*services.QuotaReservation) error) {
+	fake.releaseMutex.Lock()
+	defer fake.releaseMutex.Unlock()
+	fake.ReleaseStub = stub
+}
+
+func (fake *FakeQuotaService) ReleaseArgsForCall(i int) *services.QuotaReservation {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	argsForCall := fake.releaseArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaService) ReleaseReturns(result1 error) {
+	fake.releaseMutex.Lock()
+	defer fake.releaseMutex.Unlock()
+	fake.ReleaseStub = nil
+	fake.releaseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeQuotaService) ReleaseReturnsOnCall(i int, result1 error) {
+	fake.releaseMutex.Lock()
+	defer fake.releaseMutex.Unlock()
+	fake.ReleaseStub = nil
+	if fake.releaseReturnsOnCall == nil {
+		fake.releaseReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeQuotaService) Reserve(arg1 string, arg2 int64, arg3 int64) (*services.QuotaReservation, error) {
+	fake.reserveMutex.Lock()
+	ret, specificReturn := fake.reserveReturnsOnCall[len(fake.reserveArgsForCall)]
+	fake.reserveArgsForCall = append(fake.reserveArgsForCall, struct {
+		arg1 string
+		arg2 int64
+		arg3 int64
+	}{arg1, arg2, arg3})
+	stub := fake.ReserveStub
+	fakeReturns := fake.reserveReturns
+	fake.recordInvocation("Reserve", []interface{}{arg1, arg2, arg3})
+	fake.reserveMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeQuotaService) ReserveCallCount() int {
+	fake.reserveMutex.RLock()
+	defer fake.reserveMutex.RUnlock()
+	return len(fake.reserveArgsForCall)
+}
+
+func (fake *FakeQuotaService) ReserveCalls(stub func(string, int64, int64) (*services.QuotaReservation, error)) {
+	fake.reserveMutex.Lock()
+	defer fake.reserveMutex.Unlock()
+	fake.ReserveStub = stub
+}
+
+func (fake *FakeQuotaService) ReserveArgsForCall(i int) (string, int64, int64) {
+	fake.reserveMutex.RLock()
+	defer fake.reserveMutex.RUnlock()
+	argsForCall := fake.reserveArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeQuotaService) ReserveReturns(result1 *services.QuotaReservation, result2 error) {
+	fake.reserveMutex.Lock()
+	defer fake.reserveMutex.Unlock()
+	fake.ReserveStub = nil
+	fake.reserveReturns = struct {
+		result1 *services.QuotaReservation
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaService) ReserveReturnsOnCall(i int, result1 *services.QuotaReservation, result2 error) {
+	fake.reserveMutex.Lock()
+	defer fake.reserveMutex.Unlock()
+	fake.ReserveStub = nil
+	if fake.reserveReturnsOnCall == nil {
+		fake.reserveReturnsOnCall = make(map[int]struct {
+			result1 *services.QuotaReservation
+			result2 error
+		})
+	}
+	fake.reserveReturnsOnCall[i] = struct {
+		result1 *services.QuotaReservation
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaService) Reset(arg1 string) error {
+	fake.resetMutex.Lock()
+	ret, specificReturn := fake.resetReturnsOnCall[len(fake.resetArgsForCall)]
+	fake.resetArgsForCall = append(fake.resetArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ResetStub
+	fakeReturns := fake.resetReturns
+	fake.recordInvocation("Reset", []interface{}{arg1})
+	fake.resetMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeQuotaService) ResetCallCount() int {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	return len(fake.resetArgsForCall)
+}
+
+func (fake *FakeQuotaService) ResetCalls(stub func(string) error) {
+	fake.resetMutex.Lock()
+	defer fake.resetMutex.Unlock()
+	fake.ResetStub = stub
+}
+
+func (fake *FakeQuotaService) ResetArgsForCall(i int) string {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	argsForCall := fake.resetArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaService) ResetReturns(result1 error) {
+	fake.resetMutex.Lock()
+	defer fake.resetMutex.Unlock()
+	fake.ResetStub = nil
+	fake.resetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeQuotaService) ResetReturnsOnCall(i int, result1 error) {
+	fake.resetMutex.Lock()
+	defer fake.resetMutex.Unlock()
+	fake.ResetStub = nil
+	if fake.resetReturnsOnCall == nil {
+		fake.resetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.resetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeQuotaService) Usage(arg1 string) (*services.QuotaUsage, error) {
+	fake.usageMutex.Lock()
+	ret, specificReturn := fake.usageReturnsOnCall[len(fake.usageArgsForCall)]
+	fake.usageArgsForCall = append(fake.usageArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.UsageStub
+	fakeReturns := fake.usageReturns
+	fake.recordInvocation("Usage", []interface{}{arg1})
+	fake.usageMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeQuotaService) UsageCallCount() int {
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	return len(fake.usageArgsForCall)
+}
+
+func (fake *FakeQuotaService) UsageCalls(stub func(string) (*services.QuotaUsage, error)) {
+	fake.usageMutex.Lock()
+	defer fake.usageMutex.Unlock()
+	fake.UsageStub = stub
+}
+
+func (fake *FakeQuotaService) UsageArgsForCall(i int) string {
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	argsForCall := fake.usageArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaService) UsageReturns(result1 *services.QuotaUsage, result2 error) {
+	fake.usageMutex.Lock()
+	defer fake.usageMutex.Unlock()
+	fake.UsageStub = nil
+	fake.usageReturns = struct {
+		result1 *services.QuotaUsage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaService) UsageReturnsOnCall(i int, result1 *services.QuotaUsage, result2 error) {
+	fake.usageMutex.Lock()
+	defer fake.usageMutex.Unlock()
+	fake.UsageStub = nil
+	if fake.usageReturnsOnCall == nil {
+		fake.usageReturnsOnCall = make(map[int]struct {
+			result1 *services.QuotaUsage
+			result2 error
+		})
+	}
+	fake.usageReturnsOnCall[i] = struct {
+		result1 *services.QuotaUsage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaService) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	fake.reserveMutex.RLock()
+	defer fake.reserveMutex.RUnlock()
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	fake.usageMutex.RLock()
+	defer fake.usageMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeQuotaService) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.QuotaService = new(FakeQuotaService)
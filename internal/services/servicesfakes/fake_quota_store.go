@@ -0,0 +1,200 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeQuotaStore struct {
+	AddStub        func(string, services.QuotaCounter, time.Time) (services.QuotaCounter, error)
+	addMutex       sync.RWMutex
+	addArgsForCall []struct {
+		arg1 string
+		arg2 services.QuotaCounter
+		arg3 time.Time
+	}
+	addReturns struct {
+		result1 services.QuotaCounter
+		result2 error
+	}
+	addReturnsOnCall map[int]struct {
+		result1 services.QuotaCounter
+		result2 error
+	}
+	GetStub        func(string) (services.QuotaCounter, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		arg1 string
+	}
+	getReturns struct {
+		result1 services.QuotaCounter
+		result2 error
+	}
+	getReturnsOnCall map[int]struct {
+		result1 services.QuotaCounter
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeQuotaStore) Add(arg1 string, arg2 services.QuotaCounter, arg3 time.Time) (services.QuotaCounter, error) {
+	fake.addMutex.Lock()
+	ret, specificReturn := fake.addReturnsOnCall[len(fake.addArgsForCall)]
+	fake.addArgsForCall = append(fake.addArgsForCall, struct {
+		arg1 string
+		arg2 services.QuotaCounter
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	stub := fake.AddStub
+	fakeReturns := fake.addReturns
+	fake.recordInvocation("Add", []interface{}{arg1, arg2, arg3})
+	fake.addMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeQuotaStore) AddCallCount() int {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	return len(fake.addArgsForCall)
+}
+
+func (fake *FakeQuotaStore) AddCalls(stub func(string, services.QuotaCounter, time.Time) (services.QuotaCounter, error)) {
+	fake.addMutex.Lock()
+	defer fake.addMutex.Unlock()
+	fake.AddStub = stub
+}
+
+func (fake *FakeQuotaStore) AddArgsForCall(i int) (string, services.QuotaCounter, time.Time) {
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	argsForCall := fake.addArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeQuotaStore) AddReturns(result1 services.QuotaCounter, result2 error) {
+	fake.addMutex.Lock()
+	defer fake.addMutex.Unlock()
+	fake.AddStub = nil
+	fake.addReturns = struct {
+		result1 services.QuotaCounter
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaStore) AddReturnsOnCall(i int, result1 services.QuotaCounter, result2 error) {
+	fake.addMutex.Lock()
+	defer fake.addMutex.Unlock()
+	fake.AddStub = nil
+	if fake.addReturnsOnCall == nil {
+		fake.addReturnsOnCall = make(map[int]struct {
+			result1 services.QuotaCounter
+			result2 error
+		})
+	}
+	fake.addReturnsOnCall[i] = struct {
+		result1 services.QuotaCounter
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaStore) Get(arg1 string) (services.QuotaCounter, error) {
+	fake.getMutex.Lock()
+	ret, specificReturn := fake.getReturnsOnCall[len(fake.getArgsForCall)]
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.GetStub
+	fakeReturns := fake.getReturns
+	fake.recordInvocation("Get", []interface{}{arg1})
+	fake.getMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeQuotaStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeQuotaStore) GetCalls(stub func(string) (services.QuotaCounter, error)) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = stub
+}
+
+func (fake *FakeQuotaStore) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	argsForCall := fake.getArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeQuotaStore) GetReturns(result1 services.QuotaCounter, result2 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 services.QuotaCounter
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaStore) GetReturnsOnCall(i int, result1 services.QuotaCounter, result2 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	if fake.getReturnsOnCall == nil {
+		fake.getReturnsOnCall = make(map[int]struct {
+			result1 services.QuotaCounter
+			result2 error
+		})
+	}
+	fake.getReturnsOnCall[i] = struct {
+		result1 services.QuotaCounter
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQuotaStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.addMutex.RLock()
+	defer fake.addMutex.RUnlock()
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeQuotaStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.QuotaStore = new(FakeQuotaStore)
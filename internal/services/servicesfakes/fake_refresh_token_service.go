@@ -0,0 +1,278 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeRefreshTokenService struct {
+	IssueStub        func(services.TokenClaims) (string, error)
+	issueMutex       sync.RWMutex
+	issueArgsForCall []struct {
+		arg1 services.TokenClaims
+	}
+	issueReturns struct {
+		result1 string
+		result2 error
+	}
+	issueReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	RevokeStub        func(string, string) error
+	revokeMutex       sync.RWMutex
+	revokeArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	revokeReturns struct {
+		result1 error
+	}
+	revokeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RotateStub        func(string, string) (*services.RefreshToken, string, error)
+	rotateMutex       sync.RWMutex
+	rotateArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	rotateReturns struct {
+		result1 *services.RefreshToken
+		result2 string
+		result3 error
+	}
+	rotateReturnsOnCall map[int]struct {
+		result1 *services.RefreshToken
+		result2 string
+		result3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRefreshTokenService) Issue(arg1 services.TokenClaims) (string, error) {
+	fake.issueMutex.Lock()
+	ret, specificReturn := fake.issueReturnsOnCall[len(fake.issueArgsForCall)]
+	fake.issueArgsForCall = append(fake.issueArgsForCall, struct {
+		arg1 services.TokenClaims
+	}{arg1})
+	stub := fake.IssueStub
+	fakeReturns := fake.issueReturns
+	fake.recordInvocation("Issue", []interface{}{arg1})
+	fake.issueMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRefreshTokenService) IssueCallCount() int {
+	fake.issueMutex.RLock()
+	defer fake.issueMutex.RUnlock()
+	return len(fake.issueArgsForCall)
+}
+
+func (fake *FakeRefreshTokenService) IssueCalls(stub func(services.TokenClaims) (string, error)) {
+	fake.issueMutex.Lock()
+	defer fake.issueMutex.Unlock()
+	fake.IssueStub = stub
+}
+
+func (fake *FakeRefreshTokenService) IssueArgsForCall(i int) services.TokenClaims {
+	fake.issueMutex.RLock()
+	defer fake.issueMutex.RUnlock()
+	argsForCall := fake.issueArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRefreshTokenService) IssueReturns(result1 string, result2 error) {
+	fake.issueMutex.Lock()
+	defer fake.issueMutex.Unlock()
+	fake.IssueStub = nil
+	fake.issueReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenService) IssueReturnsOnCall(i int, result1 string, result2 error) {
+	fake.issueMutex.Lock()
+	defer fake.issueMutex.Unlock()
+	fake.IssueStub = nil
+	if fake.issueReturnsOnCall == nil {
+		fake.issueReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.issueReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenService) Revoke(arg1 string, arg2 string) error {
+	fake.revokeMutex.Lock()
+	ret, specificReturn := fake.revokeReturnsOnCall[len(fake.revokeArgsForCall)]
+	fake.revokeArgsForCall = append(fake.revokeArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.RevokeStub
+	fakeReturns := fake.revokeReturns
+	fake.recordInvocation("Revoke", []interface{}{arg1, arg2})
+	fake.revokeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRefreshTokenService) RevokeCallCount() int {
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	return len(fake.revokeArgsForCall)
+}
+
+func (fake *FakeRefreshTokenService) RevokeCalls(stub func(string, string) error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = stub
+}
+
+func (fake *FakeRefreshTokenService) RevokeArgsForCall(i int) (string, string) {
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	argsForCall := fake.revokeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRefreshTokenService) RevokeReturns(result1 error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = nil
+	fake.revokeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenService) RevokeReturnsOnCall(i int, result1 error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = nil
+	if fake.revokeReturnsOnCall == nil {
+		fake.revokeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.revokeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenService) Rotate(arg1 string, arg2 string) (*services.RefreshToken, string, error) {
+	fake.rotateMutex.Lock()
+	ret, specificReturn := fake.rotateReturnsOnCall[len(fake.rotateArgsForCall)]
+	fake.rotateArgsForCall = append(fake.rotateArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.RotateStub
+	fakeReturns := fake.rotateReturns
+	fake.recordInvocation("Rotate", []interface{}{arg1, arg2})
+	fake.rotateMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeRefreshTokenService) RotateCallCount() int {
+	fake.rotateMutex.RLock()
+	defer fake.rotateMutex.RUnlock()
+	return len(fake.rotateArgsForCall)
+}
+
+func (fake *FakeRefreshTokenService) RotateCalls(stub func(string, string) (*services.RefreshToken, string, error)) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = stub
+}
+
+func (fake *FakeRefreshTokenService) RotateArgsForCall(i int) (string, string) {
+	fake.rotateMutex.RLock()
+	defer fake.rotateMutex.RUnlock()
+	argsForCall := fake.rotateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRefreshTokenService) RotateReturns(result1 *services.RefreshToken, result2 string, result3 error) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = nil
+	fake.rotateReturns = struct {
+		result1 *services.RefreshToken
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeRefreshTokenService) RotateReturnsOnCall(i int, result1 *services.RefreshToken, result2 string, result3 error) {
+	fake.rotateMutex.Lock()
+	defer fake.rotateMutex.Unlock()
+	fake.RotateStub = nil
+	if fake.rotateReturnsOnCall == nil {
+		fake.rotateReturnsOnCall = make(map[int]struct {
+			result1 *services.RefreshToken
+			result2 string
+			result3 error
+		})
+	}
+	fake.rotateReturnsOnCall[i] = struct {
+		result1 *services.RefreshToken
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeRefreshTokenService) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.issueMutex.RLock()
+	defer fake.issueMutex.RUnlock()
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	fake.rotateMutex.RLock()
+	defer fake.rotateMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeRefreshTokenService) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.RefreshTokenService = new(FakeRefreshTokenService)
@@ -0,0 +1,425 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeRefreshTokenStore struct {
+	FamilyRevokedStub        func(string) (bool, error)
+	familyRevokedMutex       sync.RWMutex
+	familyRevokedArgsForCall []struct {
+		arg1 string
+	}
+	familyRevokedReturns struct {
+		result1 bool
+		result2 error
+	}
+	familyRevokedReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	GetStub        func(string) (*services.RefreshToken, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		arg1 string
+	}
+	getReturns struct {
+		result1 *services.RefreshToken
+		result2 error
+	}
+	getReturnsOnCall map[int]struct {
+		result1 *services.RefreshToken
+		result2 error
+	}
+	MarkUsedStub        func(string) (bool, error)
+	markUsedMutex       sync.RWMutex
+	markUsedArgsForCall []struct {
+		arg1 string
+	}
+	markUsedReturns struct {
+		result1 bool
+		result2 error
+	}
+	markUsedReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	RevokeFamilyStub        func(string, time.Time) error
+	revokeFamilyMutex       sync.RWMutex
+	revokeFamilyArgsForCall []struct {
+		arg1 string
+		arg2 time.Time
+	}
+	revokeFamilyReturns struct {
+		result1 error
+	}
+	revokeFamilyReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SaveStub        func(services.RefreshToken) error
+	saveMutex       sync.RWMutex
+	saveArgsForCall []struct {
+		arg1 services.RefreshToken
+	}
+	saveReturns struct {
+		result1 error
+	}
+	saveReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevoked(arg1 string) (bool, error) {
+	fake.familyRevokedMutex.Lock()
+	ret, specificReturn := fake.familyRevokedReturnsOnCall[len(fake.familyRevokedArgsForCall)]
+	fake.familyRevokedArgsForCall = append(fake.familyRevokedArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.FamilyRevokedStub
+	fakeReturns := fake.familyRevokedReturns
+	fake.recordInvocation("FamilyRevoked", []interface{}{arg1})
+	fake.familyRevokedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevokedCallCount() int {
+	fake.familyRevokedMutex.RLock()
+	defer fake.familyRevokedMutex.RUnlock()
+	return len(fake.familyRevokedArgsForCall)
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevokedCalls(stub func(string) (bool, error)) {
+	fake.familyRevokedMutex.Lock()
+	defer fake.familyRevokedMutex.Unlock()
+	fake.FamilyRevokedStub = stub
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevokedArgsForCall(i int) string {
+	fake.familyRevokedMutex.RLock()
+	defer fake.familyRevokedMutex.RUnlock()
+	argsForCall := fake.familyRevokedArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevokedReturns(result1 bool, result2 error) {
+	fake.familyRevokedMutex.Lock()
+	defer fake.familyRevokedMutex.Unlock()
+	fake.FamilyRevokedStub = nil
+	fake.familyRevokedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) FamilyRevokedReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.familyRevokedMutex.Lock()
+	defer fake.familyRevokedMutex.Unlock()
+	fake.FamilyRevokedStub = nil
+	if fake.familyRevokedReturnsOnCall == nil {
+		fake.familyRevokedReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.familyRevokedReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) Get(arg1 string) (*services.RefreshToken, error) {
+	fake.getMutex.Lock()
+	ret, specificReturn := fake.getReturnsOnCall[len(fake.getArgsForCall)]
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.GetStub
+	fakeReturns := fake.getReturns
+	fake.recordInvocation("Get", []interface{}{arg1})
+	fake.getMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRefreshTokenStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeRefreshTokenStore) GetCalls(stub func(string) (*services.RefreshToken, error)) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = stub
+}
+
+func (fake *FakeRefreshTokenStore) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	argsForCall := fake.getArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRefreshTokenStore) GetReturns(result1 *services.RefreshToken, result2 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 *services.RefreshToken
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) GetReturnsOnCall(i int, result1 *services.RefreshToken, result2 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	if fake.getReturnsOnCall == nil {
+		fake.getReturnsOnCall = make(map[int]struct {
+			result1 *services.RefreshToken
+			result2 error
+		})
+	}
+	fake.getReturnsOnCall[i] = struct {
+		result1 *services.RefreshToken
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsed(arg1 string) (bool, error) {
+	fake.markUsedMutex.Lock()
+	ret, specificReturn := fake.markUsedReturnsOnCall[len(fake.markUsedArgsForCall)]
+	fake.markUsedArgsForCall = append(fake.markUsedArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.MarkUsedStub
+	fakeReturns := fake.markUsedReturns
+	fake.recordInvocation("MarkUsed", []interface{}{arg1})
+	fake.markUsedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsedCallCount() int {
+	fake.markUsedMutex.RLock()
+	defer fake.markUsedMutex.RUnlock()
+	return len(fake.markUsedArgsForCall)
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsedCalls(stub func(string) (bool, error)) {
+	fake.markUsedMutex.Lock()
+	defer fake.markUsedMutex.Unlock()
+	fake.MarkUsedStub = stub
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsedArgsForCall(i int) string {
+	fake.markUsedMutex.RLock()
+	defer fake.markUsedMutex.RUnlock()
+	argsForCall := fake.markUsedArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsedReturns(result1 bool, result2 error) {
+	fake.markUsedMutex.Lock()
+	defer fake.markUsedMutex.Unlock()
+	fake.MarkUsedStub = nil
+	fake.markUsedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) MarkUsedReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.markUsedMutex.Lock()
+	defer fake.markUsedMutex.Unlock()
+	fake.MarkUsedStub = nil
+	if fake.markUsedReturnsOnCall == nil {
+		fake.markUsedReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.markUsedReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamily(arg1 string, arg2 time.Time) error {
+	fake.revokeFamilyMutex.Lock()
+	ret, specificReturn := fake.revokeFamilyReturnsOnCall[len(fake.revokeFamilyArgsForCall)]
+	fake.revokeFamilyArgsForCall = append(fake.revokeFamilyArgsForCall, struct {
+		arg1 string
+		arg2 time.Time
+	}{arg1, arg2})
+	stub := fake.RevokeFamilyStub
+	fakeReturns := fake.revokeFamilyReturns
+	fake.recordInvocation("RevokeFamily", []interface{}{arg1, arg2})
+	fake.revokeFamilyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamilyCallCount() int {
+	fake.revokeFamilyMutex.RLock()
+	defer fake.revokeFamilyMutex.RUnlock()
+	return len(fake.revokeFamilyArgsForCall)
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamilyCalls(stub func(string, time.Time) error) {
+	fake.revokeFamilyMutex.Lock()
+	defer fake.revokeFamilyMutex.Unlock()
+	fake.RevokeFamilyStub = stub
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamilyArgsForCall(i int) (string, time.Time) {
+	fake.revokeFamilyMutex.RLock()
+	defer fake.revokeFamilyMutex.RUnlock()
+	argsForCall := fake.revokeFamilyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamilyReturns(result1 error) {
+	fake.revokeFamilyMutex.Lock()
+	defer fake.revokeFamilyMutex.Unlock()
+	fake.RevokeFamilyStub = nil
+	fake.revokeFamilyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenStore) RevokeFamilyReturnsOnCall(i int, result1 error) {
+	fake.revokeFamilyMutex.Lock()
+	defer fake.revokeFamilyMutex.Unlock()
+	fake.RevokeFamilyStub = nil
+	if fake.revokeFamilyReturnsOnCall == nil {
+		fake.revokeFamilyReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.revokeFamilyReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenStore) Save(arg1 services.RefreshToken) error {
+	fake.saveMutex.Lock()
+	ret, specificReturn := fake.saveReturnsOnCall[len(fake.saveArgsForCall)]
+	fake.saveArgsForCall = append(fake.saveArgsForCall, struct {
+		arg1 services.RefreshToken
+	}{arg1})
+	stub := fake.SaveStub
+	fakeReturns := fake.saveReturns
+	fake.recordInvocation("Save", []interface{}{arg1})
+	fake.saveMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRefreshTokenStore) SaveCallCount() int {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return len(fake.saveArgsForCall)
+}
+
+func (fake *FakeRefreshTokenStore) SaveCalls(stub func(services.RefreshToken) error) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = stub
+}
+
+func (fake *FakeRefreshTokenStore) SaveArgsForCall(i int) services.RefreshToken {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	argsForCall := fake.saveArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRefreshTokenStore) SaveReturns(result1 error) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = nil
+	fake.saveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenStore) SaveReturnsOnCall(i int, result1 error) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = nil
+	if fake.saveReturnsOnCall == nil {
+		fake.saveReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.saveReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRefreshTokenStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.familyRevokedMutex.RLock()
+	defer fake.familyRevokedMutex.RUnlock()
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	fake.markUsedMutex.RLock()
+	defer fake.markUsedMutex.RUnlock()
+	fake.revokeFamilyMutex.RLock()
+	defer fake.revokeFamilyMutex.RUnlock()
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeRefreshTokenStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.RefreshTokenStore = new(FakeRefreshTokenStore)
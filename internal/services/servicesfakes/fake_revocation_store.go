@@ -0,0 +1,258 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeRevocationStore struct {
+	IsRevokedStub        func(string) (bool, error)
+	isRevokedMutex       sync.RWMutex
+	isRevokedArgsForCall []struct {
+		arg1 string
+	}
+	isRevokedReturns struct {
+		result1 bool
+		result2 error
+	}
+	isRevokedReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	ReloadStub        func() error
+	reloadMutex       sync.RWMutex
+	reloadArgsForCall []struct {
+	}
+	reloadReturns struct {
+		result1 error
+	}
+	reloadReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RevokeStub        func(string, time.Time) error
+	revokeMutex       sync.RWMutex
+	revokeArgsForCall []struct {
+		arg1 string
+		arg2 time.Time
+	}
+	revokeReturns struct {
+		result1 error
+	}
+	revokeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRevocationStore) IsRevoked(arg1 string) (bool, error) {
+	fake.isRevokedMutex.Lock()
+	ret, specificReturn := fake.isRevokedReturnsOnCall[len(fake.isRevokedArgsForCall)]
+	fake.isRevokedArgsForCall = append(fake.isRevokedArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.IsRevokedStub
+	fakeReturns := fake.isRevokedReturns
+	fake.recordInvocation("IsRevoked", []interface{}{arg1})
+	fake.isRevokedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRevocationStore) IsRevokedCallCount() int {
+	fake.isRevokedMutex.RLock()
+	defer fake.isRevokedMutex.RUnlock()
+	return len(fake.isRevokedArgsForCall)
+}
+
+func (fake *FakeRevocationStore) IsRevokedCalls(stub func(string) (bool, error)) {
+	fake.isRevokedMutex.Lock()
+	defer fake.isRevokedMutex.Unlock()
+	fake.IsRevokedStub = stub
+}
+
+func (fake *FakeRevocationStore) IsRevokedArgsForCall(i int) string {
+	fake.isRevokedMutex.RLock()
+	defer fake.isRevokedMutex.RUnlock()
+	argsForCall := fake.isRevokedArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRevocationStore) IsRevokedReturns(result1 bool, result2 error) {
+	fake.isRevokedMutex.Lock()
+	defer fake.isRevokedMutex.Unlock()
+	fake.IsRevokedStub = nil
+	fake.isRevokedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRevocationStore) IsRevokedReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.isRevokedMutex.Lock()
+	defer fake.isRevokedMutex.Unlock()
+	fake.IsRevokedStub = nil
+	if fake.isRevokedReturnsOnCall == nil {
+		fake.isRevokedReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.isRevokedReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRevocationStore) Reload() error {
+	fake.reloadMutex.Lock()
+	ret, specificReturn := fake.reloadReturnsOnCall[len(fake.reloadArgsForCall)]
+	fake.reloadArgsForCall = append(fake.reloadArgsForCall, struct {
+	}{})
+	stub := fake.ReloadStub
+	fakeReturns := fake.reloadReturns
+	fake.recordInvocation("Reload", []interface{}{})
+	fake.reloadMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRevocationStore) ReloadCallCount() int {
+	fake.reloadMutex.RLock()
+	defer fake.reloadMutex.RUnlock()
+	return len(fake.reloadArgsForCall)
+}
+
+func (fake *FakeRevocationStore) ReloadCalls(stub func() error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = stub
+}
+
+func (fake *FakeRevocationStore) ReloadReturns(result1 error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = nil
+	fake.reloadReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRevocationStore) ReloadReturnsOnCall(i int, result1 error) {
+	fake.reloadMutex.Lock()
+	defer fake.reloadMutex.Unlock()
+	fake.ReloadStub = nil
+	if fake.reloadReturnsOnCall == nil {
+		fake.reloadReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.reloadReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRevocationStore) Revoke(arg1 string, arg2 time.Time) error {
+	fake.revokeMutex.Lock()
+	ret, specificReturn := fake.revokeReturnsOnCall[len(fake.revokeArgsForCall)]
+	fake.revokeArgsForCall = append(fake.revokeArgsForCall, struct {
+		arg1 string
+		arg2 time.Time
+	}{arg1, arg2})
+	stub := fake.RevokeStub
+	fakeReturns := fake.revokeReturns
+	fake.recordInvocation("Revoke", []interface{}{arg1, arg2})
+	fake.revokeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRevocationStore) RevokeCallCount() int {
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	return len(fake.revokeArgsForCall)
+}
+
+func (fake *FakeRevocationStore) RevokeCalls(stub func(string, time.Time) error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = stub
+}
+
+func (fake *FakeRevocationStore) RevokeArgsForCall(i int) (string, time.Time) {
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	argsForCall := fake.revokeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRevocationStore) RevokeReturns(result1 error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = nil
+	fake.revokeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRevocationStore) RevokeReturnsOnCall(i int, result1 error) {
+	fake.revokeMutex.Lock()
+	defer fake.revokeMutex.Unlock()
+	fake.RevokeStub = nil
+	if fake.revokeReturnsOnCall == nil {
+		fake.revokeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.revokeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRevocationStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.isRevokedMutex.RLock()
+	defer fake.isRevokedMutex.RUnlock()
+	fake.reloadMutex.RLock()
+	defer fake.reloadMutex.RUnlock()
+	fake.revokeMutex.RLock()
+	defer fake.revokeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeRevocationStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.RevocationStore = new(FakeRevocationStore)
@@ -0,0 +1,121 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package servicesfakes
+
+import (
+	"sync"
+
+	"github.com/nathaliaguayos/msg-receiver/internal/services"
+)
+
+type FakeSpool struct {
+	AcceptStub        func([]services.SpooledMessage) ([]string, error)
+	acceptMutex       sync.RWMutex
+	acceptArgsForCall []struct {
+		arg1 []services.SpooledMessage
+	}
+	acceptReturns struct {
+		result1 []string
+		result2 error
+	}
+	acceptReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeSpool) Accept(arg1 []services.SpooledMessage) ([]string, error) {
+	var arg1Copy []services.SpooledMessage
+	if arg1 != nil {
+		arg1Copy = make([]services.SpooledMessage, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.acceptMutex.Lock()
+	ret, specificReturn := fake.acceptReturnsOnCall[len(fake.acceptArgsForCall)]
+	fake.acceptArgsForCall = append(fake.acceptArgsForCall, struct {
+		arg1 []services.SpooledMessage
+	}{arg1Copy})
+	stub := fake.AcceptStub
+	fakeReturns := fake.acceptReturns
+	fake.recordInvocation("Accept", []interface{}{arg1Copy})
+	fake.acceptMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpool) AcceptCallCount() int {
+	fake.acceptMutex.RLock()
+	defer fake.acceptMutex.RUnlock()
+	return len(fake.acceptArgsForCall)
+}
+
+func (fake *FakeSpool) AcceptCalls(stub func([]services.SpooledMessage) ([]string, error)) {
+	fake.acceptMutex.Lock()
+	defer fake.acceptMutex.Unlock()
+	fake.AcceptStub = stub
+}
+
+func (fake *FakeSpool) AcceptArgsForCall(i int) []services.SpooledMessage {
+	fake.acceptMutex.RLock()
+	defer fake.acceptMutex.RUnlock()
+	argsForCall := fake.acceptArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeSpool) AcceptReturns(result1 []string, result2 error) {
+	fake.acceptMutex.Lock()
+	defer fake.acceptMutex.Unlock()
+	fake.AcceptStub = nil
+	fake.acceptReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpool) AcceptReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.acceptMutex.Lock()
+	defer fake.acceptMutex.Unlock()
+	fake.AcceptStub = nil
+	if fake.acceptReturnsOnCall == nil {
+		fake.acceptReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.acceptReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpool) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.acceptMutex.RLock()
+	defer fake.acceptMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeSpool) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ services.Spool = new(FakeSpool)
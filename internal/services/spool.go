@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nathaliaguayos/msg-receiver/internal/spool"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Spool is a contract for accepting messages durably, to be produced later
+//
+//counterfeiter:generate . Spool
+type Spool interface {
+	// Accept stores messages on disk and returns their IDs, in order
+	Accept(messages []SpooledMessage) ([]string, error)
+}
+
+// SpooledMessage is a message accepted into the spool
+type SpooledMessage struct {
+	ID         string    `json:"id"`
+	Topic      string    `json:"topic"`
+	Key        []byte    `json:"key,omitempty"`
+	Headers    []Header  `json:"headers,omitempty"`
+	Value      []byte    `json:"value"`
+	Tenant     string    `json:"tenant,omitempty"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// WALSpoolConfig configures NewWALSpool
+type WALSpoolConfig struct {
+	// Statuses, when set, tracks the accepted messages for StatusTTL, 24 hours when zero
+	Statuses  MessageStatusStore
+	StatusTTL time.Duration
+}
+
+// ForwarderConfig tunes RunSpoolForwarder
+type ForwarderConfig struct {
+	// BatchSize is the number of messages read, produced concurrently and
+	// acknowledged together, 100 when zero
+	BatchSize int
+	// Timeout bounds each produce call, 30 seconds when zero
+	Timeout time.Duration
+	// Retry decides which failed messages are produced again and when; a
+	// message out of attempts is given up. Messages are retried until the
+	// forwarder stops when Retry.MaxAttempts is zero.
+	Retry RetryPolicy
+	// OnError is called when producing a message fails; dropped reports
+	// whether the message is given up rather than retried
+	OnError func(message SpooledMessage, err error, dropped bool)
+	// Statuses, when set, records the delivered and failed messages for
+	// StatusTTL, 24 hours when zero
+	Statuses  MessageStatusStore
+	StatusTTL time.Duration
+	// DeadLetters, when set, keeps the messages given up; a message is not
+	// acknowledged until it is added, so none is lost while the queue fails.
+	// A message the queue can never take, as when the dead-letter topic does
+	// not exist, is dropped.
+	DeadLetters DeadLetterWriter
+}
+
+type walSpool struct {
+	log *spool.Log
+	cfg WALSpoolConfig
+	now func() time.Time
+}
+
+// NewWALSpool creates a spool writing messages to a write-ahead log, from
+// which RunSpoolForwarder produces them
+// Params: log *spool.Log - the open log
+// Params: cfg WALSpoolConfig - where the accepted messages are tracked
+func NewWALSpool(log *spool.Log, cfg WALSpoolConfig) Spool {
+	if cfg.StatusTTL <= 0 {
+		cfg.StatusTTL = 24 * time.Hour
+	}
+	return &walSpool{log: log, cfg: cfg, now: time.Now}
+}
+
+// Accept assigns an ID to each message and appends them to the log; they
+// are on disk when Accept returns
+// Params: messages []SpooledMessage - the messages, whose ID and acceptance time are set
+func (s *walSpool) Accept(messages []SpooledMessage) ([]string, error) {
+	entries := make([][]byte, len(messages))
+	ids := make([]string, len(messages))
+	statuses := make([]MessageStatus, len(messages))
+	now := s.now().UTC()
+	for i, message := range messages {
+		id, err := randomToken(16)
+		if err != nil {
+			return nil, err
+		}
+		message.ID, message.AcceptedAt = id, now
+		if entries[i], err = json.Marshal(message); err != nil {
+			return nil, err
+		}
+		ids[i] = id
+		statuses[i] = MessageStatus{ID: id, Tenant: message.Tenant, State: MessageQueued, Topic: message.Topic, AcceptedAt: now, UpdatedAt: now}
+	}
+	// the messages are queued before they reach the forwarder, which could
+	// otherwise record them delivered first. Tracking is best effort: a
+	// message whose status is lost is still produced, and looks unknown.
+	if s.cfg.Statuses != nil {
+		_ = s.cfg.Statuses.Put(statuses, s.cfg.StatusTTL)
+	}
+	if _, err := s.log.Append(entries...); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSpoolUnavailable, err)
+	}
+	return ids, nil
+}
+
+// RunSpoolForwarder produces the spooled messages until ctx is done. The
+// messages of a batch are produced concurrently, except that messages
+// sharing a topic and key, which land on the same partition, keep their
+// order. A message failing with a retriable error is retried, holding back
+// the messages with its key, up to Retry.MaxAttempts; one that can never be
+// produced, such as an unknown topic, or runs out of attempts is given up
+// to the dead-letter queue. Messages produced before a crash but not yet
+// acknowledged are produced again on restart.
+// Params: log *spool.Log - the open log
+// Params: producer Producer - the producer messages are handed to
+// Params: cfg ForwarderConfig - the batch size and retries
+func RunSpoolForwarder(ctx context.Context, log *spool.Log, producer Producer, cfg ForwarderConfig) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(SpooledMessage, error, bool) {}
+	}
+	if cfg.StatusTTL <= 0 {
+		cfg.StatusTTL = 24 * time.Hour
+	}
+
+	// failures counts the spool errors in a row, to back off from a failing disk
+	failures := 0
+	for ctx.Err() == nil {
+		entries, err := log.Read(log.Acked()+1, cfg.BatchSize)
+		if err != nil {
+			failures++
+			cfg.OnError(SpooledMessage{}, err, false)
+			sleep(ctx, cfg.Retry.Backoff(failures))
+			continue
+		}
+		if len(entries) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-log.Notify():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if err := forwardBatch(ctx, log, producer, cfg, entries); err != nil {
+			if ctx.Err() == nil {
+				failures++
+				cfg.OnError(SpooledMessage{}, err, false)
+				sleep(ctx, cfg.Retry.Backoff(failures))
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+// forwardBatch produces the entries of a batch, one lane per topic and key
+// and one per keyless message, then acknowledges the entries handled in a
+// row from the start of the batch at once. The entries after a failed one
+// are read and produced again.
+func forwardBatch(ctx context.Context, log *spool.Log, producer Producer, cfg ForwarderConfig, entries []spool.Entry) error {
+	messages := make([]SpooledMessage, len(entries))
+	decodeErrs := make([]error, len(entries))
+	lanes := make(map[string][]int)
+	for i, entry := range entries {
+		// keyless messages are spread over the partitions by the producer,
+		// so they have no order to keep
+		lane := "#" + strconv.Itoa(i)
+		if decodeErrs[i] = json.Unmarshal(entry.Data, &messages[i]); decodeErrs[i] == nil && messages[i].Key != nil {
+			lane = "k:" + messages[i].Topic + "\x00" + string(messages[i].Key)
+		}
+		lanes[lane] = append(lanes[lane], i)
+	}
+
+	handled := make([]bool, len(entries))
+	errs := make([]error, len(entries))
+	var wg sync.WaitGroup
+	for _, lane := range lanes {
+		wg.Add(1)
+		go func(lane []int) {
+			defer wg.Done()
+			for _, i := range lane {
+				if errs[i] = forward(ctx, producer, cfg, entries[i], messages[i], decodeErrs[i]); errs[i] != nil {
+					return
+				}
+				handled[i] = true
+			}
+		}(lane)
+	}
+	wg.Wait()
+
+	n := 0
+	for n < len(entries) && handled[n] {
+		n++
+	}
+	if n > 0 {
+		if err := log.Ack(entries[n-1].Seq); err != nil {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forward produces one entry, or gives it up; it fails when ctx is done first
+func forward(ctx context.Context, producer Producer, cfg ForwarderConfig, entry spool.Entry, message SpooledMessage, decodeErr error) error {
+	if decodeErr != nil {
+		// the raw entry is dead-lettered, under an ID of its own
+		id, _ := randomToken(16)
+		message = SpooledMessage{ID: id, Value: entry.Data}
+		return giveUp(ctx, cfg, message, fmt.Errorf("spool entry %d: %w", entry.Seq, decodeErr), 0)
+	}
+
+	var result *DeliveryResult
+	attempts, err := cfg.Retry.Do(ctx, func(ctx context.Context) error {
+		produceCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+		var err error
+		result, err = producer.Produce(produceCtx, message.Topic, message.Key, message.Headers, message.Value)
+		return err
+	}, func(_ int, err error, _ time.Duration) {
+		cfg.OnError(message, err, false)
+	})
+	switch {
+	case err == nil:
+		delivered := MessageStatus{State: MessageDelivered}
+		if result != nil {
+			delivered.Partition, delivered.Offset = &result.Partition, &result.Offset
+		}
+		recordStatus(cfg, message, delivered)
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		return giveUp(ctx, cfg, message, err, attempts)
+	}
+}
+
+// giveUp adds a message to the dead-letter queue, retrying until it is
+// added, refused for good or ctx is done, then records it failed
+func giveUp(ctx context.Context, cfg ForwarderConfig, message SpooledMessage, err error, attempts int) error {
+	if cfg.DeadLetters != nil {
+		letter := DeadLetter{
+			ID:         message.ID,
+			Topic:      message.Topic,
+			Key:        message.Key,
+			Headers:    message.Headers,
+			Value:      message.Value,
+			Tenant:     message.Tenant,
+			Error:      err.Error(),
+			Attempts:   attempts,
+			AcceptedAt: message.AcceptedAt,
+			FailedAt:   time.Now().UTC(),
+		}
+		// failures to add are retried, the message must not be lost, unless
+		// the queue will never take it: holding it would stall its lane and
+		// fill the spool
+		policy := cfg.Retry
+		policy.MaxAttempts, policy.IsRetriable = 0, func(err error) bool { return !refused(err) }
+		_, addErr := policy.Do(ctx, func(context.Context) error {
+			return cfg.DeadLetters.Add(letter)
+		}, func(_ int, addErr error, _ time.Duration) {
+			cfg.OnError(message, fmt.Errorf("dead-lettering message: %w", addErr), false)
+		})
+		switch {
+		case addErr == nil:
+		case refused(addErr):
+			err = fmt.Errorf("%w, dead-lettering message: %w", err, addErr)
+		default:
+			return addErr
+		}
+	}
+	cfg.OnError(message, err, true)
+	recordStatus(cfg, message, MessageStatus{State: MessageFailed, Reason: err.Error()})
+	return nil
+}
+
+// refused reports whether the dead-letter queue can never take a message,
+// such as a dead-letter topic that does not exist; other failures, such as
+// an unavailable broker or disk, may pass later
+func refused(err error) bool {
+	var serviceErr ServiceError
+	return errors.As(err, &serviceErr) && !serviceErr.Retriable()
+}
+
+// recordStatus stores the outcome of a message, best effort like Accept
+func recordStatus(cfg ForwarderConfig, message SpooledMessage, status MessageStatus) {
+	if cfg.Statuses == nil {
+		return
+	}
+	status.ID, status.Tenant, status.Topic = message.ID, message.Tenant, message.Topic
+	status.AcceptedAt, status.UpdatedAt = message.AcceptedAt, time.Now().UTC()
+	_ = cfg.Statuses.Put([]MessageStatus{status}, cfg.StatusTTL)
+}
+
+// sleep waits for d, returning false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
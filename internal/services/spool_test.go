@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"github.com/nathaliaguayos/msg-receiver/internal/spool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyProducer fails the first failures calls with err before handing
+// messages to next
+type flakyProducer struct {
+	Producer
+	mu       sync.Mutex
+	failures int
+	err      error
+}
+
+func (p *flakyProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	p.mu.Lock()
+	if p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return nil, p.err
+	}
+	p.mu.Unlock()
+	return p.Producer.Produce(ctx, topic, key, headers, value)
+}
+
+// topicProducer rejects the messages of the unknown topic
+type topicProducer struct {
+	Producer
+	unknown string
+}
+
+func (p *topicProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	if topic == p.unknown {
+		return nil, ErrUnknownTopic
+	}
+	return p.Producer.Produce(ctx, topic, key, headers, value)
+}
+
+func openSpool(t *testing.T, dir string) *spool.Log {
+	t.Helper()
+	log, err := spool.Open(dir, spool.Config{SegmentBytes: 1024})
+	require.NoError(t, err)
+	return log
+}
+
+func values(messages []Message) []string {
+	var result []string
+	for _, message := range messages {
+		result = append(result, string(message.Value))
+	}
+	return result
+}
+
+func TestWALSpool_Accept(t *testing.T) {
+	dir := t.TempDir()
+	log := openSpool(t, dir)
+	ids, err := NewWALSpool(log, WALSpoolConfig{}).Accept([]SpooledMessage{
+		{Topic: "orders", Key: []byte("k"), Headers: []Header{{Key: "h", Value: []byte("v")}}, Value: []byte("1")},
+		{Topic: "orders", Key: []byte("k"), Value: []byte("2")},
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	assert.NotEqual(t, ids[0], ids[1])
+	require.NoError(t, log.Close())
+
+	// accepted messages survive a restart and are produced once it is back
+	log = openSpool(t, dir)
+	defer log.Close()
+	producer := NewInMemoryProducer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSpoolForwarder(ctx, log, producer, ForwarderConfig{})
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	messages := producer.(*inMemoryProducer).messages("orders")
+	assert.Equal(t, []string{"1", "2"}, values(messages))
+	assert.Equal(t, []byte("k"), messages[0].Key)
+	assert.Equal(t, []Header{{Key: "h", Value: []byte("v")}}, messages[0].Headers)
+
+	require.NoError(t, log.Close())
+	_, err = NewWALSpool(log, WALSpoolConfig{}).Accept([]SpooledMessage{{Topic: "orders", Value: []byte("4")}})
+	assert.ErrorIs(t, err, ErrSpoolUnavailable)
+}
+
+func TestRunSpoolForwarder(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedValues  []string
+		expectedDropped int
+	}{
+		{name: "should retry a broker failure in order", err: ErrBrokerUnavailable, expectedValues: []string{"1", "2"}},
+		{name: "should retry a timeout", err: context.DeadlineExceeded, expectedValues: []string{"1", "2"}},
+		{name: "should drop a message for an unknown topic", err: ErrUnknownTopic, expectedValues: []string{"2"}, expectedDropped: 1},
+		{name: "should drop a message too large", err: ErrMessageTooLarge, expectedValues: []string{"2"}, expectedDropped: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log := openSpool(t, t.TempDir())
+			defer log.Close()
+			next := NewInMemoryProducer()
+			producer := &flakyProducer{Producer: next, failures: 1, err: tc.err}
+
+			var mu sync.Mutex
+			var errs, dropped int
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				RunSpoolForwarder(ctx, log, producer, ForwarderConfig{
+					Retry: RetryPolicy{InitialBackoff: time.Millisecond},
+					OnError: func(message SpooledMessage, err error, drop bool) {
+						mu.Lock()
+						defer mu.Unlock()
+						assert.ErrorIs(t, err, tc.err)
+						assert.Equal(t, "1", string(message.Value))
+						errs++
+						if drop {
+							dropped++
+						}
+					},
+				})
+				close(done)
+			}()
+
+			// the messages share a key, so the second waits for the first
+			_, err := NewWALSpool(log, WALSpoolConfig{}).Accept([]SpooledMessage{
+				{Topic: "orders", Key: []byte("k"), Value: []byte("1")},
+				{Topic: "orders", Key: []byte("k"), Value: []byte("2")},
+			})
+			require.NoError(t, err)
+			require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+			cancel()
+			<-done
+
+			assert.Equal(t, tc.expectedValues, values(next.(*inMemoryProducer).messages("orders")))
+			assert.Equal(t, 1, errs)
+			assert.Equal(t, tc.expectedDropped, dropped)
+		})
+	}
+}
+
+func TestRunSpoolForwarder_Statuses(t *testing.T) {
+	log := openSpool(t, t.TempDir())
+	defer log.Close()
+	statuses := NewInMemoryMessageStatusStore()
+	ids, err := NewWALSpool(log, WALSpoolConfig{Statuses: statuses}).Accept([]SpooledMessage{
+		{Topic: "orders", Value: []byte("1"), Tenant: "acme"},
+		{Topic: "billing", Value: []byte("2"), Tenant: "acme"},
+	})
+	require.NoError(t, err)
+	status, err := statuses.Get(ids[0])
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, MessageQueued, status.State)
+	assert.Equal(t, "acme", status.Tenant)
+	assert.Equal(t, "orders", status.Topic)
+
+	producer := NewInMemoryProducer()
+	_, err = producer.Produce(context.Background(), "orders", nil, nil, []byte("0"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSpoolForwarder(ctx, log, &topicProducer{Producer: producer, unknown: "billing"}, ForwarderConfig{Statuses: statuses})
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	status, err = statuses.Get(ids[0])
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, MessageDelivered, status.State)
+	assert.Equal(t, int32(0), *status.Partition)
+	assert.Equal(t, int64(1), *status.Offset)
+	assert.Equal(t, "acme", status.Tenant)
+
+	status, err = statuses.Get(ids[1])
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, MessageFailed, status.State)
+	assert.Equal(t, ErrUnknownTopic.Error(), status.Reason)
+	assert.Nil(t, status.Offset)
+}
+
+// slowProducer delays every message and tracks the calls in flight
+type slowProducer struct {
+	Producer
+	delay       time.Duration
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (p *slowProducer) Produce(ctx context.Context, topic string, key []byte, headers []Header, value []byte) (*DeliveryResult, error) {
+	p.mu.Lock()
+	p.inFlight++
+	p.maxInFlight = max(p.maxInFlight, p.inFlight)
+	p.mu.Unlock()
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	return p.Producer.Produce(ctx, topic, key, headers, value)
+}
+
+func TestRunSpoolForwarder_Concurrent(t *testing.T) {
+	log := openSpool(t, t.TempDir())
+	defer log.Close()
+	messages := make([]SpooledMessage, 0, 40)
+	for i := 0; i < 20; i++ {
+		messages = append(messages, SpooledMessage{Topic: "orders", Value: []byte(strconv.Itoa(i))})
+		messages = append(messages, SpooledMessage{Topic: "billing", Key: []byte("acme"), Value: []byte(strconv.Itoa(i))})
+	}
+	_, err := NewWALSpool(log, WALSpoolConfig{}).Accept(messages)
+	require.NoError(t, err)
+
+	next := NewInMemoryProducer()
+	producer := &slowProducer{Producer: next, delay: 5 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSpoolForwarder(ctx, log, producer, ForwarderConfig{BatchSize: 40})
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// keyless messages are produced together, the messages of a key in order
+	assert.Greater(t, producer.maxInFlight, 10)
+	assert.Len(t, next.(*inMemoryProducer).messages("orders"), 20)
+	var expected []string
+	for i := 0; i < 20; i++ {
+		expected = append(expected, strconv.Itoa(i))
+	}
+	assert.Equal(t, expected, values(next.(*inMemoryProducer).messages("billing")))
+	assert.Equal(t, uint64(40), log.Acked())
+}
+
+// failingDeadLetterQueue fails the first failures additions with err,
+// assert.AnError when nil
+type failingDeadLetterQueue struct {
+	DeadLetterQueue
+	mu       sync.Mutex
+	failures int
+	err      error
+}
+
+func (q *failingDeadLetterQueue) Add(letter DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failures > 0 {
+		q.failures--
+		if q.err != nil {
+			return q.err
+		}
+		return assert.AnError
+	}
+	return q.DeadLetterQueue.Add(letter)
+}
+
+func TestRunSpoolForwarder_DeadLetters(t *testing.T) {
+	testCases := []struct {
+		name             string
+		err              error
+		failures         int
+		maxAttempts      int
+		expectedAttempts int
+	}{
+		{name: "should dead-letter a message that can never be produced", err: ErrUnknownTopic, failures: 1, expectedAttempts: 1},
+		{name: "should dead-letter a message out of attempts", err: ErrProducerClosed, failures: 5, maxAttempts: 3, expectedAttempts: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log := openSpool(t, t.TempDir())
+			defer log.Close()
+			next := NewInMemoryProducer()
+			producer := &flakyProducer{Producer: next, failures: tc.failures, err: tc.err}
+			deadLetters, err := NewFileDeadLetterQueue(filepath.Join(t.TempDir(), "deadletters.jsonl"))
+			require.NoError(t, err)
+			// the first addition fails, the message is kept until it succeeds
+			queue := &failingDeadLetterQueue{DeadLetterQueue: deadLetters, failures: 1}
+			statuses := NewInMemoryMessageStatusStore()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				RunSpoolForwarder(ctx, log, producer, ForwarderConfig{
+					Retry:       RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: tc.maxAttempts},
+					DeadLetters: queue,
+					Statuses:    statuses,
+				})
+				close(done)
+			}()
+			ids, err := NewWALSpool(log, WALSpoolConfig{}).Accept([]SpooledMessage{
+				{Topic: "orders", Key: []byte("k"), Value: []byte("1"), Tenant: "acme"},
+				{Topic: "orders", Key: []byte("k"), Value: []byte("2")},
+			})
+			require.NoError(t, err)
+			require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+			cancel()
+			<-done
+
+			letters, err := deadLetters.List("", 10)
+			require.NoError(t, err)
+			require.Len(t, letters, 1)
+			assert.Equal(t, ids[0], letters[0].ID)
+			assert.Equal(t, "orders", letters[0].Topic)
+			assert.Equal(t, []byte("k"), letters[0].Key)
+			assert.Equal(t, []byte("1"), letters[0].Value)
+			assert.Equal(t, "acme", letters[0].Tenant)
+			assert.Equal(t, tc.err.Error(), letters[0].Error)
+			assert.Equal(t, tc.expectedAttempts, letters[0].Attempts)
+			status, err := statuses.Get(ids[0])
+			require.NoError(t, err)
+			require.NotNil(t, status)
+			assert.Equal(t, MessageFailed, status.State)
+
+			// the messages behind the dead-lettered one are produced
+			assert.Equal(t, []string{"2"}, values(next.(*inMemoryProducer).messages("orders")))
+		})
+	}
+}
+
+func TestRunSpoolForwarder_DeadLetterRefused(t *testing.T) {
+	log := openSpool(t, t.TempDir())
+	defer log.Close()
+	next := NewInMemoryProducer()
+	producer := &flakyProducer{Producer: next, failures: 1, err: ErrUnknownTopic}
+	deadLetters, err := NewFileDeadLetterQueue(filepath.Join(t.TempDir(), "deadletters.jsonl"))
+	require.NoError(t, err)
+	// the dead-letter topic is missing, the message cannot be kept
+	queue := &failingDeadLetterQueue{DeadLetterQueue: deadLetters, failures: 100, err: ErrUnknownTopic}
+	statuses := NewInMemoryMessageStatusStore()
+	var dropped []string
+	var mu sync.Mutex
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSpoolForwarder(ctx, log, producer, ForwarderConfig{
+			Retry:       RetryPolicy{InitialBackoff: time.Millisecond},
+			DeadLetters: queue,
+			Statuses:    statuses,
+			OnError: func(message SpooledMessage, err error, drop bool) {
+				mu.Lock()
+				defer mu.Unlock()
+				if drop {
+					dropped = append(dropped, message.ID)
+				}
+			},
+		})
+		close(done)
+	}()
+	ids, err := NewWALSpool(log, WALSpoolConfig{}).Accept([]SpooledMessage{
+		{Topic: "orders", Key: []byte("k"), Value: []byte("1")},
+		{Topic: "orders", Key: []byte("k"), Value: []byte("2")},
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return log.Pending() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// the message is dropped after a single attempt to dead-letter it
+	assert.Equal(t, 99, queue.failures)
+	assert.Equal(t, []string{ids[0]}, dropped)
+	status, err := statuses.Get(ids[0])
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, MessageFailed, status.State)
+	assert.Contains(t, status.Reason, "dead-lettering message")
+	assert.Equal(t, []string{"2"}, values(next.(*inMemoryProducer).messages("orders")))
+}
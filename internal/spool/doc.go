@@ -0,0 +1,13 @@
+// Package spool provides a durable, segment-based write-ahead log.
+//
+// Entries are opaque byte slices numbered by a sequence that only grows.
+// They are appended to the active segment file and fsynced before Append
+// returns; concurrent appends share an fsync. A reader acknowledges entries
+// once handled, which checkpoints its position and deletes the segments it
+// no longer needs. Open recovers the log after a crash, truncating an entry
+// torn by a partial write.
+//
+// Each entry is framed as a 16 byte header, the data length (uint32), a
+// CRC-32C of the sequence and data (uint32) and the sequence (uint64), all
+// big-endian, followed by the data.
+package spool
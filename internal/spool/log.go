@@ -0,0 +1,505 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpointFile holds the last acknowledged sequence
+const checkpointFile = "checkpoint"
+
+var (
+	// ErrFull is returned by Append when the segments reached Config.MaxBytes
+	ErrFull = errors.New("spool is full")
+	// ErrClosed is returned once the log is closed
+	ErrClosed = errors.New("spool is closed")
+	// ErrCorrupt is returned by Open when a segment other than the last one
+	// has a torn entry, which a crash cannot cause
+	ErrCorrupt = errors.New("spool segment is corrupt")
+)
+
+// Config tunes a Log
+type Config struct {
+	// SegmentBytes rolls to a new segment once the active one reaches this size, 64 MiB when zero
+	SegmentBytes int64
+	// MaxBytes rejects appends with ErrFull once the segments reach this size, unlimited when zero
+	MaxBytes int64
+	// MaxAge drops the segments last written longer ago, acknowledged or
+	// not; segments are kept until acknowledged when zero
+	MaxAge time.Duration
+	// OnExpire is called with the number of unacknowledged entries MaxAge dropped
+	OnExpire func(entries uint64)
+}
+
+// Entry is an entry of the log
+type Entry struct {
+	Seq  uint64
+	Data []byte
+}
+
+// cursor is the position of the next entry read
+type cursor struct {
+	segment *segment
+	file    *os.File
+	seq     uint64
+	offset  int64
+}
+
+// Log is a segment-based write-ahead log
+type Log struct {
+	dir string
+	cfg Config
+	now func() time.Time
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// segments are sorted by base, the last one is the active segment
+	segments []*segment
+	active   *os.File
+	next     uint64
+	// synced is the last entry known to be on disk
+	synced  uint64
+	syncing bool
+	acked   uint64
+	size    int64
+	closed  bool
+	read    cursor
+	notify  chan struct{}
+}
+
+// Open opens the log in dir, creating dir when missing. Entries not
+// acknowledged before a crash or restart are read again; an entry torn by a
+// crash while being appended is truncated.
+// Params: dir string - the directory of the segments
+// Params: cfg Config - the segment size and retention
+func Open(dir string, cfg Config) (*Log, error) {
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 64 << 20
+	}
+	if cfg.OnExpire == nil {
+		cfg.OnExpire = func(uint64) {}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, cfg: cfg, now: time.Now, notify: make(chan struct{}, 1)}
+	l.cond = sync.NewCond(&l.mu)
+
+	var err error
+	if l.acked, err = readCheckpoint(dir); err != nil {
+		return nil, err
+	}
+	if l.segments, err = listSegments(dir); err != nil {
+		return nil, err
+	}
+	for i, seg := range l.segments {
+		err := seg.scan()
+		last := i == len(l.segments)-1
+		switch {
+		case errors.Is(err, errTorn) && last:
+			if err := os.Truncate(seg.path, seg.size); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, errTorn):
+			return nil, fmt.Errorf("%w: %s at offset %d", ErrCorrupt, seg.path, seg.size)
+		case err != nil:
+			return nil, err
+		}
+		if !last && seg.last+1 != l.segments[i+1].base {
+			return nil, fmt.Errorf("%w: entries missing after %s", ErrCorrupt, seg.path)
+		}
+		l.size += seg.size
+	}
+
+	// the sequence never goes back, even when every segment was deleted
+	l.next = l.acked + 1
+	if len(l.segments) > 0 {
+		l.next = max(l.next, l.segments[len(l.segments)-1].last+1)
+	}
+	if len(l.segments) == 0 || l.segments[len(l.segments)-1].last < l.acked {
+		if err := l.createSegment(); err != nil {
+			return nil, err
+		}
+	} else {
+		active := l.segments[len(l.segments)-1]
+		if l.active, err = os.OpenFile(active.path, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	l.synced = l.next - 1
+	if err := l.compact(); err != nil {
+		_ = l.active.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append writes entries to the log and returns the sequence of the first
+// one once they are on disk
+// Params: entries ...[]byte - the entries, written in order
+func (l *Log) Append(entries ...[]byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrClosed
+	}
+	if err := l.expire(); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		if len(entry) > maxEntrySize {
+			return 0, fmt.Errorf("spool entry of %d bytes is larger than %d", len(entry), maxEntrySize)
+		}
+		size += frameSize(entry)
+	}
+	if l.cfg.MaxBytes > 0 && l.size+size > l.cfg.MaxBytes {
+		return 0, ErrFull
+	}
+	for l.activeSegment().size > 0 && l.activeSegment().size+size > l.cfg.SegmentBytes {
+		if err := l.roll(); err != nil {
+			return 0, err
+		}
+		if l.closed {
+			return 0, ErrClosed
+		}
+		if err := l.compact(); err != nil {
+			return 0, err
+		}
+	}
+
+	first := l.next
+	buf := make([]byte, 0, size)
+	for i, entry := range entries {
+		buf = appendFrame(buf, first+uint64(i), entry)
+	}
+	active := l.activeSegment()
+	if _, err := l.active.Write(buf); err != nil {
+		// drop a partial write so the next append starts on a frame boundary
+		_ = l.active.Truncate(active.size)
+		return 0, err
+	}
+	l.next += uint64(len(entries))
+	active.last = l.next - 1
+	active.size += size
+	active.modTime = l.now()
+	l.size += size
+
+	if err := l.syncTo(l.next - 1); err != nil {
+		return 0, err
+	}
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+	return first, nil
+}
+
+// Read returns up to max entries on disk from sequence from, skipping
+// entries dropped by retention
+// Params: from uint64 - the sequence of the first entry
+// Params: max int - the number of entries returned at most
+func (l *Log) Read(from uint64, max int) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if first := l.segments[0].base; from < first {
+		from = first
+	}
+	var entries []Entry
+	for from <= l.synced && len(entries) < max {
+		if err := l.seek(from); err != nil {
+			return entries, err
+		}
+		seq, data, err := readFrame(l.read.file, l.read.offset)
+		if err != nil {
+			return entries, fmt.Errorf("reading %s at offset %d: %w", l.read.segment.path, l.read.offset, err)
+		}
+		entries = append(entries, Entry{Seq: seq, Data: data})
+		l.read.seq++
+		l.read.offset += frameSize(data)
+		from++
+	}
+	return entries, nil
+}
+
+// Ack records that every entry up to seq is handled; segments holding
+// handled entries only are deleted
+// Params: seq uint64 - the last handled sequence
+func (l *Log) Ack(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if seq <= l.acked {
+		return nil
+	}
+	if err := writeCheckpoint(l.dir, seq); err != nil {
+		return err
+	}
+	l.acked = seq
+	if err := l.compact(); err != nil {
+		return err
+	}
+	return l.expire()
+}
+
+// Acked returns the last acknowledged sequence
+func (l *Log) Acked() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acked
+}
+
+// Pending returns the number of entries on disk not yet acknowledged
+func (l *Log) Pending() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.synced < l.acked {
+		return 0
+	}
+	return l.synced - l.acked
+}
+
+// Size returns the size of the segments in bytes
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// Notify returns a channel receiving a value after appends, so a reader
+// can wait for new entries
+func (l *Log) Notify() <-chan struct{} {
+	return l.notify
+}
+
+// Close closes the log; appends in progress finish first
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	for l.syncing {
+		l.cond.Wait()
+	}
+	l.closed = true
+	l.closeCursor()
+	err := l.active.Sync()
+	if closeErr := l.active.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (l *Log) activeSegment() *segment {
+	return l.segments[len(l.segments)-1]
+}
+
+// syncTo returns once seq is on disk. The first caller fsyncs for every
+// entry written so far while the others wait, so concurrent appends share
+// an fsync. Callers must hold the lock.
+func (l *Log) syncTo(seq uint64) error {
+	for l.synced < seq {
+		if l.syncing {
+			l.cond.Wait()
+			continue
+		}
+		l.syncing = true
+		file, upTo := l.active, l.next-1
+		l.mu.Unlock()
+		err := file.Sync()
+		l.mu.Lock()
+		l.syncing = false
+		l.cond.Broadcast()
+		if err != nil {
+			return err
+		}
+		l.synced = max(l.synced, upTo)
+	}
+	return nil
+}
+
+// roll syncs and closes the active segment and starts a new one; callers
+// must hold the lock
+func (l *Log) roll() error {
+	// the active file may be being synced without the lock
+	for l.syncing {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return nil
+	}
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	l.synced = l.next - 1
+	if err := l.active.Close(); err != nil {
+		return err
+	}
+	return l.createSegment()
+}
+
+// createSegment starts an empty active segment at the next sequence;
+// callers must hold the lock
+func (l *Log) createSegment() error {
+	path := segmentPath(l.dir, l.next)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(l.dir); err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.active = f
+	l.segments = append(l.segments, &segment{base: l.next, last: l.next - 1, path: path, modTime: l.now()})
+	return nil
+}
+
+// compact deletes the segments whose entries are all acknowledged; an
+// acknowledged active segment is rolled first. Callers must hold the lock.
+func (l *Log) compact() error {
+	if active := l.activeSegment(); active.size > 0 && active.last <= l.acked {
+		if err := l.roll(); err != nil {
+			return err
+		}
+	}
+	for len(l.segments) > 1 && l.segments[0].last <= l.acked {
+		if err := l.remove(l.segments[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire deletes the segments, other than the active one, last written
+// longer than MaxAge ago; callers must hold the lock
+func (l *Log) expire() error {
+	if l.cfg.MaxAge <= 0 {
+		return nil
+	}
+	cutoff := l.now().Add(-l.cfg.MaxAge)
+	for len(l.segments) > 1 && l.segments[0].modTime.Before(cutoff) {
+		seg := l.segments[0]
+		if err := l.remove(seg); err != nil {
+			return err
+		}
+		if seg.last <= l.acked {
+			continue
+		}
+		lost := seg.last - max(l.acked, seg.base-1)
+		if err := writeCheckpoint(l.dir, seg.last); err != nil {
+			return err
+		}
+		l.acked = seg.last
+		l.cfg.OnExpire(lost)
+	}
+	return nil
+}
+
+// remove deletes the oldest segment; callers must hold the lock
+func (l *Log) remove(seg *segment) error {
+	if l.read.segment == seg {
+		l.closeCursor()
+	}
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	l.segments = l.segments[1:]
+	l.size -= seg.size
+	return nil
+}
+
+// seek moves the read cursor to seq; callers must hold the lock
+func (l *Log) seek(seq uint64) error {
+	if l.read.file != nil && l.read.seq == seq && seq <= l.read.segment.last {
+		return nil
+	}
+	var seg *segment
+	for _, s := range l.segments {
+		if seq >= s.base && seq <= s.last {
+			seg = s
+			break
+		}
+	}
+	if seg == nil {
+		return fmt.Errorf("spool entry %d not found", seq)
+	}
+	if l.read.segment != seg || l.read.seq > seq {
+		l.closeCursor()
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return err
+		}
+		l.read = cursor{segment: seg, file: f, seq: seg.base}
+	}
+	for l.read.seq < seq {
+		_, data, err := readFrame(l.read.file, l.read.offset)
+		if err != nil {
+			return fmt.Errorf("reading %s at offset %d: %w", seg.path, l.read.offset, err)
+		}
+		l.read.seq++
+		l.read.offset += frameSize(data)
+	}
+	return nil
+}
+
+func (l *Log) closeCursor() {
+	if l.read.file != nil {
+		_ = l.read.file.Close()
+	}
+	l.read = cursor{}
+}
+
+// readCheckpoint returns the last acknowledged sequence, zero when none is recorded
+func readCheckpoint(dir string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid spool checkpoint: %w", err)
+	}
+	return seq, nil
+}
+
+// writeCheckpoint replaces the checkpoint atomically
+func writeCheckpoint(dir string, seq uint64) error {
+	tmp, err := os.CreateTemp(dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, strconv.FormatUint(seq, 10)+"\n"); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, checkpointFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
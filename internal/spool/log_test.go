@@ -0,0 +1,222 @@
+package spool
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, l *Log, from uint64) []string {
+	t.Helper()
+	entries, err := l.Read(from, 1000)
+	require.NoError(t, err)
+	var data []string
+	for _, entry := range entries {
+		data = append(data, string(entry.Data))
+	}
+	return data
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	for i, match := range matches {
+		matches[i] = filepath.Base(match)
+	}
+	return matches
+}
+
+func TestLog_AppendReadAck(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Config{SegmentBytes: 64})
+	require.NoError(t, err)
+	defer l.Close()
+
+	first, err := l.Append([]byte("a"), []byte("b"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+	first, err = l.Append([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), first)
+	select {
+	case <-l.Notify():
+	default:
+		t.Error("appends should notify readers")
+	}
+
+	entries, err := l.Read(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{Seq: 1, Data: []byte("a")}, {Seq: 2, Data: []byte("b")}}, entries)
+	assert.Equal(t, []string{"c"}, readAll(t, l, 3))
+	assert.Equal(t, []string{"b", "c"}, readAll(t, l, 2))
+	assert.Empty(t, readAll(t, l, 4))
+	assert.Equal(t, uint64(3), l.Pending())
+
+	// 17 byte frames roll a 64 byte segment every three entries
+	_, err = l.Append([]byte("d"), []byte("e"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"00000000000000000001.wal", "00000000000000000004.wal"}, segmentFiles(t, dir))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, readAll(t, l, 1))
+
+	// acknowledged segments are deleted, the active one is kept
+	require.NoError(t, l.Ack(2))
+	assert.Len(t, segmentFiles(t, dir), 2)
+	require.NoError(t, l.Ack(3))
+	assert.Equal(t, []string{"00000000000000000004.wal"}, segmentFiles(t, dir))
+	assert.Equal(t, uint64(2), l.Pending())
+	assert.Equal(t, int64(34), l.Size())
+	assert.Equal(t, []string{"d", "e"}, readAll(t, l, 1))
+}
+
+func TestLog_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Config{SegmentBytes: 64})
+	require.NoError(t, err)
+	for _, data := range []string{"a", "b", "c", "d", "e"} {
+		_, err := l.Append([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Ack(2))
+	require.NoError(t, l.Close())
+
+	// a crash tears the entry being appended
+	active := filepath.Join(dir, "00000000000000000004.wal")
+	f, err := os.OpenFile(active, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write(appendFrame(nil, 6, []byte("torn"))[:10])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = Open(dir, Config{SegmentBytes: 64})
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, uint64(2), l.Acked())
+	assert.Equal(t, uint64(3), l.Pending())
+	assert.Equal(t, []string{"c", "d", "e"}, readAll(t, l, l.Acked()+1))
+	first, err := l.Append([]byte("f"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), first)
+	assert.Equal(t, []string{"c", "d", "e", "f"}, readAll(t, l, 3))
+}
+
+func TestLog_SequenceSurvivesCompaction(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Config{SegmentBytes: 17})
+	require.NoError(t, err)
+	_, err = l.Append([]byte("a"))
+	require.NoError(t, err)
+	_, err = l.Append([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, l.Ack(2))
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Config{SegmentBytes: 17})
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, uint64(0), l.Pending())
+	first, err := l.Append([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), first)
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
+
+func TestLog_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Config{SegmentBytes: 17})
+	require.NoError(t, err)
+	_, err = l.Append([]byte("a"))
+	require.NoError(t, err)
+	_, err = l.Append([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	first := filepath.Join(dir, "00000000000000000001.wal")
+	data, err := os.ReadFile(first)
+	require.NoError(t, err)
+	data[len(data)-1] = 'x'
+	require.NoError(t, os.WriteFile(first, data, 0o600))
+
+	_, err = Open(dir, Config{SegmentBytes: 17})
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestLog_MaxBytes(t *testing.T) {
+	l, err := Open(t.TempDir(), Config{SegmentBytes: 34, MaxBytes: 51})
+	require.NoError(t, err)
+	defer l.Close()
+	_, err = l.Append([]byte("a"), []byte("b"), []byte("c"))
+	require.NoError(t, err)
+	_, err = l.Append([]byte("d"))
+	assert.ErrorIs(t, err, ErrFull)
+
+	// acknowledged segments free their space
+	require.NoError(t, l.Ack(3))
+	_, err = l.Append([]byte("d"))
+	assert.NoError(t, err)
+}
+
+func TestLog_MaxAge(t *testing.T) {
+	var expired uint64
+	l, err := Open(t.TempDir(), Config{SegmentBytes: 17, MaxAge: time.Hour, OnExpire: func(n uint64) { expired += n }})
+	require.NoError(t, err)
+	defer l.Close()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for _, data := range []string{"a", "b", "c"} {
+		_, err := l.Append([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Ack(1))
+
+	// the old closed segments are dropped, the unacknowledged ones reported
+	now = now.Add(2 * time.Hour)
+	_, err = l.Append([]byte("d"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), expired)
+	assert.Equal(t, uint64(2), l.Acked())
+	assert.Equal(t, []string{"c", "d"}, readAll(t, l, 1))
+}
+
+func TestLog_ConcurrentAppends(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Config{SegmentBytes: 1024})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := l.Append([]byte(fmt.Sprintf("entry-%02d", i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Config{SegmentBytes: 1024})
+	require.NoError(t, err)
+	defer l.Close()
+	entries, err := l.Read(1, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 50)
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		assert.Equal(t, uint64(i+1), entry.Seq)
+		seen[string(entry.Data)] = true
+	}
+	assert.Len(t, seen, 50)
+
+	_, err = l.Read(1, 1)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	_, err = l.Append([]byte("late"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
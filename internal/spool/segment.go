@@ -0,0 +1,154 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// frameHeaderSize is the length, CRC and sequence preceding the data
+	frameHeaderSize = 16
+	// maxEntrySize bounds the data of one entry, so a corrupt length is not
+	// mistaken for a huge entry
+	maxEntrySize = 64 << 20
+	// segmentSuffix ends the name of segment files, named after their first sequence
+	segmentSuffix = ".wal"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTorn is returned when a frame is incomplete or fails its checksum
+var errTorn = errors.New("torn entry")
+
+// segment is a file holding the entries from base to last
+type segment struct {
+	base uint64
+	// last is base-1 while the segment is empty
+	last    uint64
+	size    int64
+	path    string
+	modTime time.Time
+}
+
+// appendFrame appends the frame of an entry to buf
+func appendFrame(buf []byte, seq uint64, data []byte) []byte {
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(header[8:16], seq)
+	crc := crc32.Update(0, crcTable, header[8:16])
+	binary.BigEndian.PutUint32(header[4:8], crc32.Update(crc, crcTable, data))
+	buf = append(buf, header[:]...)
+	return append(buf, data...)
+}
+
+// frameSize is the size of the frame of an entry
+func frameSize(data []byte) int64 {
+	return frameHeaderSize + int64(len(data))
+}
+
+// readFrame reads the frame at offset, returning errTorn when it is
+// incomplete or corrupt and io.EOF at the end of the file
+func readFrame(f *os.File, offset int64) (uint64, []byte, error) {
+	var header [frameHeaderSize]byte
+	n, err := f.ReadAt(header[:], offset)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return 0, nil, io.EOF
+	}
+	if n < frameHeaderSize {
+		if err == nil || errors.Is(err, io.EOF) {
+			return 0, nil, errTorn
+		}
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxEntrySize {
+		return 0, nil, errTorn
+	}
+	data := make([]byte, length)
+	if n, err := f.ReadAt(data, offset+frameHeaderSize); n < len(data) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return 0, nil, errTorn
+		}
+		return 0, nil, err
+	}
+	crc := crc32.Update(0, crcTable, header[8:16])
+	if crc32.Update(crc, crcTable, data) != binary.BigEndian.Uint32(header[4:8]) {
+		return 0, nil, errTorn
+	}
+	return binary.BigEndian.Uint64(header[8:16]), data, nil
+}
+
+// scan checks every frame of the segment and sets its last sequence and
+// size. A torn frame ends the scan, leaving size at the last valid frame.
+func (s *segment) scan() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.last, s.size = s.base-1, 0
+	for {
+		seq, data, err := readFrame(f, s.size)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if seq != s.last+1 {
+			return errTorn
+		}
+		s.last = seq
+		s.size += frameSize(data)
+	}
+}
+
+// segmentPath names the segment starting at base
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+// listSegments returns the segments of dir sorted by base
+func listSegments(dir string) ([]*segment, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []*segment
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), segmentSuffix)
+		if !ok || file.IsDir() {
+			continue
+		}
+		base, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || base == 0 {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, &segment{base: base, path: filepath.Join(dir, file.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].base < segments[j].base })
+	return segments, nil
+}
+
+// syncDir makes the creation, rename or removal of files in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}